@@ -0,0 +1,290 @@
+package smp
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myfantasy/mft"
+)
+
+// UndoCommand - command of CommandLog records undo of other record (param id - id of record)
+const UndoCommand Command = "undo"
+
+// FieldChange - change of field of strategy state (Field - path by json names: `swings.0.level_price_up`)
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// CommandRecord - command sent to strategy with its result and changes of strategy state
+type CommandRecord struct {
+	Id       int64             `json:"id"`
+	Time     time.Time         `json:"time"`
+	Actor    string            `json:"actor"`
+	Type     string            `json:"type"`
+	Strategy string            `json:"strategy"`
+	Command  Command           `json:"command"`
+	Params   map[string]string `json:"params,omitempty"`
+	Ok       bool              `json:"ok"`
+	Message  string            `json:"message,omitempty"`
+	Error    *mft.Error        `json:"error,omitempty"`
+	Changes  []FieldChange     `json:"changes,omitempty"`
+
+	// UndoneBy - id of record undoing this one, Undo - id of record undone by this one
+	UndoneBy int64 `json:"undone_by,omitempty"`
+	Undo     int64 `json:"undo,omitempty"`
+
+	// StateBefore, StateAfter - state of strategy (Json) before and after command
+	StateBefore json.RawMessage `json:"state_before,omitempty"`
+	StateAfter  json.RawMessage `json:"state_after,omitempty"`
+}
+
+// CommandLog - audit log of commands sent to strategies
+type CommandLog struct {
+	Records []CommandRecord `json:"records"`
+	LastId  int64           `json:"last_id"`
+	// Limit - max count of records, the oldest records are removed (0 - without limit)
+	Limit int `json:"limit,omitempty"`
+
+	mx sync.Mutex
+}
+
+// Do - sends command to strategy and records it
+func (l *CommandLog) Do(tm time.Time, actor string, s Strategy, cmd Command, params map[string]string) (res CommandResult, ok bool, err *mft.Error) {
+	before := s.Json()
+	res, ok, err = s.Command(cmd, params)
+	after := s.Json()
+
+	r := CommandRecord{
+		Time:     tm,
+		Actor:    actor,
+		Type:     s.Type(),
+		Strategy: s.String(),
+		Command:  cmd,
+		Params:   params,
+		Ok:       ok,
+		Message:  res.Message,
+		Error:    err,
+	}
+	l.setStates(&r, before, after)
+	l.add(r)
+
+	return res, ok, err
+}
+
+// Execute - sends commands of text to strategy (ExecuteCommands) and records them
+func (l *CommandLog) Execute(tm time.Time, actor string, s Strategy, text string) (res CommandResult, err *mft.Error) {
+	return executeCommands(s, text, func(cmd Command, params map[string]string) (CommandResult, bool, *mft.Error) {
+		return l.Do(tm, actor, s, cmd, params)
+	})
+}
+
+// Undo - restores state of strategy before command of record id
+// (undo is safe only when state of strategy is not changed after the command: no orders, steps or other commands)
+func (l *CommandLog) Undo(tm time.Time, actor string, s Strategy, id int64) (res CommandResult, err *mft.Error) {
+	l.mx.Lock()
+	i := l.index(id)
+	var r CommandRecord
+	if i >= 0 {
+		r = l.Records[i]
+	}
+	l.mx.Unlock()
+
+	if i < 0 {
+		return res, GenerateError(500003800, id)
+	}
+	if r.UndoneBy != 0 {
+		return res, GenerateError(500003801, id, r.UndoneBy)
+	}
+	if len(r.Changes) == 0 {
+		return res, GenerateError(500003802, id)
+	}
+	if r.Type != s.Type() {
+		return res, GenerateError(500003803, id, r.Type, s.Type())
+	}
+
+	current := s.Json()
+	changed, err := StateChanges(string(r.StateAfter), current)
+	if err != nil {
+		return res, err
+	}
+	if len(changed) > 0 {
+		fields := make([]string, 0, len(changed))
+		for _, c := range changed {
+			fields = append(fields, c.Field)
+		}
+		return res, GenerateError(500003804, id, strings.Join(fields, ", "))
+	}
+
+	er0 := restoreState(s, r.StateBefore)
+	if er0 != nil {
+		// state after command is restored back
+		restoreState(s, r.StateAfter)
+		return res, GenerateErrorE(500003805, er0, id)
+	}
+
+	undo := CommandRecord{
+		Time:     tm,
+		Actor:    actor,
+		Type:     s.Type(),
+		Strategy: s.String(),
+		Command:  UndoCommand,
+		Params:   map[string]string{"id": strconv.FormatInt(id, 10)},
+		Ok:       true,
+		Undo:     id,
+	}
+	l.setStates(&undo, current, s.Json())
+	undoId := l.add(undo)
+
+	l.mx.Lock()
+	if i = l.index(id); i >= 0 {
+		l.Records[i].UndoneBy = undoId
+	}
+	l.mx.Unlock()
+
+	res.Message = "undo " + strconv.FormatInt(id, 10) + ": " + FormatCommand(r.Command, r.Params)
+	return res, nil
+}
+
+// Record - record by id
+func (l *CommandLog) Record(id int64) (r CommandRecord, ok bool) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	i := l.index(id)
+	if i < 0 {
+		return r, false
+	}
+	return l.Records[i], true
+}
+
+// List - records of strategy type tp in order of ids ("" - records of all strategies)
+func (l *CommandLog) List(tp string) (records []CommandRecord) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	for _, r := range l.Records {
+		if tp == "" || r.Type == tp {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// setStates - sets states and changes of record (error of diff is appended to error of record)
+func (l *CommandLog) setStates(r *CommandRecord, before string, after string) {
+	changes, err := StateChanges(before, after)
+	if err != nil {
+		r.Error = r.Error.AppendList(err)
+	}
+	r.Changes = changes
+	r.StateBefore = json.RawMessage(before)
+	r.StateAfter = json.RawMessage(after)
+}
+
+// add - adds record with new id
+func (l *CommandLog) add(r CommandRecord) (id int64) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.LastId++
+	r.Id = l.LastId
+	l.Records = append(l.Records, r)
+	if l.Limit > 0 && len(l.Records) > l.Limit {
+		l.Records = append(l.Records[:0:0], l.Records[len(l.Records)-l.Limit:]...)
+	}
+	return r.Id
+}
+
+// index - index of record by id (-1 - not found)
+func (l *CommandLog) index(id int64) int {
+	i := sort.Search(len(l.Records), func(i int) bool { return l.Records[i].Id >= id })
+	if i < len(l.Records) && l.Records[i].Id == id {
+		return i
+	}
+	return -1
+}
+
+// StateChanges - changed fields of strategy state (Json) sorted by path
+func StateChanges(before string, after string) (changes []FieldChange, err *mft.Error) {
+	fb := make(map[string]json.RawMessage)
+	fa := make(map[string]json.RawMessage)
+	err = flattenState(before, fb)
+	if err != nil {
+		return nil, err
+	}
+	err = flattenState(after, fa)
+	if err != nil {
+		return nil, err
+	}
+
+	for field, b := range fb {
+		if a, ok := fa[field]; !ok || string(a) != string(b) {
+			changes = append(changes, FieldChange{Field: field, Before: b, After: a})
+		}
+	}
+	for field, a := range fa {
+		if _, ok := fb[field]; !ok {
+			changes = append(changes, FieldChange{Field: field, After: a})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+
+	return changes, nil
+}
+
+// flattenState - values of state by paths of json names (objects and arrays are expanded)
+func flattenState(state string, fields map[string]json.RawMessage) (err *mft.Error) {
+	var v interface{}
+	er0 := json.Unmarshal([]byte(state), &v)
+	if er0 != nil {
+		return GenerateErrorE(500003806, er0)
+	}
+	flattenValue("", v, fields)
+	return nil
+}
+
+func flattenValue(path string, v interface{}, fields map[string]json.RawMessage) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	switch x := v.(type) {
+	case map[string]interface{}:
+		if len(x) > 0 {
+			for k, item := range x {
+				flattenValue(join(k), item, fields)
+			}
+			return
+		}
+	case []interface{}:
+		if len(x) > 0 {
+			for i, item := range x {
+				flattenValue(join(strconv.Itoa(i)), item, fields)
+			}
+			return
+		}
+	}
+	fields[path], _ = json.Marshal(v)
+}
+
+// restoreState - json fields of strategy are set from state (fields without json and unexported fields are kept)
+func restoreState(s Strategy, state json.RawMessage) error {
+	v := reflect.ValueOf(s)
+	if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+		fields := make(map[string]reflect.Value)
+		jsonFields(v.Elem(), fields)
+		for _, f := range fields {
+			if f.CanSet() {
+				f.Set(reflect.Zero(f.Type()))
+			}
+		}
+	}
+	return json.Unmarshal(state, s)
+}
@@ -0,0 +1,166 @@
+package smp
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/myfantasy/mft"
+)
+
+// auditStrategy - strategy with commands of testTarget
+type auditStrategy struct {
+	testTarget
+	OrderId string   `json:"order_id,omitempty"`
+	Levels  []string `json:"levels,omitempty"`
+
+	steps int
+}
+
+func (s *auditStrategy) Step(p StepParams) (meta MetaForStep, err *mft.Error) {
+	s.steps++
+	return meta, nil
+}
+func (s *auditStrategy) Status() StartegyStatus { return StartegyStatus{} }
+func (s *auditStrategy) String() string         { return "audit" }
+func (s *auditStrategy) Type() string           { return "audit" }
+func (s *auditStrategy) Json() string {
+	b, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+func (s *auditStrategy) Command(cmd Command, params map[string]string) (res CommandResult, ok bool, err *mft.Error) {
+	return s.commands().Do(s.Type(), cmd, params)
+}
+func (s *auditStrategy) AllowCommands(locale Locale) map[Command]CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
+func (s *auditStrategy) Description(locale Locale) string { return "audit" }
+
+var auditTime = time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC)
+
+func TestCommandLogDo(t *testing.T) {
+	l := &CommandLog{}
+	s := &auditStrategy{Levels: []string{"a"}}
+
+	_, ok, err := l.Do(auditTime, "alice", s, "set_vol", map[string]string{"": "10"})
+	if !ok || err != nil {
+		t.Fatal(err)
+	}
+	_, ok, err = l.Do(auditTime.Add(time.Minute), "bob", s, "set_vol", map[string]string{"": "x"})
+	if ok || err == nil {
+		t.Fatalf("set_vol x should fail")
+	}
+
+	records := l.List("audit")
+	if len(records) != 2 || len(l.List("other")) != 0 {
+		t.Fatalf("2 records expected, got %v", len(records))
+	}
+	r := records[0]
+	if r.Id != 1 || r.Actor != "alice" || !r.Time.Equal(auditTime) || !r.Ok || r.Command != "set_vol" || r.Params[""] != "10" {
+		t.Fatalf("wrong record: %+v", r)
+	}
+	if len(r.Changes) != 1 || r.Changes[0].Field != "Volume" ||
+		string(r.Changes[0].Before) != "0" || string(r.Changes[0].After) != "10" {
+		t.Fatalf("wrong changes: %+v", r.Changes)
+	}
+	if r := records[1]; r.Ok || r.Error == nil || r.Error.Code != 500003602 || len(r.Changes) != 0 {
+		t.Fatalf("wrong record of failed command: %+v", r)
+	}
+
+	// nested fields
+	changes, err := StateChanges(`{"a":{"b":[1,2]},"c":1}`, `{"a":{"b":[1,3,4]},"d":[]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := ""
+	for _, c := range changes {
+		fields += c.Field + ";"
+	}
+	if fields != "a.b.1;a.b.2;c;d;" {
+		t.Fatalf("wrong changed fields: %v", fields)
+	}
+}
+
+func TestCommandLogUndo(t *testing.T) {
+	l := &CommandLog{}
+	s := &auditStrategy{Levels: []string{"a", "b"}}
+	s.Side = "buy"
+
+	_, _, err := l.Do(auditTime, "alice", s, "set_order", map[string]string{"p": "1.5", "s": "sell"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.steps = 5
+
+	res, err := l.Undo(auditTime, "bob", s, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Price != 0 || s.Side != "buy" || s.Done != 0 || len(s.Levels) != 2 || s.steps != 5 {
+		t.Fatalf("state is not restored: %v %v", s.Json(), s.steps)
+	}
+	if res.Message != "undo 1: set_order p=1.5 s=sell" {
+		t.Fatalf("wrong message: %v", res.Message)
+	}
+	r, _ := l.Record(1)
+	u, _ := l.Record(2)
+	if r.UndoneBy != 2 || u.Undo != 1 || u.Command != UndoCommand || u.Actor != "bob" || len(u.Changes) != 3 {
+		t.Fatalf("wrong records: %+v %+v", r, u)
+	}
+
+	if _, err := l.Undo(auditTime, "bob", s, 1); err == nil || err.Code != 500003801 {
+		t.Fatalf("undone error expected, got %v", err)
+	}
+	if _, err := l.Undo(auditTime, "bob", s, 10); err == nil || err.Code != 500003800 {
+		t.Fatalf("not found error expected, got %v", err)
+	}
+
+	// order is placed after command
+	l.Do(auditTime, "alice", s, "set_vol", map[string]string{"": "20"})
+	s.OrderId = "o1"
+	if _, err := l.Undo(auditTime, "bob", s, 3); err == nil || err.Code != 500003804 {
+		t.Fatalf("not safe error expected, got %v", err)
+	}
+	if s.Volume != 20 {
+		t.Fatalf("state is changed by failed undo: %v", s.Json())
+	}
+
+	// the next command is undone first
+	s.OrderId = ""
+	l.Do(auditTime, "alice", s, "set_vol", map[string]string{"": "30"})
+	if _, err := l.Undo(auditTime, "bob", s, 3); err == nil || err.Code != 500003804 {
+		t.Fatalf("not safe error expected, got %v", err)
+	}
+	if _, err := l.Undo(auditTime, "bob", s, 4); err != nil || s.Volume != 20 {
+		t.Fatalf("undo: %v %v", s.Volume, err)
+	}
+	if _, err := l.Undo(auditTime, "bob", s, 3); err != nil || s.Volume != 0 {
+		t.Fatalf("undo: %v %v", s.Volume, err)
+	}
+}
+
+func TestCommandLogExecute(t *testing.T) {
+	l := &CommandLog{Limit: 2}
+	s := &auditStrategy{}
+
+	// nothing is sent and recorded when params are wrong
+	_, err := l.Execute(auditTime, "alice", s, "set_vol 1; set_order p=1 s=hold")
+	if err == nil || err.Code != 500003706 || s.Volume != 0 || len(l.List("")) != 0 {
+		t.Fatalf("wrong command error expected, got %v", err)
+	}
+
+	_, err = l.Execute(auditTime, "alice", s, "set_vol 1; set_vol 2; set_vol 4")
+	if err != nil || s.Volume != 4 {
+		t.Fatalf("commands are not done: %v %v", s.Volume, err)
+	}
+	records := l.List("")
+	if len(records) != 2 || records[0].Id != 2 || records[1].Id != 3 || l.LastId != 3 {
+		t.Fatalf("2 last records expected, got %+v", records)
+	}
+	if _, ok := l.Record(1); ok {
+		t.Fatalf("record 1 should be removed by limit")
+	}
+}
@@ -219,3 +219,54 @@ func (c *Candle) OrderBook() *OrderBook {
 	}
 	return ob
 }
+
+// ATR - average true range of last period candles (cs should be sorted)
+func (cs Candles) ATR(period int) float64 {
+	if cs.Len() == 0 || period <= 0 {
+		return 0
+	}
+
+	from := cs.Len() - period
+	if from < 0 {
+		from = 0
+	}
+
+	sum := 0.0
+	for i := from; i < cs.Len(); i++ {
+		tr := cs[i].High - cs[i].Low
+		if i > 0 {
+			tr = math.Max(tr, math.Abs(cs[i].High-cs[i-1].Close))
+			tr = math.Max(tr, math.Abs(cs[i].Low-cs[i-1].Close))
+		}
+		sum += tr
+	}
+
+	return sum / float64(cs.Len()-from)
+}
+
+// SMA - simple moving average of close prices of last period candles
+func (cs Candles) SMA(period int) float64 {
+	if cs.Len() < period || period <= 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, c := range cs[cs.Len()-period:] {
+		sum += c.Close
+	}
+	return sum / float64(period)
+}
+
+// EMA - exponential moving average of close prices (starts from SMA of first period candles)
+func (cs Candles) EMA(period int) float64 {
+	if cs.Len() < period || period <= 0 {
+		return 0
+	}
+
+	ema := cs[:period].SMA(period)
+	k := 2 / float64(period+1)
+	for _, c := range cs[period:] {
+		ema = c.Close*k + ema*(1-k)
+	}
+	return ema
+}
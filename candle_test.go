@@ -34,3 +34,37 @@ func TestCandlesAggregate(t *testing.T) {
 		t.Fatalf("Aggregate by 5 minute shoult contains 20000 candles (current %v)", len(cs2))
 	}
 }
+
+func TestCandlesATR(t *testing.T) {
+	cs := Candles{
+		{Open: 10, High: 11, Low: 9, Close: 10},
+		{Open: 10, High: 14, Low: 12, Close: 13},
+		{Open: 13, High: 13.5, Low: 12.5, Close: 13},
+	}
+
+	// true ranges: 2, 4 (14-10), 1
+	if atr := cs.ATR(3); math.Abs(atr-7.0/3) > 1e-9 {
+		t.Fatalf("ATR(3) should be %v (current %v)", 7.0/3, atr)
+	}
+	if atr := cs.ATR(2); atr != 2.5 {
+		t.Fatalf("ATR(2) should be 2.5 (current %v)", atr)
+	}
+	if atr := cs.ATR(10); math.Abs(atr-7.0/3) > 1e-9 {
+		t.Fatalf("ATR(10) should use all candles (current %v)", atr)
+	}
+}
+
+func TestCandlesMA(t *testing.T) {
+	cs := Candles{{Close: 1}, {Close: 2}, {Close: 3}, {Close: 4}}
+
+	if sma := cs.SMA(2); sma != 3.5 {
+		t.Fatalf("SMA(2) should be 3.5 (current %v)", sma)
+	}
+	if sma := cs.SMA(5); sma != 0 {
+		t.Fatalf("SMA(5) should be 0 for 4 candles (current %v)", sma)
+	}
+	// seed 1.5, then 3*2/3 + 1.5/3 = 2.5, then 4*2/3 + 2.5/3 = 3.5
+	if ema := cs.EMA(2); math.Abs(ema-3.5) > 1e-9 {
+		t.Fatalf("EMA(2) should be 3.5 (current %v)", ema)
+	}
+}
@@ -0,0 +1,384 @@
+package smp
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/myfantasy/mft"
+)
+
+type ParamType string
+
+const (
+	ParamFloat  ParamType = "float64"
+	ParamInt    ParamType = "int"
+	ParamBool   ParamType = "bool"
+	ParamString ParamType = "string"
+	// ParamTime - time in RFC3339
+	ParamTime ParamType = "time"
+	// ParamDate - date yyyy-mm-dd (UTC)
+	ParamDate ParamType = "date"
+	// ParamClock - time of day hh:mm
+	ParamClock ParamType = "clock"
+)
+
+// Param - param of command (Name "" - positional param: `set_vol 10`, other - named: `render f=-5`)
+type Param struct {
+	Name        string    `json:"name"`
+	Type        ParamType `json:"type"`
+	Description string    `json:"description"`
+	Optional    bool      `json:"optional,omitempty"`
+	// Min, Max - bounds of numbers
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+	// Values - allowed values of strings
+	Values []string `json:"values,omitempty"`
+
+	// Target - pointer to field set by param (to kind of Type or time.Time)
+	Target interface{} `json:"-"`
+}
+
+func FloatParam(name string, description string, target interface{}) Param {
+	return Param{Name: name, Type: ParamFloat, Description: description, Target: target}
+}
+func IntParam(name string, description string, target interface{}) Param {
+	return Param{Name: name, Type: ParamInt, Description: description, Target: target}
+}
+func BoolParam(name string, description string, target interface{}) Param {
+	return Param{Name: name, Type: ParamBool, Description: description, Target: target}
+}
+func StringParam(name string, description string, target interface{}) Param {
+	return Param{Name: name, Type: ParamString, Description: description, Target: target}
+}
+func TimeParam(name string, description string, target interface{}) Param {
+	return Param{Name: name, Type: ParamTime, Description: description, Target: target}
+}
+func DateParam(name string, description string, target interface{}) Param {
+	return Param{Name: name, Type: ParamDate, Description: description, Target: target}
+}
+func ClockParam(name string, description string, target interface{}) Param {
+	return Param{Name: name, Type: ParamClock, Description: description, Target: target}
+}
+
+// WithMin - number is not less than min
+func (p Param) WithMin(min float64) Param {
+	p.Min = &min
+	return p
+}
+
+// WithMax - number is not greater than max
+func (p Param) WithMax(max float64) Param {
+	p.Max = &max
+	return p
+}
+
+// WithValues - string is one of values
+func (p Param) WithValues(values ...string) Param {
+	p.Values = values
+	return p
+}
+
+// AsOptional - param may be not set
+func (p Param) AsOptional() Param {
+	p.Optional = true
+	return p
+}
+
+// displayName - name of param in errors
+func (p Param) displayName() string {
+	if p.Name == "" {
+		return PositionalParam
+	}
+	return p.Name
+}
+
+// Usage - description of param for ParamsDescription
+func (p Param) Usage() string {
+	descr := p.Description
+	switch {
+	case len(p.Values) > 0:
+		descr += ": " + strings.Join(p.Values, " | ")
+	case p.Min != nil && p.Max != nil:
+		descr += fmt.Sprintf(" (%v .. %v)", *p.Min, *p.Max)
+	case p.Min != nil:
+		descr += fmt.Sprintf(" (>= %v)", *p.Min)
+	case p.Max != nil:
+		descr += fmt.Sprintf(" (<= %v)", *p.Max)
+	}
+	if p.Name != "" {
+		descr = p.Name + "=[" + descr + "]"
+	}
+	if p.Optional {
+		descr = "[" + descr + "]"
+	}
+	return descr
+}
+
+// parse - value of param from string
+func (p Param) parse(strategy string, cmd Command, s string) (v interface{}, err *mft.Error) {
+	var x float64
+	switch p.Type {
+	case ParamFloat:
+		f, er0 := strconv.ParseFloat(s, 64)
+		if er0 != nil {
+			return nil, GenerateErrorE(500003602, er0, strategy, cmd, p.displayName(), s, p.Type)
+		}
+		v, x = f, f
+	case ParamInt:
+		i, er0 := strconv.ParseInt(s, 10, 64)
+		if er0 != nil {
+			return nil, GenerateErrorE(500003602, er0, strategy, cmd, p.displayName(), s, p.Type)
+		}
+		v, x = int(i), float64(i)
+	case ParamBool:
+		b, er0 := strconv.ParseBool(s)
+		if er0 != nil {
+			return nil, GenerateErrorE(500003602, er0, strategy, cmd, p.displayName(), s, p.Type)
+		}
+		return b, nil
+	case ParamTime:
+		tm, er0 := time.Parse(time.RFC3339, s)
+		if er0 != nil {
+			return nil, GenerateErrorE(500003602, er0, strategy, cmd, p.displayName(), s, "time (RFC3339)")
+		}
+		return tm, nil
+	case ParamDate:
+		tm, er0 := time.Parse("2006-01-02", s)
+		if er0 != nil {
+			return nil, GenerateErrorE(500003602, er0, strategy, cmd, p.displayName(), s, "date (yyyy-mm-dd)")
+		}
+		return tm, nil
+	case ParamClock:
+		tm, er0 := time.Parse("15:04", s)
+		if er0 != nil {
+			return nil, GenerateErrorE(500003602, er0, strategy, cmd, p.displayName(), s, "time of day (hh:mm)")
+		}
+		return tm, nil
+	default:
+		if len(p.Values) > 0 {
+			found := false
+			for _, value := range p.Values {
+				found = found || value == s
+			}
+			if !found {
+				return nil, GenerateError(500003605, strategy, cmd, p.displayName(), s, strings.Join(p.Values, ", "))
+			}
+		}
+		return s, nil
+	}
+
+	if p.Min != nil && x < *p.Min {
+		return nil, GenerateError(500003603, strategy, cmd, p.displayName(), s, *p.Min)
+	}
+	if p.Max != nil && x > *p.Max {
+		return nil, GenerateError(500003604, strategy, cmd, p.displayName(), s, *p.Max)
+	}
+	return v, nil
+}
+
+// set - sets Target by value of param
+func (p Param) set(v interface{}) {
+	if p.Target == nil {
+		return
+	}
+	f := reflect.ValueOf(p.Target).Elem()
+	switch x := v.(type) {
+	case float64:
+		f.SetFloat(x)
+	case int:
+		f.SetInt(int64(x))
+	case bool:
+		f.SetBool(x)
+	case string:
+		f.SetString(x)
+	case time.Time:
+		f.Set(reflect.ValueOf(x))
+	}
+}
+
+// get - copy of value of target (invalid when param has no target)
+func (p Param) get() reflect.Value {
+	if p.Target == nil {
+		return reflect.Value{}
+	}
+	f := reflect.ValueOf(p.Target).Elem()
+	v := reflect.New(f.Type()).Elem()
+	v.Set(f)
+	return v
+}
+
+// restore - sets value of target got by get
+func (p Param) restore(v reflect.Value) {
+	if p.Target == nil || !v.IsValid() {
+		return
+	}
+	reflect.ValueOf(p.Target).Elem().Set(v)
+}
+
+// ParamValues - parsed params of command by names
+type ParamValues map[string]interface{}
+
+func (v ParamValues) Has(name string) bool {
+	_, ok := v[name]
+	return ok
+}
+func (v ParamValues) Float(name string) float64 {
+	f, _ := v[name].(float64)
+	return f
+}
+func (v ParamValues) Int(name string) int {
+	i, _ := v[name].(int)
+	return i
+}
+func (v ParamValues) Bool(name string) bool {
+	b, _ := v[name].(bool)
+	return b
+}
+func (v ParamValues) String(name string) string {
+	s, _ := v[name].(string)
+	return s
+}
+func (v ParamValues) Time(name string) time.Time {
+	tm, _ := v[name].(time.Time)
+	return tm
+}
+
+// CommandDo - action of command with parsed params
+type CommandDo func(v ParamValues) (res CommandResult, err *mft.Error)
+
+// CommandDef - command of strategy with its params
+type CommandDef struct {
+	Command     Command
+	Description string
+	Example     string
+	Params      []Param
+	// Do - is called after params are parsed and Target of params are set (nil - only targets are set)
+	Do CommandDo
+}
+
+// Parse - parses and validates all params of command (unknown params are an error)
+func (def CommandDef) Parse(strategy string, params map[string]string) (v ParamValues, err *mft.Error) {
+	v, _, err = def.parse(strategy, params)
+	return v, err
+}
+
+// parse - Parse with name of the param that failed (for position of error in text)
+func (def CommandDef) parse(strategy string, params map[string]string) (v ParamValues, failed string, err *mft.Error) {
+	v = make(ParamValues, len(params))
+	for _, p := range def.Params {
+		s, ok := params[p.Name]
+		if !ok {
+			if !p.Optional {
+				return nil, p.Name, GenerateError(500003601, strategy, def.Command, p.displayName())
+			}
+			continue
+		}
+		v[p.Name], err = p.parse(strategy, def.Command, s)
+		if err != nil {
+			return nil, p.Name, err
+		}
+	}
+
+	if len(v) != len(params) {
+		names := make([]string, 0, len(params))
+		for name := range params {
+			if !v.Has(name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return nil, names[0], GenerateError(500003606, strategy, def.Command, strings.Join(names, ", "))
+	}
+
+	return v, "", nil
+}
+
+// ParamsDescription - description of params generated from Params
+func (def CommandDef) ParamsDescription() string {
+	usage := make([]string, 0, len(def.Params))
+	for _, p := range def.Params {
+		usage = append(usage, p.Usage())
+	}
+	return strings.Join(usage, " ")
+}
+
+// CommandDefs - commands of strategy in order of AllowCommands
+type CommandDefs []CommandDef
+
+// Find - definition of command (nil - command does not exist)
+func (defs CommandDefs) Find(cmd Command) *CommandDef {
+	for i := range defs {
+		if defs[i].Command == cmd {
+			return &defs[i]
+		}
+	}
+	return nil
+}
+
+// Do - parses params of command, sets targets of params and calls Do of command
+// (targets are restored when Do fails)
+func (defs CommandDefs) Do(strategy string, cmd Command, params map[string]string) (res CommandResult, ok bool, err *mft.Error) {
+	def := defs.Find(cmd)
+	if def == nil {
+		return res, false, GenerateError(500003600, strategy, cmd)
+	}
+
+	v, err := def.Parse(strategy, params)
+	if err != nil {
+		return res, false, err
+	}
+	olds := make([]reflect.Value, len(def.Params))
+	for i, p := range def.Params {
+		if val, ok := v[p.Name]; ok {
+			olds[i] = p.get()
+			p.set(val)
+		}
+	}
+	if def.Do != nil {
+		res, err = def.Do(v)
+		if err != nil {
+			for i, p := range def.Params {
+				p.restore(olds[i])
+			}
+			return res, false, err
+		}
+	}
+
+	return res, true, nil
+}
+
+// AllowCommands - commands info generated from definitions with texts of strategy type tp in locale
+// (texts are taken from GlobalMessageCatalog)
+func (defs CommandDefs) AllowCommands(tp string, locale Locale) map[Command]CommandInfo {
+	res := make(map[Command]CommandInfo, len(defs))
+	for i, def := range defs {
+		def = GlobalMessageCatalog.Localize(locale, tp, def)
+		res[def.Command] = CommandInfo{
+			Order:             i,
+			Description:       def.Description,
+			ParamsDescription: def.ParamsDescription(),
+			Example:           def.Example,
+			Params:            def.Params,
+		}
+	}
+	return res
+}
+
+// CommandDefsOf - definitions of commands by AllowCommands sorted by Order (without Do, for parse of params)
+func CommandDefsOf(commands map[Command]CommandInfo) CommandDefs {
+	defs := make(CommandDefs, 0, len(commands))
+	for cmd, ci := range commands {
+		defs = append(defs, CommandDef{Command: cmd, Description: ci.Description, Example: ci.Example, Params: ci.Params})
+	}
+	sort.Slice(defs, func(i, j int) bool {
+		if commands[defs[i].Command].Order != commands[defs[j].Command].Order {
+			return commands[defs[i].Command].Order < commands[defs[j].Command].Order
+		}
+		return defs[i].Command < defs[j].Command
+	})
+	return defs
+}
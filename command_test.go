@@ -0,0 +1,132 @@
+package smp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/myfantasy/mft"
+)
+
+type testSide string
+
+// testTarget - fields set by params of test commands
+type testTarget struct {
+	Volume int
+	Price  float64
+	Market bool
+	Side   testSide
+	From   time.Time
+	Done   int
+}
+
+func (tt *testTarget) commands() CommandDefs {
+	return CommandDefs{
+		{Command: "set_vol", Description: "vol", Example: "set_vol 10",
+			Params: []Param{IntParam("", "lots", &tt.Volume).WithMin(1).WithMax(100)}},
+		{Command: "set_order", Description: "order", Example: "set_order p=1.5 s=buy",
+			Params: []Param{FloatParam("p", "price", &tt.Price),
+				StringParam("s", "side", &tt.Side).WithValues("buy", "sell"),
+				BoolParam("m", "by market", &tt.Market).AsOptional()},
+			Do: func(v ParamValues) (res CommandResult, err *mft.Error) {
+				tt.Done++
+				res.Message = v.String("s")
+				return res, nil
+			}},
+		{Command: "set_from", Description: "from",
+			Params: []Param{TimeParam("", "from", &tt.From)}},
+	}
+}
+
+func TestCommandDefsDo(t *testing.T) {
+	tt := &testTarget{}
+	defs := tt.commands()
+
+	_, ok, err := defs.Do("test", "set_vol", map[string]string{"": "10"})
+	if !ok || err != nil || tt.Volume != 10 {
+		t.Fatalf("set_vol: %v %v", tt.Volume, err)
+	}
+
+	res, ok, err := defs.Do("test", "set_order", map[string]string{"p": "1.5", "s": "sell"})
+	if !ok || err != nil || tt.Price != 1.5 || tt.Side != "sell" || tt.Market || tt.Done != 1 || res.Message != "sell" {
+		t.Fatalf("set_order: %+v %v", tt, err)
+	}
+
+	_, ok, err = defs.Do("test", "set_from", map[string]string{"": "2021-01-04T10:00:00Z"})
+	if !ok || err != nil || !tt.From.Equal(time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("set_from: %v %v", tt.From, err)
+	}
+}
+
+func TestCommandDefsErrors(t *testing.T) {
+	tt := &testTarget{Volume: 5, Price: 2}
+	defs := tt.commands()
+
+	cases := []struct {
+		cmd    Command
+		params map[string]string
+		code   int
+	}{
+		{"unknown", nil, 500003600},
+		{"set_vol", nil, 500003601},
+		{"set_vol", map[string]string{"": "x"}, 500003602},
+		{"set_vol", map[string]string{"": "0"}, 500003603},
+		{"set_vol", map[string]string{"": "101"}, 500003604},
+		{"set_order", map[string]string{"p": "1", "s": "hold"}, 500003605},
+		{"set_order", map[string]string{"p": "1", "s": "buy", "x": "1"}, 500003606},
+		{"set_order", map[string]string{"p": "1", "s": "buy", "m": "maybe"}, 500003602},
+		{"set_from", map[string]string{"": "2021-01-04"}, 500003602},
+	}
+	for _, c := range cases {
+		_, ok, err := defs.Do("test", c.cmd, c.params)
+		if ok || err == nil || err.Code != c.code {
+			t.Fatalf("%v %v: error %v expected, got %v", c.cmd, c.params, c.code, err)
+		}
+	}
+
+	// targets are not changed by failed commands
+	if tt.Volume != 5 || tt.Price != 2 || tt.Done != 0 {
+		t.Fatalf("targets are changed: %+v", tt)
+	}
+}
+
+func TestCommandDefsDoFailed(t *testing.T) {
+	tt := &testTarget{Price: 2, Side: "buy"}
+	defs := CommandDefs{
+		{Command: "set_order", Description: "order", Example: "set_order p=1.5 s=sell",
+			Params: []Param{FloatParam("p", "price", &tt.Price),
+				StringParam("s", "side", &tt.Side).WithValues("buy", "sell")},
+			Do: func(v ParamValues) (res CommandResult, err *mft.Error) {
+				if tt.Price != 1.5 || tt.Side != "sell" {
+					t.Fatalf("targets are not set before Do: %+v", tt)
+				}
+				return res, mft.ErrorS("rejected")
+			}},
+	}
+
+	_, ok, err := defs.Do("test", "set_order", map[string]string{"p": "1.5", "s": "sell"})
+	if ok || err == nil {
+		t.Fatalf("error of Do expected, got %v", err)
+	}
+	if tt.Price != 2 || tt.Side != "buy" {
+		t.Fatalf("targets are not restored after failed Do: %+v", tt)
+	}
+}
+
+func TestCommandDefsAllowCommands(t *testing.T) {
+	tt := &testTarget{}
+	ac := tt.commands().AllowCommands("test", DefaultLocale)
+	if len(ac) != 3 {
+		t.Fatalf("3 commands expected, got %v", len(ac))
+	}
+
+	ci := ac["set_order"]
+	if ci.Order != 1 || ci.Example != "set_order p=1.5 s=buy" || len(ci.Params) != 3 {
+		t.Fatalf("wrong command info: %+v", ci)
+	}
+	if ci.ParamsDescription != "p=[price] s=[side: buy | sell] [m=[by market]]" {
+		t.Fatalf("wrong params description: %v", ci.ParamsDescription)
+	}
+	if d := ac["set_vol"].ParamsDescription; d != "lots (1 .. 100)" {
+		t.Fatalf("wrong params description: %v", d)
+	}
+}
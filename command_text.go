@@ -0,0 +1,265 @@
+package smp
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/myfantasy/mft"
+)
+
+// Текстовый язык команд стратегий:
+//   set_level 345.67; render f=-5 t=10
+//   set_weight t=SBER w=0.25
+//   show child="swing 1"
+// Команды разделяются `;` или переводом строки, первое слово - команда,
+// слово без `=` - позиционный параметр (ключ ""), `имя=значение` - именованный параметр.
+// Значение в "..." может содержать пробелы, `;`, `=` и экранирование \" \\ \n \t, значение в '...' - без экранирования.
+
+// CommandText - command parsed from text
+type CommandText struct {
+	Command Command
+	Params  map[string]string
+	// Pos - position of command in text (in runes from 0)
+	Pos int
+	// ParamsPos - positions of params in text by names of params
+	ParamsPos map[string]int
+	// Values - typed values of params (set by CommandDefs.ParseText)
+	Values ParamValues
+}
+
+// String - text of command (FormatCommand)
+func (c CommandText) String() string {
+	return FormatCommand(c.Command, c.Params)
+}
+
+// ParseCommands - parses commands of text (the same command can be parsed again from String())
+func ParseCommands(text string) (cmds []CommandText, err *mft.Error) {
+	r := []rune(text)
+	var c *CommandText
+	for i := 0; i <= len(r); {
+		if i == len(r) || r[i] == ';' || r[i] == '\n' {
+			if c != nil {
+				cmds = append(cmds, *c)
+				c = nil
+			}
+			i++
+			continue
+		}
+		if unicode.IsSpace(r[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		tok, eq, quoted, end, err := readCommandToken(r, i)
+		if err != nil {
+			return nil, err
+		}
+		i = end
+
+		if c == nil {
+			if quoted || eq >= 0 {
+				return nil, GenerateError(500003701, start, string(r[start:end]))
+			}
+			c = &CommandText{
+				Command:   Command(tok),
+				Params:    map[string]string{},
+				Pos:       start,
+				ParamsPos: map[string]int{},
+			}
+			continue
+		}
+
+		name, value := "", tok
+		if eq >= 0 {
+			name, value = tok[:eq], tok[eq+1:]
+			if name == "" {
+				return nil, GenerateError(500003702, start)
+			}
+		}
+		if prev, ok := c.Params[name]; ok {
+			if name == "" {
+				return nil, GenerateError(500003704, start, prev)
+			}
+			return nil, GenerateError(500003703, start, name)
+		}
+		c.Params[name] = value
+		c.ParamsPos[name] = start
+	}
+
+	return cmds, nil
+}
+
+// ParseCommand - parses text of exactly one command
+func ParseCommand(text string) (c CommandText, err *mft.Error) {
+	cmds, err := ParseCommands(text)
+	if err != nil {
+		return c, err
+	}
+	if len(cmds) != 1 {
+		return c, GenerateError(500003705, len(cmds))
+	}
+	return cmds[0], nil
+}
+
+// readCommandToken - reads word from position i until unquoted space, `;` or end of line
+// (eq - index of the first unquoted `=` in tok before quotes, -1 - no `=`)
+func readCommandToken(r []rune, i int) (tok string, eq int, quoted bool, end int, err *mft.Error) {
+	var b strings.Builder
+	eq = -1
+	for i < len(r) && r[i] != ';' && r[i] != '\n' && !unicode.IsSpace(r[i]) {
+		switch {
+		case r[i] == '=' && eq < 0 && !quoted:
+			eq = b.Len()
+			b.WriteRune(r[i])
+			i++
+		case r[i] == '\'':
+			quoted = true
+			j := i + 1
+			for j < len(r) && r[j] != '\'' {
+				j++
+			}
+			if j == len(r) {
+				return "", -1, quoted, i, GenerateError(500003700, i, "'")
+			}
+			b.WriteString(string(r[i+1 : j]))
+			i = j + 1
+		case r[i] == '"':
+			quoted = true
+			j := i + 1
+			for ; j < len(r) && r[j] != '"'; j++ {
+				if r[j] == '\\' && j+1 < len(r) {
+					j++
+					b.WriteRune(unescapeCommandRune(r[j]))
+					continue
+				}
+				b.WriteRune(r[j])
+			}
+			if j == len(r) {
+				return "", -1, quoted, i, GenerateError(500003700, i, `"`)
+			}
+			i = j + 1
+		default:
+			b.WriteRune(r[i])
+			i++
+		}
+	}
+	return b.String(), eq, quoted, i, nil
+}
+
+func unescapeCommandRune(r rune) rune {
+	switch r {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	}
+	return r
+}
+
+// FormatCommand - text of command: positional param first, named params are sorted by names,
+// values are quoted when it is needed
+func FormatCommand(cmd Command, params map[string]string) string {
+	words := []string{string(cmd)}
+	if v, ok := params[""]; ok {
+		words = append(words, quoteCommandValue(v, true))
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		words = append(words, name+"="+quoteCommandValue(params[name], false))
+	}
+
+	return strings.Join(words, " ")
+}
+
+// quoteCommandValue - value in "..." when it is empty or contains spaces or special chars
+// (`=` is special only for positional value)
+func quoteCommandValue(v string, positional bool) string {
+	special := v == "" || strings.ContainsAny(v, `;"'\`) || (positional && strings.Contains(v, "="))
+	for _, r := range v {
+		special = special || unicode.IsSpace(r)
+	}
+	if !special {
+		return v
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`).Replace(v) + `"`
+}
+
+// ParseText - parses commands of text and typed values of their params
+// (errors are raised with position of command or param in text)
+func (defs CommandDefs) ParseText(strategy string, text string) (cmds []CommandText, err *mft.Error) {
+	cmds, err = ParseCommands(text)
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range cmds {
+		def := defs.Find(c.Command)
+		if def == nil {
+			return nil, GenerateErrorE(500003706, GenerateError(500003600, strategy, c.Command), c.Pos, c.Command)
+		}
+		v, failed, err := def.parse(strategy, c.Params)
+		if err != nil {
+			return nil, GenerateErrorE(500003706, err, c.position(failed), c.Command)
+		}
+		cmds[i].Values = v
+	}
+	return cmds, nil
+}
+
+// position - position of param (position of command when param is not set)
+func (c CommandText) position(name string) int {
+	if pos, ok := c.ParamsPos[name]; ok {
+		return pos
+	}
+	return c.Pos
+}
+
+// ExecuteCommands - parses text and sends commands to strategy one by one
+// (nothing is sent when text is wrong: syntax, commands and params by AllowCommands of strategy are checked first;
+// sending stops on the first failed command)
+func ExecuteCommands(s Strategy, text string) (res CommandResult, err *mft.Error) {
+	return executeCommands(s, text, s.Command)
+}
+
+// executeCommands - ExecuteCommands with function sending command to strategy
+func executeCommands(s Strategy, text string,
+	do func(cmd Command, params map[string]string) (res CommandResult, ok bool, err *mft.Error),
+) (res CommandResult, err *mft.Error) {
+	strategy := s.Type()
+	cmds, err := ParseCommands(text)
+	if err != nil {
+		return res, err
+	}
+	_, err = CommandDefsOf(s.AllowCommands(DefaultLocale)).ParseText(strategy, text)
+	if err != nil {
+		return res, err
+	}
+
+	messages := make([]string, 0, len(cmds))
+	for _, c := range cmds {
+		r, ok, err := do(c.Command, c.Params)
+		if err == nil && !ok {
+			err = GenerateError(500003708, strategy, c.Command)
+		}
+		if err != nil {
+			res.Message = strings.Join(messages, "\n")
+			return res, GenerateErrorE(500003707, err, c.Pos, c.Command)
+		}
+		if r.Message != "" {
+			messages = append(messages, r.Message)
+		}
+	}
+
+	res.Message = strings.Join(messages, "\n")
+	return res, nil
+}
@@ -0,0 +1,128 @@
+package smp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCommands(t *testing.T) {
+	cmds, err := ParseCommands("set_level 345.67; render f=-5 t=10\n  show child=\"swing 1\" label='side:buy'\n;;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 3 {
+		t.Fatalf("3 commands expected, got %v", len(cmds))
+	}
+
+	expected := []struct {
+		cmd    Command
+		params map[string]string
+		pos    int
+	}{
+		{"set_level", map[string]string{"": "345.67"}, 0},
+		{"render", map[string]string{"f": "-5", "t": "10"}, 18},
+		{"show", map[string]string{"child": "swing 1", "label": "side:buy"}, 37},
+	}
+	for i, e := range expected {
+		if cmds[i].Command != e.cmd || !reflect.DeepEqual(cmds[i].Params, e.params) || cmds[i].Pos != e.pos {
+			t.Fatalf("%v: %+v expected, got %+v", i, e, cmds[i])
+		}
+	}
+	if pos := cmds[1].ParamsPos["t"]; pos != 30 {
+		t.Fatalf("position 30 of param t expected, got %v", pos)
+	}
+
+	c, err := ParseCommand(`set_note "a \"b\"; c=d" x="1\\2\n"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Params[""] != `a "b"; c=d` || c.Params["x"] != "1\\2\n" {
+		t.Fatalf("wrong quoted values: %+v", c.Params)
+	}
+}
+
+func TestParseCommandsErrors(t *testing.T) {
+	cases := []struct {
+		text string
+		code int
+		pos  string
+	}{
+		{`show child="a`, 500003700, "position 11:"},
+		{`"show"`, 500003701, "position 0:"},
+		{`f=1 show`, 500003701, "position 0:"},
+		{`render =5`, 500003702, "position 7:"},
+		{`render f=1 f=2`, 500003703, "position 11:"},
+		{`set_vol 1 2`, 500003704, "position 10:"},
+		{`stop; set_vol 1 'x`, 500003700, "position 16:"},
+	}
+	for _, c := range cases {
+		_, err := ParseCommands(c.text)
+		if err == nil || err.Code != c.code || !strings.Contains(err.Msg, c.pos) {
+			t.Fatalf("%v: error %v at %v expected, got %v", c.text, c.code, c.pos, err)
+		}
+	}
+
+	if _, err := ParseCommand("start; stop"); err == nil || err.Code != 500003705 {
+		t.Fatalf("single command error expected, got %v", err)
+	}
+	if _, err := ParseCommand(" ; "); err == nil || err.Code != 500003705 {
+		t.Fatalf("single command error expected, got %v", err)
+	}
+}
+
+func TestFormatCommand(t *testing.T) {
+	params := map[string]string{
+		"":  "a=b",
+		"t": "10",
+		"f": "-5",
+		"n": "swing \"1\";\n",
+		"e": "",
+	}
+	text := FormatCommand("render", params)
+	if text != `render "a=b" e="" f=-5 n="swing \"1\";\n" t=10` {
+		t.Fatalf("wrong text: %v", text)
+	}
+
+	c, err := ParseCommand(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Command != "render" || !reflect.DeepEqual(c.Params, params) || c.String() != text {
+		t.Fatalf("command is changed by format and parse: %+v", c)
+	}
+}
+
+func TestCommandDefsParseText(t *testing.T) {
+	tt := &testTarget{}
+	defs := tt.commands()
+
+	cmds, err := defs.ParseText("test", "set_vol 10; set_order p=1.5 s=buy m=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmds[0].Values.Int("") != 10 || cmds[1].Values.Float("p") != 1.5 || !cmds[1].Values.Bool("m") {
+		t.Fatalf("wrong values: %v %v", cmds[0].Values, cmds[1].Values)
+	}
+	if tt.Volume != 0 || tt.Done != 0 {
+		t.Fatalf("targets are changed by parse: %+v", tt)
+	}
+
+	cases := []struct {
+		text string
+		code int
+		pos  string
+	}{
+		{"set_vol 10; unknown", 500003600, "position 12:"},
+		{"set_vol 10; set_order p=x s=buy", 500003602, "position 22:"},
+		{"set_order s=buy", 500003601, "position 0:"},
+		{"set_order p=1 s=buy z=1", 500003606, "position 20:"},
+	}
+	for _, c := range cases {
+		_, err := defs.ParseText("test", c.text)
+		if err == nil || err.Code != 500003706 || err.InternalError == nil || err.InternalError.Code != c.code ||
+			!strings.Contains(err.Msg, c.pos) {
+			t.Fatalf("%v: error %v at %v expected, got %v", c.text, c.code, c.pos, err)
+		}
+	}
+}
@@ -8,91 +8,194 @@ import (
 
 // Errors codes and description
 var Errors map[int]string = map[int]string{
-	500000000: "strategies.TakeProfitBuy: Command: `%v` does not exists",
-	500000010: "strategies.TakeProfitBuy: Command: `%v` param not set",
-	500000011: "strategies.TakeProfitBuy: Command: `%v` param `%v` is not float64",
-	500000020: "strategies.TakeProfitBuy: Command: `%v` param not set",
-	500000021: "strategies.TakeProfitBuy: Command: `%v` param `%v` is not int",
-	500000030: "strategies.TakeProfitBuy: Command: `%v` param not set",
-	500000031: "strategies.TakeProfitBuy: Command: `%v` param `%v` is not bool",
-
 	500000100: "strategies.TakeProfitBuy: Step: fail order book get",
 	500000101: "strategies.TakeProfitBuy: Step: fail buy by price",
 	500000102: "strategies.TakeProfitBuy: Step: fail get info about buy order",
 
-	500000200: "strategies.TakeProfitSell: Command: `%v` does not exists",
-	500000210: "strategies.TakeProfitSell: Command: `%v` param not set",
-	500000211: "strategies.TakeProfitSell: Command: `%v` param `%v` is not float64",
-	500000220: "strategies.TakeProfitSell: Command: `%v` param not set",
-	500000221: "strategies.TakeProfitSell: Command: `%v` param `%v` is not int",
-	500000230: "strategies.TakeProfitSell: Command: `%v` param not set",
-	500000231: "strategies.TakeProfitSell: Command: `%v` param `%v` is not bool",
-
 	500000300: "strategies.TakeProfitSell: Step: fail order book get",
 	500000301: "strategies.TakeProfitSell: Step: fail sell by price",
 	500000302: "strategies.TakeProfitSell: Step: fail get info about sell order",
 
-	500000400: "strategies.WingedSwing: Command: `%v` does not exists",
-	500000410: "strategies.WingedSwing: Command: `%v` param not set",
-	500000411: "strategies.WingedSwing: Command: `%v` param `%v` is not float64",
-	500000412: "strategies.WingedSwing: Command: `%v` param not set",
-	500000413: "strategies.WingedSwing: Command: `%v` param `%v` is not float64",
-	500000414: "strategies.WingedSwing: Command: `%v` param not set",
-	500000415: "strategies.WingedSwing: Command: `%v` param `%v` is not float64",
-	500000420: "strategies.WingedSwing: Command: `%v` param not set",
-	500000421: "strategies.WingedSwing: Command: `%v` param `%v` is not int",
-	500000430: "strategies.WingedSwing: Command: `%v` param not set",
-	500000431: "strategies.WingedSwing: Command: `%v` param `%v` is not float64",
-	500000432: "strategies.WingedSwing: Command: `%v` param not set",
-	500000433: "strategies.WingedSwing: Command: `%v` param `%v` is not float64",
-
 	500000500: "strategies.WingedSwing: Step: fail order book get",
 	500000501: "strategies.WingedSwing: Step: fail buy by price",
 	500000502: "strategies.WingedSwing: Step: fail get info about buy order",
 	500000503: "strategies.WingedSwing: Step: fail get info about sell order",
 	500000504: "strategies.WingedSwing: Step: fail sell by price",
 	500000505: "strategies.WingedSwing: Step: unexpected situation InMarket: %v",
-	500000506: "strategies.WingedSwing: Step: unexpected situation InMarket: %v; s.Volume: %v",
 	500000507: "strategies.WingedSwing: Step: fail cancel sell on prepare to stop loss",
 	500000508: "strategies.WingedSwing: Step: fail sell by market !!! stop loss",
 	500000509: "strategies.WingedSwing: Step: fail sell to StopLostBank !!! stop loss",
 	500000510: "strategies.WingedSwing: Step: fail buy from StopLostBank",
-	500000511: "strategies.WingedSwing: Step: fail buy from StopLostBank add_place",
-	500000512: "strategies.WingedSwing: Step: fail cancel buy on buy from stop loss bank",
-	500000513: "strategies.WingedSwing: Step: fail cancel buy task",
 	500000514: "strategies.WingedSwing: Step: fail cancel sell task",
-	500000515: "strategies.WingedSwing: Step: fail send STOP LOSS to StopLostBank",
 	500000516: "strategies.WingedSwing: Step: fail send REQUEST SALE to StopLostBank",
+	500000517: "strategies.WingedSwing: Step: fail cancel buy on prepare to stop loss",
+	500000518: "strategies.WingedSwing: Step: fail buy by market",
+	500000519: "strategies.WingedSwing: FlattenPosition: fail sell by market",
 
-	500000600: "strategies.WingedSwing: Command: `%v` does not exists",
-	500000610: "strategies.WingedSwing: Command: `%v` param not set",
-	500000611: "strategies.WingedSwing: Command: `%v` param `%v` is not float64",
-	500000612: "strategies.WingedSwing: Command: `%v` param not set",
-	500000613: "strategies.WingedSwing: Command: `%v` param `%v` is not float64",
-	500000614: "strategies.WingedSwing: Command: `%v` param not set",
-	500000615: "strategies.WingedSwing: Command: `%v` param `%v` is not float64",
-	500000616: "strategies.WingedSwing: Command: `%v` param not set",
-	500000617: "strategies.WingedSwing: Command: `%v` param `%v` is not float64",
-	500000618: "strategies.WingedSwing: Command: `%v` param not set",
-	500000619: "strategies.WingedSwing: Command: `%v` param `%v` is not float64",
-	500000620: "strategies.WingedSwing: Command: `%v` param not set",
-	500000621: "strategies.WingedSwing: Command: `%v` param `%v` is not int",
-	500000640: "strategies.WingedSwing: Command: `%v` param `%v` not set",
-	500000641: "strategies.WingedSwing: Command: `%v` value `%v` is not int (param `%v`)",
-	500000642: "strategies.WingedSwing: Command: `%v` param `%v` not set",
-	500000643: "strategies.WingedSwing: Command: `%v` value `%v` is not int (param `%v`)",
-	500000644: "strategies.WingedSwing: Command: `%v` param `%v` not set",
-	500000645: "strategies.WingedSwing: Command: `%v` value `%v` is not int (param `%v`)",
-	500000646: "strategies.WingedSwing: Command: `%v` param `%v` not set",
-	500000647: "strategies.WingedSwing: Command: `%v` value `%v` is not int (param `%v`)",
-	500000650: "strategies.WingedSwing: Command: `%v` lavel `i` does not exists",
-	500000651: "strategies.WingedSwing: Command: `%v` lavel `i` value `%v` is not int (element `%v`)",
-	500000660: "strategies.WingedSwing: Command: `%v` param not set",
-	500000661: "strategies.WingedSwing: Command: `%v` param `%v` is not float64",
-	500000662: "strategies.WingedSwing: Command: `%v` param not set",
-	500000663: "strategies.WingedSwing: Command: `%v` param `%v` is not float64",
+	500000650: "strategies.WingedSwingGroup: Command: swing `%v` label `i` does not exists",
+	500000651: "strategies.WingedSwingGroup: Command: swing `%v` label `i` value `%v` is not int",
 
 	500000700: "strategies.WingedSwing: Step: fail do some nested steps faild: %v of %v",
+
+	500000800: "smp.ReplaceBuyOrder: fail cancel order `%v`",
+	500000801: "smp.ReplaceBuyOrder: order `%v` is not active",
+	500000802: "smp.ReplaceBuyOrder: fail get info about order `%v`",
+	500000803: "smp.ReplaceBuyOrder: fail buy by price (replace order `%v`)",
+	500000810: "smp.ReplaceSellOrder: fail cancel order `%v`",
+	500000811: "smp.ReplaceSellOrder: order `%v` is not active",
+	500000812: "smp.ReplaceSellOrder: fail get info about order `%v`",
+	500000813: "smp.ReplaceSellOrder: fail sell by price (replace order `%v`)",
+
+	500000944: "strategies.Grid: Command: `%v` step `%v` should be greater than 0",
+	500000945: "strategies.Grid: Command: `%v` levels are rendered already: %v",
+	500000946: "strategies.Grid: Command: `%v` from `%v` is greater than to `%v`",
+
+	500001000: "strategies.Grid: Step: fail order book get",
+	500001001: "strategies.Grid: Step: fail do some nested steps faild: %v of %v",
+
+	500001200: "strategies.TrailingStop: Step: fail order book get",
+	500001201: "strategies.TrailingStop: Step: fail compute trail (candles get)",
+	500001202: "strategies.TrailingStop: Step: fail get info about sell order",
+	500001203: "strategies.TrailingStop: Step: fail sell by market !!! stop loss",
+	500001204: "strategies.TrailingStop: Step: fail place stop order",
+	500001205: "strategies.TrailingStop: Step: account positions are not supported by market",
+	500001206: "strategies.TrailingStop: Step: fail get position",
+	500001207: "strategies.TrailingStop: Step: fail cancel stop order on move",
+	500001208: "strategies.TrailingStop: FlattenPosition: fail sell by market",
+
+	500001400: "strategies.DCA: Step: fail order book get",
+	500001401: "strategies.DCA: Step: fail instrument info get",
+	500001402: "strategies.DCA: Step: fail get info about buy order",
+	500001403: "strategies.DCA: Step: fail buy by market",
+
+	500001600: "strategies.Pairs: Step: fail order book get (%v)",
+	500001601: "strategies.Pairs: Step: fail candles get",
+	500001602: "strategies.Pairs: Step: fail get info about order (%v)",
+	500001603: "strategies.Pairs: Step: fail buy by market (%v)",
+	500001604: "strategies.Pairs: Step: fail sell by market (%v)",
+	500001605: "strategies.Pairs: Step: fail instrument info get (%v)",
+	500001606: "strategies.Pairs: Step: fail cancel order (%v)",
+
+	500001800: "strategies.MACrossover: Step: fail order book get",
+	500001801: "strategies.MACrossover: Step: fail candles get",
+	500001802: "strategies.MACrossover: Step: fail get info about order",
+	500001803: "strategies.MACrossover: Step: fail buy by market",
+	500001804: "strategies.MACrossover: Step: fail sell by market",
+
+	500002000: "strategies.Breakout: Step: fail order book get",
+	500002001: "strategies.Breakout: Step: fail candles get",
+	500002002: "strategies.Breakout: Step: fail get info about order",
+	500002003: "strategies.Breakout: Step: fail buy by market",
+	500002004: "strategies.Breakout: Step: fail sell by market",
+
+	500002200: "strategies.ParentOrder: Step: fail order book get",
+	500002201: "strategies.ParentOrder: Step: fail candles get",
+	500002202: "strategies.ParentOrder: Step: fail get info about child order",
+	500002203: "strategies.ParentOrder: Step: fail cancel child order",
+	500002204: "strategies.ParentOrder: Step: fail child order by market",
+	500002205: "strategies.ParentOrder: Step: fail child order by price",
+	500002206: "strategies.ParentOrder: set_side: side can not be changed while child order `%v` is active",
+
+	500002312: "strategies.VWAP: Step: fail build volume profile",
+	500002313: "strategies.VWAP: Step: fail order book get",
+
+	500002500: "strategies.Iceberg: Step: fail instrument info get",
+	500002501: "strategies.Iceberg: Step: fail get info about order",
+	500002502: "strategies.Iceberg: Step: fail cancel order",
+	500002503: "strategies.Iceberg: Step: fail place order",
+	500002504: "strategies.Iceberg: set_side: side can not be changed while order `%v` is active",
+
+	500002613: "strategies.Rebalance: Command: `%v` param w `%v` is negative or sum of weights is over 1",
+
+	500002700: "strategies.Rebalance: Step: step params do not support portfolio",
+	500002701: "strategies.Rebalance: Step: fail position get `%v`",
+	500002702: "strategies.Rebalance: Step: fail order book get `%v`",
+	500002703: "strategies.Rebalance: Step: fail instrument info get `%v`",
+	500002704: "strategies.Rebalance: Step: fail money get",
+	500002705: "strategies.Rebalance: Step: fail get info about order `%v`",
+	500002706: "strategies.Rebalance: Step: fail sell by market `%v`",
+	500002707: "strategies.Rebalance: Step: fail buy by market `%v`",
+
+	500002900: "strategies.DividendCapture: Step: fail order book get",
+	500002901: "strategies.DividendCapture: Step: fail get info about buy order",
+	500002902: "strategies.DividendCapture: Step: fail get info about sell order",
+	500002903: "strategies.DividendCapture: Step: fail buy by market",
+	500002904: "strategies.DividendCapture: Step: fail sell by market",
+
+	500003100: "strategies.Bracket: Step: fail get info about entry order",
+	500003101: "strategies.Bracket: Step: fail get info about `%v` order",
+	500003102: "strategies.Bracket: Step: fail cancel `%v` order",
+	500003103: "strategies.Bracket: Step: fail entry buy",
+	500003104: "strategies.Bracket: Step: fail take profit sell by price",
+	500003105: "strategies.Bracket: Step: fail stop loss sell",
+	500003106: "strategies.Bracket: Step: fail order book get",
+	500003107: "strategies.Bracket: Step: %v lots are sold over position by both legs, buy back order `%v`",
+	500003108: "strategies.Bracket: Step: fail buy back of oversold lots",
+	500003109: "strategies.Bracket: Step: fail OCO sell (take profit and stop loss)",
+	500003110: "strategies.Bracket: Step: fail replace take profit order",
+
+	500003200: "strategies.Composite: Command: `%v` does not exists",
+	500003201: "strategies.Composite: Command: `%v` no children selected by `%v`",
+	500003202: "strategies.Composite: Command: `%v` param `%v` value `%v` is not `key:value`",
+	500003203: "strategies.Composite: Command: `%v` fail in %v of %v children",
+	500003204: "strategies.Composite: Command: `%v` is not done by child `%v`",
+	500003210: "strategies.Composite: Add: name of child is empty",
+	500003211: "strategies.Composite: Add: strategy of child `%v` is nil",
+	500003212: "strategies.Composite: Add: child `%v` already exists",
+	500003220: "strategies.Composite: UnmarshalJSON: fail read child",
+	500003221: "strategies.Composite: UnmarshalJSON: fail load child `%v`",
+	500003222: "strategies.Composite: MarshalJSON: fail save child `%v`",
+
+	500003300: "strategies.Composite: Step: fail do some nested steps faild: %v of %v",
+
+	500003400: "smp.StrategyRegistry: strategy type `%v` is not registered",
+	500003401: "smp.StrategyRegistry: Build: strategy `%v` is not pointer to struct",
+	500003402: "smp.StrategyRegistry: Build: strategy `%v` param `%v` does not exists",
+	500003403: "smp.StrategyRegistry: Build: strategy `%v` param `%v` is not set by commands",
+	500003404: "smp.StrategyRegistry: Build: strategy `%v` param `%v` value `%v` is wrong",
+	500003410: "smp.StrategyRegistry: Save: fail marshal strategy `%v`",
+	500003411: "smp.StrategyRegistry: Load: fail unmarshal strategy `%v`",
+	500003412: "smp.StrategyRegistry: Migrate: schema version of strategy `%v` %v is newer than supported %v",
+	500003413: "smp.StrategyRegistry: Migrate: migration of strategy `%v` from schema version %v not found",
+	500003414: "smp.StrategyRegistry: Migrate: fail migrate strategy `%v` from schema version %v to %v",
+
+	500003500: "strategies.Migration: fail read document of `%v`",
+	500003501: "strategies.Migration: fail migrate swing %v of `%v`",
+
+	500003550: "strategies: `%v`: command `%v` failed for %v of %v children",
+	500003551: "strategies: `%v`: reset is not allowed with open position %v lots",
+
+	500003600: "smp.Commands: `%v`: command `%v` does not exists",
+	500003601: "smp.Commands: `%v`: command `%v` param `%v` not set",
+	500003602: "smp.Commands: `%v`: command `%v` param `%v` value `%v` is wrong",
+	500003603: "smp.Commands: `%v`: command `%v` param `%v` value `%v` is less than %v",
+	500003604: "smp.Commands: `%v`: command `%v` param `%v` value `%v` is greater than %v",
+	500003605: "smp.Commands: `%v`: command `%v` param `%v` value `%v` is not one of: %v",
+	500003606: "smp.Commands: `%v`: command `%v` params are unknown: %v",
+
+	500003700: "smp.ParseCommands: position %v: quote `%v` is not closed",
+	500003701: "smp.ParseCommands: position %v: command expected, got `%v`",
+	500003702: "smp.ParseCommands: position %v: name of param is empty",
+	500003703: "smp.ParseCommands: position %v: param `%v` is set twice",
+	500003704: "smp.ParseCommands: position %v: positional param is set already: `%v`",
+	500003705: "smp.ParseCommand: %v commands found, 1 command expected",
+	500003706: "smp.ParseText: position %v: command `%v` is wrong",
+	500003707: "smp.ExecuteCommands: position %v: command `%v` failed",
+	500003708: "smp.ExecuteCommands: `%v`: command `%v` is not done",
+
+	500003800: "smp.CommandLog: Undo: record `%v` does not exists",
+	500003801: "smp.CommandLog: Undo: record `%v` is undone already by record `%v`",
+	500003802: "smp.CommandLog: Undo: record `%v` has no changes of state",
+	500003803: "smp.CommandLog: Undo: record `%v` is of strategy type `%v`, not `%v`",
+	500003804: "smp.CommandLog: Undo: record `%v` is not safe to undo, state is changed after it: %v",
+	500003805: "smp.CommandLog: Undo: fail restore state before record `%v`",
+	500003806: "smp.CommandLog: fail read state of strategy",
+
+	500003900: "smp.Lifecycle: `%v`: stop with flatten is not supported",
+	500003901: "smp.Lifecycle: `%v`: command `%v` is not allowed in state `%v`",
+	500003902: "smp.Lifecycle: `%v`: reset is not allowed with %v active orders",
+	500003903: "smp.Lifecycle: `%v`: reset is not supported",
+	500003904: "smp.Lifecycle: Step: fail cancel order `%v`",
 }
 
 // GenerateError -
@@ -0,0 +1,229 @@
+package smp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/myfantasy/mfs"
+	"github.com/myfantasy/mft"
+)
+
+type EventType string
+
+const (
+	OrderBookEvent     EventType = "order_book"
+	TradeEvent         EventType = "trade"
+	OrderStateEvent    EventType = "order_state"
+	TradingStatusEvent EventType = "trading_status"
+)
+
+// Trade - trade print
+type Trade struct {
+	Price    float64 `json:"price"`
+	Quantity int     `json:"quantity"`
+}
+
+// OrderState - state of order after change
+type OrderState struct {
+	OrderId string      `json:"order_id"`
+	Buy     bool        `json:"buy"`
+	Type    OrderType   `json:"type"`
+	Status  StatusOrder `json:"status"`
+	Prices  []LotPrices `json:"prices"`
+}
+
+type Event struct {
+	Type         EventType `json:"type"`
+	InstrumentId string    `json:"instrument_id"`
+	Ticker       string    `json:"ticker"`
+	Time         time.Time `json:"time"`
+
+	OrderBook   *OrderBook    `json:"order_book,omitempty"`
+	Trade       *Trade        `json:"trade,omitempty"`
+	OrderState  *OrderState   `json:"order_state,omitempty"`
+	TradeStatus TradingStatus `json:"trade_status,omitempty"`
+}
+
+// EventFilter - events of subscription
+// empty InstrumentId and Ticker - all instruments; empty Types - all types
+type EventFilter struct {
+	InstrumentId string      `json:"instrument_id"`
+	Ticker       string      `json:"ticker"`
+	Types        []EventType `json:"types"`
+}
+
+func (f EventFilter) Match(e Event) bool {
+	if f.InstrumentId != "" && f.InstrumentId != e.InstrumentId {
+		return false
+	}
+	if f.Ticker != "" && f.Ticker != e.Ticker {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+type EventHandler func(e Event)
+
+// Subscription - subscription on events
+type Subscription struct {
+	Filter EventFilter
+
+	handler       EventHandler
+	unsubscribe   func()
+	onUnsubscribe []func()
+	once          sync.Once
+}
+
+// NewSubscription - makes subscription for EventStepParams implementations
+// unsubscribe is called once on Unsubscribe
+func NewSubscription(filter EventFilter, handler EventHandler, unsubscribe func()) *Subscription {
+	return &Subscription{
+		Filter:      filter,
+		handler:     handler,
+		unsubscribe: unsubscribe,
+	}
+}
+
+// Handle - sends event to subscription handler
+func (s *Subscription) Handle(e Event) {
+	if s.handler != nil {
+		s.handler(e)
+	}
+}
+
+// OnUnsubscribe - adds function called after Unsubscribe
+func (s *Subscription) OnUnsubscribe(f func()) {
+	s.onUnsubscribe = append(s.onUnsubscribe, f)
+}
+
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		if s.unsubscribe != nil {
+			s.unsubscribe()
+		}
+		for _, f := range s.onUnsubscribe {
+			f()
+		}
+	})
+}
+
+// EventStepParams - StepParams with push events
+type EventStepParams interface {
+	StepParams
+
+	Subscribe(filter EventFilter, handler EventHandler) (sub *Subscription, err *mft.Error)
+}
+
+// SubscribeChan - subscribes on events through channel with buffer size
+// publisher is not blocked: events are dropped when buffer is full
+// channel is closed on Unsubscribe
+func SubscribeChan(p EventStepParams, filter EventFilter, size int) (sub *Subscription, ch <-chan Event, err *mft.Error) {
+	c := make(chan Event, size)
+	var mx sync.Mutex
+	closed := false
+
+	sub, err = p.Subscribe(filter, func(e Event) {
+		mx.Lock()
+		defer mx.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case c <- e:
+		default:
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sub.OnUnsubscribe(func() {
+		mx.Lock()
+		defer mx.Unlock()
+		closed = true
+		close(c)
+	})
+
+	return sub, c, nil
+}
+
+// EventBus - subscriptions storage for EventStepParams implementations
+type EventBus struct {
+	subs   map[int64]*Subscription
+	nextId int64
+	mx     mfs.PMutex
+}
+
+func (eb *EventBus) Subscribe(filter EventFilter, handler EventHandler) (sub *Subscription, err *mft.Error) {
+	eb.mx.Lock()
+	defer eb.mx.Unlock()
+	if eb.subs == nil {
+		eb.subs = make(map[int64]*Subscription)
+	}
+	eb.nextId++
+	id := eb.nextId
+	sub = NewSubscription(filter, handler, func() {
+		eb.mx.Lock()
+		defer eb.mx.Unlock()
+		delete(eb.subs, id)
+	})
+	eb.subs[id] = sub
+	return sub, nil
+}
+
+// Publish - sends event to matched subscriptions (synchronously)
+func (eb *EventBus) Publish(e Event) {
+	eb.mx.RLock()
+	subs := make([]*Subscription, 0, len(eb.subs))
+	for _, sub := range eb.subs {
+		if sub.Filter.Match(e) {
+			subs = append(subs, sub)
+		}
+	}
+	eb.mx.RUnlock()
+
+	for _, sub := range subs {
+		sub.Handle(e)
+	}
+}
+
+// EventDrivenStrategy - strategy that reacts on events instead of (or in addition to) Step
+type EventDrivenStrategy interface {
+	Strategy
+
+	// Subscriptions - events required by strategy
+	Subscriptions() []EventFilter
+	// OnEvent - reaction on event; p is used for orders like in Step
+	OnEvent(p StepParams, e Event) (meta MetaForStep, err *mft.Error)
+}
+
+// SubscribeStrategy - subscribes strategy on its events
+// result of each OnEvent is sent to onResult (may be nil)
+func SubscribeStrategy(p EventStepParams, s EventDrivenStrategy,
+	onResult func(meta MetaForStep, err *mft.Error),
+) (subs []*Subscription, err *mft.Error) {
+	for _, filter := range s.Subscriptions() {
+		sub, err := p.Subscribe(filter, func(e Event) {
+			meta, err := s.OnEvent(p, e)
+			if onResult != nil {
+				onResult(meta, err)
+			}
+		})
+		if err != nil {
+			for _, sub := range subs {
+				sub.Unsubscribe()
+			}
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
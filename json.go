@@ -2,7 +2,9 @@ package smp
 
 import "encoding/json"
 
+// JsonTypedContainer - serialized strategy (or other object) with its type and schema version of data
 type JsonTypedContainer struct {
-	Type string          `json:"type"`
-	Data json.RawMessage `json:"data"`
+	Type    string          `json:"type"`
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
 }
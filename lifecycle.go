@@ -0,0 +1,280 @@
+package smp
+
+import (
+	"github.com/myfantasy/mft"
+)
+
+// Жизненный цикл стратегии: старт, стоп (оставить заявки, снять заявки, закрыть позицию), пауза и сброс.
+// Стратегия хранит Lifecycle, отдаёт команды Lifecycle.Commands и вызывает Lifecycle.Step на каждом шаге
+// после проверки статусов своих заявок.
+
+type LifecycleState string
+
+const (
+	// LifecycleRunning - strategy trades
+	LifecycleRunning LifecycleState = "running"
+	// LifecyclePaused - strategy does not trade, orders are canceled, position is kept
+	LifecyclePaused LifecycleState = "paused"
+	// LifecycleStopping - strategy is stopped, orders are not confirmed canceled or position is not closed yet
+	LifecycleStopping LifecycleState = "stopping"
+	// LifecycleStopped - strategy does not trade
+	LifecycleStopped LifecycleState = "stopped"
+)
+
+type StopMode string
+
+const (
+	// StopKeepOrders - orders stay on the exchange
+	StopKeepOrders StopMode = "keep"
+	// StopCancelOrders - orders are canceled
+	StopCancelOrders StopMode = "cancel"
+	// StopFlatten - orders are canceled and position is closed by market
+	StopFlatten StopMode = "flatten"
+)
+
+const (
+	PauseCommand  Command = "pause"
+	ResumeCommand Command = "resume"
+	ResetCommand  Command = "reset"
+)
+
+// ActiveOrder - order of strategy on the exchange
+type ActiveOrder struct {
+	InstrumentId string
+	Ticker       string
+	OrderId      string
+	Buy          bool
+}
+
+// LifecycleHooks - strategy with standard lifecycle
+type LifecycleHooks interface {
+	// ActiveOrders - orders of strategy on the exchange (canceled on stop and pause)
+	ActiveOrders() []ActiveOrder
+}
+
+// LifecycleStarter - hook called on start before strategy is running
+type LifecycleStarter interface {
+	OnStart() (err *mft.Error)
+}
+
+// LifecyclePauser - hooks called on pause and resume
+type LifecyclePauser interface {
+	OnPause()
+	OnResume()
+}
+
+// PositionFlattener - strategy closes its position by market on stop with StopFlatten
+type PositionFlattener interface {
+	// FlattenPosition - is called on steps of stopping strategy without active orders (done - position is closed)
+	FlattenPosition(p StepParams, meta *MetaForStep) (done bool, err *mft.Error)
+}
+
+// StateResetter - strategy clears its trading state (position, counters) on reset of stopped strategy
+type StateResetter interface {
+	ResetState() (err *mft.Error)
+}
+
+// Lifecycle - lifecycle state of strategy
+type Lifecycle struct {
+	// State - "" for strategies saved before lifecycle (state is taken by online flag)
+	State    LifecycleState `json:"state,omitempty"`
+	StopMode StopMode       `json:"stop_mode,omitempty"`
+	// CancelSent - orders cancel is sent for (cancel is sent once)
+	CancelSent []string `json:"cancel_sent,omitempty"`
+	// Flattening - orders closing position are placed (they are not canceled)
+	Flattening bool `json:"flattening,omitempty"`
+}
+
+// Current - state of lifecycle
+func (l *Lifecycle) Current(isOnline bool) LifecycleState {
+	if l.State != "" {
+		return l.State
+	}
+	if isOnline {
+		return LifecycleRunning
+	}
+	return LifecycleStopped
+}
+
+// Status - status of strategy (strategy is online only when it is running)
+func (l *Lifecycle) Status(isOnline bool) StartegyStatus {
+	state := l.Current(isOnline)
+	return StartegyStatus{
+		IsOnline: isOnline && state == LifecycleRunning,
+		State:    state,
+	}
+}
+
+// StopModeParam - optional mode of stop command
+func StopModeParam() Param {
+	return StringParam("", "keep - оставить заявки, cancel - снять заявки, flatten - снять заявки и закрыть позицию по рынку", nil).
+		WithValues(string(StopKeepOrders), string(StopCancelOrders), string(StopFlatten)).
+		AsOptional()
+}
+
+// Commands - start, stop, pause, resume and reset commands of strategy s (isOnline - online flag of strategy)
+func (l *Lifecycle) Commands(strategy string, s LifecycleHooks, isOnline *bool) CommandDefs {
+	return CommandDefs{
+		{Command: StartCommand, Description: "Старт", Example: "start",
+			Do: func(v ParamValues) (res CommandResult, err *mft.Error) {
+				if st, ok := s.(LifecycleStarter); ok {
+					err = st.OnStart()
+					if err != nil {
+						return res, err
+					}
+				}
+				l.set(LifecycleRunning, "")
+				*isOnline = true
+				return res, nil
+			}},
+		{Command: StopCommand, Description: "Стоп (по умолчанию заявки снимаются)", Example: "stop flatten",
+			Params: []Param{StopModeParam()},
+			Do: func(v ParamValues) (res CommandResult, err *mft.Error) {
+				mode := StopMode(v.String(""))
+				if mode == "" {
+					mode = StopCancelOrders
+				}
+				if _, ok := s.(PositionFlattener); !ok && mode == StopFlatten {
+					return res, GenerateError(500003900, strategy)
+				}
+				*isOnline = false
+				if mode == StopKeepOrders {
+					l.set(LifecycleStopped, "")
+					return res, nil
+				}
+				l.set(LifecycleStopping, mode)
+				return res, nil
+			}},
+		{Command: PauseCommand, Description: "Приостановить (заявки снимаются, позиция остаётся)", Example: "pause",
+			Do: func(v ParamValues) (res CommandResult, err *mft.Error) {
+				if state := l.Current(*isOnline); state != LifecycleRunning {
+					return res, GenerateError(500003901, strategy, PauseCommand, state)
+				}
+				if pr, ok := s.(LifecyclePauser); ok {
+					pr.OnPause()
+				}
+				l.set(LifecyclePaused, "")
+				return res, nil
+			}},
+		{Command: ResumeCommand, Description: "Возобновить после паузы", Example: "resume",
+			Do: func(v ParamValues) (res CommandResult, err *mft.Error) {
+				if state := l.Current(*isOnline); state != LifecyclePaused {
+					return res, GenerateError(500003901, strategy, ResumeCommand, state)
+				}
+				if pr, ok := s.(LifecyclePauser); ok {
+					pr.OnResume()
+				}
+				l.set(LifecycleRunning, "")
+				return res, nil
+			}},
+		{Command: ResetCommand, Description: "Сбросить торговое состояние остановленной стратегии", Example: "reset",
+			Do: func(v ParamValues) (res CommandResult, err *mft.Error) {
+				if state := l.Current(*isOnline); state != LifecycleStopped {
+					return res, GenerateError(500003901, strategy, ResetCommand, state)
+				}
+				if orders := s.ActiveOrders(); len(orders) > 0 {
+					return res, GenerateError(500003902, strategy, len(orders))
+				}
+				r, ok := s.(StateResetter)
+				if !ok {
+					return res, GenerateError(500003903, strategy)
+				}
+				return res, r.ResetState()
+			}},
+	}
+}
+
+// Step - cancels orders of paused and stopping strategy and closes position of strategy stopping with StopFlatten
+// (halt - strategy does not trade on the step)
+func (l *Lifecycle) Step(p StepParams, s LifecycleHooks, meta *MetaForStep) (halt bool, err *mft.Error) {
+	state := l.State
+	if state == "" || state == LifecycleRunning {
+		return false, nil
+	}
+	if state == LifecycleStopped {
+		return true, nil
+	}
+
+	orders := s.ActiveOrders()
+	if !l.Flattening {
+		err = l.cancel(p, orders, meta)
+	}
+	if err != nil || state == LifecyclePaused || len(orders) > 0 {
+		return true, err
+	}
+
+	if l.StopMode == StopFlatten {
+		f, ok := s.(PositionFlattener)
+		if ok {
+			done, err := f.FlattenPosition(p, meta)
+			if err != nil {
+				return true, err
+			}
+			if !done {
+				l.Flattening = true
+				return true, nil
+			}
+		}
+	}
+
+	meta.HasChanges = true
+	meta.OpDescr = append(meta.OpDescr, "Strategy is stopped")
+	l.set(LifecycleStopped, "")
+	return true, nil
+}
+
+// cancel - sends cancel of orders once (orders are canceled when strategy confirms it by status on next steps)
+func (l *Lifecycle) cancel(p StepParams, orders []ActiveOrder, meta *MetaForStep) (err *mft.Error) {
+	sent := make(map[string]bool, len(l.CancelSent))
+	for _, id := range l.CancelSent {
+		sent[id] = true
+	}
+
+	cancelSent := make([]string, 0, len(orders))
+	for _, o := range orders {
+		cancelSent = append(cancelSent, o.OrderId)
+		if sent[o.OrderId] {
+			continue
+		}
+		if o.Buy {
+			_, err = p.CancelBuyOrder(o.InstrumentId, o.Ticker, o.OrderId, &MetaForOperations{NameOfStrategy: meta.Name})
+		} else {
+			_, err = p.CancelSellOrder(o.InstrumentId, o.Ticker, o.OrderId, &MetaForOperations{NameOfStrategy: meta.Name})
+		}
+		if err != nil {
+			return GenerateErrorE(500003904, err, o.OrderId)
+		}
+		meta.HasChanges = true
+		meta.OpDescr = append(meta.OpDescr, "Cancel order "+o.OrderId)
+	}
+
+	if len(cancelSent) == 0 {
+		cancelSent = nil
+	}
+	l.CancelSent = cancelSent
+	return nil
+}
+
+func (l *Lifecycle) set(state LifecycleState, mode StopMode) {
+	l.State = state
+	l.StopMode = mode
+	l.CancelSent = nil
+	l.Flattening = false
+}
+
+// CombineStates - state of container by states of children
+// (stopping - any child is stopping, running - any child is running, paused - any child is paused)
+func CombineStates(states ...LifecycleState) LifecycleState {
+	res := LifecycleStopped
+	for _, state := range states {
+		switch {
+		case state == LifecycleStopping:
+			return LifecycleStopping
+		case state == LifecycleRunning:
+			res = LifecycleRunning
+		case state == LifecyclePaused && res == LifecycleStopped:
+			res = LifecyclePaused
+		}
+	}
+	return res
+}
@@ -0,0 +1,217 @@
+package smp
+
+import (
+	"testing"
+
+	"github.com/myfantasy/mft"
+)
+
+// testLifecycle - strategy with lifecycle, orders are removed by cancel of testCancelParams
+type testLifecycle struct {
+	Lifecycle Lifecycle
+	IsOnline  bool
+	Orders    []ActiveOrder
+	Position  int
+	Flattens  int
+	Resets    int
+}
+
+func (s *testLifecycle) ActiveOrders() []ActiveOrder {
+	return s.Orders
+}
+
+func (s *testLifecycle) FlattenPosition(p StepParams, meta *MetaForStep) (done bool, err *mft.Error) {
+	s.Flattens++
+	if s.Position == 0 {
+		return true, nil
+	}
+	s.Position = 0
+	s.Orders = append(s.Orders, ActiveOrder{OrderId: "flatten"})
+	return false, nil
+}
+
+func (s *testLifecycle) ResetState() (err *mft.Error) {
+	s.Resets++
+	return nil
+}
+
+func (s *testLifecycle) do(cmd Command, params map[string]string) *mft.Error {
+	_, _, err := s.Lifecycle.Commands("test", s, &s.IsOnline).Do("test", cmd, params)
+	return err
+}
+
+// testCancelParams - step params counting cancels (other methods are not used by lifecycle)
+type testCancelParams struct {
+	StepParams
+	Canceled []string
+}
+
+func (p *testCancelParams) CancelBuyOrder(instrumentId string, ticker string, orderId string,
+	meta *MetaForOperations) (ok bool, err *mft.Error) {
+	p.Canceled = append(p.Canceled, orderId)
+	return true, nil
+}
+
+func (p *testCancelParams) CancelSellOrder(instrumentId string, ticker string, orderId string,
+	meta *MetaForOperations) (ok bool, err *mft.Error) {
+	p.Canceled = append(p.Canceled, orderId)
+	return true, nil
+}
+
+func TestLifecycleCommands(t *testing.T) {
+	s := &testLifecycle{}
+	if st := s.Lifecycle.Current(s.IsOnline); st != LifecycleStopped {
+		t.Fatalf("stopped expected, got %v", st)
+	}
+	if err := s.do(PauseCommand, nil); err == nil || err.Code != 500003901 {
+		t.Fatalf("pause of stopped strategy must fail, got %v", err)
+	}
+
+	if err := s.do(StartCommand, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsOnline || s.Lifecycle.Status(s.IsOnline) != (StartegyStatus{IsOnline: true, State: LifecycleRunning}) {
+		t.Fatalf("running expected: %+v", s)
+	}
+	if err := s.do(ResetCommand, nil); err == nil || err.Code != 500003901 {
+		t.Fatalf("reset of running strategy must fail, got %v", err)
+	}
+	if err := s.do(ResumeCommand, nil); err == nil || err.Code != 500003901 {
+		t.Fatalf("resume of running strategy must fail, got %v", err)
+	}
+
+	if err := s.do(PauseCommand, nil); err != nil {
+		t.Fatal(err)
+	}
+	if st := s.Lifecycle.Status(s.IsOnline); st.IsOnline || st.State != LifecyclePaused {
+		t.Fatalf("paused expected: %+v", st)
+	}
+	if err := s.do(ResumeCommand, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.do(StopCommand, map[string]string{"": "keep"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.IsOnline || s.Lifecycle.State != LifecycleStopped {
+		t.Fatalf("stopped expected: %+v", s)
+	}
+
+	s.Orders = []ActiveOrder{{OrderId: "1"}}
+	if err := s.do(ResetCommand, nil); err == nil || err.Code != 500003902 {
+		t.Fatalf("reset with orders must fail, got %v", err)
+	}
+	s.Orders = nil
+	if err := s.do(ResetCommand, nil); err != nil || s.Resets != 1 {
+		t.Fatalf("reset expected: %v %v", err, s.Resets)
+	}
+
+	if err := s.do(StopCommand, map[string]string{"": "now"}); err == nil || err.Code == 500003900 {
+		t.Fatal("wrong stop mode must fail")
+	}
+}
+
+func TestLifecycleNoHooks(t *testing.T) {
+	var l Lifecycle
+	var isOnline bool
+	s := struct{ LifecycleHooks }{LifecycleHooks: &testLifecycle{}}
+	defs := l.Commands("test", s, &isOnline)
+
+	if _, _, err := defs.Do("test", StopCommand, map[string]string{"": "flatten"}); err == nil || err.Code != 500003900 {
+		t.Fatalf("flatten without flattener must fail, got %v", err)
+	}
+	if _, _, err := defs.Do("test", ResetCommand, nil); err == nil || err.Code != 500003903 {
+		t.Fatalf("reset without resetter must fail, got %v", err)
+	}
+}
+
+func TestLifecycleStepCancel(t *testing.T) {
+	p := &testCancelParams{}
+	s := &testLifecycle{Orders: []ActiveOrder{{OrderId: "1", Buy: true}, {OrderId: "2"}}}
+	if err := s.do(StartCommand, nil); err != nil {
+		t.Fatal(err)
+	}
+	meta := &MetaForStep{}
+	if halt, err := s.Lifecycle.Step(p, s, meta); halt || err != nil {
+		t.Fatalf("running strategy must not halt: %v %v", halt, err)
+	}
+
+	if err := s.do(StopCommand, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if halt, err := s.Lifecycle.Step(p, s, meta); !halt || err != nil {
+			t.Fatalf("stopping strategy must halt: %v %v", halt, err)
+		}
+	}
+	if len(p.Canceled) != 2 || s.Lifecycle.State != LifecycleStopping {
+		t.Fatalf("cancel must be sent once for each order: %v %v", p.Canceled, s.Lifecycle.State)
+	}
+
+	s.Orders = s.Orders[1:]
+	if _, err := s.Lifecycle.Step(p, s, meta); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Canceled) != 2 || len(s.Lifecycle.CancelSent) != 1 || s.Lifecycle.State != LifecycleStopping {
+		t.Fatalf("stopping expected until orders are canceled: %v %+v", p.Canceled, s.Lifecycle)
+	}
+
+	s.Orders = nil
+	if _, err := s.Lifecycle.Step(p, s, meta); err != nil {
+		t.Fatal(err)
+	}
+	if s.Lifecycle.State != LifecycleStopped || s.Lifecycle.CancelSent != nil || s.Flattens != 0 {
+		t.Fatalf("stopped expected: %+v", s)
+	}
+}
+
+func TestLifecycleStepFlatten(t *testing.T) {
+	p := &testCancelParams{}
+	s := &testLifecycle{Position: 3}
+	if err := s.do(StartCommand, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.do(StopCommand, map[string]string{"": "flatten"}); err != nil {
+		t.Fatal(err)
+	}
+
+	meta := &MetaForStep{}
+	if _, err := s.Lifecycle.Step(p, s, meta); err != nil {
+		t.Fatal(err)
+	}
+	if s.Position != 0 || !s.Lifecycle.Flattening || s.Lifecycle.State != LifecycleStopping {
+		t.Fatalf("flattening expected: %+v", s)
+	}
+
+	// order closing position is not canceled
+	if _, err := s.Lifecycle.Step(p, s, meta); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Canceled) != 0 || s.Lifecycle.State != LifecycleStopping {
+		t.Fatalf("flatten order must not be canceled: %v %+v", p.Canceled, s)
+	}
+
+	s.Orders = nil
+	if _, err := s.Lifecycle.Step(p, s, meta); err != nil {
+		t.Fatal(err)
+	}
+	if s.Lifecycle.State != LifecycleStopped || s.Lifecycle.Flattening || s.Flattens != 2 {
+		t.Fatalf("stopped expected: %+v", s)
+	}
+}
+
+func TestCombineStates(t *testing.T) {
+	for _, c := range []struct {
+		states []LifecycleState
+		res    LifecycleState
+	}{
+		{nil, LifecycleStopped},
+		{[]LifecycleState{LifecyclePaused, LifecycleStopped}, LifecyclePaused},
+		{[]LifecycleState{LifecyclePaused, LifecycleRunning}, LifecycleRunning},
+		{[]LifecycleState{LifecycleRunning, LifecycleStopping, LifecycleStopped}, LifecycleStopping},
+	} {
+		if res := CombineStates(c.states...); res != c.res {
+			t.Fatalf("%v: %v expected, got %v", c.states, c.res, res)
+		}
+	}
+}
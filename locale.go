@@ -0,0 +1,154 @@
+package smp
+
+import (
+	"sync"
+)
+
+// Каталог текстов стратегий по языкам: описания стратегий, команд и параметров команд.
+// Тексты в коде (Description стратегии, CommandDef.Description, Param.Description) - тексты DefaultLocale,
+// тексты других языков добавляются в GlobalMessageCatalog пакетами стратегий.
+// Если текста нет в каталоге, используется текст из кода.
+
+// Locale - language of texts
+type Locale string
+
+const (
+	LocaleRu Locale = "ru"
+	LocaleEn Locale = "en"
+
+	// DefaultLocale - language of texts in code
+	DefaultLocale = LocaleRu
+)
+
+// PositionalParam - name of positional param (Param.Name "") in MessageKey and errors
+const PositionalParam = "<value>"
+
+// MessageKey - key of text in catalog
+type MessageKey struct {
+	// Strategy - type of strategy ("" - text for all strategies, is used when strategy has no own text)
+	Strategy string
+	// Command - command ("" and Param "" - description of strategy, "" and Param - param of all commands of strategy)
+	Command Command
+	// Param - name of param ("" - description of command, PositionalParam - positional param)
+	Param string
+}
+
+// fallbacks - key and keys used when catalog has no text of key
+func (k MessageKey) fallbacks() []MessageKey {
+	keys := []MessageKey{k}
+	if k.Command != "" && k.Param != "" {
+		keys = append(keys, MessageKey{Strategy: k.Strategy, Param: k.Param})
+	}
+	if k.Strategy != "" && k.Command != "" {
+		keys = append(keys, MessageKey{Command: k.Command, Param: k.Param})
+	}
+	return keys
+}
+
+// CommandMessages - texts of command: description and descriptions of params by names (PositionalParam - positional param)
+type CommandMessages struct {
+	Description string
+	Params      map[string]string
+}
+
+// StrategyMessages - texts of strategy: description, params of all commands by names and texts of commands
+type StrategyMessages struct {
+	Description string
+	Params      map[string]string
+	Commands    map[Command]CommandMessages
+}
+
+// MessageCatalog - texts by languages and keys
+type MessageCatalog struct {
+	Messages map[Locale]map[MessageKey]string
+
+	mx sync.RWMutex
+}
+
+// GlobalMessageCatalog - catalog filled by packages of strategies
+var GlobalMessageCatalog = &MessageCatalog{
+	Messages: map[Locale]map[MessageKey]string{},
+}
+
+// Add - adds text of key in locale
+func (c *MessageCatalog) Add(locale Locale, key MessageKey, text string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.Messages == nil {
+		c.Messages = map[Locale]map[MessageKey]string{}
+	}
+	if c.Messages[locale] == nil {
+		c.Messages[locale] = map[MessageKey]string{}
+	}
+	c.Messages[locale][key] = text
+}
+
+// AddStrategy - adds texts of strategy type tp in locale ("" - texts of commands for all strategies)
+func (c *MessageCatalog) AddStrategy(locale Locale, tp string, m StrategyMessages) {
+	if m.Description != "" {
+		c.Add(locale, MessageKey{Strategy: tp}, m.Description)
+	}
+	for name, text := range m.Params {
+		c.Add(locale, MessageKey{Strategy: tp, Param: name}, text)
+	}
+	for cmd, cm := range m.Commands {
+		if cm.Description != "" {
+			c.Add(locale, MessageKey{Strategy: tp, Command: cmd}, cm.Description)
+		}
+		for name, text := range cm.Params {
+			c.Add(locale, MessageKey{Strategy: tp, Command: cmd, Param: name}, text)
+		}
+	}
+}
+
+// Lookup - text of key in locale (with texts of param for all commands of strategy and texts for all strategies)
+func (c *MessageCatalog) Lookup(locale Locale, key MessageKey) (text string, ok bool) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	m := c.Messages[locale]
+	for _, k := range key.fallbacks() {
+		if text, ok = m[k]; ok {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+// Keys - keys of texts in locale
+func (c *MessageCatalog) Keys(locale Locale) []MessageKey {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	keys := make([]MessageKey, 0, len(c.Messages[locale]))
+	for k := range c.Messages[locale] {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Text - text of key in locale (text - text of DefaultLocale, is returned when catalog has no text of key)
+func (c *MessageCatalog) Text(locale Locale, key MessageKey, text string) string {
+	if locale == "" || locale == DefaultLocale {
+		return text
+	}
+	if t, ok := c.Lookup(locale, key); ok {
+		return t
+	}
+	return text
+}
+
+// Description - description of strategy type tp in locale (text - description in DefaultLocale)
+func (c *MessageCatalog) Description(locale Locale, tp string, text string) string {
+	return c.Text(locale, MessageKey{Strategy: tp}, text)
+}
+
+// Localize - command with descriptions of command and its params in locale
+func (c *MessageCatalog) Localize(locale Locale, tp string, def CommandDef) CommandDef {
+	def.Description = c.Text(locale, MessageKey{Strategy: tp, Command: def.Command}, def.Description)
+	params := make([]Param, 0, len(def.Params))
+	for _, p := range def.Params {
+		p.Description = c.Text(locale, MessageKey{Strategy: tp, Command: def.Command, Param: p.displayName()}, p.Description)
+		params = append(params, p)
+	}
+	def.Params = params
+	return def
+}
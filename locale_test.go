@@ -0,0 +1,72 @@
+package smp
+
+import (
+	"testing"
+)
+
+func TestMessageCatalogText(t *testing.T) {
+	c := &MessageCatalog{}
+	c.AddStrategy(LocaleEn, "", StrategyMessages{
+		Commands: map[Command]CommandMessages{
+			"set_vol": {Description: "common volume", Params: map[string]string{PositionalParam: "common lots"}},
+		},
+	})
+	c.AddStrategy(LocaleEn, "test", StrategyMessages{
+		Description: "test strategy",
+		Params:      map[string]string{"s": "side"},
+		Commands: map[Command]CommandMessages{
+			"set_order": {Description: "order", Params: map[string]string{"p": "price"}},
+		},
+	})
+
+	cases := []struct {
+		key  MessageKey
+		text string
+	}{
+		{MessageKey{Strategy: "test"}, "test strategy"},
+		{MessageKey{Strategy: "test", Command: "set_order"}, "order"},
+		{MessageKey{Strategy: "test", Command: "set_order", Param: "p"}, "price"},
+		// param of all commands of strategy
+		{MessageKey{Strategy: "test", Command: "set_order", Param: "s"}, "side"},
+		// text of all strategies
+		{MessageKey{Strategy: "test", Command: "set_vol"}, "common volume"},
+		{MessageKey{Strategy: "test", Command: "set_vol", Param: PositionalParam}, "common lots"},
+		// text in code
+		{MessageKey{Strategy: "test", Command: "set_order", Param: "m"}, "default"},
+		{MessageKey{Strategy: "other"}, "default"},
+	}
+	for _, cs := range cases {
+		if text := c.Text(LocaleEn, cs.key, "default"); text != cs.text {
+			t.Fatalf("%+v: `%v` expected, got `%v`", cs.key, cs.text, text)
+		}
+	}
+
+	if text := c.Text(DefaultLocale, MessageKey{Strategy: "test"}, "default"); text != "default" {
+		t.Fatalf("text in code expected for default locale, got %v", text)
+	}
+	if text := c.Text("de", MessageKey{Strategy: "test"}, "default"); text != "default" {
+		t.Fatalf("text in code expected for unknown locale, got %v", text)
+	}
+}
+
+func TestCommandDefsAllowCommandsLocale(t *testing.T) {
+	GlobalMessageCatalog.AddStrategy(LocaleEn, "test_locale", StrategyMessages{
+		Commands: map[Command]CommandMessages{
+			"set_vol": {Description: "volume", Params: map[string]string{PositionalParam: "count"}},
+		},
+	})
+
+	tt := &testTarget{}
+	ci := tt.commands().AllowCommands("test_locale", LocaleEn)["set_vol"]
+	if ci.Description != "volume" || ci.Params[0].Description != "count" || ci.ParamsDescription != "count (1 .. 100)" {
+		t.Fatalf("wrong english texts: %+v", ci)
+	}
+	if ci.Example != "set_vol 10" || ci.Params[0].Target != &tt.Volume {
+		t.Fatalf("example and target are not kept: %+v", ci)
+	}
+
+	ci = tt.commands().AllowCommands("test_locale", DefaultLocale)["set_vol"]
+	if ci.Description != "vol" || ci.ParamsDescription != "lots (1 .. 100)" {
+		t.Fatalf("texts in code expected: %+v", ci)
+	}
+}
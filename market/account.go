@@ -0,0 +1,310 @@
+package market
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+// Account - money and positions of simulated market
+type Account struct {
+	// Money - amount by currency
+	Money map[string]float64
+	// Positions - positions by instrument key (see PositionKey)
+	Positions map[string]*smp.Position
+
+	// CheckBuyingPower - reject orders when free margin (money) is not enough
+	// or short is not allowed; close positions forcibly on margin call
+	CheckBuyingPower bool
+	// MaxLeverage - limit of positions value to equity (0 - no limit)
+	MaxLeverage float64
+
+	// BorrowFees - sum of charged fees of short positions
+	BorrowFees float64
+	// Dividends - sum of paid dividends (short positions pay dividends)
+	Dividends float64
+	// MarginCalls - count of margin calls
+	MarginCalls int
+}
+
+func PositionKey(instrumentId string, ticker string) string {
+	return instrumentId + "-" + ticker
+}
+
+func currencyOf(ii *smp.InstrumentInfo) string {
+	if ii == nil {
+		return ""
+	}
+	return ii.Currency
+}
+
+func (a *Account) init() {
+	if a.Money == nil {
+		a.Money = make(map[string]float64)
+	}
+	if a.Positions == nil {
+		a.Positions = make(map[string]*smp.Position)
+	}
+}
+
+// Position - position of instrument (empty position when it does not exist)
+func (a *Account) Position(instrumentId string, ticker string) smp.Position {
+	p, ok := a.Positions[PositionKey(instrumentId, ticker)]
+	if !ok {
+		return smp.Position{
+			InstrumentId: instrumentId,
+			Ticker:       ticker,
+		}
+	}
+	return *p
+}
+
+// Trade - applies executed trade: cnt lots by price of item
+func (a *Account) Trade(ii *smp.InstrumentInfo, instrumentId string, ticker string,
+	buy bool, cnt int, price float64) {
+	a.init()
+	currency := currencyOf(ii)
+	key := PositionKey(instrumentId, ticker)
+	p, ok := a.Positions[key]
+	if !ok {
+		p = &smp.Position{
+			InstrumentId: instrumentId,
+			Ticker:       ticker,
+		}
+		a.Positions[key] = p
+	}
+
+	amount := float64(cnt*ii.Lot()) * price
+	delta := cnt
+	if buy {
+		a.Money[currency] = smp.Round(a.Money[currency]-amount, 6)
+	} else {
+		a.Money[currency] = smp.Round(a.Money[currency]+amount, 6)
+		delta = -cnt
+	}
+
+	q := p.Quantity + delta
+	switch {
+	case q == 0:
+		p.AveragePrice = 0
+	case p.Quantity == 0 || (p.Quantity > 0) != (q > 0):
+		// new position or position is turned over
+		p.AveragePrice = price
+	case (p.Quantity > 0) == buy:
+		// position is increased
+		p.AveragePrice = smp.Round((p.AveragePrice*float64(p.Quantity)+price*float64(delta))/float64(q), 6)
+	}
+	p.Quantity = q
+
+	if p.Quantity == 0 {
+		delete(a.Positions, key)
+	}
+}
+
+// positionsList - positions sorted by key; blocked - blocked lots by key
+func (a *Account) positionsList(blocked map[string]int) []smp.Position {
+	keys := make([]string, 0, len(a.Positions))
+	for k := range a.Positions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	positions := make([]smp.Position, 0, len(keys))
+	for _, k := range keys {
+		p := *a.Positions[k]
+		p.Blocked = blocked[k]
+		positions = append(positions, p)
+	}
+	return positions
+}
+
+// moneyList - money sorted by currency; blocked - blocked money by currency
+func (a *Account) moneyList(blocked map[string]float64) []smp.MoneyBalance {
+	currencies := make([]string, 0, len(a.Money))
+	for c := range a.Money {
+		currencies = append(currencies, c)
+	}
+	for c := range blocked {
+		if _, ok := a.Money[c]; !ok {
+			currencies = append(currencies, c)
+		}
+	}
+	sort.Strings(currencies)
+
+	money := make([]smp.MoneyBalance, 0, len(currencies))
+	for _, c := range currencies {
+		money = append(money, smp.MoneyBalance{
+			Currency: c,
+			Amount:   a.Money[c],
+			Blocked:  smp.Round(blocked[c], 6),
+		})
+	}
+	return money
+}
+
+// blockedFunds - money (margin) and lots blocked by active orders
+type blockedFunds struct {
+	Money map[string]float64
+	// Lots - lots of sell orders
+	Lots map[string]int
+	// BuyLots - lots of buy orders
+	BuyLots map[string]int
+}
+
+func newBlockedFunds() *blockedFunds {
+	return &blockedFunds{
+		Money:   make(map[string]float64),
+		Lots:    make(map[string]int),
+		BuyLots: make(map[string]int),
+	}
+}
+
+// opening - count of lots of order that opens (or increases) position;
+// the rest of order closes position that is not closed by other active orders
+func (bf *blockedFunds) opening(a *Account, instrumentId string, ticker string, buy bool, cnt int) int {
+	key := PositionKey(instrumentId, ticker)
+	q := a.Position(instrumentId, ticker).Quantity
+	closable := 0
+	if buy && q < 0 {
+		closable = -q - bf.BuyLots[key]
+	}
+	if !buy && q > 0 {
+		closable = q - bf.Lots[key]
+	}
+	if closable < 0 {
+		closable = 0
+	}
+	if cnt < closable {
+		return 0
+	}
+	return cnt - closable
+}
+
+func (bf *blockedFunds) Add(a *Account, ii *smp.InstrumentInfo, instrumentId string, ticker string,
+	buy bool, cnt int, price float64) {
+	opening := bf.opening(a, instrumentId, ticker, buy, cnt)
+	bf.Money[currencyOf(ii)] += float64(opening*ii.Lot()) * price * ii.MarginRate(buy)
+	if buy {
+		bf.BuyLots[PositionKey(instrumentId, ticker)] += cnt
+	} else {
+		bf.Lots[PositionKey(instrumentId, ticker)] += cnt
+	}
+}
+
+// Equity - money and value of positions by price (in currency of instrument)
+func (a *Account) Equity(ii *smp.InstrumentInfo, price float64) float64 {
+	equity := a.Money[currencyOf(ii)]
+	for _, p := range a.Positions {
+		equity += float64(p.Quantity*ii.Lot()) * price
+	}
+	return equity
+}
+
+// Exposure - value of all positions (long and short) by price
+func (a *Account) Exposure(ii *smp.InstrumentInfo, price float64) float64 {
+	exposure := 0.0
+	for _, p := range a.Positions {
+		exposure += math.Abs(float64(p.Quantity*ii.Lot()) * price)
+	}
+	return exposure
+}
+
+// Margin - margin of positions by price (initial or maintenance)
+func (a *Account) Margin(ii *smp.InstrumentInfo, price float64, maintenance bool) float64 {
+	margin := 0.0
+	for _, p := range a.Positions {
+		rate := ii.MarginRate(p.Quantity > 0)
+		if maintenance {
+			rate = ii.MaintenanceMarginRate(p.Quantity > 0)
+		}
+		margin += math.Abs(float64(p.Quantity*ii.Lot())*price) * rate
+	}
+	return margin
+}
+
+// FreeMargin - money allowed to open positions (equity without margin of positions and active orders)
+func (a *Account) FreeMargin(bf *blockedFunds, ii *smp.InstrumentInfo, price float64) float64 {
+	return smp.Round(a.Equity(ii, price)-a.Margin(ii, price, false)-bf.Money[currencyOf(ii)], 6)
+}
+
+// IsMarginCall - equity is less than maintenance margin
+func (a *Account) IsMarginCall(ii *smp.InstrumentInfo, price float64) bool {
+	return len(a.Positions) > 0 && a.Equity(ii, price) < a.Margin(ii, price, true)
+}
+
+// ChargeBorrowFee - charges fee of short positions for nights
+func (a *Account) ChargeBorrowFee(ii *smp.InstrumentInfo, price float64, nights int) {
+	if ii == nil || ii.BorrowFeeRate <= 0 || nights <= 0 {
+		return
+	}
+	a.init()
+	currency := currencyOf(ii)
+	for _, p := range a.Positions {
+		if p.Quantity >= 0 {
+			continue
+		}
+		fee := smp.Round(float64(-p.Quantity*ii.Lot())*price*ii.BorrowFeeRate*float64(nights), 6)
+		a.Money[currency] = smp.Round(a.Money[currency]-fee, 6)
+		a.BorrowFees = smp.Round(a.BorrowFees+fee, 6)
+	}
+}
+
+// PayDividend - pays dividend amount of item to position of instrument (short position pays it)
+func (a *Account) PayDividend(ii *smp.InstrumentInfo, instrumentId string, ticker string, amount float64) {
+	a.init()
+	p, ok := a.Positions[PositionKey(instrumentId, ticker)]
+	if !ok || p.Quantity == 0 {
+		return
+	}
+	currency := currencyOf(ii)
+	sum := smp.Round(float64(p.Quantity*ii.Lot())*amount, 6)
+	a.Money[currency] = smp.Round(a.Money[currency]+sum, 6)
+	a.Dividends = smp.Round(a.Dividends+sum, 6)
+}
+
+// payDividends - pays dividends with end of last buy date in (from, to]
+func (a *Account) payDividends(ds smp.Dividends, ii *smp.InstrumentInfo, from time.Time, to time.Time) {
+	for _, d := range ds {
+		end := d.LastDate.Add(smp.H24)
+		if from.Before(end) && !to.Before(end) {
+			a.PayDividend(ii, d.InstrumentId, d.Ticker, d.Amount)
+		}
+	}
+}
+
+// checkOrder - checks that free margin (money) is enough for order, short is allowed and leverage is not exceeded
+// price - price of order, marketPrice - current price of instrument
+func (a *Account) checkOrder(bf *blockedFunds, ii *smp.InstrumentInfo, instrumentId string, ticker string,
+	buy bool, cnt int, price float64, marketPrice float64) *mft.Error {
+	if !a.CheckBuyingPower {
+		return nil
+	}
+
+	opening := bf.opening(a, instrumentId, ticker, buy, cnt)
+	if opening == 0 {
+		return nil
+	}
+	if !buy && (ii == nil || !ii.ShortEnabled) {
+		return mft.ErrorSf("Not enough lots: %v required, %v is free (short is not allowed)", cnt, cnt-opening)
+	}
+
+	currency := currencyOf(ii)
+	amount := float64(opening*ii.Lot()) * price
+	required := amount * ii.MarginRate(buy)
+	free := a.FreeMargin(bf, ii, marketPrice)
+	if required > free {
+		return mft.ErrorSf("Not enough money: %v %v required, %v %v is free", required, currency, free, currency)
+	}
+
+	if a.MaxLeverage > 0 {
+		equity := a.Equity(ii, marketPrice)
+		exposure := a.Exposure(ii, marketPrice) + amount
+		if exposure > equity*a.MaxLeverage {
+			return mft.ErrorSf("Leverage limit %v is exceeded: exposure %v, equity %v", a.MaxLeverage, exposure, equity)
+		}
+	}
+	return nil
+}
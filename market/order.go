@@ -0,0 +1,164 @@
+package market
+
+import (
+	"math"
+	"time"
+
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+// Order - order inside simulated market
+type Order struct {
+	Id           string
+	InstrumentId string
+	Ticker       string
+
+	Buy  bool
+	Type smp.OrderType
+	Cnt  int
+	// Price - price of limit order (and stop limit order after trigger)
+	Price float64
+	// StopPrice - trigger price (current trigger price for trailing stop)
+	StopPrice float64
+	// Trail - distance between extreme price and trigger price for trailing stop
+	Trail float64
+	// Extreme - max price (sell) or min price (buy) after trailing stop placement
+	Extreme float64
+	// Oco - other order of OCO pair (nil - order is not in pair)
+	Oco *Order
+
+	Time time.Time
+
+	Status smp.StatusOrder
+	Prices []smp.LotPrices
+}
+
+// IsStop - order waits trigger
+func (o *Order) IsStop() bool {
+	return o.Type == smp.StopMarketOrder || o.Type == smp.StopLimitOrder || o.Type == smp.TrailingStopOrder
+}
+
+// Filled - count of executed lots
+func (o *Order) Filled() int {
+	cnt := 0
+	for _, p := range o.Prices {
+		cnt += p.Count
+	}
+	return cnt
+}
+
+// Rest - count of not executed lots
+func (o *Order) Rest() int {
+	return o.Cnt - o.Filled()
+}
+
+// Trigger checks stop condition inside the candle (by High/Low)
+// returns execution price for stop market order; stop limit order becomes limit order
+func (o *Order) Trigger(c smp.Candle) (triggered bool, price float64) {
+	switch o.Type {
+	case smp.StopMarketOrder, smp.StopLimitOrder:
+		if o.Buy {
+			if c.High >= o.StopPrice {
+				triggered, price = true, math.Max(o.StopPrice, c.Open)
+			}
+		} else {
+			if c.Low <= o.StopPrice {
+				triggered, price = true, math.Min(o.StopPrice, c.Open)
+			}
+		}
+	case smp.TrailingStopOrder:
+		if o.Buy {
+			if o.Extreme == 0 {
+				o.Extreme = c.Open
+			}
+			o.StopPrice = o.Extreme + o.Trail
+			if c.High >= o.StopPrice {
+				return true, math.Max(o.StopPrice, c.Open)
+			}
+			o.Extreme = math.Min(o.Extreme, c.Low)
+			o.StopPrice = o.Extreme + o.Trail
+			// Close is after Low so price has already come back by trail
+			if c.Close >= o.StopPrice {
+				return true, o.StopPrice
+			}
+		} else {
+			if o.Extreme == 0 {
+				o.Extreme = c.Open
+			}
+			o.StopPrice = o.Extreme - o.Trail
+			if c.Low <= o.StopPrice {
+				return true, math.Min(o.StopPrice, c.Open)
+			}
+			o.Extreme = math.Max(o.Extreme, c.High)
+			o.StopPrice = o.Extreme - o.Trail
+			// Close is after High so price has already come back by trail
+			if c.Close <= o.StopPrice {
+				return true, o.StopPrice
+			}
+		}
+		return false, 0
+	}
+
+	if triggered && o.Type == smp.StopLimitOrder {
+		o.Type = smp.LimitOrder
+	}
+
+	return triggered, price
+}
+
+// State - order state for OrderStateEvent
+func (o *Order) State() *smp.OrderState {
+	prices := make([]smp.LotPrices, len(o.Prices))
+	copy(prices, o.Prices)
+	return &smp.OrderState{
+		OrderId: o.Id,
+		Buy:     o.Buy,
+		Type:    o.Type,
+		Status:  o.Status,
+		Prices:  prices,
+	}
+}
+
+// orderEvent - OrderStateEvent of order o
+func orderEvent(o *Order, tm time.Time) smp.Event {
+	return smp.Event{
+		Type:         smp.OrderStateEvent,
+		InstrumentId: o.InstrumentId,
+		Ticker:       o.Ticker,
+		Time:         tm,
+		OrderState:   o.State(),
+	}
+}
+
+// candleEvents - market events of new candle c with order book ob (prev - previous order book)
+func candleEvents(prev *smp.OrderBook, ob *smp.OrderBook, c smp.Candle) (events []smp.Event) {
+	if prev == nil || prev.TradeStatus != ob.TradeStatus {
+		events = append(events, smp.Event{
+			Type:         smp.TradingStatusEvent,
+			InstrumentId: c.InstrumentId,
+			Ticker:       c.Ticker,
+			Time:         c.Date,
+			TradeStatus:  ob.TradeStatus,
+		})
+	}
+	events = append(events, smp.Event{
+		Type:         smp.OrderBookEvent,
+		InstrumentId: c.InstrumentId,
+		Ticker:       c.Ticker,
+		Time:         c.Date,
+		OrderBook:    ob,
+	})
+	if c.Vol > 0 {
+		events = append(events, smp.Event{
+			Type:         smp.TradeEvent,
+			InstrumentId: c.InstrumentId,
+			Ticker:       c.Ticker,
+			Time:         c.Date,
+			Trade: &smp.Trade{
+				Price:    c.Close,
+				Quantity: c.Vol,
+			},
+		})
+	}
+	return events
+}
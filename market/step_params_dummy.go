@@ -4,15 +4,23 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/myfantasy/mfs"
 	"github.com/myfantasy/mft"
 	smp "github.com/myfantasy/stock_market_primitives"
 )
 
 var (
-	_ smp.StepParams = &StepParamsDummy{}
+	_ smp.StepParams             = &StepParamsDummy{}
+	_ smp.StopOrderStepParams    = &StepParamsDummy{}
+	_ smp.ReplaceOrderStepParams = &StepParamsDummy{}
+	_ smp.EventStepParams        = &StepParamsDummy{}
+	_ smp.PortfolioStepParams    = &StepParamsDummy{}
 )
 
 type Action struct {
+	InstrumentId string
+	Ticker       string
+
 	Buy   bool
 	Sell  bool
 	Time  time.Time
@@ -27,94 +35,276 @@ type StepParamsDummy struct {
 	InstrumentInfo *smp.InstrumentInfo
 	Position       int
 
+	Account Account
 	Actions []Action
+	// Dividends - dividends paid to Account (by end of last buy date)
+	Dividends smp.Dividends
 
 	nextId      int
 	waitActions map[string]Action
+	stopOrders  map[string]*Order
+	// filled - executed market and stop orders waiting status request
+	filled map[string]Action
+
+	events smp.EventBus
+	// outbox - events published after unlock
+	outbox []smp.Event
+
+	mx mfs.PMutex
+}
+
+// lock - market is used by strategies at the same time (Composite in parallel mode)
+func (sp *StepParamsDummy) lock() {
+	sp.mx.Lock()
+}
+
+// unlock - unlocks market and publishes events (handlers can use market)
+func (sp *StepParamsDummy) unlock() {
+	events := sp.outbox
+	sp.outbox = nil
+	sp.mx.Unlock()
+	for _, e := range events {
+		sp.events.Publish(e)
+	}
+}
+
+func (sp *StepParamsDummy) trade(a Action) {
+	sp.Actions = append(sp.Actions, a)
+	sp.Account.Trade(sp.InstrumentInfo, a.InstrumentId, a.Ticker, a.Buy, a.Cnt, a.Price)
+}
+
+// blocked - money and lots blocked by waiting limit orders
+func (sp *StepParamsDummy) blocked() *blockedFunds {
+	bf := newBlockedFunds()
+	for _, a := range sp.waitActions {
+		bf.Add(&sp.Account, sp.InstrumentInfo, a.InstrumentId, a.Ticker, a.Buy, a.Cnt, a.Price)
+	}
+	return bf
+}
+
+func (sp *StepParamsDummy) GetPositions() (positions []smp.Position, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
+	return sp.Account.positionsList(sp.blocked().Lots), nil
+}
+func (sp *StepParamsDummy) GetPosition(instrumentId string, ticker string) (position smp.Position, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
+	position = sp.Account.Position(instrumentId, ticker)
+	position.Blocked = sp.blocked().Lots[PositionKey(instrumentId, ticker)]
+	return position, nil
+}
+func (sp *StepParamsDummy) GetMoney() (money []smp.MoneyBalance, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
+	return sp.Account.moneyList(sp.blocked().Money), nil
+}
+func (sp *StepParamsDummy) GetBuyingPower(currency string) (amount float64, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
+	if currency == currencyOf(sp.InstrumentInfo) {
+		return sp.Account.FreeMargin(sp.blocked(), sp.InstrumentInfo, sp.price()), nil
+	}
+	return smp.Round(sp.Account.Money[currency]-sp.blocked().Money[currency], 6), nil
+}
+
+// price - current price of instrument
+func (sp *StepParamsDummy) price() float64 {
+	if sp.OrderBook == nil {
+		return 0
+	}
+	return sp.OrderBook.Price()
+}
+
+func (sp *StepParamsDummy) Subscribe(filter smp.EventFilter, handler smp.EventHandler) (sub *smp.Subscription, err *mft.Error) {
+	return sp.events.Subscribe(filter, handler)
 }
 
 func (sp *StepParamsDummy) GetCandles(instrumentId string, ticker string, dateFrom time.Time, dateTo time.Time) (cs smp.Candles, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
 	return sp.Candles[0:sp.Position].After(dateFrom).Before(dateTo).Before(sp.OrderBook.Time).Clone(),
 		nil
 }
 func (sp *StepParamsDummy) GetOrderBook(instrumentId string, ticker string) (ob *smp.OrderBook, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
 	return sp.OrderBook, nil
 }
 func (sp *StepParamsDummy) GetInstrumentInfo(instrumentId string, ticker string) (instrumentInfo *smp.InstrumentInfo, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
 	return sp.InstrumentInfo, nil
 }
 
+// marketOrder - executes market order at once by price of next order book
+func (sp *StepParamsDummy) marketOrder(a Action) (orderId string, err *mft.Error) {
+	if sp.filled == nil {
+		sp.filled = make(map[string]Action)
+	}
+	sp.trade(a)
+	sp.nextId++
+	orderId = strconv.Itoa(sp.nextId)
+	sp.filled[orderId] = a
+	return orderId, nil
+}
+
 func (sp *StepParamsDummy) BuyByMarket(instrumentId string, ticker string, cnt int,
 	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
-	sp.Actions = append(sp.Actions, Action{
-		Buy:   true,
-		Cnt:   cnt,
-		Price: sp.OrderBookNext.BuyPrice(),
-		Time:  sp.OrderBookNext.Time,
+	sp.lock()
+	defer sp.unlock()
+	return sp.marketOrder(Action{
+		InstrumentId: instrumentId,
+		Ticker:       ticker,
+		Buy:          true,
+		Cnt:          cnt,
+		Price:        sp.OrderBookNext.BuyPrice(),
+		Time:         sp.OrderBookNext.Time,
 	})
-	return "dummy_order_id", nil
 }
 func (sp *StepParamsDummy) SellByMarket(instrumentId string, ticker string, cnt int,
 	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
-	sp.Actions = append(sp.Actions, Action{
-		Sell:  true,
-		Cnt:   cnt,
-		Price: sp.OrderBookNext.SellPrice(),
-		Time:  sp.OrderBookNext.Time,
+	sp.lock()
+	defer sp.unlock()
+	return sp.marketOrder(Action{
+		InstrumentId: instrumentId,
+		Ticker:       ticker,
+		Sell:         true,
+		Cnt:          cnt,
+		Price:        sp.OrderBookNext.SellPrice(),
+		Time:         sp.OrderBookNext.Time,
 	})
-	return "dummy_order_id", nil
 }
 
 func (sp *StepParamsDummy) BuyByPrice(instrumentId string, ticker string, cnt int, price float64,
 	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
+	err = sp.Account.checkOrder(sp.blocked(), sp.InstrumentInfo, instrumentId, ticker, true, cnt, price, sp.price())
+	if err != nil {
+		return "", err
+	}
 	sp.nextId++
 	orderId = strconv.Itoa(sp.nextId)
 	sp.waitActions[orderId] = Action{
-		Buy:   true,
-		Cnt:   cnt,
-		Price: price,
-		Time:  sp.OrderBook.Time,
+		InstrumentId: instrumentId,
+		Ticker:       ticker,
+		Buy:          true,
+		Cnt:          cnt,
+		Price:        price,
+		Time:         sp.OrderBook.Time,
 	}
 	return orderId, nil
 }
 func (sp *StepParamsDummy) SellByPrice(instrumentId string, ticker string, cnt int, price float64,
 	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
+	err = sp.Account.checkOrder(sp.blocked(), sp.InstrumentInfo, instrumentId, ticker, false, cnt, price, sp.price())
+	if err != nil {
+		return "", err
+	}
 	sp.nextId++
 	orderId = strconv.Itoa(sp.nextId)
 	sp.waitActions[orderId] = Action{
-		Sell:  true,
-		Cnt:   cnt,
-		Price: price,
-		Time:  sp.OrderBook.Time,
+		InstrumentId: instrumentId,
+		Ticker:       ticker,
+		Sell:         true,
+		Cnt:          cnt,
+		Price:        price,
+		Time:         sp.OrderBook.Time,
 	}
 	return orderId, nil
 }
 
 func (sp *StepParamsDummy) CancelBuyOrder(instrumentId string, ticker string, orderId string,
 	meta *smp.MetaForOperations) (ok bool, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
 	_, ok = sp.waitActions[orderId]
 	if ok {
 		delete(sp.waitActions, orderId)
 		return true, nil
 	}
+	_, ok = sp.stopOrders[orderId]
+	if ok {
+		delete(sp.stopOrders, orderId)
+		return true, nil
+	}
 	return false, mft.ErrorS("Not found")
 }
 func (sp *StepParamsDummy) CancelSellOrder(instrumentId string, ticker string, orderId string,
 	meta *smp.MetaForOperations) (ok bool, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
 	_, ok = sp.waitActions[orderId]
 	if ok {
 		delete(sp.waitActions, orderId)
 		return true, nil
 	}
+	_, ok = sp.stopOrders[orderId]
+	if ok {
+		delete(sp.stopOrders, orderId)
+		return true, nil
+	}
 	return false, mft.ErrorS("Not found")
 }
 
+func (sp *StepParamsDummy) replaceOrder(orderId string, buy bool, cnt int, price float64) (newOrderId string, err *mft.Error) {
+	a, ok := sp.waitActions[orderId]
+	if !ok || a.Buy != buy {
+		return "", mft.ErrorS("Not found")
+	}
+	delete(sp.waitActions, orderId)
+
+	// limit orders of StepParamsDummy are executed at once so nothing is executed before replace
+	if cnt <= 0 {
+		return "", nil
+	}
+	sp.nextId++
+	newOrderId = strconv.Itoa(sp.nextId)
+	a.Cnt = cnt
+	a.Price = price
+	a.Time = sp.OrderBook.Time
+	sp.waitActions[newOrderId] = a
+	return newOrderId, nil
+}
+
+func (sp *StepParamsDummy) ReplaceBuyOrder(instrumentId string, ticker string, orderId string, cnt int, price float64,
+	meta *smp.MetaForOperations) (newOrderId string, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
+	return sp.replaceOrder(orderId, true, cnt, price)
+}
+func (sp *StepParamsDummy) ReplaceSellOrder(instrumentId string, ticker string, orderId string, cnt int, price float64,
+	meta *smp.MetaForOperations) (newOrderId string, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
+	return sp.replaceOrder(orderId, false, cnt, price)
+}
+
 func (sp *StepParamsDummy) StatusBuyOrder(instrumentId string, ticker string, orderId string,
 	meta *smp.MetaForOperations) (status smp.StatusOrder, prices []smp.LotPrices, err *mft.Error) {
-	if orderId == "dummy_order_id" {
+	sp.lock()
+	defer sp.unlock()
+	return sp.statusOrder(orderId)
+}
+func (sp *StepParamsDummy) StatusSellOrder(instrumentId string, ticker string, orderId string,
+	meta *smp.MetaForOperations) (status smp.StatusOrder, prices []smp.LotPrices, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
+	return sp.statusOrder(orderId)
+}
+
+func (sp *StepParamsDummy) statusOrder(orderId string) (status smp.StatusOrder, prices []smp.LotPrices, err *mft.Error) {
+	if _, ok := sp.stopOrders[orderId]; ok {
+		return smp.Wait, make([]smp.LotPrices, 0), nil
+	}
+	if a, ok := sp.filled[orderId]; ok {
+		delete(sp.filled, orderId)
 		return smp.Complete, []smp.LotPrices{{
-			Count: 1,
-			Price: sp.OrderBook.Price(),
+			Count: a.Cnt,
+			Price: a.Price,
 		},
 		}, nil
 	}
@@ -131,7 +321,7 @@ func (sp *StepParamsDummy) StatusBuyOrder(instrumentId string, ticker string, or
 			if sp.OrderBook.BuyPrice() <= a.Price {
 				delete(sp.waitActions, orderId)
 				a.Time = sp.OrderBook.Time
-				sp.Actions = append(sp.Actions, a)
+				sp.trade(a)
 				return smp.Complete, []smp.LotPrices{{
 					Count: a.Cnt,
 					Price: a.Price,
@@ -143,7 +333,7 @@ func (sp *StepParamsDummy) StatusBuyOrder(instrumentId string, ticker string, or
 			if sp.OrderBook.SellPrice() >= a.Price {
 				delete(sp.waitActions, orderId)
 				a.Time = sp.OrderBook.Time
-				sp.Actions = append(sp.Actions, a)
+				sp.trade(a)
 				return smp.Complete, []smp.LotPrices{{
 					Count: a.Cnt,
 					Price: a.Price,
@@ -155,15 +345,22 @@ func (sp *StepParamsDummy) StatusBuyOrder(instrumentId string, ticker string, or
 	}
 	return smp.Canceled, make([]smp.LotPrices, 0), nil
 }
-func (sp *StepParamsDummy) StatusSellOrder(instrumentId string, ticker string, orderId string,
-	meta *smp.MetaForOperations) (status smp.StatusOrder, prices []smp.LotPrices, err *mft.Error) {
-	return sp.StatusBuyOrder(instrumentId, ticker, orderId, meta)
+func (sp *StepParamsDummy) DoStep() bool {
+	sp.lock()
+	defer sp.unlock()
+	return sp.doStep()
 }
 
-func (sp *StepParamsDummy) DoStep() bool {
+func (sp *StepParamsDummy) doStep() bool {
 	if sp.waitActions == nil {
 		sp.waitActions = make(map[string]Action)
 	}
+	if sp.stopOrders == nil {
+		sp.stopOrders = make(map[string]*Order)
+	}
+	if sp.filled == nil {
+		sp.filled = make(map[string]Action)
+	}
 
 	if sp.Position >= sp.Candles.Len()-1 {
 		return false
@@ -173,7 +370,115 @@ func (sp *StepParamsDummy) DoStep() bool {
 		return false
 	}
 
+	prev := sp.OrderBook
 	sp.OrderBook = sp.Candles[sp.Position].OrderBook()
 	sp.OrderBookNext = sp.Candles[sp.Position+1].OrderBook()
+	if prev != nil {
+		sp.Account.payDividends(sp.Dividends, sp.InstrumentInfo, prev.Time, sp.OrderBook.Time)
+	}
+	events := sp.triggerStopOrders(sp.Candles[sp.Position])
+
+	events = append(events, candleEvents(prev, sp.OrderBook, sp.Candles[sp.Position])...)
+	sp.outbox = append(sp.outbox, events...)
 	return true
 }
+
+func (sp *StepParamsDummy) placeStopOrder(o *Order) (orderId string, err *mft.Error) {
+	sp.nextId++
+	o.Id = strconv.Itoa(sp.nextId)
+	o.Time = sp.OrderBook.Time
+	if o.Type == smp.TrailingStopOrder {
+		o.Extreme = sp.OrderBook.Price()
+	}
+	sp.stopOrders[o.Id] = o
+	return o.Id, nil
+}
+
+func (sp *StepParamsDummy) BuyStopByMarket(instrumentId string, ticker string, cnt int, stopPrice float64,
+	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
+	return sp.placeStopOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Buy: true, Type: smp.StopMarketOrder, Cnt: cnt, StopPrice: stopPrice,
+	})
+}
+func (sp *StepParamsDummy) SellStopByMarket(instrumentId string, ticker string, cnt int, stopPrice float64,
+	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
+	return sp.placeStopOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Type: smp.StopMarketOrder, Cnt: cnt, StopPrice: stopPrice,
+	})
+}
+func (sp *StepParamsDummy) BuyStopByPrice(instrumentId string, ticker string, cnt int, stopPrice float64, price float64,
+	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
+	return sp.placeStopOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Buy: true, Type: smp.StopLimitOrder, Cnt: cnt, StopPrice: stopPrice, Price: price,
+	})
+}
+func (sp *StepParamsDummy) SellStopByPrice(instrumentId string, ticker string, cnt int, stopPrice float64, price float64,
+	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
+	return sp.placeStopOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Type: smp.StopLimitOrder, Cnt: cnt, StopPrice: stopPrice, Price: price,
+	})
+}
+func (sp *StepParamsDummy) BuyTrailingStop(instrumentId string, ticker string, cnt int, trail float64,
+	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
+	return sp.placeStopOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Buy: true, Type: smp.TrailingStopOrder, Cnt: cnt, Trail: trail,
+	})
+}
+func (sp *StepParamsDummy) SellTrailingStop(instrumentId string, ticker string, cnt int, trail float64,
+	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	sp.lock()
+	defer sp.unlock()
+	return sp.placeStopOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Type: smp.TrailingStopOrder, Cnt: cnt, Trail: trail,
+	})
+}
+
+// triggerStopOrders - checks stop orders inside candle c
+// stop market orders are executed immediately, stop limit orders become usual limit orders
+func (sp *StepParamsDummy) triggerStopOrders(c smp.Candle) (events []smp.Event) {
+	for id, o := range sp.stopOrders {
+		triggered, price := o.Trigger(c)
+		if !triggered {
+			continue
+		}
+		delete(sp.stopOrders, id)
+
+		a := Action{
+			InstrumentId: o.InstrumentId,
+			Ticker:       o.Ticker,
+			Buy:          o.Buy,
+			Sell:         !o.Buy,
+			Cnt:          o.Cnt,
+			Price:        price,
+			Time:         c.Date,
+		}
+		if o.Type == smp.LimitOrder {
+			a.Price = o.Price
+			sp.waitActions[id] = a
+			continue
+		}
+		sp.trade(a)
+		sp.filled[id] = a
+
+		o.Status = smp.Complete
+		o.Prices = []smp.LotPrices{{Count: a.Cnt, Price: a.Price}}
+		events = append(events, orderEvent(o, c.Date))
+	}
+	return events
+}
@@ -1,67 +1,450 @@
 package market
 
 import (
+	"math"
+	"strconv"
 	"time"
 
+	"github.com/myfantasy/mfs"
 	"github.com/myfantasy/mft"
 	smp "github.com/myfantasy/stock_market_primitives"
 )
 
 var (
-	_ smp.StepParams = &VirtualMarket{}
+	_ smp.StepParams             = &VirtualMarket{}
+	_ smp.StopOrderStepParams    = &VirtualMarket{}
+	_ smp.ReplaceOrderStepParams = &VirtualMarket{}
+	_ smp.OcoStepParams          = &VirtualMarket{}
+	_ smp.EventStepParams        = &VirtualMarket{}
+	_ smp.PortfolioStepParams    = &VirtualMarket{}
 )
 
+// VirtualMarket - candle based market simulator
+// orders are executed on the next candles (DoStep):
+// market orders by Open, limit orders when Low (buy) or High (sell) reaches the price
+// (not more than candle volume), stop orders are triggered by High/Low
+// (stop order of OCO pair is executed when candle reaches both prices of pair);
+// events of the candle are published after orders execution;
+// executed orders change Account (money and lots are blocked by active orders, stop orders
+// are checked when placed and when triggered),
+// fee of short positions is charged for each night, dividends are paid by end of last buy date,
+// positions are closed on margin call;
+// market is safe for concurrent use (Composite in parallel mode)
 type VirtualMarket struct {
 	Candles        smp.Candles
 	OrderBook      *smp.OrderBook
 	InstrumentInfo *smp.InstrumentInfo
 	Position       int
+
+	Account Account
+	Actions []Action
+	// Dividends - dividends paid to Account (by end of last buy date)
+	Dividends smp.Dividends
+
+	nextId int
+	orders map[string]*Order
+	active []*Order
+
+	events  smp.EventBus
+	pending []smp.Event
+	// outbox - events published after unlock
+	outbox []smp.Event
+
+	mx mfs.PMutex
+}
+
+// lock - market is used by strategies at the same time (Composite in parallel mode)
+func (vm *VirtualMarket) lock() {
+	vm.mx.Lock()
+}
+
+// unlock - unlocks market and publishes events (handlers can use market)
+func (vm *VirtualMarket) unlock() {
+	events := vm.outbox
+	vm.outbox = nil
+	vm.mx.Unlock()
+	for _, e := range events {
+		vm.events.Publish(e)
+	}
+}
+
+// publish - event is published after unlock
+func (vm *VirtualMarket) publish(e smp.Event) {
+	vm.outbox = append(vm.outbox, e)
 }
 
 func (vm *VirtualMarket) GetCandles(instrumentId string, ticker string, dateFrom time.Time, dateTo time.Time) (cs smp.Candles, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
 	return vm.Candles[0:vm.Position].After(dateFrom).Before(dateTo).Before(vm.OrderBook.Time).Clone(),
 		nil
 }
 func (vm *VirtualMarket) GetOrderBook(instrumentId string, ticker string) (ob *smp.OrderBook, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
 	return vm.OrderBook, nil
 }
 func (vm *VirtualMarket) GetInstrumentInfo(instrumentId string, ticker string) (instrumentInfo *smp.InstrumentInfo, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
 	return vm.InstrumentInfo, nil
 }
+
+func (vm *VirtualMarket) Subscribe(filter smp.EventFilter, handler smp.EventHandler) (sub *smp.Subscription, err *mft.Error) {
+	return vm.events.Subscribe(filter, handler)
+}
+
+func (vm *VirtualMarket) now() time.Time {
+	if vm.OrderBook == nil {
+		return time.Time{}
+	}
+	return vm.OrderBook.Time
+}
+
+// blocked - money and lots blocked by active orders (stop orders are blocked by their prices,
+// OCO pair is blocked once by its order by price)
+func (vm *VirtualMarket) blocked() *blockedFunds {
+	return vm.blockedExcept(nil)
+}
+
+// blockedExcept - money and lots blocked by active orders except order except and its OCO pair
+func (vm *VirtualMarket) blockedExcept(except *Order) *blockedFunds {
+	bf := newBlockedFunds()
+	for _, o := range vm.active {
+		if o.Status != smp.Wait || except != nil && (o == except || o == except.Oco) {
+			continue
+		}
+		if o.IsStop() && o.Oco != nil && o.Oco.Status == smp.Wait {
+			continue
+		}
+		if price, ok := vm.orderPrice(o); ok {
+			bf.Add(&vm.Account, vm.InstrumentInfo, o.InstrumentId, o.Ticker, o.Buy, o.Rest(), price)
+		}
+	}
+	return bf
+}
+
+// orderPrice - expected price of order to check and block money
+// (market order by order book, stop order by its trigger price or limit price)
+func (vm *VirtualMarket) orderPrice(o *Order) (price float64, ok bool) {
+	switch o.Type {
+	case smp.MarketOrder:
+		if vm.OrderBook == nil {
+			return 0, false
+		}
+		return vm.OrderBook.BuyPrice(), true
+	case smp.LimitOrder, smp.StopLimitOrder:
+		return o.Price, true
+	case smp.StopMarketOrder:
+		return o.StopPrice, true
+	case smp.TrailingStopOrder:
+		if o.Buy {
+			return o.Extreme + o.Trail, true
+		}
+		return math.Max(o.Extreme-o.Trail, 0), true
+	}
+	return 0, false
+}
+
+func (vm *VirtualMarket) GetPositions() (positions []smp.Position, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
+	return vm.Account.positionsList(vm.blocked().Lots), nil
+}
+func (vm *VirtualMarket) GetPosition(instrumentId string, ticker string) (position smp.Position, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
+	position = vm.Account.Position(instrumentId, ticker)
+	position.Blocked = vm.blocked().Lots[PositionKey(instrumentId, ticker)]
+	return position, nil
+}
+func (vm *VirtualMarket) GetMoney() (money []smp.MoneyBalance, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
+	return vm.Account.moneyList(vm.blocked().Money), nil
+}
+func (vm *VirtualMarket) GetBuyingPower(currency string) (amount float64, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
+	if currency == currencyOf(vm.InstrumentInfo) {
+		return vm.Account.FreeMargin(vm.blocked(), vm.InstrumentInfo, vm.price()), nil
+	}
+	return smp.Round(vm.Account.Money[currency]-vm.blocked().Money[currency], 6), nil
+}
+
+// price - current price of instrument
+func (vm *VirtualMarket) price() float64 {
+	if vm.OrderBook == nil {
+		return 0
+	}
+	return vm.OrderBook.Price()
+}
+
+func (vm *VirtualMarket) placeOrder(o *Order) (orderId string, err *mft.Error) {
+	if o.Cnt <= 0 {
+		return "", mft.ErrorSf("Wrong count %v", o.Cnt)
+	}
+	if o.Type == smp.TrailingStopOrder && vm.OrderBook != nil {
+		o.Extreme = vm.OrderBook.Price()
+	}
+	if price, ok := vm.orderPrice(o); ok {
+		err = vm.Account.checkOrder(vm.blockedExcept(o), vm.InstrumentInfo, o.InstrumentId, o.Ticker, o.Buy, o.Cnt, price, vm.price())
+		if err != nil {
+			return "", err
+		}
+	}
+	if vm.orders == nil {
+		vm.orders = make(map[string]*Order)
+	}
+	vm.nextId++
+	o.Id = strconv.Itoa(vm.nextId)
+	o.Status = smp.Wait
+	o.Prices = make([]smp.LotPrices, 0)
+	if vm.OrderBook != nil {
+		o.Time = vm.OrderBook.Time
+	}
+	vm.orders[o.Id] = o
+	vm.active = append(vm.active, o)
+	vm.publish(orderEvent(o, vm.now()))
+	return o.Id, nil
+}
+
 func (vm *VirtualMarket) BuyByMarket(instrumentId string, ticker string, cnt int,
 	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
-	return "", nil
+	vm.lock()
+	defer vm.unlock()
+	return vm.placeOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Buy: true, Type: smp.MarketOrder, Cnt: cnt,
+	})
 }
 func (vm *VirtualMarket) SellByMarket(instrumentId string, ticker string, cnt int,
 	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
-	return "", nil
+	vm.lock()
+	defer vm.unlock()
+	return vm.placeOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Type: smp.MarketOrder, Cnt: cnt,
+	})
 }
 func (vm *VirtualMarket) BuyByPrice(instrumentId string, ticker string, cnt int, price float64,
 	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
-	return "", nil
+	vm.lock()
+	defer vm.unlock()
+	return vm.placeOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Buy: true, Type: smp.LimitOrder, Cnt: cnt, Price: price,
+	})
 }
 func (vm *VirtualMarket) SellByPrice(instrumentId string, ticker string, cnt int, price float64,
 	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
-	return "", nil
+	vm.lock()
+	defer vm.unlock()
+	return vm.placeOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Type: smp.LimitOrder, Cnt: cnt, Price: price,
+	})
 }
+func (vm *VirtualMarket) BuyStopByMarket(instrumentId string, ticker string, cnt int, stopPrice float64,
+	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
+	return vm.placeOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Buy: true, Type: smp.StopMarketOrder, Cnt: cnt, StopPrice: stopPrice,
+	})
+}
+func (vm *VirtualMarket) SellStopByMarket(instrumentId string, ticker string, cnt int, stopPrice float64,
+	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
+	return vm.placeOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Type: smp.StopMarketOrder, Cnt: cnt, StopPrice: stopPrice,
+	})
+}
+func (vm *VirtualMarket) BuyStopByPrice(instrumentId string, ticker string, cnt int, stopPrice float64, price float64,
+	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
+	return vm.placeOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Buy: true, Type: smp.StopLimitOrder, Cnt: cnt, StopPrice: stopPrice, Price: price,
+	})
+}
+func (vm *VirtualMarket) SellStopByPrice(instrumentId string, ticker string, cnt int, stopPrice float64, price float64,
+	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
+	return vm.placeOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Type: smp.StopLimitOrder, Cnt: cnt, StopPrice: stopPrice, Price: price,
+	})
+}
+func (vm *VirtualMarket) BuyTrailingStop(instrumentId string, ticker string, cnt int, trail float64,
+	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
+	return vm.placeOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Buy: true, Type: smp.TrailingStopOrder, Cnt: cnt, Trail: trail,
+	})
+}
+func (vm *VirtualMarket) SellTrailingStop(instrumentId string, ticker string, cnt int, trail float64,
+	meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
+	return vm.placeOrder(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Type: smp.TrailingStopOrder, Cnt: cnt, Trail: trail,
+	})
+}
+
+// placeOco - places stop order and order by price of OCO pair
+// (stop order is placed first and is executed first inside candle)
+func (vm *VirtualMarket) placeOco(o *Order, stop *Order) (orderId string, stopOrderId string, err *mft.Error) {
+	o.Oco, stop.Oco = stop, o
+	stopOrderId, err = vm.placeOrder(stop)
+	if err != nil {
+		return "", "", err
+	}
+	orderId, err = vm.placeOrder(o)
+	if err != nil {
+		stop.Status = smp.Canceled
+		vm.publish(orderEvent(stop, vm.now()))
+		return "", "", err
+	}
+	return orderId, stopOrderId, nil
+}
+
+func (vm *VirtualMarket) BuyOco(instrumentId string, ticker string, cnt int, price float64, stopPrice float64,
+	meta *smp.MetaForOperations) (orderId string, stopOrderId string, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
+	return vm.placeOco(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Buy: true, Type: smp.LimitOrder, Cnt: cnt, Price: price,
+	}, &Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Buy: true, Type: smp.StopMarketOrder, Cnt: cnt, StopPrice: stopPrice,
+	})
+}
+func (vm *VirtualMarket) SellOco(instrumentId string, ticker string, cnt int, price float64, stopPrice float64,
+	meta *smp.MetaForOperations) (orderId string, stopOrderId string, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
+	return vm.placeOco(&Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Type: smp.LimitOrder, Cnt: cnt, Price: price,
+	}, &Order{
+		InstrumentId: instrumentId, Ticker: ticker,
+		Type: smp.StopMarketOrder, Cnt: cnt, StopPrice: stopPrice,
+	})
+}
+
+// cancelOco - cancels waiting other order of OCO pair of order o
+func (vm *VirtualMarket) cancelOco(o *Order, tm time.Time) {
+	if o.Oco == nil || o.Oco.Status != smp.Wait {
+		return
+	}
+	o.Oco.Status = smp.Canceled
+	vm.pending = append(vm.pending, orderEvent(o.Oco, tm))
+}
+
+func (vm *VirtualMarket) cancelOrder(orderId string) (ok bool, err *mft.Error) {
+	o, ok := vm.orders[orderId]
+	if !ok {
+		return false, mft.ErrorS("Not found")
+	}
+	if o.Status != smp.Wait {
+		return false, nil
+	}
+	o.Status = smp.Canceled
+	vm.publish(orderEvent(o, vm.now()))
+	if o.Oco != nil && o.Oco.Status == smp.Wait {
+		o.Oco.Status = smp.Canceled
+		vm.publish(orderEvent(o.Oco, vm.now()))
+	}
+	return true, nil
+}
+
 func (vm *VirtualMarket) CancelBuyOrder(instrumentId string, ticker string, orderId string,
 	meta *smp.MetaForOperations) (ok bool, err *mft.Error) {
-	return false, nil
+	vm.lock()
+	defer vm.unlock()
+	return vm.cancelOrder(orderId)
 }
 func (vm *VirtualMarket) CancelSellOrder(instrumentId string, ticker string, orderId string,
 	meta *smp.MetaForOperations) (ok bool, err *mft.Error) {
-	return false, nil
+	vm.lock()
+	defer vm.unlock()
+	return vm.cancelOrder(orderId)
 }
+
+func (vm *VirtualMarket) replaceOrder(orderId string, buy bool, cnt int, price float64) (newOrderId string, err *mft.Error) {
+	o, ok := vm.orders[orderId]
+	if !ok {
+		return "", mft.ErrorS("Not found")
+	}
+	if o.Status != smp.Wait || o.Type != smp.LimitOrder || o.Buy != buy || o.Oco != nil {
+		return "", mft.ErrorSf("Order `%v` can not be replaced", orderId)
+	}
+	o.Status = smp.Canceled
+	vm.publish(orderEvent(o, vm.now()))
+
+	rest := cnt - o.Filled()
+	if rest <= 0 {
+		return "", nil
+	}
+	return vm.placeOrder(&Order{
+		InstrumentId: o.InstrumentId, Ticker: o.Ticker,
+		Buy: o.Buy, Type: smp.LimitOrder, Cnt: rest, Price: price,
+	})
+}
+
+func (vm *VirtualMarket) ReplaceBuyOrder(instrumentId string, ticker string, orderId string, cnt int, price float64,
+	meta *smp.MetaForOperations) (newOrderId string, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
+	return vm.replaceOrder(orderId, true, cnt, price)
+}
+func (vm *VirtualMarket) ReplaceSellOrder(instrumentId string, ticker string, orderId string, cnt int, price float64,
+	meta *smp.MetaForOperations) (newOrderId string, err *mft.Error) {
+	vm.lock()
+	defer vm.unlock()
+	return vm.replaceOrder(orderId, false, cnt, price)
+}
+
+func (vm *VirtualMarket) statusOrder(orderId string) (status smp.StatusOrder, prices []smp.LotPrices, err *mft.Error) {
+	o, ok := vm.orders[orderId]
+	if !ok {
+		return smp.Unknown, make([]smp.LotPrices, 0), mft.ErrorS("Not found")
+	}
+	prices = make([]smp.LotPrices, len(o.Prices))
+	copy(prices, o.Prices)
+	return o.Status, prices, nil
+}
+
 func (vm *VirtualMarket) StatusBuyOrder(instrumentId string, ticker string, orderId string,
 	meta *smp.MetaForOperations) (status smp.StatusOrder, prices []smp.LotPrices, err *mft.Error) {
-	return smp.Complete, make([]smp.LotPrices, 0), nil
+	vm.lock()
+	defer vm.unlock()
+	return vm.statusOrder(orderId)
 }
 func (vm *VirtualMarket) StatusSellOrder(instrumentId string, ticker string, orderId string,
 	meta *smp.MetaForOperations) (status smp.StatusOrder, prices []smp.LotPrices, err *mft.Error) {
-	return smp.Complete, make([]smp.LotPrices, 0), nil
+	vm.lock()
+	defer vm.unlock()
+	return vm.statusOrder(orderId)
 }
 
 func (vm *VirtualMarket) DoStep() bool {
+	vm.lock()
+	defer vm.unlock()
+	return vm.doStep()
+}
+
+func (vm *VirtualMarket) doStep() bool {
 	if vm.Position >= vm.Candles.Len() {
 		return false
 	}
@@ -70,6 +453,150 @@ func (vm *VirtualMarket) DoStep() bool {
 		return false
 	}
 
-	vm.OrderBook = vm.Candles[vm.Position].OrderBook()
+	prev := vm.OrderBook
+	c := vm.Candles[vm.Position]
+	vm.OrderBook = c.OrderBook()
+	if prev != nil {
+		vm.Account.ChargeBorrowFee(vm.InstrumentInfo, prev.Price(), nights(prev.Time, c.Date))
+		vm.Account.payDividends(vm.Dividends, vm.InstrumentInfo, prev.Time, c.Date)
+	}
+	vm.executeOrders(c)
+	vm.marginCall(c)
+
+	events := append(vm.pending, candleEvents(prev, vm.OrderBook, c)...)
+	vm.pending = nil
+	for _, e := range events {
+		vm.publish(e)
+	}
 	return true
 }
+
+// nights - count of nights between from and to
+func nights(from time.Time, to time.Time) int {
+	y1, m1, d1 := from.Date()
+	y2, m2, d2 := to.In(from.Location()).Date()
+	return int(time.Date(y2, m2, d2, 0, 0, 0, 0, time.UTC).Sub(time.Date(y1, m1, d1, 0, 0, 0, 0, time.UTC)) / smp.H24)
+}
+
+// marginCall - cancels active orders and closes positions by Close of candle c
+// when equity is less than maintenance margin
+func (vm *VirtualMarket) marginCall(c smp.Candle) {
+	if !vm.Account.CheckBuyingPower || !vm.Account.IsMarginCall(vm.InstrumentInfo, c.Close) {
+		return
+	}
+	vm.Account.MarginCalls++
+
+	for _, o := range vm.active {
+		if o.Status == smp.Wait {
+			o.Status = smp.Canceled
+			vm.pending = append(vm.pending, orderEvent(o, c.Date))
+		}
+	}
+	vm.active = vm.active[:0]
+
+	if vm.orders == nil {
+		vm.orders = make(map[string]*Order)
+	}
+	for _, p := range vm.Account.positionsList(nil) {
+		vm.nextId++
+		o := &Order{
+			Id:           strconv.Itoa(vm.nextId),
+			InstrumentId: p.InstrumentId,
+			Ticker:       p.Ticker,
+			Buy:          p.Quantity < 0,
+			Type:         smp.MarketOrder,
+			Cnt:          p.Quantity,
+			Time:         c.Date,
+			Status:       smp.Wait,
+			Prices:       make([]smp.LotPrices, 0),
+		}
+		if o.Buy {
+			o.Cnt = -p.Quantity
+		}
+		vm.orders[o.Id] = o
+		vm.fill(o, o.Cnt, c.Close, c)
+	}
+}
+
+func (vm *VirtualMarket) fill(o *Order, cnt int, price float64, c smp.Candle) {
+	if cnt <= 0 {
+		return
+	}
+	o.Prices = append(o.Prices, smp.LotPrices{
+		Count: cnt,
+		Price: price,
+	})
+	vm.Actions = append(vm.Actions, Action{
+		InstrumentId: o.InstrumentId,
+		Ticker:       o.Ticker,
+		Buy:          o.Buy,
+		Sell:         !o.Buy,
+		Time:         c.Date,
+		Cnt:          cnt,
+		Price:        price,
+	})
+	vm.Account.Trade(vm.InstrumentInfo, o.InstrumentId, o.Ticker, o.Buy, cnt, price)
+	if o.Rest() <= 0 {
+		o.Status = smp.Complete
+	}
+	vm.pending = append(vm.pending, orderEvent(o, c.Date))
+	vm.cancelOco(o, c.Date)
+}
+
+// limitCnt - count that can be executed by limit order inside candle c
+func limitCnt(o *Order, c smp.Candle) int {
+	cnt := o.Rest()
+	if c.Vol > 0 && c.Vol < cnt {
+		cnt = c.Vol
+	}
+	return cnt
+}
+
+// executeOrders - executes waiting orders inside candle c
+// (triggered stop order is canceled when account can not execute it)
+func (vm *VirtualMarket) executeOrders(c smp.Candle) {
+	// vm.active is not changed during execution: it is used for blocked funds
+	active := make([]*Order, 0, len(vm.active))
+	for _, o := range vm.active {
+		if o.Status != smp.Wait {
+			continue
+		}
+
+		if o.IsStop() {
+			triggered, price := o.Trigger(c)
+			if !triggered {
+				active = append(active, o)
+				continue
+			}
+			err := vm.Account.checkOrder(vm.blockedExcept(o), vm.InstrumentInfo, o.InstrumentId, o.Ticker, o.Buy, o.Rest(), price, price)
+			if err != nil {
+				o.Status = smp.Canceled
+				vm.pending = append(vm.pending, orderEvent(o, c.Date))
+				continue
+			}
+			if o.Type != smp.LimitOrder {
+				vm.fill(o, o.Rest(), price, c)
+				continue
+			}
+			// stop limit order: trigger price is better than limit price
+			if o.Buy && price <= o.Price || !o.Buy && price >= o.Price {
+				vm.fill(o, limitCnt(o, c), price, c)
+			}
+		} else if o.Type == smp.MarketOrder {
+			vm.fill(o, o.Rest(), c.Open, c)
+		} else if o.Buy {
+			if c.Low <= o.Price {
+				vm.fill(o, limitCnt(o, c), math.Min(o.Price, c.Open), c)
+			}
+		} else {
+			if c.High >= o.Price {
+				vm.fill(o, limitCnt(o, c), math.Max(o.Price, c.Open), c)
+			}
+		}
+
+		if o.Status == smp.Wait {
+			active = append(active, o)
+		}
+	}
+	vm.active = active
+}
@@ -0,0 +1,428 @@
+package market
+
+import (
+	"testing"
+	"time"
+
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+func testCandles(ohlc ...[4]float64) smp.Candles {
+	tmStart := time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC)
+	cs := make(smp.Candles, 0, len(ohlc))
+	for i, v := range ohlc {
+		tm := tmStart.Add(time.Duration(i) * time.Minute)
+		cs = append(cs, smp.Candle{
+			Ticker: "TTTT",
+			Start:  tm,
+			Date:   tm.Add(time.Minute),
+			Open:   v[0],
+			High:   v[1],
+			Low:    v[2],
+			Close:  v[3],
+			Vol:    100,
+		})
+	}
+	return cs
+}
+
+func TestVirtualMarketStopOrders(t *testing.T) {
+	vm := &VirtualMarket{
+		Candles: testCandles(
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 104, 99.5, 103},
+			[4]float64{103, 106, 102, 105.5},
+			[4]float64{105.5, 106, 103, 103.5},
+			[4]float64{97, 98, 95, 96},
+		),
+	}
+	vm.DoStep()
+
+	stopMarket, _ := vm.SellStopByMarket("", "TTTT", 1, 98, nil)
+	stopLimit, _ := vm.SellStopByPrice("", "TTTT", 1, 98, 97.5, nil)
+	buyStop, _ := vm.BuyStopByMarket("", "TTTT", 1, 103.5, nil)
+	trailing, _ := vm.SellTrailingStop("", "TTTT", 1, 2, nil)
+
+	for vm.DoStep() {
+	}
+
+	check := func(name string, orderId string, status smp.StatusOrder, price float64) {
+		st, prices, err := vm.StatusSellOrder("", "TTTT", orderId, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st != status {
+			t.Fatalf("%v: status should be %v (current %v)", name, status, st)
+		}
+		if status == smp.Complete && (len(prices) != 1 || prices[0].Price != price) {
+			t.Fatalf("%v: should be executed by %v (current %v)", name, price, prices)
+		}
+	}
+
+	check("buy stop", buyStop, smp.Complete, 103.5)
+	// peak 106 - trail 2 = 104 reached inside the 4th candle
+	check("trailing stop", trailing, smp.Complete, 104)
+	// gap down under stop price: executed by open
+	check("sell stop market", stopMarket, smp.Complete, 97)
+	// gap down under limit price: limit order stays in the book
+	check("sell stop limit", stopLimit, smp.Wait, 0)
+}
+
+// noReplace hides native replace of VirtualMarket
+type noReplace struct {
+	smp.StepParams
+}
+
+func TestVirtualMarketReplaceOrder(t *testing.T) {
+	for _, native := range []bool{true, false} {
+		vm := &VirtualMarket{
+			Candles: testCandles(
+				[4]float64{100, 101, 99, 100},
+				[4]float64{100, 101, 98, 99},
+				[4]float64{99, 100, 97, 98},
+			),
+		}
+		var p smp.StepParams = vm
+		if !native {
+			p = noReplace{vm}
+		}
+		vm.DoStep()
+		vm.Candles[2].Vol = 3
+
+		orderId, _ := p.BuyByPrice("", "TTTT", 10, 97.5, nil)
+		vm.DoStep()
+
+		newOrderId, err := smp.ReplaceBuyOrder(p, "", "TTTT", orderId, 8, 99, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		st, prices, _ := p.StatusBuyOrder("", "TTTT", orderId, nil)
+		if st != smp.Canceled || len(prices) != 1 || prices[0].Count != 3 {
+			t.Fatalf("native: %v; old order should be canceled with 3 executed lots (current %v %v)", native, st, prices)
+		}
+
+		if _, err := smp.ReplaceBuyOrder(p, "", "TTTT", orderId, 8, 99, nil); err == nil {
+			t.Fatalf("native: %v; replace of not active order should fail", native)
+		}
+
+		vm.Candles = append(vm.Candles, testCandles([4]float64{99, 100, 98, 99})...)
+		vm.DoStep()
+		st, prices, _ = p.StatusBuyOrder("", "TTTT", newOrderId, nil)
+		if st != smp.Complete || len(prices) != 1 || prices[0].Count != 5 || prices[0].Price != 99 {
+			t.Fatalf("native: %v; new order should execute rest 5 lots by 99 (current %v %v)", native, st, prices)
+		}
+	}
+}
+
+func TestVirtualMarketEvents(t *testing.T) {
+	vm := &VirtualMarket{
+		Candles: testCandles(
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 98, 99},
+			[4]float64{99, 100, 97, 98},
+		),
+	}
+
+	sub, ch, err := smp.SubscribeChan(vm, smp.EventFilter{Ticker: "TTTT"}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orderStates := 0
+	subOrders, _ := vm.Subscribe(smp.EventFilter{Types: []smp.EventType{smp.OrderStateEvent}},
+		func(e smp.Event) { orderStates++ })
+
+	vm.DoStep()
+	vm.BuyByPrice("", "TTTT", 1, 97.5, nil)
+	vm.DoStep()
+	sub.Unsubscribe()
+	subOrders.Unsubscribe()
+	vm.DoStep()
+
+	types := make([]smp.EventType, 0)
+	for e := range ch {
+		types = append(types, e.Type)
+	}
+	expected := []smp.EventType{
+		smp.TradingStatusEvent, smp.OrderBookEvent, smp.TradeEvent,
+		smp.OrderStateEvent,
+		smp.OrderStateEvent, smp.OrderBookEvent, smp.TradeEvent,
+	}
+	if len(types) != len(expected) {
+		t.Fatalf("events should be %v (current %v)", expected, types)
+	}
+	for i := range types {
+		if types[i] != expected[i] {
+			t.Fatalf("events should be %v (current %v)", expected, types)
+		}
+	}
+	if orderStates != 2 {
+		t.Fatalf("order state events should be 2 (current %v)", orderStates)
+	}
+}
+
+func TestVirtualMarketAccount(t *testing.T) {
+	vm := &VirtualMarket{
+		Candles: testCandles(
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 98, 99},
+			[4]float64{99, 101, 97, 100},
+			[4]float64{100, 103, 99, 102},
+		),
+		InstrumentInfo: &smp.InstrumentInfo{Ticker: "TTTT", LotSize: 10, Currency: "rub"},
+		Account: Account{
+			Money:            map[string]float64{"rub": 10000},
+			CheckBuyingPower: true,
+		},
+	}
+	vm.DoStep()
+
+	if _, err := vm.BuyByPrice("", "TTTT", 6, 99, nil); err != nil {
+		t.Fatal(err)
+	}
+	if bp, _ := vm.GetBuyingPower("rub"); bp != 4060 {
+		t.Fatalf("buying power should be 4060 (current %v)", bp)
+	}
+	if _, err := vm.BuyByPrice("", "TTTT", 5, 99, nil); err == nil {
+		t.Fatal("buy over buying power should fail")
+	}
+	if _, err := vm.SellByPrice("", "TTTT", 1, 101, nil); err == nil {
+		t.Fatal("sell without position should fail")
+	}
+	vm.DoStep()
+
+	p, _ := vm.GetPosition("", "TTTT")
+	if p.Quantity != 6 || p.AveragePrice != 99 {
+		t.Fatalf("position should be 6 lots by 99 (current %v)", p)
+	}
+	if _, err := vm.SellByPrice("", "TTTT", 4, 102, nil); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := vm.GetPosition("", "TTTT"); p.Blocked != 4 || p.Free() != 2 {
+		t.Fatalf("4 lots should be blocked (current %v)", p)
+	}
+	vm.DoStep()
+
+	money, _ := vm.GetMoney()
+	if len(money) != 1 || money[0].Amount != 10000-5940+4080 || money[0].Blocked != 0 {
+		t.Fatalf("money should be %v (current %v)", 10000-5940+4080, money)
+	}
+	positions, _ := vm.GetPositions()
+	if len(positions) != 1 || positions[0].Quantity != 2 || positions[0].AveragePrice != 99 {
+		t.Fatalf("position should be 2 lots by 99 (current %v)", positions)
+	}
+}
+
+func TestVirtualMarketStopOrdersBuyingPower(t *testing.T) {
+	vm := &VirtualMarket{
+		Candles: testCandles(
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 94, 95},
+		),
+		InstrumentInfo: &smp.InstrumentInfo{Ticker: "TTTT", LotSize: 10, Currency: "rub"},
+		Account: Account{
+			Money: map[string]float64{"rub": 10000},
+			Positions: map[string]*smp.Position{
+				PositionKey("", "TTTT"): {Ticker: "TTTT", Quantity: 5, AveragePrice: 100},
+			},
+			CheckBuyingPower: true,
+		},
+	}
+	vm.DoStep()
+
+	if _, err := vm.SellStopByMarket("", "TTTT", 6, 95, nil); err == nil {
+		t.Fatal("sell stop over position should fail")
+	}
+	if _, err := vm.SellTrailingStop("", "TTTT", 6, 2, nil); err == nil {
+		t.Fatal("trailing stop over position should fail")
+	}
+	stop, err := vm.SellStopByMarket("", "TTTT", 5, 95, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := vm.GetPosition("", "TTTT"); p.Blocked != 5 || p.Free() != 0 {
+		t.Fatalf("5 lots should be blocked by stop order (current %v)", p)
+	}
+	if _, err := vm.SellByPrice("", "TTTT", 1, 110, nil); err == nil {
+		t.Fatal("sell of lots blocked by stop order should fail")
+	}
+
+	// position is decreased before trigger: stop order is canceled on trigger
+	vm.Account.Positions[PositionKey("", "TTTT")].Quantity = 3
+	vm.DoStep()
+
+	st, prices, _ := vm.StatusSellOrder("", "TTTT", stop, nil)
+	if st != smp.Canceled || len(prices) != 0 {
+		t.Fatalf("stop order should be canceled (current %v %v)", st, prices)
+	}
+	if p, _ := vm.GetPosition("", "TTTT"); p.Quantity != 3 {
+		t.Fatalf("position should be 3 lots (current %v)", p)
+	}
+}
+
+func TestVirtualMarketOco(t *testing.T) {
+	vm := &VirtualMarket{
+		Candles: testCandles(
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 106, 94, 100},
+			[4]float64{100, 101, 99, 100},
+		),
+		InstrumentInfo: &smp.InstrumentInfo{Ticker: "TTTT", LotSize: 10, Currency: "rub"},
+		Account: Account{
+			Money: map[string]float64{"rub": 10000},
+			Positions: map[string]*smp.Position{
+				PositionKey("", "TTTT"): {Ticker: "TTTT", Quantity: 5, AveragePrice: 100},
+			},
+			CheckBuyingPower: true,
+		},
+	}
+	vm.DoStep()
+
+	if _, _, err := vm.SellOco("", "TTTT", 6, 105, 95, nil); err == nil {
+		t.Fatal("oco over position should fail")
+	}
+	take, stop, err := vm.SellOco("", "TTTT", 5, 105, 95, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := vm.GetPosition("", "TTTT"); p.Blocked != 5 {
+		t.Fatalf("5 lots should be blocked by oco once (current %v)", p)
+	}
+
+	// cancel of one order cancels the other
+	take2, stop2, err := vm.SellOco("", "TTTT", 5, 110, 90, nil)
+	if err == nil {
+		t.Fatalf("second oco over position should fail: %v %v", take2, stop2)
+	}
+	if _, err := vm.CancelSellOrder("", "TTTT", take, nil); err != nil {
+		t.Fatal(err)
+	}
+	if st, _, _ := vm.StatusSellOrder("", "TTTT", stop, nil); st != smp.Canceled {
+		t.Fatalf("stop order should be canceled with its pair (current %v)", st)
+	}
+
+	// candle reaches both prices: only stop order is executed
+	take, stop, err = vm.SellOco("", "TTTT", 5, 105, 95, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm.DoStep()
+
+	if st, prices, _ := vm.StatusSellOrder("", "TTTT", stop, nil); st != smp.Complete || len(prices) != 1 || prices[0].Price != 95 {
+		t.Fatalf("stop order should be executed by 95 (current %v %v)", st, prices)
+	}
+	if st, prices, _ := vm.StatusSellOrder("", "TTTT", take, nil); st != smp.Canceled || len(prices) != 0 {
+		t.Fatalf("take order should be canceled (current %v %v)", st, prices)
+	}
+	if p, _ := vm.GetPosition("", "TTTT"); p.Quantity != 0 {
+		t.Fatalf("position should be closed (current %v)", p)
+	}
+}
+
+func TestVirtualMarketMarginShort(t *testing.T) {
+	cs := testCandles(
+		[4]float64{100, 101, 99, 100},
+		[4]float64{100, 101, 99, 100},
+		[4]float64{100, 101, 99, 100},
+		[4]float64{100, 120, 100, 120},
+		[4]float64{120, 150, 120, 150},
+		[4]float64{150, 150, 150, 150},
+	)
+	// candles 3.. are the next day
+	for i := 3; i < len(cs); i++ {
+		cs[i].Start = cs[i].Start.Add(smp.H24)
+		cs[i].Date = cs[i].Date.Add(smp.H24)
+	}
+	vm := &VirtualMarket{
+		Candles: cs,
+		InstrumentInfo: &smp.InstrumentInfo{
+			Ticker: "TTTT", LotSize: 1, Currency: "rub",
+			ShortEnabled: true, MarginShort: 0.5, MaintenanceMarginShort: 0.3, BorrowFeeRate: 0.001,
+		},
+		Account: Account{
+			Money:            map[string]float64{"rub": 1000},
+			CheckBuyingPower: true,
+			MaxLeverage:      1.8,
+		},
+	}
+	vm.DoStep()
+
+	if _, err := vm.SellByPrice("", "TTTT", 25, 100, nil); err == nil {
+		t.Fatal("short over free margin should fail")
+	}
+	vm.InstrumentInfo.ShortEnabled = false
+	if _, err := vm.SellByPrice("", "TTTT", 15, 100, nil); err == nil {
+		t.Fatal("short of not shortable instrument should fail")
+	}
+	vm.InstrumentInfo.ShortEnabled = true
+	if _, err := vm.SellByPrice("", "TTTT", 15, 100, nil); err != nil {
+		t.Fatal(err)
+	}
+	vm.DoStep()
+
+	if p, _ := vm.GetPosition("", "TTTT"); p.Quantity != -15 || p.AveragePrice != 100 {
+		t.Fatalf("position should be -15 lots by 100 (current %v)", p)
+	}
+	if bp, _ := vm.GetBuyingPower("rub"); bp != 250 {
+		t.Fatalf("buying power should be 250 (current %v)", bp)
+	}
+	if _, err := vm.SellByPrice("", "TTTT", 5, 100, nil); err == nil {
+		t.Fatal("leverage over 1.8 should fail")
+	}
+
+	for vm.DoStep() {
+	}
+
+	if vm.Account.BorrowFees != 1.5 {
+		t.Fatalf("borrow fee should be 1.5 (current %v)", vm.Account.BorrowFees)
+	}
+	if vm.Account.MarginCalls != 1 {
+		t.Fatalf("margin call should be (current %v)", vm.Account.MarginCalls)
+	}
+	if p, _ := vm.GetPositions(); len(p) != 0 {
+		t.Fatalf("positions should be closed (current %v)", p)
+	}
+	if vm.Account.Money["rub"] != 1000+1500-1.5-15*150 {
+		t.Fatalf("money should be %v (current %v)", 1000+1500-1.5-15*150, vm.Account.Money["rub"])
+	}
+}
+
+func TestVirtualMarketDividends(t *testing.T) {
+	cs := testCandles(
+		[4]float64{100, 101, 99, 100},
+		[4]float64{100, 101, 99, 100},
+		[4]float64{100, 101, 99, 100},
+		[4]float64{98, 99, 97, 98},
+		[4]float64{98, 99, 97, 98},
+	)
+	// candles 3.. are the next day (ex-date)
+	for i := 3; i < len(cs); i++ {
+		cs[i].Start = cs[i].Start.Add(smp.H24)
+		cs[i].Date = cs[i].Date.Add(smp.H24)
+	}
+	vm := &VirtualMarket{
+		Candles:        cs,
+		InstrumentInfo: &smp.InstrumentInfo{Ticker: "TTTT", LotSize: 10, Currency: "rub"},
+		Account:        Account{Money: map[string]float64{"rub": 10000}},
+		Dividends: smp.Dividends{
+			{Ticker: "TTTT", Amount: 2, LastDate: time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)},
+			{Ticker: "TTTT", Amount: 3, LastDate: time.Date(2021, 1, 6, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+	vm.DoStep()
+
+	if _, err := vm.BuyByMarket("", "TTTT", 5, nil); err != nil {
+		t.Fatal(err)
+	}
+	for vm.DoStep() {
+	}
+
+	if vm.Account.Dividends != 100 {
+		t.Fatalf("dividends should be 100 (current %v)", vm.Account.Dividends)
+	}
+	if vm.Account.Money["rub"] != 10000-5000+100 {
+		t.Fatalf("money should be %v (current %v)", 10000-5000+100, vm.Account.Money["rub"])
+	}
+}
@@ -1,6 +1,7 @@
 package smp
 
 import (
+	"math"
 	"time"
 )
 
@@ -64,6 +65,64 @@ type InstrumentInfo struct {
 	InstrumentId string `json:"instrument_id"`
 	Ticker       string `json:"ticker"`
 
-	LotSize int     `json:"lot_size"`
-	MinStep float64 `json:"min_step"`
+	LotSize  int     `json:"lot_size"`
+	MinStep  float64 `json:"min_step"`
+	Currency string  `json:"currency,omitempty"`
+
+	// ShortEnabled - instrument can be sold short
+	ShortEnabled bool `json:"short_enabled,omitempty"`
+	// MarginLong, MarginShort - initial margin rate (part of position value) of long and short positions
+	MarginLong  float64 `json:"margin_long,omitempty"`
+	MarginShort float64 `json:"margin_short,omitempty"`
+	// MaintenanceMarginLong, MaintenanceMarginShort - margin rate of positions under which positions are closed forcibly
+	MaintenanceMarginLong  float64 `json:"maintenance_margin_long,omitempty"`
+	MaintenanceMarginShort float64 `json:"maintenance_margin_short,omitempty"`
+	// BorrowFeeRate - fee for one night (part of short position value)
+	BorrowFeeRate float64 `json:"borrow_fee_rate,omitempty"`
+}
+
+// Lot - lot size (1 when it is not set)
+func (ii *InstrumentInfo) Lot() int {
+	if ii == nil || ii.LotSize <= 0 {
+		return 1
+	}
+	return ii.LotSize
+}
+
+// RoundPrice - price rounded to MinStep (price is not changed when MinStep is not set)
+func (ii *InstrumentInfo) RoundPrice(price float64) float64 {
+	if ii == nil || ii.MinStep <= 0 {
+		return price
+	}
+	return Round(math.Round(price/ii.MinStep)*ii.MinStep, 9)
+}
+
+// MarginRate - initial margin rate of long or short position (1 when it is not set)
+func (ii *InstrumentInfo) MarginRate(long bool) float64 {
+	rate := 0.0
+	if ii != nil && long {
+		rate = ii.MarginLong
+	}
+	if ii != nil && !long {
+		rate = ii.MarginShort
+	}
+	if rate <= 0 {
+		return 1
+	}
+	return rate
+}
+
+// MaintenanceMarginRate - maintenance margin rate of long or short position (initial margin rate when it is not set)
+func (ii *InstrumentInfo) MaintenanceMarginRate(long bool) float64 {
+	rate := 0.0
+	if ii != nil && long {
+		rate = ii.MaintenanceMarginLong
+	}
+	if ii != nil && !long {
+		rate = ii.MaintenanceMarginShort
+	}
+	if rate <= 0 {
+		return ii.MarginRate(long)
+	}
+	return rate
 }
@@ -0,0 +1,19 @@
+package smp
+
+import "testing"
+
+func TestInstrumentInfoRoundPrice(t *testing.T) {
+	ii := &InstrumentInfo{MinStep: 0.05}
+
+	if p := ii.RoundPrice(101.23); p != 101.25 {
+		t.Fatalf("RoundPrice(101.23) should be 101.25 (current %v)", p)
+	}
+	if p := ii.RoundPrice(101.21); p != 101.2 {
+		t.Fatalf("RoundPrice(101.21) should be 101.2 (current %v)", p)
+	}
+
+	var empty *InstrumentInfo
+	if p := empty.RoundPrice(101.23); p != 101.23 {
+		t.Fatalf("RoundPrice without MinStep should not change price (current %v)", p)
+	}
+}
@@ -0,0 +1,45 @@
+package smp
+
+import "github.com/myfantasy/mft"
+
+// Position - position of account by instrument
+type Position struct {
+	InstrumentId string `json:"instrument_id"`
+	Ticker       string `json:"ticker"`
+
+	// Quantity - count of lots
+	Quantity int `json:"quantity"`
+	// AveragePrice - average price of one item (not lot)
+	AveragePrice float64 `json:"average_price"`
+	// Blocked - count of lots blocked by active sell orders
+	Blocked int `json:"blocked"`
+}
+
+// Free - count of lots allowed to sell
+func (p Position) Free() int {
+	return p.Quantity - p.Blocked
+}
+
+// MoneyBalance - money of account by currency
+type MoneyBalance struct {
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount"`
+	// Blocked - money blocked by active buy orders
+	Blocked float64 `json:"blocked"`
+}
+
+// Free - money allowed to spend
+func (m MoneyBalance) Free() float64 {
+	return m.Amount - m.Blocked
+}
+
+// PortfolioStepParams - StepParams with account queries
+type PortfolioStepParams interface {
+	StepParams
+
+	GetPositions() (positions []Position, err *mft.Error)
+	GetPosition(instrumentId string, ticker string) (position Position, err *mft.Error)
+	GetMoney() (money []MoneyBalance, err *mft.Error)
+	// GetBuyingPower - money allowed to spend by currency
+	GetBuyingPower(currency string) (amount float64, err *mft.Error)
+}
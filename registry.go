@@ -0,0 +1,314 @@
+package smp
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/myfantasy/mft"
+)
+
+// StrategyGenerate - creates new strategy with zero state
+type StrategyGenerate func() Strategy
+
+// StrategyMigration - upgrades document of strategy (fields by json names) to the next schema version
+type StrategyMigration func(data map[string]json.RawMessage) (err *mft.Error)
+
+// StrategyInfo - type of strategy with its description and commands
+type StrategyInfo struct {
+	Type        string                  `json:"type"`
+	Version     int                     `json:"version"`
+	Description string                  `json:"description"`
+	Commands    map[Command]CommandInfo `json:"commands"`
+}
+
+// StrategyRegistry - generators of strategies by Strategy.Type()
+type StrategyRegistry struct {
+	Generators map[string]StrategyGenerate
+	// Migrations - migrations by type and schema version they upgrade from
+	Migrations map[string]map[int]StrategyMigration
+
+	mx sync.RWMutex
+}
+
+// GlobalStrategyRegistry - registry filled by packages of strategies
+var GlobalStrategyRegistry = &StrategyRegistry{
+	Generators: map[string]StrategyGenerate{},
+	Migrations: map[string]map[int]StrategyMigration{},
+}
+
+// Add - registers generator by type of strategy it creates
+func (r *StrategyRegistry) Add(generate StrategyGenerate) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.Generators == nil {
+		r.Generators = map[string]StrategyGenerate{}
+	}
+	r.Generators[generate().Type()] = generate
+}
+
+// AddMigration - registers migration of strategy type tp from schema version to version+1
+func (r *StrategyRegistry) AddMigration(tp string, version int, migration StrategyMigration) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.Migrations == nil {
+		r.Migrations = map[string]map[int]StrategyMigration{}
+	}
+	if r.Migrations[tp] == nil {
+		r.Migrations[tp] = map[int]StrategyMigration{}
+	}
+	r.Migrations[tp][version] = migration
+}
+
+// Version - current schema version of strategy type (0 - without migrations)
+func (r *StrategyRegistry) Version(tp string) int {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	version := 0
+	for from := range r.Migrations[tp] {
+		if from+1 > version {
+			version = from + 1
+		}
+	}
+	return version
+}
+
+// Migrate - upgrades document of strategy type tp step by step from version to the current one
+func (r *StrategyRegistry) Migrate(tp string, version int, data []byte) (out []byte, err *mft.Error) {
+	current := r.Version(tp)
+	if version > current {
+		return nil, GenerateError(500003412, tp, version, current)
+	}
+	if version == current {
+		return data, nil
+	}
+
+	var doc map[string]json.RawMessage
+	er0 := json.Unmarshal(data, &doc)
+	if er0 != nil {
+		return nil, GenerateErrorE(500003411, er0, tp)
+	}
+	if doc == nil {
+		doc = map[string]json.RawMessage{}
+	}
+
+	for ; version < current; version++ {
+		r.mx.RLock()
+		migration, ok := r.Migrations[tp][version]
+		r.mx.RUnlock()
+		if !ok {
+			return nil, GenerateError(500003413, tp, version)
+		}
+		err = migration(doc)
+		if err != nil {
+			return nil, GenerateErrorE(500003414, err, tp, version, version+1)
+		}
+	}
+
+	out, er0 = json.Marshal(doc)
+	if er0 != nil {
+		return nil, GenerateErrorE(500003410, er0, tp)
+	}
+	return out, nil
+}
+
+// Types - sorted types of registered strategies
+func (r *StrategyRegistry) Types() []string {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	types := make([]string, 0, len(r.Generators))
+	for tp := range r.Generators {
+		types = append(types, tp)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Infos - descriptions and commands of registered strategies in locale sorted by type
+func (r *StrategyRegistry) Infos(locale Locale) []StrategyInfo {
+	types := r.Types()
+	infos := make([]StrategyInfo, 0, len(types))
+	for _, tp := range types {
+		info, err := r.Info(tp, locale)
+		if err == nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// Info - description and commands of strategy type in locale
+func (r *StrategyRegistry) Info(tp string, locale Locale) (info StrategyInfo, err *mft.Error) {
+	s, err := r.New(tp)
+	if err != nil {
+		return info, err
+	}
+	return StrategyInfo{
+		Type:        tp,
+		Version:     r.Version(tp),
+		Description: s.Description(locale),
+		Commands:    s.AllowCommands(locale),
+	}, nil
+}
+
+// New - creates strategy of type tp with zero state
+func (r *StrategyRegistry) New(tp string) (s Strategy, err *mft.Error) {
+	r.mx.RLock()
+	generate, ok := r.Generators[tp]
+	r.mx.RUnlock()
+	if !ok {
+		return nil, GenerateError(500003400, tp)
+	}
+	return generate(), nil
+}
+
+// BuildIdentityFields - json names of string fields of identity of strategy (name and instrument),
+// are set by Build, other fields are set only by params of commands
+var BuildIdentityFields = []string{"name", "instrument_id", "ticker", "instrument_id_a", "ticker_a", "instrument_id_b", "ticker_b"}
+
+// buildTarget - address and type of field of strategy
+type buildTarget struct {
+	ptr uintptr
+	tp  reflect.Type
+}
+
+// buildParam - param of command setting field of strategy
+type buildParam struct {
+	cmd   Command
+	param Param
+}
+
+// Build - creates strategy of type tp and sets fields from params by json names of fields:
+// identity fields (BuildIdentityFields) and fields which are targets of params of commands
+// (values are checked by params of commands)
+func (r *StrategyRegistry) Build(tp string, params map[string]string) (s Strategy, err *mft.Error) {
+	s, err = r.New(tp)
+	if err != nil {
+		return nil, err
+	}
+
+	v := reflect.ValueOf(s)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, GenerateError(500003401, tp)
+	}
+	fields := make(map[string]reflect.Value)
+	jsonFields(v.Elem(), fields)
+
+	// params of commands by targets (pointer and type, embedded struct has address of its first field)
+	targets := make(map[buildTarget]buildParam)
+	commands := s.AllowCommands(DefaultLocale)
+	cmds := make([]Command, 0, len(commands))
+	for cmd := range commands {
+		cmds = append(cmds, cmd)
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i] < cmds[j] })
+	for _, cmd := range cmds {
+		for _, p := range commands[cmd].Params {
+			if p.Target == nil {
+				continue
+			}
+			pt := reflect.ValueOf(p.Target)
+			t := buildTarget{ptr: pt.Pointer(), tp: pt.Type().Elem()}
+			if _, ok := targets[t]; !ok {
+				targets[t] = buildParam{cmd: cmd, param: p}
+			}
+		}
+	}
+
+	identity := make(map[string]bool, len(BuildIdentityFields))
+	for _, name := range BuildIdentityFields {
+		identity[name] = true
+	}
+
+	// params are sorted to raise the same error for the same params
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f, ok := fields[name]
+		if !ok {
+			return nil, GenerateError(500003402, tp, name)
+		}
+		if identity[name] && f.Kind() == reflect.String {
+			f.SetString(params[name])
+			continue
+		}
+		bp, ok := targets[buildTarget{ptr: f.Addr().Pointer(), tp: f.Type()}]
+		if !ok {
+			return nil, GenerateError(500003403, tp, name)
+		}
+		value, er := bp.param.parse(tp, bp.cmd, params[name])
+		if er != nil {
+			return nil, GenerateErrorE(500003404, er, tp, name, params[name])
+		}
+		bp.param.set(value)
+	}
+
+	return s, nil
+}
+
+// Save - puts strategy into container with its type and current schema version
+func (r *StrategyRegistry) Save(s Strategy) (c JsonTypedContainer, err *mft.Error) {
+	b, er0 := json.Marshal(s)
+	if er0 != nil {
+		return c, GenerateErrorE(500003410, er0, s.Type())
+	}
+	c.Type = s.Type()
+	c.Version = r.Version(c.Type)
+	c.Data = b
+	return c, nil
+}
+
+// Load - restores strategy from container, data of old schema version is migrated
+// (fields unknown to the strategy after migrations are an error)
+func (r *StrategyRegistry) Load(c JsonTypedContainer) (s Strategy, err *mft.Error) {
+	s, err = r.New(c.Type)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Data) == 0 {
+		return s, nil
+	}
+
+	data, err := r.Migrate(c.Type, c.Version, c.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	er0 := dec.Decode(s)
+	if er0 != nil {
+		return nil, GenerateErrorE(500003411, er0, c.Type)
+	}
+	return s, nil
+}
+
+// jsonFields - settable fields of struct v by json names (fields of embedded structs are included)
+func jsonFields(v reflect.Value, fields map[string]reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			jsonFields(v.Field(i), fields)
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields[name] = v.Field(i)
+	}
+}
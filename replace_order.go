@@ -0,0 +1,91 @@
+package smp
+
+import "github.com/myfantasy/mft"
+
+// ReplaceOrderStepParams - StepParams with replace (amend) of limit orders
+// cnt - new total count of the order including lots executed by the old order;
+// the old order becomes Canceled and keeps executed lots (check it by StatusBuyOrder/StatusSellOrder),
+// the new order is placed for the rest (cnt - executed) by price;
+// when nothing rests newOrderId is empty.
+// Replace of not active order returns error
+type ReplaceOrderStepParams interface {
+	StepParams
+
+	ReplaceBuyOrder(instrumentId string, ticker string, orderId string, cnt int, price float64,
+		meta *MetaForOperations) (newOrderId string, err *mft.Error)
+	ReplaceSellOrder(instrumentId string, ticker string, orderId string, cnt int, price float64,
+		meta *MetaForOperations) (newOrderId string, err *mft.Error)
+}
+
+// executedCount - count of executed lots
+func executedCount(prices []LotPrices) int {
+	cnt := 0
+	for _, p := range prices {
+		cnt += p.Count
+	}
+	return cnt
+}
+
+// ReplaceBuyOrder - replaces buy order by ReplaceOrderStepParams
+// or emulates replace by cancel and place when p does not support it
+func ReplaceBuyOrder(p StepParams, instrumentId string, ticker string, orderId string, cnt int, price float64,
+	meta *MetaForOperations) (newOrderId string, err *mft.Error) {
+	if rp, ok := p.(ReplaceOrderStepParams); ok {
+		return rp.ReplaceBuyOrder(instrumentId, ticker, orderId, cnt, price, meta)
+	}
+
+	ok, err := p.CancelBuyOrder(instrumentId, ticker, orderId, meta)
+	if err != nil {
+		return "", GenerateErrorE(500000800, err, orderId)
+	}
+	if !ok {
+		return "", GenerateError(500000801, orderId)
+	}
+	_, prices, err := p.StatusBuyOrder(instrumentId, ticker, orderId, meta)
+	if err != nil {
+		return "", GenerateErrorE(500000802, err, orderId)
+	}
+
+	rest := cnt - executedCount(prices)
+	if rest <= 0 {
+		return "", nil
+	}
+
+	newOrderId, err = p.BuyByPrice(instrumentId, ticker, rest, price, meta)
+	if err != nil {
+		return "", GenerateErrorE(500000803, err, orderId)
+	}
+	return newOrderId, nil
+}
+
+// ReplaceSellOrder - replaces sell order by ReplaceOrderStepParams
+// or emulates replace by cancel and place when p does not support it
+func ReplaceSellOrder(p StepParams, instrumentId string, ticker string, orderId string, cnt int, price float64,
+	meta *MetaForOperations) (newOrderId string, err *mft.Error) {
+	if rp, ok := p.(ReplaceOrderStepParams); ok {
+		return rp.ReplaceSellOrder(instrumentId, ticker, orderId, cnt, price, meta)
+	}
+
+	ok, err := p.CancelSellOrder(instrumentId, ticker, orderId, meta)
+	if err != nil {
+		return "", GenerateErrorE(500000810, err, orderId)
+	}
+	if !ok {
+		return "", GenerateError(500000811, orderId)
+	}
+	_, prices, err := p.StatusSellOrder(instrumentId, ticker, orderId, meta)
+	if err != nil {
+		return "", GenerateErrorE(500000812, err, orderId)
+	}
+
+	rest := cnt - executedCount(prices)
+	if rest <= 0 {
+		return "", nil
+	}
+
+	newOrderId, err = p.SellByPrice(instrumentId, ticker, rest, price, meta)
+	if err != nil {
+		return "", GenerateErrorE(500000813, err, orderId)
+	}
+	return newOrderId, nil
+}
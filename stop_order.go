@@ -0,0 +1,55 @@
+package smp
+
+import "github.com/myfantasy/mft"
+
+type OrderType string
+
+const (
+	MarketOrder       OrderType = "market"
+	LimitOrder        OrderType = "limit"
+	StopMarketOrder   OrderType = "stop_market"
+	StopLimitOrder    OrderType = "stop_limit"
+	TrailingStopOrder OrderType = "trailing_stop"
+)
+
+// StopOrderStepParams - StepParams with stop orders
+// stop orders are canceled by CancelBuyOrder/CancelSellOrder
+// and checked by StatusBuyOrder/StatusSellOrder as usual orders
+type StopOrderStepParams interface {
+	StepParams
+
+	// BuyStopByMarket - buy by market when price rises to stopPrice
+	BuyStopByMarket(instrumentId string, ticker string, cnt int, stopPrice float64,
+		meta *MetaForOperations) (orderId string, err *mft.Error)
+	// SellStopByMarket - sell by market when price falls to stopPrice
+	SellStopByMarket(instrumentId string, ticker string, cnt int, stopPrice float64,
+		meta *MetaForOperations) (orderId string, err *mft.Error)
+
+	// BuyStopByPrice - place buy order by price when price rises to stopPrice
+	BuyStopByPrice(instrumentId string, ticker string, cnt int, stopPrice float64, price float64,
+		meta *MetaForOperations) (orderId string, err *mft.Error)
+	// SellStopByPrice - place sell order by price when price falls to stopPrice
+	SellStopByPrice(instrumentId string, ticker string, cnt int, stopPrice float64, price float64,
+		meta *MetaForOperations) (orderId string, err *mft.Error)
+
+	// BuyTrailingStop - buy by market when price rises by trail from its minimum after order placement
+	BuyTrailingStop(instrumentId string, ticker string, cnt int, trail float64,
+		meta *MetaForOperations) (orderId string, err *mft.Error)
+	// SellTrailingStop - sell by market when price falls by trail from its maximum after order placement
+	SellTrailingStop(instrumentId string, ticker string, cnt int, trail float64,
+		meta *MetaForOperations) (orderId string, err *mft.Error)
+}
+
+// OcoStepParams - StepParams with OCO (one cancels other) pair of orders for the same lots:
+// order by price and stop order by market; execution (also partial) of one order of pair
+// and cancel of one order (CancelBuyOrder/CancelSellOrder) cancel the other order
+type OcoStepParams interface {
+	StepParams
+
+	// BuyOco - buy by price (under market) or by market when price rises to stopPrice
+	BuyOco(instrumentId string, ticker string, cnt int, price float64, stopPrice float64,
+		meta *MetaForOperations) (orderId string, stopOrderId string, err *mft.Error)
+	// SellOco - sell by price (over market) or by market when price falls to stopPrice
+	SellOco(instrumentId string, ticker string, cnt int, price float64, stopPrice float64,
+		meta *MetaForOperations) (orderId string, stopOrderId string, err *mft.Error)
+}
@@ -0,0 +1,504 @@
+package strategies
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+//go:generate mfjson bracket.go
+
+// Вход с парой заявок на выход (OCO - one cancels other): тейк-профит и стоп-лосс
+
+var (
+	_ smp.Strategy = &Bracket{}
+)
+
+type BracketState string
+
+const (
+	BracketWaiting  BracketState = "waiting"
+	BracketEntering BracketState = "entering"
+	// BracketOpen - take profit and stop loss are active
+	BracketOpen   BracketState = "open"
+	BracketClosed BracketState = "closed"
+)
+
+// BracketLeg - sell order of OCO pair
+type BracketLeg struct {
+	OrderId string `json:"order_id"`
+	// Cnt - lots of order; Filled, FilledPrice - executed part of order
+	Cnt         int     `json:"cnt"`
+	Filled      int     `json:"filled"`
+	FilledPrice float64 `json:"filled_price"`
+}
+
+//mfjson:interface smp.strategies.bracket
+type Bracket struct {
+	Name string `json:"name"`
+
+	InstrumentId string `json:"instrument_id"`
+	Ticker       string `json:"ticker"`
+
+	Volume int `json:"volume"`
+	// EntryPrice - price of entry buy (0 - by market)
+	EntryPrice float64 `json:"entry_price"`
+	// TakeProfit - price of take profit sell
+	TakeProfit float64 `json:"take_profit"`
+	// StopLoss - stop price of stop loss sell by market
+	StopLoss float64 `json:"stop_loss"`
+
+	IsOnline bool `json:"is_online"`
+
+	State BracketState `json:"state"`
+
+	EntryOrderId  string  `json:"entry_order_id"`
+	InMarket      int     `json:"in_market"`
+	InMarketPrice float64 `json:"in_market_price"`
+
+	Take BracketLeg `json:"take"`
+	Stop BracketLeg `json:"stop"`
+	// Sold, SoldPrice - lots sold by both legs
+	Sold      int     `json:"sold"`
+	SoldPrice float64 `json:"sold_price"`
+	// BuyBack - buy of lots sold over position (both legs are executed without OCO pair of market)
+	BuyBack BracketLeg `json:"buy_back"`
+	// Exit - leg of the last sell (take_profit or stop_loss)
+	Exit   string  `json:"exit"`
+	Profit float64 `json:"profit"`
+
+	Lifecycle smp.Lifecycle `json:"lifecycle"`
+}
+
+func (s *Bracket) Type() string {
+	return "bracket"
+}
+
+func (s *Bracket) String() string {
+	return "bracket"
+}
+
+func (s *Bracket) Status() smp.StartegyStatus {
+	st := s.Lifecycle.Status(s.IsOnline)
+	st.IsOnline = st.IsOnline && s.State != BracketClosed
+	return st
+}
+func (s *Bracket) Json() string {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return string(b)
+}
+
+// commands - commands of strategy
+func (s *Bracket) commands() smp.CommandDefs {
+	show := showCommand("Отобразить (позиция, выход, прибыль)", func() string {
+		return fmt.Sprintf("%v (%v): %v lots by %v, sold %v, take profit %v, stop loss %v, exit %v, profit %v",
+			s.String(), s.State, s.InMarket, s.InMarketPrice, s.Sold, s.TakeProfit, s.StopLoss, s.Exit, s.Profit)
+	})
+	lifecycle := s.Lifecycle.Commands(s.Type(), s, &s.IsOnline)
+	lifecycle.Find(smp.StartCommand).Description = "Старт (после закрытия - новый вход)"
+	return lifecycleCommands(show, lifecycle, smp.CommandDefs{
+		{Command: SetVolume, Description: "Установить объём входа", Example: "set_vol 10",
+			Params: []smp.Param{smp.IntParam("", "кол-во лотов", &s.Volume).WithMin(0)}},
+		{Command: SetEntryPrice, Description: "Установить цену входа (0 - по рынку)", Example: "set_entry_price 345.67",
+			Params: []smp.Param{smp.FloatParam("", "цена", &s.EntryPrice).WithMin(0)}},
+		{Command: SetTakeProfit, Description: "Установить цену тейк-профита", Example: "set_take_profit 360",
+			Params: []smp.Param{smp.FloatParam("", "цена", &s.TakeProfit).WithMin(0)}},
+		{Command: SetStopLoss, Description: "Установить цену стоп-лосса", Example: "set_stop_loss 330",
+			Params: []smp.Param{smp.FloatParam("", "цена", &s.StopLoss).WithMin(0)}},
+	})
+}
+
+func (s *Bracket) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	return s.commands().Do(s.Type(), cmd, params)
+}
+
+func (s *Bracket) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
+
+func (s *Bracket) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), `bracket - стратегия, вход с тейк-профитом и стоп-лоссом (OCO)
+	Покупка по цене или по рынку, затем одновременно выставляются продажа по цене тейк-профита и стоп по рынку
+	Если рынок поддерживает OCO, заявки выставляются парой OCO, исполнение одной заявки снимает другую на рынке,
+	остаток выставляется новой парой
+	Иначе исполнение одной заявки снимает другую, частичное исполнение уменьшает объём другой,
+	лоты, проданные обеими заявками сверх позиции, откупаются по рынку (шаг возвращает ошибку)
+	Без поддержки стоп заявок стоп-лосс продаётся по рынку при падении цены`)
+}
+
+// OnStart - new bracket after closed one
+func (s *Bracket) OnStart() (err *mft.Error) {
+	if s.State == BracketClosed {
+		s.newBracket()
+	}
+	return nil
+}
+
+// ActiveOrders - entry order and orders of legs
+// (buy back of oversold lots is not canceled: Step finishes it before lifecycle)
+func (s *Bracket) ActiveOrders() (orders []smp.ActiveOrder) {
+	if s.EntryOrderId != "" {
+		orders = append(orders, smp.ActiveOrder{InstrumentId: s.InstrumentId, Ticker: s.Ticker, OrderId: s.EntryOrderId, Buy: true})
+	}
+	for _, leg := range []BracketLeg{s.Take, s.Stop} {
+		if leg.OrderId != "" {
+			orders = append(orders, smp.ActiveOrder{InstrumentId: s.InstrumentId, Ticker: s.Ticker, OrderId: leg.OrderId})
+		}
+	}
+	return orders
+}
+
+// FlattenPosition - sells the rest of position by market (as stop loss leg) and closes bracket
+func (s *Bracket) FlattenPosition(p smp.StepParams, meta *smp.MetaForStep) (done bool, err *mft.Error) {
+	if s.State != BracketOpen {
+		return true, nil
+	}
+	rest := s.InMarket - s.Sold
+	if rest <= 0 {
+		s.close(meta)
+		return true, nil
+	}
+
+	meta.HasChanges = true
+	meta.OpDescr = append(meta.OpDescr, "Sell by market (flatten)")
+	s.Stop = BracketLeg{Cnt: rest}
+	s.Stop.OrderId, err = p.SellByMarket(s.InstrumentId, s.Ticker, rest, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	if err != nil {
+		s.Stop = BracketLeg{}
+		return false, smp.GenerateErrorE(500003105, err)
+	}
+	return false, nil
+}
+
+// ResetState - new bracket without profit of previous ones
+func (s *Bracket) ResetState() (err *mft.Error) {
+	if s.State == BracketOpen && s.InMarket > s.Sold {
+		return smp.GenerateError(500003551, s.Type(), s.InMarket-s.Sold)
+	}
+	s.newBracket()
+	s.Profit = 0
+	return nil
+}
+
+// newBracket - clears position and legs of previous bracket
+func (s *Bracket) newBracket() {
+	s.State = BracketWaiting
+	s.InMarket, s.InMarketPrice, s.Sold, s.SoldPrice = 0, 0, 0, 0
+	s.Take, s.Stop, s.BuyBack = BracketLeg{}, BracketLeg{}, BracketLeg{}
+	s.Exit = ""
+}
+
+// close - all lots are sold
+func (s *Bracket) close(meta *smp.MetaForStep) {
+	meta.HasChanges = true
+	s.State = BracketClosed
+	s.Profit = smp.Round(s.Profit+s.SoldPrice-s.InMarketPrice, 6)
+	meta.OpDescr = append(meta.OpDescr, fmt.Sprintf("Bracket is closed by %v", s.Exit))
+}
+
+// pollLeg - applies executed lots of leg (order is cleared when it is finished)
+func (s *Bracket) pollLeg(p smp.StepParams, leg *BracketLeg, exit string, meta *smp.MetaForStep) (err *mft.Error) {
+	status, prices, err := p.StatusSellOrder(s.InstrumentId, s.Ticker, leg.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	if err != nil {
+		return smp.GenerateErrorE(500003101, err, exit)
+	}
+
+	cnt, price := lotPricesSum(prices)
+	if cnt > leg.Filled {
+		meta.HasChanges = true
+		s.Sold += cnt - leg.Filled
+		s.SoldPrice = smp.Round(s.SoldPrice+price-leg.FilledPrice, 6)
+		s.Exit = exit
+		leg.Filled, leg.FilledPrice = cnt, price
+	}
+	if status == smp.Complete || status == smp.Canceled {
+		meta.HasChanges = true
+		*leg = BracketLeg{}
+	}
+	return nil
+}
+
+// cancelLeg - cancels order of leg and applies its executed lots
+func (s *Bracket) cancelLeg(p smp.StepParams, leg *BracketLeg, exit string, meta *smp.MetaForStep) (err *mft.Error) {
+	if leg.OrderId == "" {
+		return nil
+	}
+	_, err = p.CancelSellOrder(s.InstrumentId, s.Ticker, leg.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	if err != nil {
+		return smp.GenerateErrorE(500003102, err, exit)
+	}
+	return s.pollLeg(p, leg, exit, meta)
+}
+
+// resizeTake - amends take profit order to the rest of position (smp.ReplaceSellOrder),
+// the old order is canceled with its executed lots
+func (s *Bracket) resizeTake(p smp.StepParams, meta *smp.MetaForStep) (err *mft.Error) {
+	orderId, err := smp.ReplaceSellOrder(p, s.InstrumentId, s.Ticker, s.Take.OrderId, s.Take.Filled+s.InMarket-s.Sold,
+		s.TakeProfit, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	if err != nil {
+		return smp.GenerateErrorE(500003110, err)
+	}
+	err = s.pollLeg(p, &s.Take, "take_profit", meta)
+	if err != nil {
+		return err
+	}
+	meta.HasChanges = true
+	if orderId != "" {
+		s.Take = BracketLeg{OrderId: orderId, Cnt: s.InMarket - s.Sold}
+	}
+	return nil
+}
+
+// buyBack - cancels legs and buys back by market lots sold over position
+// (returns error of oversell when buy back is placed)
+func (s *Bracket) buyBack(p smp.StepParams, meta *smp.MetaForStep) (err *mft.Error) {
+	err = s.cancelLeg(p, &s.Take, "take_profit", meta)
+	if err != nil {
+		return err
+	}
+	err = s.cancelLeg(p, &s.Stop, "stop_loss", meta)
+	if err != nil {
+		return err
+	}
+	excess := s.Sold - s.InMarket
+	if s.Take.OrderId != "" || s.Stop.OrderId != "" || excess <= 0 {
+		// cancel is not finished
+		return nil
+	}
+
+	meta.HasChanges = true
+	meta.OpDescr = append(meta.OpDescr, fmt.Sprintf("Buy back %v oversold lots by market", excess))
+	s.BuyBack = BracketLeg{Cnt: excess}
+	s.BuyBack.OrderId, err = p.BuyByMarket(s.InstrumentId, s.Ticker, excess, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	if err != nil {
+		s.BuyBack = BracketLeg{}
+		return smp.GenerateErrorE(500003108, err)
+	}
+	return smp.GenerateError(500003107, excess, s.BuyBack.OrderId)
+}
+
+// pollBuyBack - applies bought back lots (they are not sold)
+func (s *Bracket) pollBuyBack(p smp.StepParams, meta *smp.MetaForStep) (err *mft.Error) {
+	status, prices, err := p.StatusBuyOrder(s.InstrumentId, s.Ticker, s.BuyBack.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	if err != nil {
+		return smp.GenerateErrorE(500003101, err, "buy_back")
+	}
+
+	cnt, price := lotPricesSum(prices)
+	if cnt > s.BuyBack.Filled {
+		meta.HasChanges = true
+		s.Sold -= cnt - s.BuyBack.Filled
+		s.SoldPrice = smp.Round(s.SoldPrice-(price-s.BuyBack.FilledPrice), 6)
+		s.BuyBack.Filled, s.BuyBack.FilledPrice = cnt, price
+	}
+	if status == smp.Complete || status == smp.Canceled {
+		meta.HasChanges = true
+		s.BuyBack = BracketLeg{}
+	}
+	return nil
+}
+
+func (s *Bracket) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
+	meta.Name = s.Name
+
+	if s.State == "" {
+		s.State = BracketWaiting
+	}
+
+	if s.State == BracketEntering {
+		status, prices, err := p.StatusBuyOrder(s.InstrumentId, s.Ticker, s.EntryOrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+		if err != nil {
+			return meta, smp.GenerateErrorE(500003100, err)
+		}
+		if status == smp.Complete || status == smp.Canceled {
+			meta.HasChanges = true
+			s.EntryOrderId = ""
+			s.InMarket, s.InMarketPrice = lotPricesSum(prices)
+			s.State = BracketWaiting
+			if s.InMarket > 0 {
+				s.State = BracketOpen
+			}
+		}
+	}
+
+	if s.State == BracketOpen {
+		if s.Take.OrderId != "" {
+			err = s.pollLeg(p, &s.Take, "take_profit", &meta)
+			if err != nil {
+				return meta, err
+			}
+		}
+		if s.Stop.OrderId != "" {
+			err = s.pollLeg(p, &s.Stop, "stop_loss", &meta)
+			if err != nil {
+				return meta, err
+			}
+		}
+		if s.BuyBack.OrderId != "" {
+			err = s.pollBuyBack(p, &meta)
+			if err != nil {
+				return meta, err
+			}
+		}
+		if s.Sold > s.InMarket && s.BuyBack.OrderId == "" {
+			err = s.buyBack(p, &meta)
+			if err != nil {
+				return meta, err
+			}
+		}
+		if s.Sold > s.InMarket || s.BuyBack.OrderId != "" {
+			// buy back of oversold lots is finished before lifecycle and legs
+			return meta, nil
+		}
+	}
+
+	if halt, err := s.Lifecycle.Step(p, s, &meta); halt || err != nil {
+		return meta, err
+	}
+
+	if !s.IsOnline || s.State == BracketClosed {
+		return meta, nil
+	}
+
+	if s.State == BracketWaiting {
+		if s.Volume <= 0 {
+			return meta, nil
+		}
+		meta.HasChanges = true
+		if s.EntryPrice > 0 {
+			s.EntryOrderId, err = p.BuyByPrice(s.InstrumentId, s.Ticker, s.Volume, s.EntryPrice, &smp.MetaForOperations{NameOfStrategy: s.Name})
+		} else {
+			s.EntryOrderId, err = p.BuyByMarket(s.InstrumentId, s.Ticker, s.Volume, &smp.MetaForOperations{NameOfStrategy: s.Name})
+		}
+		if err != nil {
+			s.EntryOrderId = ""
+			return meta, smp.GenerateErrorE(500003103, err)
+		}
+		s.State = BracketEntering
+		return meta, nil
+	}
+
+	if s.State != BracketOpen {
+		return meta, nil
+	}
+
+	// fill of one leg resizes the other leg: OCO pair is canceled and placed again for the rest,
+	// take profit is amended, stop order is canceled and placed again on this step (stop orders are not amended)
+	op, oco := p.(smp.OcoStepParams)
+	oco = oco && s.TakeProfit > 0 && s.StopLoss > 0
+	if s.Take.OrderId != "" && s.Take.Cnt-s.Take.Filled != s.InMarket-s.Sold {
+		if oco {
+			err = s.cancelLeg(p, &s.Take, "take_profit", &meta)
+		} else {
+			err = s.resizeTake(p, &meta)
+		}
+		if err != nil {
+			return meta, err
+		}
+	}
+	if s.Stop.OrderId != "" && s.Stop.Cnt-s.Stop.Filled != s.InMarket-s.Sold {
+		err = s.cancelLeg(p, &s.Stop, "stop_loss", &meta)
+		if err != nil {
+			return meta, err
+		}
+	}
+	if oco && s.Take.OrderId == "" && s.Stop.OrderId != "" {
+		// pair is broken by market: the rest is placed as new pair
+		err = s.cancelLeg(p, &s.Stop, "stop_loss", &meta)
+		if err != nil {
+			return meta, err
+		}
+	}
+	if oco && s.Stop.OrderId == "" && s.Take.OrderId != "" {
+		err = s.cancelLeg(p, &s.Take, "take_profit", &meta)
+		if err != nil {
+			return meta, err
+		}
+	}
+	if s.Take.OrderId != "" && s.Take.Cnt-s.Take.Filled != s.InMarket-s.Sold ||
+		s.Stop.OrderId != "" && s.Stop.Cnt-s.Stop.Filled != s.InMarket-s.Sold ||
+		oco && (s.Take.OrderId == "") != (s.Stop.OrderId == "") {
+		// cancel is not finished
+		return meta, nil
+	}
+
+	rest := s.InMarket - s.Sold
+	if rest <= 0 {
+		s.close(&meta)
+		return meta, nil
+	}
+
+	if oco {
+		if s.Take.OrderId != "" {
+			return meta, nil
+		}
+		meta.HasChanges = true
+		s.Take, s.Stop = BracketLeg{Cnt: rest}, BracketLeg{Cnt: rest}
+		s.Take.OrderId, s.Stop.OrderId, err = op.SellOco(s.InstrumentId, s.Ticker, rest, s.TakeProfit, s.StopLoss,
+			&smp.MetaForOperations{NameOfStrategy: s.Name, IsStopLoss: true})
+		if err != nil {
+			s.Take, s.Stop = BracketLeg{}, BracketLeg{}
+			return meta, smp.GenerateErrorE(500003109, err)
+		}
+		return meta, nil
+	}
+
+	sp, stopOrders := p.(smp.StopOrderStepParams)
+	if !stopOrders && s.Stop.OrderId == "" && s.StopLoss > 0 {
+		// market does not support stop orders: sell by market when price falls under stop
+		ob, err := p.GetOrderBook(s.InstrumentId, s.Ticker)
+		if err != nil {
+			return meta, smp.GenerateErrorE(500003106, err)
+		}
+		if ob.SellPrice() <= s.StopLoss {
+			err = s.cancelLeg(p, &s.Take, "take_profit", &meta)
+			if err != nil {
+				return meta, err
+			}
+			if s.Take.OrderId != "" {
+				return meta, nil
+			}
+			rest = s.InMarket - s.Sold
+			if rest <= 0 {
+				return meta, nil
+			}
+			meta.HasChanges = true
+			meta.IsStopLoss = true
+			s.Stop = BracketLeg{Cnt: rest}
+			s.Stop.OrderId, err = p.SellByMarket(s.InstrumentId, s.Ticker, rest, &smp.MetaForOperations{NameOfStrategy: s.Name, IsStopLoss: true})
+			if err != nil {
+				s.Stop = BracketLeg{}
+				return meta, smp.GenerateErrorE(500003105, err)
+			}
+			return meta, nil
+		}
+	}
+
+	if s.Take.OrderId == "" && s.TakeProfit > 0 && (stopOrders || s.Stop.OrderId == "") {
+		meta.HasChanges = true
+		s.Take = BracketLeg{Cnt: rest}
+		s.Take.OrderId, err = p.SellByPrice(s.InstrumentId, s.Ticker, rest, s.TakeProfit, &smp.MetaForOperations{NameOfStrategy: s.Name})
+		if err != nil {
+			s.Take = BracketLeg{}
+			return meta, smp.GenerateErrorE(500003104, err)
+		}
+	}
+
+	if stopOrders && s.Stop.OrderId == "" && s.StopLoss > 0 {
+		meta.HasChanges = true
+		s.Stop = BracketLeg{Cnt: rest}
+		s.Stop.OrderId, err = sp.SellStopByMarket(s.InstrumentId, s.Ticker, rest, s.StopLoss, &smp.MetaForOperations{NameOfStrategy: s.Name, IsStopLoss: true})
+		if err != nil {
+			s.Stop = BracketLeg{}
+			return meta, smp.GenerateErrorE(500003105, err)
+		}
+	}
+
+	return meta, nil
+}
@@ -0,0 +1,19 @@
+// Code generated by mfjson for marshaling/unmarshaling. DO NOT EDIT.
+// https://github.com/myfantasy/json
+
+package strategies
+
+import (
+	mfj "github.com/myfantasy/json"
+)
+func (obj *Bracket) UnmarshalJSONTypeName() string {
+	return "smp.strategies.bracket"
+}
+
+func init() {
+	mfj.GlobalStructFactory.Add("smp.strategies.bracket", func() mfj.JsonInterfaceMarshaller { return &Bracket{} })
+	mfj.GlobalStructFactory.AddNil("smp.strategies.bracket", func() mfj.JsonInterfaceMarshaller {
+		var out *Bracket
+		return out
+	})
+}
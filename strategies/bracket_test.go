@@ -0,0 +1,271 @@
+package strategies
+
+import (
+	"testing"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+	"github.com/myfantasy/stock_market_primitives/market"
+)
+
+func testBracket(volume int) *Bracket {
+	return &Bracket{
+		Name:       "test",
+		Ticker:     "TTTT",
+		Volume:     volume,
+		TakeProfit: 105,
+		StopLoss:   95,
+		IsOnline:   true,
+	}
+}
+
+func TestBracketTakeProfit(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{106, 107, 105, 106},
+			[4]float64{106, 107, 105, 106},
+		),
+	}
+	s := testBracket(5)
+
+	stepExecution(t, p, s)
+	if s.State != BracketEntering {
+		t.Fatalf("entry is not placed: %v", s.Json())
+	}
+
+	stepExecution(t, p, s)
+	if s.State != BracketOpen || s.InMarket != 5 || s.Take.OrderId == "" || s.Stop.OrderId == "" {
+		t.Fatalf("OCO is not placed: %v", s.Json())
+	}
+
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	if s.State != BracketClosed || s.Exit != "take_profit" || s.Stop.OrderId != "" || s.Profit != 5*105-5*101 {
+		t.Fatalf("wrong state after take profit: %v", s.Json())
+	}
+	if s.Status().IsOnline {
+		t.Fatal("closed bracket is online")
+	}
+}
+
+func TestBracketStopLoss(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{96, 96, 94, 94},
+			[4]float64{94, 94, 93, 93},
+			[4]float64{94, 94, 93, 93},
+		),
+	}
+	s := testBracket(5)
+
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	if s.State != BracketClosed || s.Exit != "stop_loss" || s.Take.OrderId != "" || s.Sold != 5 {
+		t.Fatalf("wrong state after stop loss: %v", s.Json())
+	}
+}
+
+func TestBracketStopLossByMarket(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{96, 96, 94, 94},
+			[4]float64{94, 94, 93, 93},
+			[4]float64{94, 94, 93, 93},
+		),
+	}
+	pp := &plainStepParams{StepParams: p}
+	s := testBracket(5)
+
+	p.DoStep()
+	s.Step(pp)
+	p.DoStep()
+	s.Step(pp)
+	if s.Take.OrderId == "" || s.Stop.OrderId != "" {
+		t.Fatalf("take profit only is expected without stop orders: %v", s.Json())
+	}
+
+	p.DoStep()
+	if _, err := s.Step(pp); err != nil {
+		t.Fatal(err)
+	}
+	if s.Take.OrderId != "" || s.Stop.OrderId == "" {
+		t.Fatalf("take profit is not replaced by stop loss: %v", s.Json())
+	}
+
+	p.DoStep()
+	if _, err := s.Step(pp); err != nil {
+		t.Fatal(err)
+	}
+	if s.State != BracketClosed || s.Exit != "stop_loss" || s.Profit != 5*93-5*101 {
+		t.Fatalf("wrong state after stop loss: %v", s.Json())
+	}
+}
+
+func TestBracketPartialFill(t *testing.T) {
+	p := &market.VirtualMarket{
+		Candles: testCandles(
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{104, 106, 104, 105},
+			[4]float64{104, 104, 100, 101},
+			[4]float64{96, 96, 94, 94},
+			[4]float64{94, 94, 93, 93},
+		),
+	}
+	p.Candles[3].Vol = 50
+	s := testBracket(80)
+	s.EntryPrice = 100
+
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	if s.State != BracketOpen || s.InMarket != 80 || s.Take.Cnt != 80 || s.Stop.Cnt != 80 {
+		t.Fatalf("entry is not filled: %v", s.Json())
+	}
+
+	// take profit is filled by candle volume only, stop loss is canceled by market,
+	// the rest is placed as new OCO pair
+	stepExecution(t, p, s)
+	if s.Sold != 50 || s.Take.Cnt != 30 || s.Stop.Cnt != 30 || s.Take.OrderId == "" || s.Stop.OrderId == "" {
+		t.Fatalf("OCO pair is not placed for the rest: %v", s.Json())
+	}
+
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	if s.State != BracketClosed || s.Exit != "stop_loss" || s.Sold != 80 || s.Take.OrderId != "" {
+		t.Fatalf("wrong state after stop loss: %v", s.Json())
+	}
+	if s.Profit != 50*105+30*95-80*100 {
+		t.Fatalf("wrong profit: %v", s.Profit)
+	}
+}
+
+func TestBracketOcoCandleReachesBothPrices(t *testing.T) {
+	p := &market.VirtualMarket{
+		Candles: testCandles(
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 106, 94, 100},
+			[4]float64{100, 101, 99, 100},
+		),
+	}
+	s := testBracket(5)
+
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	if s.State != BracketClosed || s.Exit != "stop_loss" || s.Sold != 5 || s.Profit != 5*95-5*100 {
+		t.Fatalf("only stop loss of OCO pair is expected: %v", s.Json())
+	}
+	if pos, _ := p.GetPosition("", "TTTT"); pos.Quantity != 0 {
+		t.Fatalf("position is not closed: %v", pos)
+	}
+}
+
+// stopStepParams - market with stop orders and without OCO pairs
+type stopStepParams struct {
+	smp.StopOrderStepParams
+}
+
+func TestBracketOversell(t *testing.T) {
+	p := &market.VirtualMarket{
+		Candles: testCandles(
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 106, 94, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+		),
+	}
+	pp := &stopStepParams{StopOrderStepParams: p}
+	s := testBracket(5)
+
+	step := func() (err *mft.Error) {
+		p.DoStep()
+		_, err = s.Step(pp)
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// both legs are executed by one candle without OCO pair of market
+	err := step()
+	if err == nil || err.Code != 500003107 || s.BuyBack.OrderId == "" || s.BuyBack.Cnt != 5 || s.Sold != 10 {
+		t.Fatalf("oversell error and buy back are expected, got %v: %v", err, s.Json())
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.State != BracketClosed || s.Sold != 5 || s.BuyBack.OrderId != "" {
+		t.Fatalf("closed bracket is expected after buy back: %v", s.Json())
+	}
+	if s.Profit != 5*105+5*95-5*100-5*100 {
+		t.Fatalf("wrong profit after buy back: %v", s.Json())
+	}
+	if pos, _ := p.GetPosition("", "TTTT"); pos.Quantity != 0 {
+		t.Fatalf("position is not closed: %v", pos)
+	}
+}
+
+// partialStopParams - stop order of bracket is executed partially by 2 lots (as by broker)
+type partialStopParams struct {
+	*market.StepParamsDummy
+	stopOrderId string
+	replaced    int
+}
+
+func (p *partialStopParams) StatusSellOrder(instrumentId string, ticker string, orderId string,
+	meta *smp.MetaForOperations) (status smp.StatusOrder, prices []smp.LotPrices, err *mft.Error) {
+	if orderId == p.stopOrderId {
+		return smp.Wait, []smp.LotPrices{{Count: 2, Price: 95}}, nil
+	}
+	return p.StepParamsDummy.StatusSellOrder(instrumentId, ticker, orderId, meta)
+}
+
+func (p *partialStopParams) ReplaceSellOrder(instrumentId string, ticker string, orderId string, cnt int, price float64,
+	meta *smp.MetaForOperations) (newOrderId string, err *mft.Error) {
+	p.replaced++
+	return p.StepParamsDummy.ReplaceSellOrder(instrumentId, ticker, orderId, cnt, price, meta)
+}
+
+func TestBracketResizeTakeProfit(t *testing.T) {
+	p := &partialStopParams{StepParamsDummy: &market.StepParamsDummy{Candles: flatCandles(6)}}
+	s := testBracket(5)
+
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	if s.State != BracketOpen || s.Take.Cnt != 5 || s.Stop.Cnt != 5 {
+		t.Fatalf("legs are not placed: %v", s.Json())
+	}
+
+	// take profit is amended to the rest of position
+	takeOrderId := s.Take.OrderId
+	p.stopOrderId = s.Stop.OrderId
+	stepExecution(t, p, s)
+	if p.replaced != 1 || s.Sold != 2 || s.Take.Cnt != 3 || s.Take.OrderId == "" || s.Take.OrderId == takeOrderId {
+		t.Fatalf("take profit is not amended: %v", s.Json())
+	}
+	if s.Stop.OrderId != p.stopOrderId {
+		t.Fatalf("stop loss should stay: %v", s.Json())
+	}
+}
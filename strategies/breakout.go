@@ -0,0 +1,282 @@
+package strategies
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+//go:generate mfjson breakout.go
+
+// Пробой канала (Breakout)
+
+var (
+	_ smp.Strategy = &Breakout{}
+)
+
+//mfjson:interface smp.strategies.breakout
+type Breakout struct {
+	Name string `json:"name"`
+
+	InstrumentId string `json:"instrument_id"`
+	Ticker       string `json:"ticker"`
+
+	Volume int `json:"volume"`
+	// Period - candles of channel; position is bought when price is higher than channel
+	Period int `json:"period"`
+	// ExitPeriod - candles of exit channel; position is sold when price is lower than exit channel (0 - no exit channel)
+	ExitPeriod int `json:"exit_period"`
+	// AtrPeriod, AtrMultiplier - stop is AtrMultiplier * ATR under entry price
+	AtrPeriod     int     `json:"atr_period"`
+	AtrMultiplier float64 `json:"atr_multiplier"`
+	// Frame - candle frame in minutes
+	Frame int `json:"frame"`
+
+	IsOnline bool `json:"is_online"`
+
+	ChannelHigh float64 `json:"channel_high"`
+	ChannelLow  float64 `json:"channel_low"`
+	StopPrice   float64 `json:"stop_price"`
+
+	InMarket      int     `json:"in_market"`
+	InMarketPrice float64 `json:"in_market_price"`
+	OrderId       string  `json:"order_id"`
+	OrderBuy      bool    `json:"order_buy"`
+
+	Profit    float64 `json:"profit"`
+	Iteration int     `json:"iteration"`
+
+	Lifecycle smp.Lifecycle `json:"lifecycle"`
+}
+
+func (s *Breakout) Type() string {
+	return "breakout"
+}
+
+func (s *Breakout) String() string {
+	return "breakout"
+}
+
+func (s *Breakout) Status() smp.StartegyStatus {
+	return s.Lifecycle.Status(s.IsOnline)
+}
+func (s *Breakout) Json() string {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return string(b)
+}
+
+// commands - commands of strategy
+func (s *Breakout) commands() smp.CommandDefs {
+	return lifecycleCommands(showCommand("Отобразить", s.String), s.Lifecycle.Commands(s.Type(), s, &s.IsOnline), smp.CommandDefs{
+		{Command: SetVolume, Description: "Установить объём", Example: "set_vol 25",
+			Params: []smp.Param{smp.IntParam("", "объём (кол-во лотов)", &s.Volume).WithMin(0)}},
+		{Command: SetPeriod, Description: "Установить кол-во свечей канала входа", Example: "set_period 20",
+			Params: []smp.Param{smp.IntParam("", "кол-во свечей", &s.Period).WithMin(1)}},
+		{Command: SetExitPeriod, Description: "Установить кол-во свечей канала выхода (0 - без канала выхода)",
+			Example: "set_exit_period 10",
+			Params:  []smp.Param{smp.IntParam("", "кол-во свечей", &s.ExitPeriod).WithMin(0)}},
+		{Command: SetAtrPeriod, Description: "Установить кол-во свечей для ATR", Example: "set_atr_period 14",
+			Params: []smp.Param{smp.IntParam("", "кол-во свечей", &s.AtrPeriod).WithMin(1)}},
+		{Command: SetAtrMultiplier, Description: "Установить множитель ATR для стопа (0 - без стопа)",
+			Example: "set_atr_multiplier 2",
+			Params:  []smp.Param{smp.FloatParam("", "множитель", &s.AtrMultiplier).WithMin(0)}},
+		{Command: SetFrame, Description: "Установить размер свечи", Example: "set_frame 60",
+			Params: []smp.Param{smp.IntParam("", "минуты", &s.Frame).WithMin(1)}},
+	})
+}
+
+func (s *Breakout) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	return s.commands().Do(s.Type(), cmd, params)
+}
+
+func (s *Breakout) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
+
+func (s *Breakout) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), `breakout - стратегия, пробой канала (Donchian)
+	При цене выше максимума канала покупка, продажа по стопу ATR или при цене ниже минимума канала выхода`)
+}
+
+// applyOrder - applies status of market order
+func (s *Breakout) applyOrder(status smp.StatusOrder, prices []smp.LotPrices, meta *smp.MetaForStep) {
+	if status != smp.Complete && status != smp.Canceled {
+		return
+	}
+
+	cnt, price := lotPricesSum(prices)
+	meta.HasChanges = true
+	s.OrderId = ""
+	if !s.OrderBuy {
+		cnt, price = -cnt, -price
+	}
+	s.InMarket += cnt
+	s.InMarketPrice = smp.Round(s.InMarketPrice+price, 6)
+	if s.InMarket == 0 {
+		s.Iteration++
+		s.Profit = smp.Round(s.Profit-s.InMarketPrice, 6)
+		s.InMarketPrice = 0
+	}
+}
+
+// order - market order to change position by cnt lots
+func (s *Breakout) order(p smp.StepParams, cnt int, meta *smp.MetaForStep) (err *mft.Error) {
+	if cnt == 0 {
+		return nil
+	}
+	meta.HasChanges = true
+	s.OrderBuy = cnt > 0
+	if s.OrderBuy {
+		s.OrderId, err = p.BuyByMarket(s.InstrumentId, s.Ticker, cnt, &smp.MetaForOperations{NameOfStrategy: s.Name, IsStopLoss: meta.IsStopLoss})
+		if err != nil {
+			s.OrderId = ""
+			return smp.GenerateErrorE(500002003, err)
+		}
+		return nil
+	}
+	s.OrderId, err = p.SellByMarket(s.InstrumentId, s.Ticker, -cnt, &smp.MetaForOperations{NameOfStrategy: s.Name, IsStopLoss: meta.IsStopLoss})
+	if err != nil {
+		s.OrderId = ""
+		return smp.GenerateErrorE(500002004, err)
+	}
+	return nil
+}
+
+// checkOrder - status of active order
+func (s *Breakout) checkOrder(p smp.StepParams, meta *smp.MetaForStep) (err *mft.Error) {
+	if s.OrderId == "" {
+		return nil
+	}
+	var status smp.StatusOrder
+	var prices []smp.LotPrices
+	if s.OrderBuy {
+		status, prices, err = p.StatusBuyOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	} else {
+		status, prices, err = p.StatusSellOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	}
+	if err != nil {
+		return smp.GenerateErrorE(500002002, err)
+	}
+	s.applyOrder(status, prices, meta)
+	return nil
+}
+
+// ActiveOrders - market order of strategy
+func (s *Breakout) ActiveOrders() []smp.ActiveOrder {
+	if s.OrderId == "" {
+		return nil
+	}
+	return []smp.ActiveOrder{{InstrumentId: s.InstrumentId, Ticker: s.Ticker, OrderId: s.OrderId, Buy: s.OrderBuy}}
+}
+
+// FlattenPosition - closes position by market
+func (s *Breakout) FlattenPosition(p smp.StepParams, meta *smp.MetaForStep) (done bool, err *mft.Error) {
+	if s.InMarket == 0 {
+		return true, nil
+	}
+	meta.OpDescr = append(meta.OpDescr, "Flatten")
+	return false, s.order(p, -s.InMarket, meta)
+}
+
+// ResetState - clears channel, stop, profit and iterations
+func (s *Breakout) ResetState() (err *mft.Error) {
+	if s.InMarket != 0 {
+		return smp.GenerateError(500003551, s.Type(), s.InMarket)
+	}
+	s.ChannelHigh = 0
+	s.ChannelLow = 0
+	s.StopPrice = 0
+	s.InMarketPrice = 0
+	s.Profit = 0
+	s.Iteration = 0
+	return nil
+}
+
+func (s *Breakout) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
+	meta.Name = s.Name
+
+	err = s.checkOrder(p, &meta)
+	if err != nil {
+		return meta, err
+	}
+
+	if halt, err := s.Lifecycle.Step(p, s, &meta); halt || err != nil {
+		return meta, err
+	}
+
+	if !s.IsOnline || s.OrderId != "" || s.Volume <= 0 || s.Period <= 0 {
+		return meta, nil
+	}
+
+	ob, err := p.GetOrderBook(s.InstrumentId, s.Ticker)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500002000, err)
+	}
+	if ob.TradeStatus != smp.NormalTrading {
+		return meta, nil
+	}
+
+	count := s.Period
+	if s.ExitPeriod > count {
+		count = s.ExitPeriod
+	}
+	if s.AtrPeriod+1 > count {
+		count = s.AtrPeriod + 1
+	}
+	cs, err := historyCandles(p, s.InstrumentId, s.Ticker, ob.Time, s.Frame, count)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500002001, err)
+	}
+	if cs.Len() < s.Period {
+		return meta, nil
+	}
+
+	price := ob.Price()
+	_, _, _, high := cs[cs.Len()-s.Period:].Bounds()
+	low := 0.0
+	if s.ExitPeriod > 0 && cs.Len() >= s.ExitPeriod {
+		_, _, low, _ = cs[cs.Len()-s.ExitPeriod:].Bounds()
+	}
+	if s.ChannelHigh != high || s.ChannelLow != low {
+		meta.HasChanges = true
+		s.ChannelHigh, s.ChannelLow = high, low
+	}
+
+	if s.InMarket <= 0 {
+		if price <= high {
+			return meta, nil
+		}
+		meta.OpDescr = append(meta.OpDescr, "Breakout of channel")
+		s.StopPrice = 0
+		if s.AtrMultiplier > 0 && s.AtrPeriod > 0 {
+			s.StopPrice = smp.Round(math.Max(price-s.AtrMultiplier*cs.ATR(s.AtrPeriod), 0), 6)
+		}
+		err = s.order(p, s.Volume-s.InMarket, &meta)
+		if err != nil {
+			return meta, err
+		}
+		return meta, nil
+	}
+
+	switch {
+	case s.StopPrice > 0 && price <= s.StopPrice:
+		meta.OpDescr = append(meta.OpDescr, "ATR stop")
+		meta.IsStopLoss = true
+	case low > 0 && price < low:
+		meta.OpDescr = append(meta.OpDescr, "Exit channel")
+	default:
+		return meta, nil
+	}
+
+	err = s.order(p, -s.InMarket, &meta)
+	if err != nil {
+		return meta, err
+	}
+	return meta, nil
+}
@@ -0,0 +1,19 @@
+// Code generated by mfjson for marshaling/unmarshaling. DO NOT EDIT.
+// https://github.com/myfantasy/json
+
+package strategies
+
+import (
+	mfj "github.com/myfantasy/json"
+)
+func (obj *Breakout) UnmarshalJSONTypeName() string {
+	return "smp.strategies.breakout"
+}
+
+func init() {
+	mfj.GlobalStructFactory.Add("smp.strategies.breakout", func() mfj.JsonInterfaceMarshaller { return &Breakout{} })
+	mfj.GlobalStructFactory.AddNil("smp.strategies.breakout", func() mfj.JsonInterfaceMarshaller {
+		var out *Breakout
+		return out
+	})
+}
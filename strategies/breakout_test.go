@@ -0,0 +1,102 @@
+package strategies
+
+import (
+	"testing"
+
+	"github.com/myfantasy/stock_market_primitives/market"
+)
+
+func TestBreakoutATRStop(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{101, 103, 101, 103},
+			[4]float64{103, 103.5, 102.5, 103},
+			[4]float64{102, 102, 100.5, 100.5},
+			[4]float64{100.5, 100.5, 100, 100},
+			[4]float64{100, 100.5, 99.5, 100},
+		),
+	}
+	s := &Breakout{
+		Ticker:        "TTTT",
+		Volume:        2,
+		Period:        3,
+		AtrPeriod:     3,
+		AtrMultiplier: 1,
+		IsOnline:      true,
+	}
+
+	step := func() {
+		t.Helper()
+		p.DoStep()
+		_, err := s.Step(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	step()
+	step()
+	step()
+	if s.OrderId != "" || s.ChannelHigh != 101 {
+		t.Fatalf("wrong state inside channel: %v", s.Json())
+	}
+
+	// price 103 is higher than channel
+	step()
+	if s.OrderId == "" || s.StopPrice != 101 {
+		t.Fatalf("breakout is not bought: %v", s.Json())
+	}
+
+	step()
+	if s.InMarket != 2 {
+		t.Fatalf("position is not opened: %v", s.Json())
+	}
+
+	// price 100.5 is under stop
+	step()
+	if s.OrderId == "" {
+		t.Fatalf("stop is not executed: %v", s.Json())
+	}
+	step()
+	if s.InMarket != 0 || s.Iteration != 1 {
+		t.Fatalf("position is not closed: %v", s.Json())
+	}
+}
+
+func TestBreakoutExitChannel(t *testing.T) {
+	p := &market.VirtualMarket{
+		Candles: testCandles(
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{101, 103, 101, 103},
+			[4]float64{103, 106, 103, 106},
+			[4]float64{106, 108, 106, 108},
+			[4]float64{108, 108, 104, 104},
+			[4]float64{104, 104, 103, 103},
+			[4]float64{103, 103, 102, 102},
+			[4]float64{102, 102, 101, 101},
+		),
+	}
+	s := &Breakout{
+		Ticker:     "TTTT",
+		Volume:     2,
+		Period:     2,
+		ExitPeriod: 2,
+		IsOnline:   true,
+	}
+
+	for p.DoStep() {
+		_, err := s.Step(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.Iteration != 1 || s.InMarket != 0 {
+		t.Fatalf("position is not closed by exit channel: %v", s.Json())
+	}
+}
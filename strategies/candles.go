@@ -0,0 +1,72 @@
+package strategies
+
+import (
+	"time"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+const (
+	// historyMaxWiden - max window of history request in windows of count candles
+	// (nights, weekends and holidays have no candles)
+	historyMaxWiden = 8
+	// historyMaxGap - max period without candles (holidays) added to max window of history request
+	historyMaxGap = 14 * 24 * time.Hour
+)
+
+// frameDuration - duration of candle of frame minutes
+func frameDuration(frame int) time.Duration {
+	if frame <= 0 {
+		frame = 1
+	}
+	return time.Duration(frame) * time.Minute
+}
+
+// historyWindows - calls fetch with start of history before tm; window of count candles is widened twice
+// until fetch is done or max window is reached
+func historyWindows(tm time.Time, duration time.Duration, count int,
+	fetch func(from time.Time) (done bool, err *mft.Error)) (err *mft.Error) {
+	window := duration * time.Duration(count+1)
+	maxWindow := window*historyMaxWiden + historyMaxGap
+	for {
+		done, err := fetch(tm.Add(-window))
+		if err != nil || done || window >= maxWindow {
+			return err
+		}
+		window *= 2
+		if window > maxWindow {
+			window = maxWindow
+		}
+	}
+}
+
+// frameCandles - candles of frame minutes from from to tm (1 minute candles are aggregated)
+func frameCandles(p smp.StepParams, instrumentId string, ticker string, from time.Time, tm time.Time,
+	duration time.Duration) (cs smp.Candles, err *mft.Error) {
+	cs, err = p.GetCandles(instrumentId, ticker, from, tm)
+	if err != nil {
+		return nil, err
+	}
+	if duration > time.Minute && cs.Len() > 0 {
+		cs = cs.Aggregate(duration)
+	}
+	return cs, nil
+}
+
+// historyCandles - last count candles of frame minutes before tm (1 minute candles are aggregated)
+func historyCandles(p smp.StepParams, instrumentId string, ticker string, tm time.Time,
+	frame int, count int) (cs smp.Candles, err *mft.Error) {
+	duration := frameDuration(frame)
+	err = historyWindows(tm, duration, count, func(from time.Time) (done bool, err *mft.Error) {
+		cs, err = frameCandles(p, instrumentId, ticker, from, tm, duration)
+		return cs.Len() >= count, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cs.Len() > count {
+		cs = cs[cs.Len()-count:]
+	}
+	return cs, nil
+}
@@ -0,0 +1,78 @@
+package strategies
+
+import (
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+// showCommand - show command with message of strategy
+func showCommand(description string, message func() string) smp.CommandDef {
+	return smp.CommandDef{
+		Command:     smp.ShowCommand,
+		Description: description,
+		Example:     "show",
+		Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+			res.Message = message()
+			return res, nil
+		},
+	}
+}
+
+// lifecycleCommands - show command, lifecycle commands (smp.Lifecycle.Commands) and own commands of strategy
+func lifecycleCommands(show smp.CommandDef, lifecycle smp.CommandDefs, defs smp.CommandDefs) smp.CommandDefs {
+	return append(append(smp.CommandDefs{show}, lifecycle...), defs...)
+}
+
+// childrenLifecycleCommands - start, stop, pause, resume and reset commands sent to every child of container
+// (start and stop set isOnline of container)
+func childrenLifecycleCommands(strategy string, isOnline *bool, children func() []smp.Strategy) smp.CommandDefs {
+	forward := func(cmd smp.Command, online *bool) smp.CommandDo {
+		return func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+			var params map[string]string
+			if mode := v.String(""); mode != "" {
+				params = map[string]string{"": mode}
+			}
+			list := children()
+			for _, c := range list {
+				_, _, er := c.Command(cmd, params)
+				err = err.AppendList(er)
+			}
+			if err != nil {
+				return res, smp.GenerateErrorSubList(500003550, err.InternalErrors, strategy, cmd, len(err.InternalErrors), len(list))
+			}
+			if online != nil {
+				*isOnline = *online
+			}
+			return res, nil
+		}
+	}
+	start, stop := true, false
+
+	return smp.CommandDefs{
+		{Command: smp.StartCommand, Description: "Старт всех внутренних стратегий", Example: "start",
+			Do: forward(smp.StartCommand, &start)},
+		{Command: smp.StopCommand, Description: "Стоп всех внутренних стратегий (по умолчанию заявки снимаются)", Example: "stop keep",
+			Params: []smp.Param{smp.StopModeParam()}, Do: forward(smp.StopCommand, &stop)},
+		{Command: smp.PauseCommand, Description: "Приостановить все внутренние стратегии", Example: "pause",
+			Do: forward(smp.PauseCommand, nil)},
+		{Command: smp.ResumeCommand, Description: "Возобновить все внутренние стратегии", Example: "resume",
+			Do: forward(smp.ResumeCommand, nil)},
+		{Command: smp.ResetCommand, Description: "Сбросить торговое состояние всех внутренних стратегий", Example: "reset",
+			Do: forward(smp.ResetCommand, nil)},
+	}
+}
+
+// childrenStatus - status of container: online flag of container and combined lifecycle state of children
+func childrenStatus(isOnline bool, children []smp.Strategy) smp.StartegyStatus {
+	if len(children) == 0 {
+		return (&smp.Lifecycle{}).Status(isOnline)
+	}
+	states := make([]smp.LifecycleState, 0, len(children))
+	for _, c := range children {
+		states = append(states, c.Status().State)
+	}
+	return smp.StartegyStatus{
+		IsOnline: isOnline,
+		State:    smp.CombineStates(states...),
+	}
+}
@@ -0,0 +1,231 @@
+package strategies
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	smp "github.com/myfantasy/stock_market_primitives"
+	"github.com/myfantasy/stock_market_primitives/market"
+)
+
+func TestCommandsParams(t *testing.T) {
+	for _, tp := range smp.GlobalStrategyRegistry.Types() {
+		s, err := smp.GlobalStrategyRegistry.New(tp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for cmd, ci := range s.AllowCommands(smp.DefaultLocale) {
+			for _, p := range ci.Params {
+				if p.Target != nil && reflect.ValueOf(p.Target).Kind() != reflect.Ptr {
+					t.Fatalf("%v: %v: target of param `%v` is not a pointer", tp, cmd, p.Name)
+				}
+			}
+		}
+
+		if _, ok, err := s.Command(smp.ShowCommand, nil); !ok || err != nil {
+			t.Fatalf("%v: show: %v", tp, err)
+		}
+		if _, _, err := s.Command("unknown", nil); err == nil {
+			t.Fatalf("%v: unknown command should fail", tp)
+		}
+	}
+}
+
+// checkExamples - every command of strategy has example parsed by params of command
+func checkExamples(t *testing.T, tp string, s smp.Strategy) {
+	t.Helper()
+	for cmd, ci := range s.AllowCommands(smp.DefaultLocale) {
+		if ci.Example == "" {
+			t.Errorf("%v: %v: no example", tp, cmd)
+			continue
+		}
+		c, err := smp.ParseCommand(ci.Example)
+		if err != nil {
+			t.Fatalf("%v: %v: example `%v`: %v", tp, cmd, ci.Example, err)
+		}
+		if c.Command != cmd {
+			t.Fatalf("%v: %v: example `%v` is command %v", tp, cmd, ci.Example, c.Command)
+		}
+		if _, err := (smp.CommandDef{Command: cmd, Params: ci.Params}).Parse(tp, c.Params); err != nil {
+			t.Fatalf("%v: %v: example `%v`: %v", tp, cmd, ci.Example, err)
+		}
+	}
+}
+
+func TestCommandsExamples(t *testing.T) {
+	for _, tp := range smp.GlobalStrategyRegistry.Types() {
+		s, err := smp.GlobalStrategyRegistry.New(tp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkExamples(t, tp, s)
+	}
+}
+
+func TestCommandsExamplesContainers(t *testing.T) {
+	grid := &Grid{Name: "grid", Ticker: "TTTT", LevelPrice: 100, PriceStep: 2, Volume: 2}
+	group := &WingedSwingGroup{Name: "group", Ticker: "TTTT", LevelPrice: 100, PriceBetween: 2, PriceUp: 5, Volume: 1}
+	for _, c := range []smp.Strategy{grid, group} {
+		if _, _, err := c.Command(Render, map[string]string{"f": "-1", "t": "1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(grid.Levels) != 3 || len(group.Swings) != 3 {
+		t.Fatalf("containers are not rendered: %v %v", len(grid.Levels), len(group.Swings))
+	}
+	s := testComposite(t)
+	if err := s.Add("grid", nil, grid); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("group", nil, group); err != nil {
+		t.Fatal(err)
+	}
+
+	// commands of children are routed by composite
+	checkExamples(t, s.Type(), s)
+	for _, it := range s.Items {
+		checkExamples(t, it.Name, it.Strategy)
+	}
+	for _, c := range append(grid.children(), group.children()...) {
+		checkExamples(t, c.Type(), c)
+	}
+}
+
+// hasCyrillic - text is not translated from DefaultLocale
+func hasCyrillic(text string) bool {
+	return strings.IndexFunc(text, func(r rune) bool { return unicode.Is(unicode.Cyrillic, r) }) >= 0
+}
+
+func TestCommandsLocaleEn(t *testing.T) {
+	params := map[smp.MessageKey]bool{}
+	for _, tp := range smp.GlobalStrategyRegistry.Types() {
+		s, err := smp.GlobalStrategyRegistry.New(tp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := s.Description(smp.LocaleEn); hasCyrillic(d) || d == s.Description(smp.DefaultLocale) {
+			t.Fatalf("%v: description is not translated: %v", tp, d)
+		}
+		params[smp.MessageKey{Strategy: tp}] = true
+		for cmd, ci := range s.AllowCommands(smp.LocaleEn) {
+			if hasCyrillic(ci.Description) || hasCyrillic(ci.ParamsDescription) {
+				t.Fatalf("%v: %v: command is not translated: %v %v", tp, cmd, ci.Description, ci.ParamsDescription)
+			}
+			params[smp.MessageKey{Strategy: tp, Command: cmd}] = true
+			params[smp.MessageKey{Command: cmd}] = true
+			for _, p := range ci.Params {
+				name := p.Name
+				if name == "" {
+					name = smp.PositionalParam
+				}
+				params[smp.MessageKey{Strategy: tp, Command: cmd, Param: name}] = true
+				params[smp.MessageKey{Strategy: tp, Param: name}] = true
+				params[smp.MessageKey{Command: cmd, Param: name}] = true
+			}
+		}
+	}
+
+	// catalog has no texts of removed commands and params
+	for _, key := range smp.GlobalMessageCatalog.Keys(smp.LocaleEn) {
+		if !params[key] {
+			t.Fatalf("text of unknown command or param: %+v", key)
+		}
+	}
+}
+
+func TestCommandsTypedErrors(t *testing.T) {
+	s := &Bracket{Volume: 5}
+	cases := []struct {
+		params map[string]string
+		code   int
+	}{
+		{nil, 500003601},
+		{map[string]string{"": "x"}, 500003602},
+		{map[string]string{"": "-1"}, 500003603},
+		{map[string]string{"": "1", "child": "a"}, 500003606},
+	}
+	for _, c := range cases {
+		_, ok, err := s.Command(SetVolume, c.params)
+		if ok || err == nil || err.Code != c.code {
+			t.Fatalf("%v: error %v expected, got %v", c.params, c.code, err)
+		}
+	}
+	if s.Volume != 5 {
+		t.Fatalf("volume is changed by failed command: %v", s.Volume)
+	}
+
+	_, ok, err := s.Command(SetVolume, map[string]string{"": "7"})
+	if !ok || err != nil || s.Volume != 7 {
+		t.Fatalf("set_vol: %v %v", s.Volume, err)
+	}
+}
+
+func TestExecuteCommands(t *testing.T) {
+	s := &Bracket{Name: "b"}
+	res, err := smp.ExecuteCommands(s, "set_vol 10; set_take_profit 360\nset_stop_loss 330; start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Volume != 10 || s.TakeProfit != 360 || s.StopLoss != 330 || !s.IsOnline || res.Message != "" {
+		t.Fatalf("wrong bracket: %v", s.Json())
+	}
+
+	// sending stops on the failed command
+	res, err = smp.ExecuteCommands(s, "show; resume; stop")
+	if err == nil || err.Code != 500003707 || err.InternalError == nil || err.InternalError.Code != 500003901 {
+		t.Fatalf("command error expected, got %v", err)
+	}
+	if !s.IsOnline || res.Message == "" {
+		t.Fatalf("commands before the failed one should be done: %v %v", res.Message, s.Json())
+	}
+
+	// nothing is sent when text is wrong
+	if _, err = smp.ExecuteCommands(s, "stop; set_vol 'x"); err == nil || err.Code != 500003700 || !s.IsOnline {
+		t.Fatalf("syntax error expected, got %v", err)
+	}
+	_, err = smp.ExecuteCommands(s, "set_vol 20; set_stop_loss abc")
+	if err == nil || err.Code != 500003706 || err.InternalError == nil || err.InternalError.Code != 500003602 || s.Volume != 10 {
+		t.Fatalf("param error expected before sending, got %v %v", err, s.Volume)
+	}
+	if _, err = smp.ExecuteCommands(s, "set_vol 20; unknown"); err == nil || err.Code != 500003706 || s.Volume != 10 {
+		t.Fatalf("unknown command error expected before sending, got %v %v", err, s.Volume)
+	}
+}
+
+func TestCommandLogWingedSwing(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{102, 102, 101, 101},
+			[4]float64{120, 121, 119, 120},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{100, 100, 99, 99},
+		),
+	}
+	s := testSwing(2)
+	l := &smp.CommandLog{}
+	tm := time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC)
+
+	if _, _, err := l.Do(tm, "operator", s, SetLevelUp, map[string]string{"": "106"}); err != nil {
+		t.Fatal(err)
+	}
+	if r, _ := l.Record(1); len(r.Changes) != 1 || r.Changes[0].Field != "level_price_up" {
+		t.Fatalf("wrong changes: %+v", r.Changes)
+	}
+	if _, err := l.Undo(tm, "operator", s, 1); err != nil || s.LevelPriceUp != 105 {
+		t.Fatalf("undo: %v %v", s.LevelPriceUp, err)
+	}
+
+	// buy order is placed after command
+	if _, _, err := l.Do(tm, "operator", s, SetLevelUp, map[string]string{"": "107"}); err != nil {
+		t.Fatal(err)
+	}
+	stepSwing(t, p, s)
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingBuying, 0)
+	if _, err := l.Undo(tm, "operator", s, 3); err == nil || err.Code != 500003804 || s.LevelPriceUp != 107 {
+		t.Fatalf("not safe error expected, got %v", err)
+	}
+}
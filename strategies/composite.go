@@ -0,0 +1,420 @@
+package strategies
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/myfantasy/mfs"
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+//go:generate mfjson composite.go
+
+// Группа произвольных стратегий (Composite)
+
+var (
+	_ smp.Strategy     = &Composite{}
+	_ StopLostBankUser = &Composite{}
+)
+
+type CompositeMode string
+
+const (
+	// CompositeSequential - children are stepped one by one in order of Items
+	CompositeSequential CompositeMode = "sequential"
+	// CompositeParallel - children are stepped at the same time (StepParams should be safe for concurrent use,
+	// market.VirtualMarket and market.StepParamsDummy are)
+	CompositeParallel CompositeMode = "parallel"
+)
+
+const (
+	// CompositeChildParam - param of command: name of child to route command
+	CompositeChildParam = "child"
+	// CompositeLabelParam - param of command: label `key:value` of children to route command
+	CompositeLabelParam = "label"
+)
+
+// CompositeItem - child strategy of Composite
+// (strategy is stored as smp.JsonTypedContainer of smp.GlobalStrategyRegistry with schema version of child)
+type CompositeItem struct {
+	Name     string            `json:"name"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Strategy smp.Strategy      `json:"strategy"`
+}
+
+// compositeItemJson - stored CompositeItem
+type compositeItemJson struct {
+	Name     string                  `json:"name"`
+	Labels   map[string]string       `json:"labels,omitempty"`
+	Strategy *smp.JsonTypedContainer `json:"strategy"`
+}
+
+func (it CompositeItem) MarshalJSON() (res []byte, err error) {
+	out := compositeItemJson{Name: it.Name, Labels: it.Labels}
+	if it.Strategy != nil {
+		c, er0 := smp.GlobalStrategyRegistry.Save(it.Strategy)
+		if er0 != nil {
+			return nil, smp.GenerateErrorE(500003222, er0, it.Name)
+		}
+		out.Strategy = &c
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON - child is loaded by smp.GlobalStrategyRegistry (with migration of its schema version)
+func (it *CompositeItem) UnmarshalJSON(data []byte) (err error) {
+	var in compositeItemJson
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	er0 := dec.Decode(&in)
+	if er0 != nil {
+		return smp.GenerateErrorE(500003220, er0)
+	}
+	it.Name, it.Labels, it.Strategy = in.Name, in.Labels, nil
+	if in.Strategy == nil || in.Strategy.Type == "" {
+		return nil
+	}
+	s, er1 := smp.GlobalStrategyRegistry.Load(*in.Strategy)
+	if er1 != nil {
+		return smp.GenerateErrorE(500003221, er1, in.Name)
+	}
+	it.Strategy = s
+	return nil
+}
+
+//mfjson:interface smp.strategies.composite
+type Composite struct {
+	Name string `json:"name"`
+
+	Mode     CompositeMode `json:"mode"`
+	IsOnline bool          `json:"is_online"`
+
+	Items []CompositeItem `json:"items"`
+
+	// StopLostBank - shared by children (StopLostBankUser)
+	StopLostBank *StopLostBank `json:"stop_lost_bank,omitempty"`
+	// sharedBank - StopLostBank of container, used when StopLostBank is not set
+	sharedBank *StopLostBank
+
+	mx mfs.PMutex
+}
+
+func (s *Composite) Type() string {
+	return "composite"
+}
+
+func (s *Composite) String() string {
+	return "composite"
+}
+
+func (s *Composite) Status() smp.StartegyStatus {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	children := make([]smp.Strategy, 0, len(s.Items))
+	for _, it := range s.Items {
+		children = append(children, it.Strategy)
+	}
+	return childrenStatus(s.IsOnline, children)
+}
+func (s *Composite) Json() string {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return string(b)
+}
+
+// UseStopLostBank - sets StopLostBank shared by container
+func (s *Composite) UseStopLostBank(slb *StopLostBank) {
+	s.sharedBank = slb
+}
+
+// bank - own StopLostBank or shared one
+func (s *Composite) bank() *StopLostBank {
+	if s.StopLostBank != nil {
+		return s.StopLostBank
+	}
+	return s.sharedBank
+}
+
+// Add - adds child strategy
+func (s *Composite) Add(name string, labels map[string]string, strategy smp.Strategy) (err *mft.Error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if name == "" {
+		return smp.GenerateError(500003210)
+	}
+	if strategy == nil {
+		return smp.GenerateError(500003211, name)
+	}
+	if s.item(name) != nil {
+		return smp.GenerateError(500003212, name)
+	}
+	s.Items = append(s.Items, CompositeItem{
+		Name:     name,
+		Labels:   labels,
+		Strategy: strategy,
+	})
+	return nil
+}
+
+// Child - child strategy by name
+func (s *Composite) Child(name string) smp.Strategy {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if it := s.item(name); it != nil {
+		return it.Strategy
+	}
+	return nil
+}
+
+func (s *Composite) item(name string) *CompositeItem {
+	for i := range s.Items {
+		if s.Items[i].Name == name {
+			return &s.Items[i]
+		}
+	}
+	return nil
+}
+
+// selected - children selected by params `child` and `label` (all children without them)
+func (s *Composite) selected(cmd smp.Command, params map[string]string) (items []*CompositeItem, explicit bool, err *mft.Error) {
+	name, byName := params[CompositeChildParam]
+	label, byLabel := params[CompositeLabelParam]
+	var key, value string
+	if byLabel {
+		kv := strings.SplitN(label, ":", 2)
+		if len(kv) != 2 {
+			return nil, true, smp.GenerateError(500003202, cmd, CompositeLabelParam, label)
+		}
+		key, value = kv[0], kv[1]
+	}
+
+	for i := range s.Items {
+		if byName && s.Items[i].Name != name {
+			continue
+		}
+		if byLabel && s.Items[i].Labels[key] != value {
+			continue
+		}
+		items = append(items, &s.Items[i])
+	}
+
+	if (byName || byLabel) && len(items) == 0 {
+		return nil, true, smp.GenerateError(500003201, cmd, name+label)
+	}
+
+	return items, byName || byLabel, nil
+}
+
+// route - sends command to selected children
+// (children without the command in AllowCommands are skipped when command is sent to all of them)
+func (s *Composite) route(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	items, explicit, err := s.selected(cmd, params)
+	if err != nil {
+		return res, false, err
+	}
+
+	childParams := make(map[string]string, len(params))
+	for k, v := range params {
+		if k != CompositeChildParam && k != CompositeLabelParam {
+			childParams[k] = v
+		}
+	}
+
+	var messages []string
+	var errs *mft.Error
+	done := 0
+	for _, it := range items {
+		if _, allow := it.Strategy.AllowCommands(smp.DefaultLocale)[cmd]; !allow && !explicit {
+			continue
+		}
+		done++
+		r, okChild, er := it.Strategy.Command(cmd, childParams)
+		if er != nil {
+			errs = errs.AppendList(er)
+			continue
+		}
+		if !okChild {
+			errs = errs.AppendList(smp.GenerateError(500003204, cmd, it.Name))
+			continue
+		}
+		if r.Message != "" {
+			messages = append(messages, it.Name+": "+r.Message)
+		}
+	}
+
+	if done == 0 {
+		return res, false, smp.GenerateError(500003200, cmd)
+	}
+
+	res.Message = strings.Join(messages, "\n")
+	if errs != nil {
+		return res, false, smp.GenerateErrorSubList(500003203, errs.InternalErrors, cmd, len(errs.InternalErrors), done)
+	}
+
+	return res, true, nil
+}
+
+// selectorParams - params selecting children of command (without them command is sent to all children)
+func selectorParams() []smp.Param {
+	return []smp.Param{
+		smp.StringParam(CompositeChildParam, "имя", nil).AsOptional(),
+		smp.StringParam(CompositeLabelParam, "ключ:значение", nil).AsOptional(),
+	}
+}
+
+// commands - own commands of group (other commands are routed to children)
+func (s *Composite) commands() smp.CommandDefs {
+	return smp.CommandDefs{
+		{Command: smp.ShowCommand, Description: "Отобразить (группа и дочерние стратегии)", Example: "show child=swing_1",
+			Params: selectorParams(),
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				if v.Has(CompositeChildParam) || v.Has(CompositeLabelParam) {
+					return s.routeSelected(smp.ShowCommand, v)
+				}
+				header := fmt.Sprintf("%v (%v): %v children, online %v", s.String(), s.Mode, len(s.Items), s.IsOnline)
+				if len(s.Items) == 0 {
+					res.Message = header
+					return res, nil
+				}
+				res, err = s.routeSelected(smp.ShowCommand, v)
+				res.Message = strings.TrimSpace(header + "\n" + res.Message)
+				return res, err
+			}},
+		{Command: smp.StartCommand, Description: "Старт (без child и label - группа и все дочерние стратегии)",
+			Example: "start label=side:buy", Params: selectorParams(),
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				return s.setOnline(smp.StartCommand, v)
+			}},
+		{Command: smp.StopCommand, Description: "Стоп (без child и label - группа и все дочерние стратегии, по умолчанию заявки снимаются)",
+			Example: "stop keep child=swing_1", Params: append([]smp.Param{smp.StopModeParam()}, selectorParams()...),
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				return s.setOnline(smp.StopCommand, v)
+			}},
+		{Command: SetMode, Description: "Установить порядок шага дочерних стратегий (sequential - по очереди, parallel - одновременно)",
+			Example: "set_mode parallel",
+			Params: []smp.Param{smp.StringParam("", "порядок", &s.Mode).
+				WithValues(string(CompositeSequential), string(CompositeParallel))}},
+		{Command: RemoveChild, Description: "Удалить дочернюю стратегию", Example: "remove_child child=swing_1",
+			Params: []smp.Param{smp.StringParam(CompositeChildParam, "имя", nil)},
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				name := v.String(CompositeChildParam)
+				for i := range s.Items {
+					if s.Items[i].Name == name {
+						s.Items = append(s.Items[:i], s.Items[i+1:]...)
+						return res, nil
+					}
+				}
+				return res, smp.GenerateError(500003201, RemoveChild, name)
+			}},
+	}
+}
+
+func (s *Composite) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	defs := s.commands()
+	if defs.Find(cmd) != nil {
+		return defs.Do(s.Type(), cmd, params)
+	}
+	return s.route(cmd, params)
+}
+
+func (s *Composite) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	res := s.commands().AllowCommands(s.Type(), locale)
+
+	// commands of children are routed to them
+	for _, it := range s.Items {
+		for cmd, ci := range it.Strategy.AllowCommands(locale) {
+			if _, ok := res[cmd]; ok {
+				continue
+			}
+			selectors := smp.GlobalMessageCatalog.Localize(locale, s.Type(), smp.CommandDef{Command: cmd, Params: selectorParams()})
+			ci.Order += 100
+			ci.Params = append(append([]smp.Param{}, ci.Params...), selectors.Params...)
+			ci.ParamsDescription = strings.TrimSpace(ci.ParamsDescription + " " + selectors.ParamsDescription())
+			res[cmd] = ci
+		}
+	}
+
+	return res
+}
+
+// setOnline - start or stop of selected children (without child and label - group and all children)
+func (s *Composite) setOnline(cmd smp.Command, v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+	if !v.Has(CompositeChildParam) && !v.Has(CompositeLabelParam) {
+		s.IsOnline = cmd == smp.StartCommand
+		if len(s.Items) == 0 {
+			return res, nil
+		}
+	}
+	return s.routeSelected(cmd, v)
+}
+
+// routeSelected - route of command with parsed selector params
+func (s *Composite) routeSelected(cmd smp.Command, v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+	params := make(map[string]string, len(v))
+	for k := range v {
+		params[k] = v.String(k)
+	}
+	res, _, err = s.route(cmd, params)
+	return res, err
+}
+
+func (s *Composite) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), `composite - группа произвольных стратегий
+	Дочерние стратегии выполняются по очереди или одновременно, общий StopLostBank передаётся стратегиям, которые его используют
+	Команды отправляются всем дочерним стратегиям (которые их поддерживают) или выбранным по child=имя или label=ключ:значение`)
+}
+
+func (s *Composite) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	meta.Name = s.Name
+
+	slb := s.bank()
+	for _, it := range s.Items {
+		if u, ok := it.Strategy.(StopLostBankUser); ok {
+			u.UseStopLostBank(slb)
+		}
+	}
+
+	metas := make([]smp.MetaForStep, len(s.Items))
+	errs := make([]*mft.Error, len(s.Items))
+	if s.Mode == CompositeParallel {
+		var wg sync.WaitGroup
+		for i := range s.Items {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				metas[i], errs[i] = s.Items[i].Strategy.Step(p)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range s.Items {
+			metas[i], errs[i] = s.Items[i].Strategy.Step(p)
+		}
+	}
+
+	for i := range metas {
+		meta.HasChanges = meta.HasChanges || metas[i].HasChanges
+		meta.IsStopLoss = meta.IsStopLoss || metas[i].IsStopLoss
+		meta.SubMeta = append(meta.SubMeta, metas[i])
+		err = err.AppendList(errs[i])
+	}
+
+	if err != nil {
+		return meta, smp.GenerateErrorSubList(500003300, err.InternalErrors, len(err.InternalErrors), len(s.Items))
+	}
+
+	return meta, nil
+}
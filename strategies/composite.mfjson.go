@@ -0,0 +1,19 @@
+// Code generated by mfjson for marshaling/unmarshaling. DO NOT EDIT.
+// https://github.com/myfantasy/json
+
+package strategies
+
+import (
+	mfj "github.com/myfantasy/json"
+)
+func (obj *Composite) UnmarshalJSONTypeName() string {
+	return "smp.strategies.composite"
+}
+
+func init() {
+	mfj.GlobalStructFactory.Add("smp.strategies.composite", func() mfj.JsonInterfaceMarshaller { return &Composite{} })
+	mfj.GlobalStructFactory.AddNil("smp.strategies.composite", func() mfj.JsonInterfaceMarshaller {
+		var out *Composite
+		return out
+	})
+}
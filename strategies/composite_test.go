@@ -0,0 +1,257 @@
+package strategies
+
+import (
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/myfantasy/mft"
+	"github.com/myfantasy/stock_market_primitives/market"
+
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+// countStrategy - strategy counts steps (and fails them when Fail is set)
+type countStrategy struct {
+	Steps int32
+	Fail  bool
+}
+
+func (s *countStrategy) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
+	atomic.AddInt32(&s.Steps, 1)
+	meta.HasChanges = true
+	if s.Fail {
+		return meta, smp.GenerateError(500003200, "step")
+	}
+	return meta, nil
+}
+func (s *countStrategy) Status() smp.StartegyStatus { return smp.StartegyStatus{} }
+func (s *countStrategy) String() string             { return "count" }
+func (s *countStrategy) Type() string               { return "count" }
+func (s *countStrategy) Json() string               { return "{}" }
+func (s *countStrategy) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	return res, false, nil
+}
+func (s *countStrategy) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo { return nil }
+func (s *countStrategy) Description(locale smp.Locale) string                            { return "count" }
+
+func testComposite(t *testing.T) *Composite {
+	s := &Composite{Name: "group"}
+	if err := s.Add("buy", map[string]string{"side": "buy"}, testBracket(5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("swing", map[string]string{"side": "buy"}, testSwing(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("trail", nil, &TrailingStop{Name: "trail", Ticker: "TTTT", Volume: 3}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCompositeJson(t *testing.T) {
+	s := testComposite(t)
+	inner := &Composite{Name: "inner", Mode: CompositeParallel}
+	if err := inner.Add("b", nil, testBracket(7)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("inner", nil, inner); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("buy", nil, testBracket(1)); err == nil || err.Code != 500003212 {
+		t.Fatalf("duplicate child error expected, got %v", err)
+	}
+
+	var out Composite
+	if err := json.Unmarshal([]byte(s.Json()), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Items) != 4 || out.Items[1].Labels["side"] != "buy" {
+		t.Fatalf("wrong items: %v", out.Json())
+	}
+	if b, ok := out.Child("buy").(*Bracket); !ok || b.Volume != 5 {
+		t.Fatalf("bracket is not restored: %v", out.Json())
+	}
+	if _, ok := out.Child("swing").(*WingedSwing); !ok {
+		t.Fatalf("winged swing is not restored: %v", out.Json())
+	}
+	in, ok := out.Child("inner").(*Composite)
+	if !ok || in.Mode != CompositeParallel {
+		t.Fatalf("inner composite is not restored: %v", out.Json())
+	}
+	if b, ok := in.Child("b").(*Bracket); !ok || b.Volume != 7 {
+		t.Fatalf("bracket of inner composite is not restored: %v", out.Json())
+	}
+}
+
+func TestCompositeCommand(t *testing.T) {
+	s := testComposite(t)
+
+	// routed by name
+	if _, ok, err := s.Command(SetVolume, map[string]string{"": "9", CompositeChildParam: "buy"}); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	if s.Child("buy").(*Bracket).Volume != 9 || s.Child("swing").(*WingedSwing).Volume != 2 {
+		t.Fatalf("set_vol is not routed by name: %v", s.Json())
+	}
+
+	// routed by label
+	if _, ok, err := s.Command(smp.StartCommand, map[string]string{CompositeLabelParam: "side:buy"}); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	if s.IsOnline || !s.Child("swing").(*WingedSwing).IsOnline || s.Child("trail").(*TrailingStop).IsOnline {
+		t.Fatalf("start is not routed by label: %v", s.Json())
+	}
+
+	// broadcast
+	if _, ok, err := s.Command(SetVolume, map[string]string{"": "4"}); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	if s.Child("buy").(*Bracket).Volume != 4 || s.Child("swing").(*WingedSwing).Volume != 4 ||
+		s.Child("trail").(*TrailingStop).Volume != 4 {
+		t.Fatalf("set_vol is not broadcasted: %v", s.Json())
+	}
+	if _, ok, err := s.Command(smp.StartCommand, nil); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	if !s.IsOnline || !s.Child("trail").(*TrailingStop).IsOnline {
+		t.Fatalf("start is not broadcasted: %v", s.Json())
+	}
+
+	res, ok, err := s.Command(smp.ShowCommand, nil)
+	if !ok || err != nil || len(strings.Split(res.Message, "\n")) != 4 {
+		t.Fatalf("wrong show: %v %v %v", ok, err, res.Message)
+	}
+
+	// errors of children are collected
+	if _, _, err := s.Command(SetVolume, map[string]string{"": "x"}); err == nil || err.Code != 500003203 ||
+		len(err.InternalErrors) != 3 {
+		t.Fatalf("errors of 3 children expected, got %v", err)
+	}
+	if _, _, err := s.Command(SetVolume, map[string]string{"": "1", CompositeChildParam: "none"}); err == nil || err.Code != 500003201 {
+		t.Fatalf("no children error expected, got %v", err)
+	}
+	if _, _, err := s.Command(SetVolume, map[string]string{"": "1", CompositeLabelParam: "side"}); err == nil || err.Code != 500003202 {
+		t.Fatalf("wrong label error expected, got %v", err)
+	}
+	if _, _, err := s.Command("unknown", nil); err == nil || err.Code != 500003200 {
+		t.Fatalf("unknown command error expected, got %v", err)
+	}
+
+	if _, ok, err := s.Command(RemoveChild, map[string]string{CompositeChildParam: "swing"}); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	if len(s.Items) != 2 || s.Child("swing") != nil {
+		t.Fatalf("child is not removed: %v", s.Json())
+	}
+
+	cmds := s.AllowCommands(smp.DefaultLocale)
+	if _, ok := cmds[SetTakeProfit]; !ok {
+		t.Fatal("commands of children expected")
+	}
+	if _, ok := cmds[RemoveChild]; !ok {
+		t.Fatal("commands of composite expected")
+	}
+}
+
+func TestCompositeStep(t *testing.T) {
+	for _, mode := range []CompositeMode{CompositeSequential, CompositeParallel} {
+		children := []*countStrategy{{}, {Fail: true}, {}, {Fail: true}}
+		s := &Composite{Name: "group", Mode: mode}
+		for i, c := range children {
+			if err := s.Add(string(rune('a'+i)), nil, c); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		meta, err := s.Step(nil)
+		if err == nil || err.Code != 500003300 || len(err.InternalErrors) != 2 {
+			t.Fatalf("%v: errors of 2 children expected, got %v", mode, err)
+		}
+		if !meta.HasChanges || len(meta.SubMeta) != 4 || meta.Name != "group" {
+			t.Fatalf("%v: wrong meta: %v", mode, meta)
+		}
+		for i, c := range children {
+			if c.Steps != 1 {
+				t.Fatalf("%v: child %v is stepped %v times", mode, i, c.Steps)
+			}
+		}
+	}
+}
+
+func TestCompositeStopLostBank(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{100, 100, 99, 99},
+			[4]float64{86, 86, 84, 84},
+			[4]float64{102, 102, 101, 101},
+		),
+	}
+	swing := testSwing(2)
+	inner := &Composite{Name: "inner"}
+	if err := inner.Add("swing", nil, swing); err != nil {
+		t.Fatal(err)
+	}
+	s := &Composite{Name: "group", StopLostBank: &StopLostBank{}}
+	if err := s.Add("inner", nil, inner); err != nil {
+		t.Fatal(err)
+	}
+
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+
+	// lots of stop loss are given to StopLostBank of outer composite
+	if s.StopLostBank.AllowCount("", "TTTT") != 2 || swing.StopLostTimes != 1 {
+		t.Fatalf("2 lots in StopLostBank expected: %v", s.Json())
+	}
+	if swing.StopLostBank != nil || strings.Contains(inner.Json(), "stop_lost_bank") {
+		t.Fatalf("shared StopLostBank should not be saved by children: %v", inner.Json())
+	}
+}
+
+func TestCompositeParallelMarkets(t *testing.T) {
+	candles := []([4]float64){
+		{100, 101, 99, 100},
+		{100, 101, 99, 100},
+		{100, 101, 99, 100},
+		{100, 101, 99, 100},
+		{106, 107, 105, 106},
+		{106, 107, 105, 106},
+		{106, 107, 105, 106},
+	}
+	markets := []interface {
+		smp.PortfolioStepParams
+		DoStep() bool
+	}{
+		&market.VirtualMarket{Candles: testCandles(candles...)},
+		&market.StepParamsDummy{Candles: testCandles(candles...)},
+	}
+	for _, p := range markets {
+		s := &Composite{Name: "group", Mode: CompositeParallel}
+		for i := 0; i < 8; i++ {
+			if err := s.Add(string(rune('a'+i)), nil, testBracket(5)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 0; i < 5; i++ {
+			stepExecution(t, p, s)
+		}
+		for _, it := range s.Items {
+			b := it.Strategy.(*Bracket)
+			if b.State != BracketClosed || b.Exit != "take_profit" || b.Sold != 5 {
+				t.Fatalf("%T: child %v is not closed by take profit: %v", p, it.Name, b.Json())
+			}
+		}
+		pos, err := p.GetPosition("", "TTTT")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos.Quantity != 0 {
+			t.Fatalf("%T: position is not closed: %v", p, pos)
+		}
+	}
+}
@@ -8,6 +8,7 @@ const (
 	SetLevel        smp.Command = "set_level"
 	SetVolume       smp.Command = "set_vol"
 	SetStayInMarket smp.Command = "stay_in_market"
+	SetRearm        smp.Command = "rearm"
 
 	SetLevelUp   smp.Command = "set_level_up"
 	SetLevelDown smp.Command = "set_level_down"
@@ -28,4 +29,78 @@ const (
 
 	SetInMarket    smp.Command = "set_in_market"
 	SetOutOfMarket smp.Command = "set_out_of_market"
+
+	SetSpacing          smp.Command = "set_spacing"
+	SetPriceStep        smp.Command = "set_price_step"
+	SetVolumeMode       smp.Command = "set_volume_mode"
+	SetVolumeStep       smp.Command = "set_volume_step"
+	SetVolumeMultiplier smp.Command = "set_volume_multiplier"
+	SetVolumeMax        smp.Command = "set_volume_max"
+	SetLowerBound       smp.Command = "set_lower_bound"
+	SetUpperBound       smp.Command = "set_upper_bound"
+	SetRecenter         smp.Command = "set_recenter"
+
+	SetTrail            smp.Command = "set_trail"
+	SetTrailMode        smp.Command = "set_trail_mode"
+	SetAtrPeriod        smp.Command = "set_atr_period"
+	SetAtrFrame         smp.Command = "set_atr_frame"
+	SetActivationProfit smp.Command = "set_activation_profit"
+	SetEntryPrice       smp.Command = "set_entry_price"
+	TakePosition        smp.Command = "take_position"
+
+	SetAmount        smp.Command = "set_amount"
+	SetSchedule      smp.Command = "set_schedule"
+	SetInterval      smp.Command = "set_interval"
+	SetTime          smp.Command = "set_time"
+	SetWeekday       smp.Command = "set_weekday"
+	SetMonthDay      smp.Command = "set_month_day"
+	SetCeiling       smp.Command = "set_ceiling"
+	SetDipPercent    smp.Command = "set_dip_percent"
+	SetDipMultiplier smp.Command = "set_dip_multiplier"
+	SetTarget        smp.Command = "set_target"
+
+	SetEntryZ        smp.Command = "set_entry_z"
+	SetExitZ         smp.Command = "set_exit_z"
+	SetStopZ         smp.Command = "set_stop_z"
+	SetLookback      smp.Command = "set_lookback"
+	SetFrame         smp.Command = "set_frame"
+	SetMaxLegRetries smp.Command = "set_max_leg_retries"
+	SetLegTimeout    smp.Command = "set_leg_timeout"
+
+	SetFast          smp.Command = "set_fast"
+	SetSlow          smp.Command = "set_slow"
+	SetMAType        smp.Command = "set_ma_type"
+	SetAllowShort    smp.Command = "set_allow_short"
+	SetPeriod        smp.Command = "set_period"
+	SetExitPeriod    smp.Command = "set_exit_period"
+	SetAtrMultiplier smp.Command = "set_atr_multiplier"
+
+	Cancel         smp.Command = "cancel"
+	SetSide        smp.Command = "set_side"
+	SetWindow      smp.Command = "set_window"
+	SetLimit       smp.Command = "set_limit"
+	SetSlices      smp.Command = "set_slices"
+	SetByMarket    smp.Command = "set_by_market"
+	SetProfileDays smp.Command = "set_profile_days"
+
+	SetVisible         smp.Command = "set_visible"
+	SetVisibleVariance smp.Command = "set_visible_variance"
+	SetPriceOffset     smp.Command = "set_price_offset"
+
+	DryRun       smp.Command = "dry_run"
+	RebalanceNow smp.Command = "rebalance"
+	SetWeight    smp.Command = "set_weight"
+	SetDrift     smp.Command = "set_drift"
+
+	AddDividend    smp.Command = "add_dividend"
+	SetBuyDays     smp.Command = "set_buy_days"
+	SetMinYield    smp.Command = "set_min_yield"
+	SetRecovery    smp.Command = "set_recovery"
+	SetMaxHoldDays smp.Command = "set_max_hold_days"
+
+	SetTakeProfit smp.Command = "set_take_profit"
+	SetStopLoss   smp.Command = "set_stop_loss"
+
+	SetMode     smp.Command = "set_mode"
+	RemoveChild smp.Command = "remove_child"
 )
@@ -0,0 +1,322 @@
+package strategies
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+//go:generate mfjson dca.go
+
+// Усреднение (DCA - dollar-cost averaging)
+
+var (
+	_ smp.Strategy = &DCA{}
+)
+
+type DCASchedule string
+
+const (
+	// IntervalSchedule - buy every Interval minutes
+	IntervalSchedule DCASchedule = "interval"
+	// DailySchedule - buy every day at Hour:Minute
+	DailySchedule DCASchedule = "daily"
+	// WeeklySchedule - buy every Weekday at Hour:Minute
+	WeeklySchedule DCASchedule = "weekly"
+	// MonthlySchedule - buy every MonthDay at Hour:Minute (the last day of short month)
+	MonthlySchedule DCASchedule = "monthly"
+)
+
+//mfjson:interface smp.strategies.dca
+type DCA struct {
+	Name string `json:"name"`
+
+	InstrumentId string `json:"instrument_id"`
+	Ticker       string `json:"ticker"`
+
+	// Amount - money for one buy (rounded down to lots)
+	Amount float64 `json:"amount"`
+
+	Schedule DCASchedule `json:"schedule"`
+	// Interval - minutes between buys (IntervalSchedule)
+	Interval int `json:"interval"`
+	Hour     int `json:"hour"`
+	Minute   int `json:"minute"`
+	// Weekday - 0 - sunday ... 6 - saturday (WeeklySchedule)
+	Weekday int `json:"weekday"`
+	// MonthDay - 1 ... 31 (MonthlySchedule)
+	MonthDay int `json:"month_day"`
+
+	// PriceCeiling - buy is skipped when price is higher (0 - no ceiling)
+	PriceCeiling float64 `json:"price_ceiling"`
+	// DipPercent, DipMultiplier - Amount is multiplied when price is DipPercent under AverageCost
+	DipPercent    float64 `json:"dip_percent"`
+	DipMultiplier float64 `json:"dip_multiplier"`
+	// TargetVolume - lots to accumulate (0 - no limit)
+	TargetVolume int `json:"target_volume"`
+
+	IsOnline bool `json:"is_online"`
+
+	NextTime time.Time `json:"next_time"`
+	OrderId  string    `json:"order_id"`
+
+	InMarket      int     `json:"in_market"`
+	InMarketPrice float64 `json:"in_market_price"`
+	AverageCost   float64 `json:"average_cost"`
+	Buys          int     `json:"buys"`
+
+	Lifecycle smp.Lifecycle `json:"lifecycle"`
+}
+
+func (s *DCA) Type() string {
+	return "dca"
+}
+
+func (s *DCA) String() string {
+	return "dca"
+}
+
+func (s *DCA) Status() smp.StartegyStatus {
+	return s.Lifecycle.Status(s.IsOnline)
+}
+func (s *DCA) Json() string {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return string(b)
+}
+
+// commands - commands of strategy
+func (s *DCA) commands() smp.CommandDefs {
+	show := showCommand("Отобразить (кол-во лотов и средняя цена)", func() string {
+		return fmt.Sprintf("%v: %v lots, average cost %v, buys %v, next buy %v",
+			s.String(), s.InMarket, s.AverageCost, s.Buys, s.NextTime.Format(time.RFC3339))
+	})
+	return lifecycleCommands(show, s.Lifecycle.Commands(s.Type(), s, &s.IsOnline), smp.CommandDefs{
+		{Command: SetAmount, Description: "Установить сумму одной покупки", Example: "set_amount 10000",
+			Params: []smp.Param{smp.FloatParam("", "сумма", &s.Amount).WithMin(0)}},
+		{Command: SetSchedule, Description: "Установить расписание (interval - через интервал, daily - каждый день, weekly - каждую неделю, monthly - каждый месяц)",
+			Example: "set_schedule weekly",
+			Params: []smp.Param{smp.StringParam("", "расписание", &s.Schedule).
+				WithValues(string(IntervalSchedule), string(DailySchedule), string(WeeklySchedule), string(MonthlySchedule))},
+			Do: s.resetNextTime},
+		{Command: SetInterval, Description: "Установить интервал между покупками (interval)", Example: "set_interval 60",
+			Params: []smp.Param{smp.IntParam("", "минуты", &s.Interval).WithMin(1)},
+			Do:     s.resetNextTime},
+		{Command: SetTime, Description: "Установить время покупки (daily, weekly, monthly)", Example: "set_time 10:30",
+			Params: []smp.Param{smp.ClockParam("", "ЧЧ:ММ", nil)},
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				s.Hour, s.Minute = v.Time("").Hour(), v.Time("").Minute()
+				return s.resetNextTime(v)
+			}},
+		{Command: SetWeekday, Description: "Установить день недели покупки (weekly)", Example: "set_weekday 1",
+			Params: []smp.Param{smp.IntParam("", "0 - воскресенье ... 6 - суббота", &s.Weekday).WithMin(0).WithMax(6)},
+			Do:     s.resetNextTime},
+		{Command: SetMonthDay, Description: "Установить день месяца покупки (monthly)", Example: "set_month_day 15",
+			Params: []smp.Param{smp.IntParam("", "день месяца", &s.MonthDay).WithMin(1).WithMax(31)},
+			Do:     s.resetNextTime},
+		{Command: SetCeiling, Description: "Установить максимальную цену покупки (0 - без ограничения)", Example: "set_ceiling 400",
+			Params: []smp.Param{smp.FloatParam("", "цена", &s.PriceCeiling).WithMin(0)}},
+		{Command: SetDipPercent, Description: "Установить падение цены от средней для увеличения покупки", Example: "set_dip_percent 10",
+			Params: []smp.Param{smp.FloatParam("", "процент", &s.DipPercent).WithMin(0)}},
+		{Command: SetDipMultiplier, Description: "Установить множитель суммы покупки при падении цены", Example: "set_dip_multiplier 2",
+			Params: []smp.Param{smp.FloatParam("", "множитель", &s.DipMultiplier).WithMin(0)}},
+		{Command: SetTarget, Description: "Установить целевой объём позиции (0 - без ограничения)", Example: "set_target 100",
+			Params: []smp.Param{smp.IntParam("", "кол-во лотов", &s.TargetVolume).WithMin(0)}},
+	})
+}
+
+func (s *DCA) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	return s.commands().Do(s.Type(), cmd, params)
+}
+
+func (s *DCA) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
+
+// resetNextTime - next buy time is computed again by new schedule
+func (s *DCA) resetNextTime(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+	s.NextTime = time.Time{}
+	return res, nil
+}
+
+func (s *DCA) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), `dca - стратегия, усреднение
+	По расписанию покупается инструмент на указанную сумму (по рынку)`)
+}
+
+// monthDayTime - time of day of month (the last day of short month)
+func monthDayTime(y int, m time.Month, day int, hour int, minute int, loc *time.Location) time.Time {
+	last := time.Date(y, m+1, 0, 0, 0, 0, 0, loc).Day()
+	if day > last {
+		day = last
+	}
+	if day < 1 {
+		day = 1
+	}
+	return time.Date(y, m, day, hour, minute, 0, 0, loc)
+}
+
+// next - time of buy after tm (tm is included when inclusive is set)
+func (s *DCA) next(tm time.Time, inclusive bool) time.Time {
+	after := func(t time.Time) bool {
+		return t.After(tm) || inclusive && t.Equal(tm)
+	}
+
+	switch s.Schedule {
+	case DailySchedule, WeeklySchedule, MonthlySchedule:
+	default:
+		if inclusive {
+			return tm
+		}
+		interval := s.Interval
+		if interval <= 0 {
+			interval = 24 * 60
+		}
+		return tm.Add(time.Duration(interval) * time.Minute)
+	}
+
+	// weekday out of 0..6 (loaded or built without command) is taken by modulo 7
+	weekday := (s.Weekday%7 + 7) % 7
+
+	// the next day of week is found in 8 days, the next day of month - in 2 months
+	y, m, d := tm.Date()
+	for i := 0; i <= 7; i++ {
+		var t time.Time
+		switch s.Schedule {
+		case DailySchedule:
+			t = time.Date(y, m, d+i, s.Hour, s.Minute, 0, 0, tm.Location())
+		case WeeklySchedule:
+			t = time.Date(y, m, d+i, s.Hour, s.Minute, 0, 0, tm.Location())
+			if int(t.Weekday()) != weekday {
+				continue
+			}
+		case MonthlySchedule:
+			t = monthDayTime(y, m+time.Month(i), s.MonthDay, s.Hour, s.Minute, tm.Location())
+		}
+		if after(t) {
+			return t
+		}
+	}
+	return tm.Add(24 * time.Hour)
+}
+
+// applyOrder - applies status of buy order
+func (s *DCA) applyOrder(status smp.StatusOrder, prices []smp.LotPrices, meta *smp.MetaForStep) {
+	if status != smp.Complete && status != smp.Canceled {
+		return
+	}
+
+	cnt, price := lotPricesSum(prices)
+	meta.HasChanges = true
+	s.OrderId = ""
+	s.InMarket += cnt
+	s.InMarketPrice = smp.Round(s.InMarketPrice+price, 6)
+	if s.InMarket > 0 {
+		s.AverageCost = smp.Round(s.InMarketPrice/float64(s.InMarket), 6)
+	}
+}
+
+// ActiveOrders - buy order
+func (s *DCA) ActiveOrders() []smp.ActiveOrder {
+	if s.OrderId == "" {
+		return nil
+	}
+	return []smp.ActiveOrder{{InstrumentId: s.InstrumentId, Ticker: s.Ticker, OrderId: s.OrderId, Buy: true}}
+}
+
+// ResetState - clears schedule, accumulated lots, average cost and buys (lots are not sold)
+func (s *DCA) ResetState() (err *mft.Error) {
+	s.NextTime = time.Time{}
+	s.InMarket = 0
+	s.InMarketPrice = 0
+	s.AverageCost = 0
+	s.Buys = 0
+	return nil
+}
+
+func (s *DCA) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
+	meta.Name = s.Name
+
+	if s.OrderId != "" {
+		status, prices, err := p.StatusBuyOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+		if err != nil {
+			return meta, smp.GenerateErrorE(500001402, err)
+		}
+		s.applyOrder(status, prices, &meta)
+	}
+
+	if halt, err := s.Lifecycle.Step(p, s, &meta); halt || err != nil {
+		return meta, err
+	}
+
+	if !s.IsOnline || s.OrderId != "" || s.Amount <= 0 {
+		return meta, nil
+	}
+
+	if s.TargetVolume > 0 && s.InMarket >= s.TargetVolume {
+		return meta, nil
+	}
+
+	ob, err := p.GetOrderBook(s.InstrumentId, s.Ticker)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500001400, err)
+	}
+
+	if ob.TradeStatus != smp.NormalTrading {
+		return meta, nil
+	}
+
+	if s.NextTime.IsZero() {
+		meta.HasChanges = true
+		s.NextTime = s.next(ob.Time, true)
+	}
+	if ob.Time.Before(s.NextTime) {
+		return meta, nil
+	}
+
+	meta.HasChanges = true
+	s.NextTime = s.next(ob.Time, false)
+
+	price := ob.BuyPrice()
+	if s.PriceCeiling > 0 && price > s.PriceCeiling {
+		meta.OpDescr = append(meta.OpDescr, "Price is over ceiling, buy is skipped")
+		return meta, nil
+	}
+
+	amount := s.Amount
+	if s.DipPercent > 0 && s.DipMultiplier > 0 && s.AverageCost > 0 &&
+		price <= s.AverageCost*(1-s.DipPercent/100) {
+		meta.OpDescr = append(meta.OpDescr, "Buy the dip")
+		amount *= s.DipMultiplier
+	}
+
+	ii, err := p.GetInstrumentInfo(s.InstrumentId, s.Ticker)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500001401, err)
+	}
+
+	cnt := int(math.Floor(amount / (price * float64(ii.Lot()))))
+	if s.TargetVolume > 0 && cnt > s.TargetVolume-s.InMarket {
+		cnt = s.TargetVolume - s.InMarket
+	}
+	if cnt <= 0 {
+		meta.OpDescr = append(meta.OpDescr, "Amount is less than lot, buy is skipped")
+		return meta, nil
+	}
+
+	s.OrderId, err = p.BuyByMarket(s.InstrumentId, s.Ticker, cnt, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	if err != nil {
+		s.OrderId = ""
+		return meta, smp.GenerateErrorE(500001403, err)
+	}
+	s.Buys++
+
+	return meta, nil
+}
@@ -0,0 +1,19 @@
+// Code generated by mfjson for marshaling/unmarshaling. DO NOT EDIT.
+// https://github.com/myfantasy/json
+
+package strategies
+
+import (
+	mfj "github.com/myfantasy/json"
+)
+func (obj *DCA) UnmarshalJSONTypeName() string {
+	return "smp.strategies.dca"
+}
+
+func init() {
+	mfj.GlobalStructFactory.Add("smp.strategies.dca", func() mfj.JsonInterfaceMarshaller { return &DCA{} })
+	mfj.GlobalStructFactory.AddNil("smp.strategies.dca", func() mfj.JsonInterfaceMarshaller {
+		var out *DCA
+		return out
+	})
+}
@@ -0,0 +1,176 @@
+package strategies
+
+import (
+	"testing"
+	"time"
+
+	smp "github.com/myfantasy/stock_market_primitives"
+	"github.com/myfantasy/stock_market_primitives/market"
+)
+
+func TestDCASchedule(t *testing.T) {
+	s := &DCA{Schedule: DailySchedule, Hour: 10, Minute: 30}
+	tm := time.Date(2021, 1, 4, 11, 0, 0, 0, time.UTC)
+
+	if n := s.next(tm, false); !n.Equal(time.Date(2021, 1, 5, 10, 30, 0, 0, time.UTC)) {
+		t.Fatalf("daily: wrong next time %v", n)
+	}
+	if n := s.next(time.Date(2021, 1, 4, 10, 30, 0, 0, time.UTC), true); !n.Equal(time.Date(2021, 1, 4, 10, 30, 0, 0, time.UTC)) {
+		t.Fatalf("daily inclusive: wrong next time %v", n)
+	}
+
+	s.Schedule = WeeklySchedule
+	s.Weekday = int(time.Friday)
+	if n := s.next(tm, false); !n.Equal(time.Date(2021, 1, 8, 10, 30, 0, 0, time.UTC)) {
+		t.Fatalf("weekly: wrong next time %v", n)
+	}
+	// weekday out of range is not looped forever
+	s.Weekday = 9
+	if n := s.next(tm, false); !n.Equal(time.Date(2021, 1, 5, 10, 30, 0, 0, time.UTC)) {
+		t.Fatalf("weekly by modulo: wrong next time %v", n)
+	}
+	s.Weekday = -1
+	if n := s.next(tm, false); !n.Equal(time.Date(2021, 1, 9, 10, 30, 0, 0, time.UTC)) {
+		t.Fatalf("weekly by negative modulo: wrong next time %v", n)
+	}
+
+	// schedule commands compute next time again
+	for _, text := range []string{"set_weekday 1", "set_interval 30", "set_month_day 2"} {
+		s.NextTime = tm
+		if _, err := smp.ExecuteCommands(s, text); err != nil || !s.NextTime.IsZero() {
+			t.Fatalf("%v: next time is not reset: %v %v", text, s.NextTime, err)
+		}
+	}
+
+	s.Schedule = MonthlySchedule
+	s.MonthDay = 31
+	if n := s.next(time.Date(2021, 1, 31, 11, 0, 0, 0, time.UTC), false); !n.Equal(time.Date(2021, 2, 28, 10, 30, 0, 0, time.UTC)) {
+		t.Fatalf("monthly: wrong next time %v", n)
+	}
+
+	s.Schedule = IntervalSchedule
+	s.Interval = 90
+	if n := s.next(tm, false); !n.Equal(tm.Add(90 * time.Minute)) {
+		t.Fatalf("interval: wrong next time %v", n)
+	}
+}
+
+func TestDCAStep(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+		),
+		InstrumentInfo: &smp.InstrumentInfo{LotSize: 10},
+	}
+	s := &DCA{
+		Ticker:       "TTTT",
+		Amount:       5000,
+		Schedule:     IntervalSchedule,
+		Interval:     2,
+		TargetVolume: 10,
+		IsOnline:     true,
+	}
+
+	step := func() {
+		t.Helper()
+		p.DoStep()
+		_, err := s.Step(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	step()
+	if s.Buys != 1 || s.OrderId == "" {
+		t.Fatalf("first buy is not done: %v", s.Json())
+	}
+	step()
+	if s.InMarket != 4 || s.AverageCost != 101 || s.Buys != 1 {
+		t.Fatalf("wrong state after first buy: %v", s.Json())
+	}
+
+	_, _, err := s.Command(smp.PauseCommand, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step()
+	if s.Buys != 1 {
+		t.Fatalf("buy on pause: %v", s.Json())
+	}
+	_, _, err = s.Command(smp.ResumeCommand, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step()
+	step()
+	step()
+	step()
+	if s.InMarket != 10 || s.Buys != 3 {
+		t.Fatalf("target is not reached: %v", s.Json())
+	}
+
+	res, _, err := s.Command(smp.ShowCommand, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Message == "" {
+		t.Fatal("empty report")
+	}
+}
+
+func TestDCADipAndCeiling(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{100, 110, 99, 100},
+			[4]float64{100, 110, 99, 100},
+			[4]float64{89, 90, 88, 89},
+			[4]float64{89, 90, 88, 89},
+		),
+	}
+	s := &DCA{
+		Ticker:        "TTTT",
+		Amount:        900,
+		Schedule:      IntervalSchedule,
+		Interval:      1,
+		PriceCeiling:  105,
+		DipPercent:    10,
+		DipMultiplier: 2,
+		IsOnline:      true,
+
+		InMarket:      10,
+		InMarketPrice: 1000,
+		AverageCost:   100,
+	}
+
+	p.DoStep()
+	_, err := s.Step(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.OrderId != "" || s.NextTime.IsZero() {
+		t.Fatalf("buy over ceiling: %v", s.Json())
+	}
+
+	p.DoStep()
+	_, err = s.Step(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.DoStep()
+	_, err = s.Step(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 900 * 2 / 90
+	if s.InMarket != 30 {
+		t.Fatalf("dip is not bought: %v", s.Json())
+	}
+}
@@ -0,0 +1,303 @@
+package strategies
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+//go:generate mfjson dividend_capture.go
+
+// Захват дивидендов - покупка до последнего дня покупки под дивиденд и продажа после отсечки
+
+var (
+	_ smp.Strategy = &DividendCapture{}
+)
+
+//mfjson:interface smp.strategies.dividend_capture
+type DividendCapture struct {
+	Name string `json:"name"`
+
+	InstrumentId string `json:"instrument_id"`
+	Ticker       string `json:"ticker"`
+
+	// Volume - lots to buy
+	Volume int `json:"volume"`
+	// Dividends - calendar of dividends (LastDate - last date for buy)
+	Dividends smp.Dividends `json:"dividends"`
+
+	// BuyDaysBefore - buy is allowed from BuyDaysBefore days before LastDate
+	BuyDaysBefore int `json:"buy_days_before"`
+	// MinYield - dividend is skipped when its yield (percent of price) is less
+	MinYield float64 `json:"min_yield"`
+	// Recovery - part of dividend gap to be recovered by price for sell (0 - sell at break-even with dividend, 1 - sell at buy price)
+	Recovery float64 `json:"recovery"`
+	// MaxHoldDays - position is sold by market after MaxHoldDays days after ex-date (0 - no limit)
+	MaxHoldDays int `json:"max_hold_days"`
+
+	IsOnline bool `json:"is_online"`
+
+	// Dividend - captured dividend
+	Dividend   smp.Dividend `json:"dividend"`
+	IsCredited bool         `json:"is_credited"`
+
+	OrderId  string `json:"order_id"`
+	OrderBuy bool   `json:"order_buy"`
+
+	InMarket      int     `json:"in_market"`
+	InMarketPrice float64 `json:"in_market_price"`
+
+	// Profit - profit of prices; DividendIncome - dividends of held lots (both without lot size as InMarketPrice)
+	Profit         float64 `json:"profit"`
+	DividendIncome float64 `json:"dividend_income"`
+	Captures       int     `json:"captures"`
+
+	Lifecycle smp.Lifecycle `json:"lifecycle"`
+}
+
+func (s *DividendCapture) Type() string {
+	return "dividend_capture"
+}
+
+func (s *DividendCapture) String() string {
+	return "dividend_capture"
+}
+
+func (s *DividendCapture) Status() smp.StartegyStatus {
+	return s.Lifecycle.Status(s.IsOnline)
+}
+func (s *DividendCapture) Json() string {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return string(b)
+}
+
+// commands - commands of strategy
+func (s *DividendCapture) commands() smp.CommandDefs {
+	show := showCommand("Отобразить (позиция, прибыль и дивиденды)", func() string {
+		return fmt.Sprintf("%v: %v lots, captures %v, profit %v + dividends %v = %v",
+			s.String(), s.InMarket, s.Captures, s.Profit, s.DividendIncome, smp.Round(s.Profit+s.DividendIncome, 6))
+	})
+	return lifecycleCommands(show, s.Lifecycle.Commands(s.Type(), s, &s.IsOnline), smp.CommandDefs{
+		{Command: SetVolume, Description: "Установить объём покупки", Example: "set_vol 10",
+			Params: []smp.Param{smp.IntParam("", "кол-во лотов", &s.Volume).WithMin(0)}},
+		{Command: AddDividend, Description: "Добавить дивиденд в календарь", Example: "add_dividend a=18.7 d=2021-07-13",
+			Params: []smp.Param{smp.FloatParam("a", "размер на одну бумагу", nil).WithMin(0),
+				smp.DateParam("d", "последний день покупки (ГГГГ-ММ-ДД)", nil)},
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				s.Dividends = append(s.Dividends, smp.Dividend{
+					InstrumentId: s.InstrumentId,
+					Ticker:       s.Ticker,
+					Amount:       v.Float("a"),
+					LastDate:     v.Time("d"),
+				})
+				s.Dividends.Sort()
+				return res, nil
+			}},
+		{Command: SetBuyDays, Description: "Установить за сколько дней до последнего дня покупки можно покупать",
+			Example: "set_buy_days 3",
+			Params:  []smp.Param{smp.IntParam("", "кол-во дней", &s.BuyDaysBefore).WithMin(0)}},
+		{Command: SetMinYield, Description: "Установить минимальную доходность дивиденда", Example: "set_min_yield 5",
+			Params: []smp.Param{smp.FloatParam("", "процент от цены", &s.MinYield).WithMin(0)}},
+		{Command: SetRecovery, Description: "Установить часть дивидендного гэпа для закрытия перед продажей",
+			Example: "set_recovery 0.5",
+			Params:  []smp.Param{smp.FloatParam("", "доля гэпа", &s.Recovery).WithMin(0).WithMax(1)}},
+		{Command: SetMaxHoldDays, Description: "Установить максимальный срок удержания после отсечки (0 - без ограничения)",
+			Example: "set_max_hold_days 30",
+			Params:  []smp.Param{smp.IntParam("", "кол-во дней", &s.MaxHoldDays).WithMin(0)}},
+	})
+}
+
+func (s *DividendCapture) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	return s.commands().Do(s.Type(), cmd, params)
+}
+
+func (s *DividendCapture) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
+
+func (s *DividendCapture) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), `dividend_capture - стратегия, захват дивидендов
+	Покупка по рынку до последнего дня покупки под дивиденд, удержание через отсечку
+	Продажа по рынку после закрытия части дивидендного гэпа или по истечении срока удержания
+	Дивиденд учитывается в доходе стратегии`)
+}
+
+// next - dividend with buy window containing tm and yield not less than MinYield by price
+func (s *DividendCapture) next(tm time.Time, price float64) (d smp.Dividend, ok bool) {
+	for _, d := range s.Dividends {
+		if d.Ticker != s.Ticker || d.InstrumentId != s.InstrumentId {
+			continue
+		}
+		if s.MinYield > 0 && d.Amount/price*100 < s.MinYield {
+			continue
+		}
+		from := d.LastDate.Add(-smp.H24 * time.Duration(s.BuyDaysBefore))
+		if !tm.Before(from) && tm.Before(d.LastDate.Add(smp.H24)) {
+			return d, true
+		}
+	}
+	return d, false
+}
+
+// applyOrder - applies status of order
+func (s *DividendCapture) applyOrder(status smp.StatusOrder, prices []smp.LotPrices, meta *smp.MetaForStep) {
+	if status != smp.Complete && status != smp.Canceled {
+		return
+	}
+
+	cnt, price := lotPricesSum(prices)
+	meta.HasChanges = true
+	s.OrderId = ""
+	if s.OrderBuy {
+		s.InMarket += cnt
+		s.InMarketPrice = smp.Round(s.InMarketPrice+price, 6)
+		return
+	}
+
+	if cnt <= 0 || s.InMarket <= 0 {
+		return
+	}
+	cost := s.InMarketPrice * float64(cnt) / float64(s.InMarket)
+	s.Profit = smp.Round(s.Profit+price-cost, 6)
+	s.InMarket -= cnt
+	s.InMarketPrice = smp.Round(s.InMarketPrice-cost, 6)
+	if s.InMarket == 0 {
+		s.InMarketPrice = 0
+		s.Captures++
+	}
+}
+
+// ActiveOrders - market order of strategy
+func (s *DividendCapture) ActiveOrders() []smp.ActiveOrder {
+	if s.OrderId == "" {
+		return nil
+	}
+	return []smp.ActiveOrder{{InstrumentId: s.InstrumentId, Ticker: s.Ticker, OrderId: s.OrderId, Buy: s.OrderBuy}}
+}
+
+// FlattenPosition - sells position by market
+func (s *DividendCapture) FlattenPosition(p smp.StepParams, meta *smp.MetaForStep) (done bool, err *mft.Error) {
+	if s.InMarket == 0 {
+		return true, nil
+	}
+
+	s.OrderBuy = false
+	s.OrderId, err = p.SellByMarket(s.InstrumentId, s.Ticker, s.InMarket, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	if err != nil {
+		s.OrderId = ""
+		return false, smp.GenerateErrorE(500002904, err)
+	}
+	meta.HasChanges = true
+	meta.OpDescr = append(meta.OpDescr, "Sell by market (flatten)")
+	return false, nil
+}
+
+// ResetState - clears captured dividend, profit, dividend income and captures
+func (s *DividendCapture) ResetState() (err *mft.Error) {
+	if s.InMarket != 0 {
+		return smp.GenerateError(500003551, s.Type(), s.InMarket)
+	}
+	s.Dividend = smp.Dividend{}
+	s.IsCredited = false
+	s.InMarketPrice = 0
+	s.Profit = 0
+	s.DividendIncome = 0
+	s.Captures = 0
+	return nil
+}
+
+func (s *DividendCapture) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
+	meta.Name = s.Name
+
+	if s.OrderId != "" {
+		var status smp.StatusOrder
+		var prices []smp.LotPrices
+		if s.OrderBuy {
+			status, prices, err = p.StatusBuyOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+			if err != nil {
+				return meta, smp.GenerateErrorE(500002901, err)
+			}
+		} else {
+			status, prices, err = p.StatusSellOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+			if err != nil {
+				return meta, smp.GenerateErrorE(500002902, err)
+			}
+		}
+		s.applyOrder(status, prices, &meta)
+	}
+
+	if halt, err := s.Lifecycle.Step(p, s, &meta); halt || err != nil {
+		return meta, err
+	}
+
+	if s.OrderId != "" || !s.IsOnline {
+		return meta, nil
+	}
+
+	ob, err := p.GetOrderBook(s.InstrumentId, s.Ticker)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500002900, err)
+	}
+	if ob.TradeStatus != smp.NormalTrading {
+		return meta, nil
+	}
+
+	if s.InMarket == 0 {
+		if s.Volume <= 0 {
+			return meta, nil
+		}
+		d, ok := s.next(ob.Time, ob.BuyPrice())
+		if !ok {
+			return meta, nil
+		}
+
+		meta.HasChanges = true
+		s.Dividend = d
+		s.IsCredited = false
+		s.OrderBuy = true
+		s.OrderId, err = p.BuyByMarket(s.InstrumentId, s.Ticker, s.Volume, &smp.MetaForOperations{NameOfStrategy: s.Name})
+		if err != nil {
+			s.OrderId = ""
+			return meta, smp.GenerateErrorE(500002903, err)
+		}
+		meta.OpDescr = append(meta.OpDescr, fmt.Sprintf("Buy for dividend %v of %v", d.Amount, d.LastDate.Format("2006-01-02")))
+		return meta, nil
+	}
+
+	exDate := s.Dividend.LastDate.Add(smp.H24)
+	if ob.Time.Before(exDate) {
+		return meta, nil
+	}
+
+	if !s.IsCredited {
+		meta.HasChanges = true
+		s.IsCredited = true
+		s.DividendIncome = smp.Round(s.DividendIncome+s.Dividend.Amount*float64(s.InMarket), 6)
+	}
+
+	target := s.InMarketPrice/float64(s.InMarket) - s.Dividend.Amount*(1-s.Recovery)
+	expired := s.MaxHoldDays > 0 && !ob.Time.Before(exDate.Add(smp.H24*time.Duration(s.MaxHoldDays)))
+	if ob.SellPrice() < target && !expired {
+		return meta, nil
+	}
+
+	meta.HasChanges = true
+	if expired {
+		meta.OpDescr = append(meta.OpDescr, "Max holding time is over")
+	}
+	s.OrderBuy = false
+	s.OrderId, err = p.SellByMarket(s.InstrumentId, s.Ticker, s.InMarket, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	if err != nil {
+		s.OrderId = ""
+		return meta, smp.GenerateErrorE(500002904, err)
+	}
+
+	return meta, nil
+}
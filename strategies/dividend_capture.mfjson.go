@@ -0,0 +1,19 @@
+// Code generated by mfjson for marshaling/unmarshaling. DO NOT EDIT.
+// https://github.com/myfantasy/json
+
+package strategies
+
+import (
+	mfj "github.com/myfantasy/json"
+)
+func (obj *DividendCapture) UnmarshalJSONTypeName() string {
+	return "smp.strategies.dividend_capture"
+}
+
+func init() {
+	mfj.GlobalStructFactory.Add("smp.strategies.dividend_capture", func() mfj.JsonInterfaceMarshaller { return &DividendCapture{} })
+	mfj.GlobalStructFactory.AddNil("smp.strategies.dividend_capture", func() mfj.JsonInterfaceMarshaller {
+		var out *DividendCapture
+		return out
+	})
+}
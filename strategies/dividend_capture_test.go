@@ -0,0 +1,138 @@
+package strategies
+
+import (
+	"testing"
+	"time"
+
+	smp "github.com/myfantasy/stock_market_primitives"
+	"github.com/myfantasy/stock_market_primitives/market"
+)
+
+// dividendCandles - candles of 2021-01-04 (day 0) and next days by day of each candle
+func dividendCandles(days []int, ohlc ...[4]float64) smp.Candles {
+	cs := testCandles(ohlc...)
+	for i := range cs {
+		cs[i].Start = cs[i].Start.Add(smp.H24 * time.Duration(days[i]))
+		cs[i].Date = cs[i].Date.Add(smp.H24 * time.Duration(days[i]))
+	}
+	return cs
+}
+
+func testDividendCapture() *DividendCapture {
+	return &DividendCapture{
+		Name:     "test",
+		Ticker:   "TTTT",
+		Volume:   5,
+		Recovery: 0.5,
+		Dividends: smp.Dividends{
+			{Ticker: "TTTT", Amount: 2, LastDate: time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)},
+		},
+		IsOnline: true,
+	}
+}
+
+func TestDividendCaptureRecovery(t *testing.T) {
+	p := &market.VirtualMarket{
+		Candles: dividendCandles([]int{0, 0, 0, 1, 1, 1, 1, 1},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{98, 98, 97, 98},
+			[4]float64{99, 99, 98.5, 99},
+			[4]float64{99, 100, 99, 100},
+			[4]float64{100, 101, 100, 101},
+			[4]float64{100, 101, 100, 101},
+		),
+		InstrumentInfo: &smp.InstrumentInfo{Ticker: "TTTT", LotSize: 1},
+		Account:        market.Account{Money: map[string]float64{"": 1000}},
+	}
+	p.Dividends = testDividendCapture().Dividends
+	s := testDividendCapture()
+
+	stepExecution(t, p, s)
+	if s.OrderId == "" || !s.OrderBuy {
+		t.Fatal("buy order is not placed before last date")
+	}
+
+	stepExecution(t, p, s)
+	if s.InMarket != 5 || s.IsCredited {
+		t.Fatalf("wrong state before ex-date: %v", s.Json())
+	}
+
+	// ex-date: price is under recovery target 99
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	if !s.IsCredited || s.DividendIncome != 10 || s.OrderId != "" {
+		t.Fatalf("wrong state after ex-date: %v", s.Json())
+	}
+
+	stepExecution(t, p, s)
+	if s.OrderId == "" || s.OrderBuy {
+		t.Fatalf("sell order is not placed after recovery: %v", s.Json())
+	}
+
+	stepExecution(t, p, s)
+	if s.InMarket != 0 || s.Captures != 1 || s.Profit != 0 {
+		t.Fatalf("wrong state after sell: %v", s.Json())
+	}
+
+	// backtest account gets the same dividend
+	if p.Account.Dividends != 10 || p.Account.Money[""] != 1000+10 {
+		t.Fatalf("wrong account after capture: %v", p.Account)
+	}
+}
+
+func TestDividendCaptureMaxHold(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: dividendCandles([]int{0, 0, 0, 1, 2, 2, 2},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{98, 98, 97, 98},
+			[4]float64{98, 98, 97, 98},
+			[4]float64{98, 98, 97, 98},
+			[4]float64{98, 98, 97, 98},
+		),
+	}
+	s := testDividendCapture()
+	s.Recovery = 1
+	s.MaxHoldDays = 1
+
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	if s.InMarket != 5 || s.OrderId != "" {
+		t.Fatalf("position is sold before max holding time: %v", s.Json())
+	}
+
+	stepExecution(t, p, s)
+	if s.OrderId == "" {
+		t.Fatalf("position is not sold after max holding time: %v", s.Json())
+	}
+	stepExecution(t, p, s)
+	if s.InMarket != 0 || s.Profit != 5*97-5*101 || s.DividendIncome != 10 {
+		t.Fatalf("wrong state after sell: %v", s.Json())
+	}
+}
+
+func TestDividendCaptureMinYield(t *testing.T) {
+	p := &market.StepParamsDummy{Candles: flatCandles(4)}
+	s := testDividendCapture()
+	s.MinYield = 5
+
+	stepExecution(t, p, s)
+	if s.OrderId != "" {
+		t.Fatal("dividend under min yield is bought")
+	}
+
+	_, ok, err := s.Command(AddDividend, map[string]string{"a": "6", "d": "2021-01-05"})
+	if !ok || err != nil || len(s.Dividends) != 2 {
+		t.Fatalf("add_dividend: %v", err)
+	}
+	s.BuyDaysBefore = 1
+
+	stepExecution(t, p, s)
+	if s.OrderId == "" || s.Dividend.Amount != 6 {
+		t.Fatalf("dividend over min yield is not bought: %v", s.Json())
+	}
+}
@@ -0,0 +1,380 @@
+package strategies
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+// Исполнение крупной заявки (родительской) частями (дочерними заявками) в течение окна времени
+
+// ParentOrder - parent order and its execution state (base of TWAP and VWAP)
+type ParentOrder struct {
+	Name string `json:"name"`
+
+	InstrumentId string `json:"instrument_id"`
+	Ticker       string `json:"ticker"`
+
+	// Buy - side of parent order (false - sell)
+	Buy bool `json:"buy"`
+	// Quantity - lots to execute
+	Quantity int `json:"quantity"`
+	// From, To - execution window
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// LimitPrice - worst price of child orders (0 - no limit)
+	LimitPrice float64 `json:"limit_price"`
+	// Slices - count of child orders in window
+	Slices int `json:"slices"`
+	// ByMarket - child orders are sent by market (skipped when price is over LimitPrice)
+	ByMarket bool `json:"by_market"`
+
+	IsOnline   bool `json:"is_online"`
+	IsCanceled bool `json:"is_canceled"`
+	IsDone     bool `json:"is_done"`
+
+	OrderId    string `json:"order_id"`
+	OrderSlice int    `json:"order_slice"`
+	Children   int    `json:"children"`
+
+	Filled      int     `json:"filled"`
+	FilledPrice float64 `json:"filled_price"`
+
+	// ArrivalPrice - price of first step in window
+	ArrivalPrice float64 `json:"arrival_price"`
+	// MarketVolume, MarketTurnover, MarketCandles, MarketPriceSum - market in window (by candles)
+	MarketVolume   int     `json:"market_volume"`
+	MarketTurnover float64 `json:"market_turnover"`
+	MarketCandles  int     `json:"market_candles"`
+	MarketPriceSum float64 `json:"market_price_sum"`
+
+	Lifecycle smp.Lifecycle `json:"lifecycle"`
+}
+
+// status - status of strategy (done parent order is not online)
+func (s *ParentOrder) status() smp.StartegyStatus {
+	st := s.Lifecycle.Status(s.IsOnline)
+	st.IsOnline = st.IsOnline && !s.IsDone
+	return st
+}
+
+// ActiveOrders - child order
+func (s *ParentOrder) ActiveOrders() []smp.ActiveOrder {
+	if s.OrderId == "" {
+		return nil
+	}
+	return []smp.ActiveOrder{{InstrumentId: s.InstrumentId, Ticker: s.Ticker, OrderId: s.OrderId, Buy: s.Buy}}
+}
+
+// ResetState - clears execution (parent order is executed again)
+func (s *ParentOrder) ResetState() (err *mft.Error) {
+	s.IsCanceled = false
+	s.IsDone = false
+	s.OrderSlice = 0
+	s.Children = 0
+	s.Filled = 0
+	s.FilledPrice = 0
+	s.ArrivalPrice = 0
+	s.MarketVolume, s.MarketTurnover, s.MarketCandles, s.MarketPriceSum = 0, 0, 0, 0
+	return nil
+}
+
+// Progress - executed part of Quantity
+func (s *ParentOrder) Progress() float64 {
+	if s.Quantity <= 0 {
+		return 0
+	}
+	return float64(s.Filled) / float64(s.Quantity)
+}
+
+// AveragePrice - average price of executed lots
+func (s *ParentOrder) AveragePrice() float64 {
+	if s.Filled <= 0 {
+		return 0
+	}
+	return smp.Round(s.FilledPrice/float64(s.Filled), 6)
+}
+
+// Participation - executed part of market volume in window
+func (s *ParentOrder) Participation() float64 {
+	if s.MarketVolume <= 0 {
+		return 0
+	}
+	return float64(s.Filled) / float64(s.MarketVolume)
+}
+
+// MarketTWAP - average close price of candles in window
+func (s *ParentOrder) MarketTWAP() float64 {
+	if s.MarketCandles <= 0 {
+		return 0
+	}
+	return smp.Round(s.MarketPriceSum/float64(s.MarketCandles), 6)
+}
+
+// MarketVWAP - volume weighted close price of candles in window
+func (s *ParentOrder) MarketVWAP() float64 {
+	if s.MarketVolume <= 0 {
+		return 0
+	}
+	return smp.Round(s.MarketTurnover/float64(s.MarketVolume), 6)
+}
+
+// Slippage - percent of AveragePrice worse than benchmark (negative is better)
+func (s *ParentOrder) Slippage(benchmark float64) float64 {
+	if benchmark <= 0 || s.Filled <= 0 {
+		return 0
+	}
+	d := (s.AveragePrice() - benchmark) / benchmark * 100
+	if !s.Buy {
+		d = -d
+	}
+	return smp.Round(d, 4)
+}
+
+func (s *ParentOrder) show(name string, benchmark float64) string {
+	state := "active"
+	if s.Lifecycle.Current(s.IsOnline) == smp.LifecyclePaused {
+		state = "paused"
+	}
+	if s.IsCanceled {
+		state = "canceled"
+	}
+	if s.IsDone {
+		state = "done"
+	}
+	return fmt.Sprintf("%v (%v): %v/%v lots (%.2f%%), average price %v, benchmark %v (slippage %v%%), arrival price %v, participation %.2f%%, children %v",
+		name, state, s.Filled, s.Quantity, s.Progress()*100, s.AveragePrice(), benchmark, s.Slippage(benchmark),
+		s.ArrivalPrice, s.Participation()*100, s.Children)
+}
+
+// sliceOf - index of slice of window by time
+func (s *ParentOrder) sliceOf(tm time.Time) int {
+	slices := s.Slices
+	if slices <= 0 {
+		slices = 1
+	}
+	d := s.To.Sub(s.From) / time.Duration(slices)
+	if d <= 0 {
+		return slices - 1
+	}
+	k := int(tm.Sub(s.From) / d)
+	if k < 0 {
+		k = 0
+	}
+	if k > slices-1 {
+		k = slices - 1
+	}
+	return k
+}
+
+// evenTarget - lots should be executed at the end of slice by even slices
+func (s *ParentOrder) evenTarget(slice int) int {
+	if s.Slices <= 1 {
+		return s.Quantity
+	}
+	return s.Quantity * (slice + 1) / s.Slices
+}
+
+// commands - commands of parent order (strategy - type of strategy, hooks and show are given by strategy)
+func (s *ParentOrder) commands(strategy string, hooks smp.LifecycleHooks, show smp.CommandDef) smp.CommandDefs {
+	return lifecycleCommands(show, s.Lifecycle.Commands(strategy, hooks, &s.IsOnline), smp.CommandDefs{
+		{Command: Cancel, Description: "Отменить исполнение (дочерняя заявка снимается)", Example: "cancel",
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				s.IsCanceled = true
+				return res, nil
+			}},
+		{Command: SetSide, Description: "Установить направление", Example: "set_side buy",
+			Params: []smp.Param{smp.StringParam("", "направление", nil).WithValues("buy", "sell")},
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				buy := v.String("") == "buy"
+				if buy != s.Buy && s.OrderId != "" {
+					return res, smp.GenerateError(500002206, s.OrderId)
+				}
+				s.Buy = buy
+				return res, nil
+			}},
+		{Command: SetVolume, Description: "Установить объём родительской заявки", Example: "set_vol 1000",
+			Params: []smp.Param{smp.IntParam("", "кол-во лотов", &s.Quantity).WithMin(0)}},
+		{Command: SetWindow, Description: "Установить окно исполнения",
+			Example: "set_window f=2021-01-04T10:00:00Z t=2021-01-04T18:00:00Z",
+			Params: []smp.Param{smp.TimeParam("f", "начало (RFC3339)", &s.From),
+				smp.TimeParam("t", "окончание (RFC3339)", &s.To)}},
+		{Command: SetLimit, Description: "Установить предельную цену (0 - без ограничения)", Example: "set_limit 345.67",
+			Params: []smp.Param{smp.FloatParam("", "цена", &s.LimitPrice).WithMin(0)}},
+		{Command: SetSlices, Description: "Установить кол-во частей", Example: "set_slices 20",
+			Params: []smp.Param{smp.IntParam("", "кол-во", &s.Slices).WithMin(1)}},
+		{Command: SetByMarket, Description: "Дочерние заявки по рынку", Example: "set_by_market true",
+			Params: []smp.Param{smp.BoolParam("", "true или false", &s.ByMarket)}},
+	})
+}
+
+// applyOrder - applies status of child order
+func (s *ParentOrder) applyOrder(status smp.StatusOrder, prices []smp.LotPrices, meta *smp.MetaForStep) {
+	if status != smp.Complete && status != smp.Canceled {
+		return
+	}
+
+	cnt, price := lotPricesSum(prices)
+	meta.HasChanges = true
+	s.OrderId = ""
+	s.Filled += cnt
+	s.FilledPrice = smp.Round(s.FilledPrice+price, 6)
+}
+
+func (s *ParentOrder) statusOrder(p smp.StepParams) (status smp.StatusOrder, prices []smp.LotPrices, err *mft.Error) {
+	if s.Buy {
+		return p.StatusBuyOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	}
+	return p.StatusSellOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+}
+
+func (s *ParentOrder) cancelOrder(p smp.StepParams) (ok bool, err *mft.Error) {
+	if s.Buy {
+		return p.CancelBuyOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	}
+	return p.CancelSellOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+}
+
+// market - market volume and prices in window
+func (s *ParentOrder) market(p smp.StepParams, tm time.Time) (err *mft.Error) {
+	if tm.After(s.To) {
+		tm = s.To
+	}
+	cs, err := p.GetCandles(s.InstrumentId, s.Ticker, s.From, tm)
+	if err != nil {
+		return err
+	}
+
+	s.MarketVolume, s.MarketTurnover, s.MarketCandles, s.MarketPriceSum = 0, 0, 0, 0
+	for _, c := range cs {
+		if c.Start.Before(s.From) {
+			continue
+		}
+		s.MarketVolume += c.Vol
+		s.MarketTurnover += c.Close * float64(c.Vol)
+		s.MarketCandles++
+		s.MarketPriceSum += c.Close
+	}
+	return nil
+}
+
+// step - executes parent order, target - lots should be executed at the end of slice
+func (s *ParentOrder) step(p smp.StepParams, target func(slice int) int) (meta smp.MetaForStep, err *mft.Error) {
+	meta.Name = s.Name
+
+	if s.OrderId != "" {
+		status, prices, err := s.statusOrder(p)
+		if err != nil {
+			return meta, smp.GenerateErrorE(500002202, err)
+		}
+		s.applyOrder(status, prices, &meta)
+	}
+
+	if halt, err := s.Lifecycle.Step(p, s, &meta); halt || err != nil {
+		return meta, err
+	}
+
+	if !s.IsOnline || s.IsDone {
+		return meta, nil
+	}
+
+	ob, err := p.GetOrderBook(s.InstrumentId, s.Ticker)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500002200, err)
+	}
+
+	if ob.Time.Before(s.From) && !s.IsCanceled {
+		return meta, nil
+	}
+
+	if !ob.Time.Before(s.From) {
+		err = s.market(p, ob.Time)
+		if err != nil {
+			return meta, smp.GenerateErrorE(500002201, err)
+		}
+		if s.ArrivalPrice == 0 {
+			meta.HasChanges = true
+			s.ArrivalPrice = ob.Price()
+		}
+	}
+
+	slice := s.sliceOf(ob.Time)
+	expired := !ob.Time.Before(s.To)
+
+	// child order lives till end of its slice
+	if s.OrderId != "" && (s.IsCanceled || expired || slice > s.OrderSlice) {
+		_, err := s.cancelOrder(p)
+		if err != nil {
+			return meta, smp.GenerateErrorE(500002203, err)
+		}
+		status, prices, err := s.statusOrder(p)
+		if err != nil {
+			return meta, smp.GenerateErrorE(500002202, err)
+		}
+		s.applyOrder(status, prices, &meta)
+	}
+
+	if s.OrderId != "" {
+		return meta, nil
+	}
+
+	if s.IsCanceled || expired || s.Filled >= s.Quantity {
+		meta.HasChanges = true
+		s.IsDone = true
+		meta.OpDescr = append(meta.OpDescr, fmt.Sprintf("Parent order is finished: %v/%v lots", s.Filled, s.Quantity))
+		return meta, nil
+	}
+
+	if ob.TradeStatus != smp.NormalTrading {
+		return meta, nil
+	}
+
+	cnt := target(slice) - s.Filled
+	if cnt > s.Quantity-s.Filled {
+		cnt = s.Quantity - s.Filled
+	}
+	if cnt <= 0 {
+		return meta, nil
+	}
+
+	price := ob.BuyPrice()
+	overLimit := s.LimitPrice > 0 && price > s.LimitPrice
+	if !s.Buy {
+		price = ob.SellPrice()
+		overLimit = s.LimitPrice > 0 && price < s.LimitPrice
+	}
+	if overLimit {
+		if s.ByMarket {
+			meta.OpDescr = append(meta.OpDescr, "Price is over limit, child order is skipped")
+			return meta, nil
+		}
+		price = s.LimitPrice
+	}
+
+	meta.HasChanges = true
+	if s.ByMarket && s.Buy {
+		s.OrderId, err = p.BuyByMarket(s.InstrumentId, s.Ticker, cnt, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	}
+	if s.ByMarket && !s.Buy {
+		s.OrderId, err = p.SellByMarket(s.InstrumentId, s.Ticker, cnt, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	}
+	if !s.ByMarket && s.Buy {
+		s.OrderId, err = p.BuyByPrice(s.InstrumentId, s.Ticker, cnt, price, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	}
+	if !s.ByMarket && !s.Buy {
+		s.OrderId, err = p.SellByPrice(s.InstrumentId, s.Ticker, cnt, price, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	}
+	if err != nil {
+		s.OrderId = ""
+		if s.ByMarket {
+			return meta, smp.GenerateErrorE(500002204, err)
+		}
+		return meta, smp.GenerateErrorE(500002205, err)
+	}
+	s.OrderSlice = slice
+	s.Children++
+
+	return meta, nil
+}
@@ -0,0 +1,229 @@
+package strategies
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	smp "github.com/myfantasy/stock_market_primitives"
+	"github.com/myfantasy/stock_market_primitives/market"
+)
+
+func testTWAP(quantity int, slices int) *TWAP {
+	return &TWAP{ParentOrder: ParentOrder{
+		Name:     "test",
+		Ticker:   "TTTT",
+		Buy:      true,
+		Quantity: quantity,
+		From:     time.Date(2021, 1, 4, 10, 2, 0, 0, time.UTC),
+		To:       time.Date(2021, 1, 4, 10, 7, 0, 0, time.UTC),
+		Slices:   slices,
+		ByMarket: true,
+		IsOnline: true,
+	}}
+}
+
+// stepExecution - next candle and step of execution strategy
+func stepExecution(t *testing.T, p interface {
+	smp.StepParams
+	DoStep() bool
+}, s smp.Strategy) {
+	t.Helper()
+	if !p.DoStep() {
+		t.Fatal("no more candles")
+	}
+	_, err := s.Step(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func flatCandles(count int) smp.Candles {
+	ohlc := make([][4]float64, count)
+	for i := range ohlc {
+		ohlc[i] = [4]float64{100, 101, 99, 100}
+	}
+	return testCandles(ohlc...)
+}
+
+func TestTWAPByMarket(t *testing.T) {
+	p := &market.StepParamsDummy{Candles: flatCandles(9)}
+	s := testTWAP(10, 5)
+
+	for i := 0; i < 5; i++ {
+		stepExecution(t, p, s)
+		if s.Filled != 2*i || s.OrderId == "" {
+			t.Fatalf("slice %v: %v lots filled expected, got %v (order `%v`)", i, 2*i, s.Filled, s.OrderId)
+		}
+	}
+
+	stepExecution(t, p, s)
+	if !s.IsDone || s.Filled != 10 || s.Children != 5 || s.AveragePrice() != 101 {
+		t.Fatalf("wrong state after window: %v", s.Json())
+	}
+	if s.MarketVolume != 400 || s.MarketTWAP() != 100 || s.Slippage(s.MarketTWAP()) != 1 {
+		t.Fatalf("wrong market stats: %v", s.Json())
+	}
+	if s.Status().IsOnline {
+		t.Fatal("finished parent order is online")
+	}
+}
+
+func TestTWAPPauseResume(t *testing.T) {
+	p := &market.StepParamsDummy{Candles: flatCandles(9)}
+	s := testTWAP(10, 5)
+
+	stepExecution(t, p, s)
+	if _, ok, err := s.Command(smp.PauseCommand, nil); !ok || err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	if s.Filled != 2 || s.OrderId != "" || s.Children != 1 {
+		t.Fatalf("child order is placed on pause: %v", s.Json())
+	}
+
+	if _, ok, err := s.Command(smp.ResumeCommand, nil); !ok || err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+
+	// missed slices are caught up
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	if s.Filled != 8 || s.Children != 3 {
+		t.Fatalf("wrong state after resume: %v", s.Json())
+	}
+}
+
+func TestTWAPLimitCancel(t *testing.T) {
+	p := &market.StepParamsDummy{Candles: flatCandles(9)}
+	s := testTWAP(10, 5)
+	s.ByMarket = false
+	s.LimitPrice = 95
+
+	stepExecution(t, p, s)
+	if s.OrderId == "" {
+		t.Fatal("child order is not placed")
+	}
+
+	if _, ok, err := s.Command(Cancel, nil); !ok || err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	stepExecution(t, p, s)
+	if !s.IsDone || s.OrderId != "" || s.Filled != 0 || len(p.Actions) != 0 {
+		t.Fatalf("wrong state after cancel: %v", s.Json())
+	}
+}
+
+func TestTWAPSellByPrice(t *testing.T) {
+	p := &market.StepParamsDummy{Candles: flatCandles(9)}
+	s := testTWAP(4, 2)
+	s.Buy = false
+	s.ByMarket = false
+
+	for !s.IsDone {
+		stepExecution(t, p, s)
+	}
+	if s.Filled != 4 || s.Children != 2 || s.AveragePrice() != 99 {
+		t.Fatalf("wrong state after window: %v", s.Json())
+	}
+}
+
+func TestParentOrderCommands(t *testing.T) {
+	s := testTWAP(10, 5)
+
+	_, ok, err := s.Command(SetWindow, map[string]string{"f": "2021-01-05T10:00:00Z", "t": "2021-01-05T18:00:00Z"})
+	if !ok || err != nil {
+		t.Fatalf("set_window: %v", err)
+	}
+	if !s.From.Equal(time.Date(2021, 1, 5, 10, 0, 0, 0, time.UTC)) || !s.To.Equal(time.Date(2021, 1, 5, 18, 0, 0, 0, time.UTC)) {
+		t.Fatalf("wrong window: %v - %v", s.From, s.To)
+	}
+
+	_, ok, err = s.Command(SetSide, map[string]string{"": "sell"})
+	if !ok || err != nil || s.Buy {
+		t.Fatalf("set_side: %v", err)
+	}
+
+	s.OrderId = "1"
+	_, _, err = s.Command(SetSide, map[string]string{"": "buy"})
+	if err == nil || err.Code != 500002206 || s.Buy {
+		t.Fatalf("set_side with active child order should fail: %v", err)
+	}
+	s.OrderId = ""
+
+	_, ok, err = s.Command(SetSide, map[string]string{"": "hold"})
+	if ok || err == nil || err.Code != 500003605 {
+		t.Fatalf("set_side with wrong side should fail: %v", err)
+	}
+
+	_, _, err = s.Command("unknown", nil)
+	if err == nil || err.Code != 500003600 {
+		t.Fatalf("unknown command should fail: %v", err)
+	}
+}
+
+// volCandles - 1 minute candles from tm with volumes
+func volCandles(tm time.Time, vols ...int) smp.Candles {
+	cs := make(smp.Candles, 0, len(vols))
+	for i, v := range vols {
+		start := tm.Add(time.Duration(i) * time.Minute)
+		cs = append(cs, smp.Candle{
+			Ticker: "TTTT",
+			Start:  start,
+			Date:   start.Add(time.Minute),
+			Open:   100,
+			High:   101,
+			Low:    99,
+			Close:  100,
+			Vol:    v,
+		})
+	}
+	return cs
+}
+
+func TestVWAPProfile(t *testing.T) {
+	cs := volCandles(time.Date(2021, 1, 3, 10, 0, 0, 0, time.UTC), 300, 100, 100, 100)
+	cs = append(cs, volCandles(time.Date(2021, 1, 3, 10, 0, 0, 0, time.UTC).Add(smp.H24).Add(-time.Minute),
+		100, 100, 100, 100, 100, 100, 100)...)
+	p := &market.VirtualMarket{Candles: cs}
+	s := &VWAP{
+		ParentOrder: ParentOrder{
+			Name:     "test",
+			Ticker:   "TTTT",
+			Buy:      true,
+			Quantity: 10,
+			From:     time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC),
+			To:       time.Date(2021, 1, 4, 10, 4, 0, 0, time.UTC),
+			Slices:   4,
+			ByMarket: true,
+			IsOnline: true,
+		},
+		ProfileDays: 2,
+	}
+
+	// previous day: out of window
+	for i := 0; i < 3; i++ {
+		stepExecution(t, p, s)
+	}
+	if s.IsProfiled || s.OrderId != "" {
+		t.Fatalf("parent order is started out of window: %v", s.Json())
+	}
+
+	filled := []int{0, 5, 7, 8}
+	for i := range filled {
+		stepExecution(t, p, s)
+		if s.Filled != filled[i] {
+			t.Fatalf("slice %v: %v lots filled expected, got %v", i, filled[i], s.Filled)
+		}
+	}
+	if !reflect.DeepEqual(s.Profile, []float64{0.5, 0.666667, 0.833333, 1}) {
+		t.Fatalf("wrong profile: %v", s.Profile)
+	}
+
+	stepExecution(t, p, s)
+	if !s.IsDone || s.Filled != 10 || s.Children != 4 || s.AveragePrice() != 100 {
+		t.Fatalf("wrong state after window: %v", s.Json())
+	}
+}
@@ -0,0 +1,288 @@
+package strategies
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"github.com/myfantasy/mfs"
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+//go:generate mfjson grid.go
+
+// Сетка (Grid)
+
+var (
+	_ smp.Strategy = &Grid{}
+)
+
+type GridSpacing string
+
+const (
+	// ArithmeticSpacing - levels are LevelPrice + i*PriceStep
+	ArithmeticSpacing GridSpacing = "arithmetic"
+	// GeometricSpacing - levels are LevelPrice * (1 + PriceStep/100)^i
+	GeometricSpacing GridSpacing = "geometric"
+)
+
+type GridVolumeMode string
+
+const (
+	// FlatVolume - the same Volume at every level
+	FlatVolume GridVolumeMode = "flat"
+	// LinearVolume - Volume + VolumeStep for every level under LevelPrice
+	LinearVolume GridVolumeMode = "linear"
+	// MartingaleVolume - Volume * VolumeMultiplier for every level under LevelPrice
+	MartingaleVolume GridVolumeMode = "martingale"
+)
+
+//mfjson:interface smp.strategies.grid
+type Grid struct {
+	Name string `json:"name"`
+
+	InstrumentId string `json:"instrument_id"`
+	Ticker       string `json:"ticker"`
+
+	LevelPrice float64     `json:"level_price"`
+	Spacing    GridSpacing `json:"spacing"`
+	// PriceStep - price step (arithmetic) or percent (geometric) between levels
+	PriceStep float64 `json:"price_step"`
+
+	Volume           int            `json:"volume"`
+	VolumeMode       GridVolumeMode `json:"volume_mode"`
+	VolumeStep       int            `json:"volume_step"`
+	VolumeMultiplier float64        `json:"volume_multiplier"`
+	// VolumeMax - max volume of level (0 - no limit)
+	VolumeMax int `json:"volume_max"`
+
+	// LowerBound, UpperBound - new orders are not placed when price is out of bounds (0 - no bound)
+	LowerBound float64 `json:"lower_bound"`
+	UpperBound float64 `json:"upper_bound"`
+
+	// Recenter - render levels around current price when price leaves levels and grid is out of market
+	Recenter   bool `json:"recenter"`
+	Recentered int  `json:"recentered"`
+
+	// From, To - rendered levels (steps from LevelPrice)
+	From int `json:"from"`
+	To   int `json:"to"`
+
+	IsOnline bool `json:"is_online"`
+
+	Levels []WingedSwing `json:"levels"`
+
+	mx mfs.PMutex
+}
+
+func (s *Grid) Type() string {
+	return "grid"
+}
+
+func (s *Grid) String() string {
+	return "grid"
+}
+
+func (s *Grid) Status() smp.StartegyStatus {
+	return childrenStatus(s.IsOnline, s.children())
+}
+func (s *Grid) Json() string {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return string(b)
+}
+
+// commands - commands of strategy
+func (s *Grid) commands() smp.CommandDefs {
+	return lifecycleCommands(showCommand("Отобразить", s.String), childrenLifecycleCommands(s.Type(), &s.IsOnline, s.children), smp.CommandDefs{
+		{Command: SetLevel, Description: "Установить центр сетки", Example: "set_level 345.67",
+			Params: []smp.Param{smp.FloatParam("", "уровень", &s.LevelPrice)}},
+		{Command: SetSpacing, Description: "Установить тип шага сетки (arithmetic - шаг цены, geometric - шаг в процентах)",
+			Example: "set_spacing geometric",
+			Params: []smp.Param{smp.StringParam("", "тип шага", &s.Spacing).
+				WithValues(string(ArithmeticSpacing), string(GeometricSpacing))}},
+		{Command: SetPriceStep, Description: "Установить шаг сетки", Example: "set_price_step 1.5",
+			Params: []smp.Param{smp.FloatParam("", "шаг цены или процент", &s.PriceStep).WithMin(0)}},
+		{Command: SetVolume, Description: "Установить объём уровня", Example: "set_vol 25",
+			Params: []smp.Param{smp.IntParam("", "объём (кол-во лотов)", &s.Volume).WithMin(0)}},
+		{Command: SetVolumeMode, Description: "Установить изменение объёма ниже центра сетки (flat - одинаковый, linear - +шаг объёма, martingale - *множитель)",
+			Example: "set_volume_mode linear",
+			Params: []smp.Param{smp.StringParam("", "изменение объёма", &s.VolumeMode).
+				WithValues(string(FlatVolume), string(LinearVolume), string(MartingaleVolume))}},
+		{Command: SetVolumeStep, Description: "Установить шаг объёма (linear)", Example: "set_volume_step 5",
+			Params: []smp.Param{smp.IntParam("", "кол-во лотов", &s.VolumeStep).WithMin(0)}},
+		{Command: SetVolumeMultiplier, Description: "Установить множитель объёма (martingale)", Example: "set_volume_multiplier 2",
+			Params: []smp.Param{smp.FloatParam("", "множитель", &s.VolumeMultiplier).WithMin(0)}},
+		{Command: SetVolumeMax, Description: "Установить максимальный объём уровня (0 - без ограничения)", Example: "set_volume_max 100",
+			Params: []smp.Param{smp.IntParam("", "кол-во лотов", &s.VolumeMax).WithMin(0)}},
+		{Command: SetLowerBound, Description: "Установить нижнюю границу (ниже границы сетка не выставляет заявки)",
+			Example: "set_lower_bound 300",
+			Params:  []smp.Param{smp.FloatParam("", "цена", &s.LowerBound)}},
+		{Command: SetUpperBound, Description: "Установить верхнюю границу (выше границы сетка не выставляет заявки)",
+			Example: "set_upper_bound 400",
+			Params:  []smp.Param{smp.FloatParam("", "цена", &s.UpperBound)}},
+		{Command: SetRecenter, Description: "Перестраивать сетку вокруг текущей цены при выходе цены за уровни сетки",
+			Example: "set_recenter true",
+			Params:  []smp.Param{smp.BoolParam("", "true/false", &s.Recenter)}},
+		{Command: Render, Description: "Сгенерировать уровни сетки", Example: "render f=-5 t=10",
+			Params: []smp.Param{smp.IntParam("f", "от шагов", nil), smp.IntParam("t", "до шагов", nil)},
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				if s.PriceStep <= 0 {
+					return res, smp.GenerateError(500000944, Render, s.PriceStep)
+				}
+				if len(s.Levels) > 0 {
+					return res, smp.GenerateError(500000945, Render, len(s.Levels))
+				}
+				if v.Int("f") > v.Int("t") {
+					return res, smp.GenerateError(500000946, Render, v.Int("f"), v.Int("t"))
+				}
+
+				s.From, s.To = v.Int("f"), v.Int("t")
+				s.render()
+				return res, nil
+			}},
+	})
+}
+
+func (s *Grid) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.commands().Do(s.Type(), cmd, params)
+}
+
+func (s *Grid) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
+
+// children - levels of grid
+func (s *Grid) children() []smp.Strategy {
+	children := make([]smp.Strategy, 0, len(s.Levels))
+	for i := range s.Levels {
+		children = append(children, &s.Levels[i])
+	}
+	return children
+}
+
+func (s *Grid) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), `grid - стратегия, сетка
+	На каждом уровне покупка по цене уровня и продажа по цене следующего уровня`)
+}
+
+// LevelPriceOf - price of level i (steps from LevelPrice)
+func (s *Grid) LevelPriceOf(i int) float64 {
+	if s.Spacing == GeometricSpacing {
+		return smp.Round(s.LevelPrice*math.Pow(1+s.PriceStep/100, float64(i)), 6)
+	}
+	return smp.Round(s.LevelPrice+float64(i)*s.PriceStep, 6)
+}
+
+// VolumeOf - volume of level i (steps from LevelPrice)
+func (s *Grid) VolumeOf(i int) int {
+	depth := 0
+	if i < 0 {
+		depth = -i
+	}
+
+	volume := s.Volume
+	switch s.VolumeMode {
+	case LinearVolume:
+		volume = s.Volume + depth*s.VolumeStep
+	case MartingaleVolume:
+		if s.VolumeMultiplier > 0 {
+			volume = int(math.Round(float64(s.Volume) * math.Pow(s.VolumeMultiplier, float64(depth))))
+		}
+	}
+
+	if s.VolumeMax > 0 && volume > s.VolumeMax {
+		return s.VolumeMax
+	}
+	if volume < 0 {
+		return 0
+	}
+	return volume
+}
+
+// render - makes levels From..To
+func (s *Grid) render() {
+	s.Levels = make([]WingedSwing, 0, s.To-s.From+1)
+	for i := s.From; i <= s.To; i++ {
+		s.Levels = append(s.Levels, WingedSwing{
+			Name:         s.Name + "[" + strconv.Itoa(i) + "]",
+			InstrumentId: s.InstrumentId,
+			Ticker:       s.Ticker,
+			Volume:       s.VolumeOf(i),
+
+			LevelPriceUp:   s.LevelPriceOf(i + 1),
+			LevelPriceDown: s.LevelPriceOf(i),
+
+			// level buys when price comes down from above, sells inside the grid
+			LevelPriceOnTheMarketUp:   s.LevelPriceOf(s.To + 1),
+			LevelPriceOnTheMarketDown: s.LevelPriceOf(i),
+
+			IsOnline: s.IsOnline,
+
+			Labels: map[string]string{"i": strconv.Itoa(i)},
+		})
+	}
+}
+
+// isOutOfMarket - there are no lots and no orders on levels
+func (s *Grid) isOutOfMarket() bool {
+	for i := range s.Levels {
+		if s.Levels[i].InMarket != 0 || s.Levels[i].OrderIdBuy != "" || s.Levels[i].OrderIdSell != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Grid) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	meta.Name = s.Name
+
+	ob, err := p.GetOrderBook(s.InstrumentId, s.Ticker)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500001000, err)
+	}
+	price := ob.Price()
+
+	outOfBounds := s.LowerBound > 0 && price < s.LowerBound ||
+		s.UpperBound > 0 && price > s.UpperBound
+
+	// paused and stopping levels are not rendered again
+	if s.IsOnline && s.Status().State == smp.LifecycleRunning && !outOfBounds && s.Recenter && len(s.Levels) > 0 &&
+		(price < s.LevelPriceOf(s.From) || price > s.LevelPriceOf(s.To+1)) &&
+		s.isOutOfMarket() {
+		meta.HasChanges = true
+		meta.OpDescr = append(meta.OpDescr, "Recenter: "+strconv.FormatFloat(price, 'f', -1, 64))
+		s.LevelPrice = price
+		s.Recentered++
+		s.render()
+	}
+
+	for i := range s.Levels {
+		isOnline := s.Levels[i].IsOnline
+		if outOfBounds {
+			// orders of level are tracked but new orders are not placed
+			s.Levels[i].IsOnline = false
+		}
+		mt, er := s.Levels[i].Step(p)
+		s.Levels[i].IsOnline = isOnline
+		if mt.HasChanges {
+			meta.HasChanges = true
+		}
+		meta.SubMeta = append(meta.SubMeta, mt)
+		err = err.AppendList(er)
+	}
+
+	if err != nil {
+		return meta, smp.GenerateErrorSubList(500001001, err.InternalErrors, len(err.InternalErrors), len(s.Levels))
+	}
+
+	return meta, nil
+}
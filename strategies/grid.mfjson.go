@@ -0,0 +1,19 @@
+// Code generated by mfjson for marshaling/unmarshaling. DO NOT EDIT.
+// https://github.com/myfantasy/json
+
+package strategies
+
+import (
+	mfj "github.com/myfantasy/json"
+)
+func (obj *Grid) UnmarshalJSONTypeName() string {
+	return "smp.strategies.grid"
+}
+
+func init() {
+	mfj.GlobalStructFactory.Add("smp.strategies.grid", func() mfj.JsonInterfaceMarshaller { return &Grid{} })
+	mfj.GlobalStructFactory.AddNil("smp.strategies.grid", func() mfj.JsonInterfaceMarshaller {
+		var out *Grid
+		return out
+	})
+}
@@ -0,0 +1,178 @@
+package strategies
+
+import (
+	"testing"
+
+	smp "github.com/myfantasy/stock_market_primitives"
+	"github.com/myfantasy/stock_market_primitives/market"
+)
+
+func TestGridLevels(t *testing.T) {
+	s := &Grid{
+		LevelPrice: 100,
+		PriceStep:  2,
+		Volume:     2,
+	}
+
+	if s.LevelPriceOf(-2) != 96 || s.LevelPriceOf(3) != 106 {
+		t.Fatalf("arithmetic: wrong levels %v %v", s.LevelPriceOf(-2), s.LevelPriceOf(3))
+	}
+	s.Spacing = GeometricSpacing
+	if s.LevelPriceOf(2) != 104.04 || s.LevelPriceOf(-1) != 98.039216 {
+		t.Fatalf("geometric: wrong levels %v %v", s.LevelPriceOf(2), s.LevelPriceOf(-1))
+	}
+
+	if s.VolumeOf(-3) != 2 || s.VolumeOf(3) != 2 {
+		t.Fatalf("flat: wrong volumes %v %v", s.VolumeOf(-3), s.VolumeOf(3))
+	}
+	s.VolumeMode = LinearVolume
+	s.VolumeStep = 3
+	if s.VolumeOf(-3) != 11 || s.VolumeOf(3) != 2 {
+		t.Fatalf("linear: wrong volumes %v %v", s.VolumeOf(-3), s.VolumeOf(3))
+	}
+	s.VolumeMode = MartingaleVolume
+	s.VolumeMultiplier = 2
+	s.VolumeMax = 10
+	if s.VolumeOf(-2) != 8 || s.VolumeOf(-3) != 10 {
+		t.Fatalf("martingale: wrong volumes %v %v", s.VolumeOf(-2), s.VolumeOf(-3))
+	}
+}
+
+func TestGridCommands(t *testing.T) {
+	s := &Grid{}
+
+	cmds := []struct {
+		cmd    string
+		params map[string]string
+	}{
+		{"set_level", map[string]string{"": "100"}},
+		{"set_price_step", map[string]string{"": "2"}},
+		{"set_vol", map[string]string{"": "1"}},
+		{"set_volume_mode", map[string]string{"": "linear"}},
+		{"set_volume_step", map[string]string{"": "1"}},
+		{"render", map[string]string{"f": "-1", "t": "1"}},
+	}
+	for _, c := range cmds {
+		_, ok, err := s.Command(smp.Command(c.cmd), c.params)
+		if err != nil || !ok {
+			t.Fatalf("command %v: %v", c.cmd, err)
+		}
+	}
+
+	if len(s.Levels) != 3 || s.Levels[0].LevelPriceDown != 98 || s.Levels[0].LevelPriceUp != 100 ||
+		s.Levels[0].Volume != 2 || s.Levels[2].Volume != 1 {
+		t.Fatalf("wrong levels: %v", s.Json())
+	}
+
+	_, _, err := s.Command(SetSpacing, map[string]string{"": "exponential"})
+	if err == nil {
+		t.Fatal("wrong spacing is accepted")
+	}
+	_, _, err = s.Command(Render, map[string]string{"f": "-1", "t": "1"})
+	if err == nil {
+		t.Fatal("levels are rendered twice")
+	}
+	_, _, err = s.Command(SetVolumeStep, map[string]string{"": "-1"})
+	if err == nil || err.Code != 500003603 {
+		t.Fatalf("negative volume step error expected, got %v", err)
+	}
+
+	s = &Grid{PriceStep: 1}
+	_, _, err = s.Command(Render, map[string]string{"f": "10", "t": "-5"})
+	if err == nil || err.Code != 500000946 || len(s.Levels) != 0 {
+		t.Fatalf("wrong range error expected, got %v", err)
+	}
+}
+
+func TestGridStep(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{101, 101, 100.5, 101},
+			[4]float64{101, 101, 100.5, 101},
+			[4]float64{100, 100, 99.5, 100},
+			[4]float64{102, 103, 102, 103},
+			[4]float64{102, 103, 102, 103},
+		),
+	}
+	s := &Grid{
+		Ticker:     "TTTT",
+		LevelPrice: 100,
+		PriceStep:  2,
+		Volume:     1,
+		From:       -1,
+		To:         1,
+		IsOnline:   true,
+	}
+	s.render()
+
+	step := func() {
+		t.Helper()
+		p.DoStep()
+		_, err := s.Step(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// levels under price are armed
+	step()
+	if s.Levels[0].State != SwingBuying || s.Levels[1].State != SwingBuying || s.Levels[2].State != SwingWaiting {
+		t.Fatalf("wrong levels after start: %v", s.Json())
+	}
+
+	step()
+	if s.Levels[1].State != SwingSelling || s.Levels[1].OrderIdSell == "" {
+		t.Fatalf("level 0 is not bought: %v", s.Json())
+	}
+
+	step()
+	if s.Levels[1].Iteration != 1 || s.Levels[1].Profit != 2 {
+		t.Fatalf("level 0 is not sold: %v", s.Json())
+	}
+	if s.Levels[2].State != SwingBuying {
+		t.Fatalf("level 1 is not armed: %v", s.Json())
+	}
+}
+
+func TestGridBoundsAndRecenter(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{110, 110, 109.5, 110},
+			[4]float64{110, 110, 109.5, 110},
+			[4]float64{110, 110, 109.5, 110},
+			[4]float64{110, 110, 109.5, 110},
+		),
+	}
+	s := &Grid{
+		Ticker:     "TTTT",
+		LevelPrice: 100,
+		PriceStep:  2,
+		Volume:     1,
+		From:       -1,
+		To:         1,
+		UpperBound: 105,
+		Recenter:   true,
+		IsOnline:   true,
+	}
+	s.render()
+
+	// price is over upper bound: nothing to do
+	p.DoStep()
+	_, err := s.Step(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Recentered != 0 || s.Levels[0].OrderIdBuy != "" || !s.Levels[0].IsOnline {
+		t.Fatalf("grid works over upper bound: %v", s.Json())
+	}
+
+	s.UpperBound = 0
+	p.DoStep()
+	_, err = s.Step(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Recentered != 1 || s.LevelPrice != 110 || s.Levels[0].LevelPriceDown != 108 || s.Levels[0].OrderIdBuy == "" {
+		t.Fatalf("grid is not recentered: %v", s.Json())
+	}
+}
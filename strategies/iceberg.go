@@ -0,0 +1,289 @@
+package strategies
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+//go:generate mfjson iceberg.go
+
+// Айсберг - крупная заявка по цене, на рынке видна только её часть
+
+var (
+	_ smp.Strategy = &Iceberg{}
+)
+
+//mfjson:interface smp.strategies.iceberg
+type Iceberg struct {
+	Name string `json:"name"`
+
+	InstrumentId string `json:"instrument_id"`
+	Ticker       string `json:"ticker"`
+
+	// Buy - side of order (false - sell)
+	Buy bool `json:"buy"`
+	// Quantity - lots of whole order
+	Quantity int `json:"quantity"`
+	// LevelPrice - limit price of order
+	LevelPrice float64 `json:"level_price"`
+	// Visible - lots of visible slice
+	Visible int `json:"visible"`
+	// VisibleVariance - visible slice is randomized by +- VisibleVariance lots (0 - no randomization)
+	VisibleVariance int `json:"visible_variance"`
+	// PriceOffset - price of slice is randomized by 0 ... PriceOffset price steps to passive side (0 - no randomization)
+	PriceOffset int `json:"price_offset"`
+	// Seed - seed of randomization (with Refills makes slices reproducible)
+	Seed int64 `json:"seed"`
+
+	IsOnline    bool `json:"is_online"`
+	IsCanceled  bool `json:"is_canceled"`
+	IsDone      bool `json:"is_done"`
+	NeedReplace bool `json:"need_replace"`
+
+	OrderId    string  `json:"order_id"`
+	OrderCnt   int     `json:"order_cnt"`
+	OrderPrice float64 `json:"order_price"`
+	// Refills - count of placed slices
+	Refills int `json:"refills"`
+
+	Filled      int     `json:"filled"`
+	FilledPrice float64 `json:"filled_price"`
+
+	Lifecycle smp.Lifecycle `json:"lifecycle"`
+}
+
+func (s *Iceberg) Type() string {
+	return "iceberg"
+}
+
+func (s *Iceberg) String() string {
+	return "iceberg"
+}
+
+func (s *Iceberg) Status() smp.StartegyStatus {
+	st := s.Lifecycle.Status(s.IsOnline)
+	st.IsOnline = st.IsOnline && !s.IsDone
+	return st
+}
+func (s *Iceberg) Json() string {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return string(b)
+}
+
+// AveragePrice - average price of executed lots
+func (s *Iceberg) AveragePrice() float64 {
+	if s.Filled <= 0 {
+		return 0
+	}
+	return smp.Round(s.FilledPrice/float64(s.Filled), 6)
+}
+
+// commands - commands of strategy
+func (s *Iceberg) commands() smp.CommandDefs {
+	show := showCommand("Отобразить (исполнено, средняя цена, видимая часть)", func() string {
+		return fmt.Sprintf("%v: %v/%v lots, average price %v, refills %v, visible %v lots by %v",
+			s.String(), s.Filled, s.Quantity, s.AveragePrice(), s.Refills, s.OrderCnt, s.OrderPrice)
+	})
+	return lifecycleCommands(show, s.Lifecycle.Commands(s.Type(), s, &s.IsOnline), smp.CommandDefs{
+		{Command: Cancel, Description: "Отменить (видимая заявка снимается)", Example: "cancel",
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				s.IsCanceled = true
+				return res, nil
+			}},
+		{Command: SetSide, Description: "Установить направление", Example: "set_side buy",
+			Params: []smp.Param{smp.StringParam("", "направление", nil).WithValues("buy", "sell")},
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				buy := v.String("") == "buy"
+				if buy != s.Buy && s.OrderId != "" {
+					return res, smp.GenerateError(500002504, s.OrderId)
+				}
+				s.Buy = buy
+				return res, nil
+			}},
+		{Command: SetLevel, Description: "Установить цену (видимая заявка переставляется)", Example: "set_level 345.67",
+			Params: []smp.Param{smp.FloatParam("", "цена", &s.LevelPrice).WithMin(0)},
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				s.NeedReplace = s.OrderId != ""
+				return res, nil
+			}},
+		{Command: SetVolume, Description: "Установить объём всей заявки", Example: "set_vol 1000",
+			Params: []smp.Param{smp.IntParam("", "кол-во лотов", &s.Quantity).WithMin(0)}},
+		{Command: SetVisible, Description: "Установить объём видимой части", Example: "set_visible 50",
+			Params: []smp.Param{smp.IntParam("", "кол-во лотов", &s.Visible).WithMin(1)}},
+		{Command: SetVisibleVariance, Description: "Установить случайное отклонение объёма видимой части (0 - без отклонения)",
+			Example: "set_visible_variance 10",
+			Params:  []smp.Param{smp.IntParam("", "кол-во лотов", &s.VisibleVariance).WithMin(0)}},
+		{Command: SetPriceOffset, Description: "Установить случайное отклонение цены в пассивную сторону (0 - без отклонения)",
+			Example: "set_price_offset 3",
+			Params:  []smp.Param{smp.IntParam("", "кол-во шагов цены", &s.PriceOffset).WithMin(0)}},
+	})
+}
+
+func (s *Iceberg) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	return s.commands().Do(s.Type(), cmd, params)
+}
+
+func (s *Iceberg) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
+
+func (s *Iceberg) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), `iceberg - стратегия, айсберг заявка
+	На рынке выставляется только видимая часть заявки по цене, после исполнения выставляется следующая часть
+	Объём и цена видимой части могут случайно отклоняться (в пределах шага цены)`)
+}
+
+// slice - lots and price of next visible slice
+func (s *Iceberg) slice(ii *smp.InstrumentInfo) (cnt int, price float64) {
+	r := rand.New(rand.NewSource(s.Seed + int64(s.Refills)))
+
+	cnt = s.Visible
+	if s.VisibleVariance > 0 {
+		cnt += r.Intn(2*s.VisibleVariance+1) - s.VisibleVariance
+	}
+	if cnt < 1 {
+		cnt = 1
+	}
+	if cnt > s.Quantity-s.Filled {
+		cnt = s.Quantity - s.Filled
+	}
+
+	price = s.LevelPrice
+	if s.PriceOffset > 0 && ii != nil && ii.MinStep > 0 {
+		offset := float64(r.Intn(s.PriceOffset+1)) * ii.MinStep
+		if s.Buy {
+			price -= offset
+		} else {
+			price += offset
+		}
+	}
+	return cnt, ii.RoundPrice(price)
+}
+
+// applyOrder - applies status of visible slice
+func (s *Iceberg) applyOrder(status smp.StatusOrder, prices []smp.LotPrices, meta *smp.MetaForStep) {
+	if status != smp.Complete && status != smp.Canceled {
+		return
+	}
+
+	cnt, price := lotPricesSum(prices)
+	meta.HasChanges = true
+	s.OrderId = ""
+	s.OrderCnt = 0
+	s.Filled += cnt
+	s.FilledPrice = smp.Round(s.FilledPrice+price, 6)
+}
+
+func (s *Iceberg) statusOrder(p smp.StepParams) (status smp.StatusOrder, prices []smp.LotPrices, err *mft.Error) {
+	if s.Buy {
+		return p.StatusBuyOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	}
+	return p.StatusSellOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+}
+
+// ActiveOrders - visible order
+func (s *Iceberg) ActiveOrders() []smp.ActiveOrder {
+	if s.OrderId == "" {
+		return nil
+	}
+	return []smp.ActiveOrder{{InstrumentId: s.InstrumentId, Ticker: s.Ticker, OrderId: s.OrderId, Buy: s.Buy}}
+}
+
+// ResetState - clears execution (order is executed again)
+func (s *Iceberg) ResetState() (err *mft.Error) {
+	s.IsCanceled = false
+	s.IsDone = false
+	s.NeedReplace = false
+	s.OrderCnt = 0
+	s.OrderPrice = 0
+	s.Refills = 0
+	s.Filled = 0
+	s.FilledPrice = 0
+	return nil
+}
+
+func (s *Iceberg) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
+	meta.Name = s.Name
+
+	if s.OrderId != "" {
+		status, prices, err := s.statusOrder(p)
+		if err != nil {
+			return meta, smp.GenerateErrorE(500002501, err)
+		}
+		s.applyOrder(status, prices, &meta)
+	}
+
+	if halt, err := s.Lifecycle.Step(p, s, &meta); halt || err != nil {
+		return meta, err
+	}
+
+	if s.OrderId != "" && (s.IsCanceled || s.NeedReplace) {
+		if s.Buy {
+			_, err = p.CancelBuyOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+		} else {
+			_, err = p.CancelSellOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+		}
+		if err != nil {
+			return meta, smp.GenerateErrorE(500002502, err)
+		}
+		status, prices, err := s.statusOrder(p)
+		if err != nil {
+			return meta, smp.GenerateErrorE(500002501, err)
+		}
+		s.applyOrder(status, prices, &meta)
+	}
+
+	if s.OrderId != "" {
+		return meta, nil
+	}
+	if s.NeedReplace {
+		meta.HasChanges = true
+		s.NeedReplace = false
+	}
+
+	if s.IsDone || !s.IsOnline {
+		return meta, nil
+	}
+
+	if s.IsCanceled || s.Filled >= s.Quantity {
+		meta.HasChanges = true
+		s.IsDone = true
+		meta.OpDescr = append(meta.OpDescr, fmt.Sprintf("Iceberg is finished: %v/%v lots", s.Filled, s.Quantity))
+		return meta, nil
+	}
+
+	if s.Visible <= 0 || s.LevelPrice <= 0 {
+		return meta, nil
+	}
+
+	ii, err := p.GetInstrumentInfo(s.InstrumentId, s.Ticker)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500002500, err)
+	}
+
+	cnt, price := s.slice(ii)
+
+	meta.HasChanges = true
+	if s.Buy {
+		s.OrderId, err = p.BuyByPrice(s.InstrumentId, s.Ticker, cnt, price, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	} else {
+		s.OrderId, err = p.SellByPrice(s.InstrumentId, s.Ticker, cnt, price, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	}
+	if err != nil {
+		s.OrderId = ""
+		return meta, smp.GenerateErrorE(500002503, err)
+	}
+	s.OrderCnt = cnt
+	s.OrderPrice = price
+	s.Refills++
+
+	return meta, nil
+}
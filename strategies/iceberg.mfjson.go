@@ -0,0 +1,19 @@
+// Code generated by mfjson for marshaling/unmarshaling. DO NOT EDIT.
+// https://github.com/myfantasy/json
+
+package strategies
+
+import (
+	mfj "github.com/myfantasy/json"
+)
+func (obj *Iceberg) UnmarshalJSONTypeName() string {
+	return "smp.strategies.iceberg"
+}
+
+func init() {
+	mfj.GlobalStructFactory.Add("smp.strategies.iceberg", func() mfj.JsonInterfaceMarshaller { return &Iceberg{} })
+	mfj.GlobalStructFactory.AddNil("smp.strategies.iceberg", func() mfj.JsonInterfaceMarshaller {
+		var out *Iceberg
+		return out
+	})
+}
@@ -0,0 +1,118 @@
+package strategies
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	smp "github.com/myfantasy/stock_market_primitives"
+	"github.com/myfantasy/stock_market_primitives/market"
+)
+
+func testIceberg() *Iceberg {
+	return &Iceberg{
+		Name:       "test",
+		Ticker:     "TTTT",
+		Buy:        true,
+		Quantity:   10,
+		LevelPrice: 102,
+		Visible:    4,
+		IsOnline:   true,
+	}
+}
+
+func TestIcebergRefill(t *testing.T) {
+	p := &market.StepParamsDummy{Candles: flatCandles(8)}
+	s := testIceberg()
+
+	cnts := []int{4, 4, 2}
+	for i, cnt := range cnts {
+		stepExecution(t, p, s)
+		if s.OrderCnt != cnt || s.Filled != 4*i || s.Refills != i+1 {
+			t.Fatalf("refill %v: wrong state %v", i, s.Json())
+		}
+	}
+
+	stepExecution(t, p, s)
+	if !s.IsDone || s.Filled != 10 || s.AveragePrice() != 102 || s.OrderId != "" {
+		t.Fatalf("wrong state after fill: %v", s.Json())
+	}
+	if len(p.Actions) != 3 {
+		t.Fatalf("3 actions expected, got %v", len(p.Actions))
+	}
+}
+
+func TestIcebergReplace(t *testing.T) {
+	p := &market.StepParamsDummy{Candles: flatCandles(8)}
+	s := testIceberg()
+	s.LevelPrice = 95
+
+	stepExecution(t, p, s)
+	stepExecution(t, p, s)
+	if s.Refills != 1 || s.Filled != 0 {
+		t.Fatalf("slice under market is filled: %v", s.Json())
+	}
+
+	_, ok, err := s.Command(SetLevel, map[string]string{"": "102"})
+	if !ok || err != nil || !s.NeedReplace {
+		t.Fatalf("set_level: %v", err)
+	}
+
+	stepExecution(t, p, s)
+	if s.NeedReplace || s.Refills != 2 || s.OrderPrice != 102 {
+		t.Fatalf("slice is not replaced: %v", s.Json())
+	}
+
+	stepExecution(t, p, s)
+	if s.Filled != 4 {
+		t.Fatalf("4 lots filled expected, got %v", s.Filled)
+	}
+}
+
+func TestIcebergRandomSlice(t *testing.T) {
+	ii := &smp.InstrumentInfo{MinStep: 0.5}
+	s := testIceberg()
+	s.Quantity = 1000
+	s.Seed = 42
+	s.Visible = 10
+	s.VisibleVariance = 3
+	s.PriceOffset = 4
+
+	for i := 0; i < 50; i++ {
+		s.Refills = i
+		cnt, price := s.slice(ii)
+		if cnt < 7 || cnt > 13 {
+			t.Fatalf("refill %v: visible %v is out of variance", i, cnt)
+		}
+		if price > 102 || price < 100 || math.Mod(price, 0.5) != 0 {
+			t.Fatalf("refill %v: price %v is out of offset or grid", i, price)
+		}
+	}
+
+	// slice is reproducible after restore from json
+	cnt, price := s.slice(ii)
+	s2 := &Iceberg{}
+	if er0 := json.Unmarshal([]byte(s.Json()), s2); er0 != nil {
+		t.Fatal(er0)
+	}
+	if cnt2, price2 := s2.slice(ii); cnt2 != cnt || price2 != price {
+		t.Fatalf("restored slice %v by %v differs from %v by %v", cnt2, price2, cnt, price)
+	}
+}
+
+func TestIcebergSetSide(t *testing.T) {
+	p := &market.StepParamsDummy{Candles: flatCandles(4)}
+	s := testIceberg()
+	s.LevelPrice = 95
+
+	stepExecution(t, p, s)
+	if s.OrderId == "" {
+		t.Fatal("order is not placed")
+	}
+	if _, _, err := s.Command(SetSide, map[string]string{"": "sell"}); err == nil || err.Code != 500002504 || !s.Buy {
+		t.Fatalf("set_side with active order must fail, got %v", err)
+	}
+	if _, _, err := s.Command(SetSide, map[string]string{"": "buy"}); err != nil {
+		t.Fatal(err)
+	}
+}
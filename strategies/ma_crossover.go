@@ -0,0 +1,273 @@
+package strategies
+
+import (
+	"encoding/json"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+//go:generate mfjson ma_crossover.go
+
+// Пересечение скользящих средних (MACrossover)
+
+var (
+	_ smp.Strategy = &MACrossover{}
+)
+
+type MAType string
+
+const (
+	SMA MAType = "sma"
+	EMA MAType = "ema"
+)
+
+//mfjson:interface smp.strategies.ma_crossover
+type MACrossover struct {
+	Name string `json:"name"`
+
+	InstrumentId string `json:"instrument_id"`
+	Ticker       string `json:"ticker"`
+
+	Volume     int    `json:"volume"`
+	FastPeriod int    `json:"fast_period"`
+	SlowPeriod int    `json:"slow_period"`
+	MAType     MAType `json:"ma_type"`
+	// Frame - candle frame in minutes
+	Frame int `json:"frame"`
+	// AllowShort - sell Volume short when fast MA crosses slow MA down (otherwise position is closed only)
+	AllowShort bool `json:"allow_short"`
+
+	IsOnline bool `json:"is_online"`
+
+	Fast float64 `json:"fast"`
+	Slow float64 `json:"slow"`
+
+	// InMarket - lots (negative - short)
+	InMarket      int     `json:"in_market"`
+	InMarketPrice float64 `json:"in_market_price"`
+	OrderId       string  `json:"order_id"`
+	OrderBuy      bool    `json:"order_buy"`
+
+	Profit    float64 `json:"profit"`
+	Iteration int     `json:"iteration"`
+
+	Lifecycle smp.Lifecycle `json:"lifecycle"`
+}
+
+func (s *MACrossover) Type() string {
+	return "ma_crossover"
+}
+
+func (s *MACrossover) String() string {
+	return "ma_crossover"
+}
+
+func (s *MACrossover) Status() smp.StartegyStatus {
+	return s.Lifecycle.Status(s.IsOnline)
+}
+func (s *MACrossover) Json() string {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return string(b)
+}
+
+// commands - commands of strategy
+func (s *MACrossover) commands() smp.CommandDefs {
+	return lifecycleCommands(showCommand("Отобразить", s.String), s.Lifecycle.Commands(s.Type(), s, &s.IsOnline), smp.CommandDefs{
+		{Command: SetVolume, Description: "Установить объём", Example: "set_vol 25",
+			Params: []smp.Param{smp.IntParam("", "объём (кол-во лотов)", &s.Volume).WithMin(0)}},
+		{Command: SetFast, Description: "Установить период быстрой средней", Example: "set_fast 10",
+			Params: []smp.Param{smp.IntParam("", "кол-во свечей", &s.FastPeriod).WithMin(1)}},
+		{Command: SetSlow, Description: "Установить период медленной средней", Example: "set_slow 30",
+			Params: []smp.Param{smp.IntParam("", "кол-во свечей", &s.SlowPeriod).WithMin(1)}},
+		{Command: SetMAType, Description: "Установить тип средней", Example: "set_ma_type ema",
+			Params: []smp.Param{smp.StringParam("", "тип средней", &s.MAType).WithValues(string(SMA), string(EMA))}},
+		{Command: SetFrame, Description: "Установить размер свечи", Example: "set_frame 5",
+			Params: []smp.Param{smp.IntParam("", "минуты", &s.Frame).WithMin(1)}},
+		{Command: SetAllowShort, Description: "Продавать в шорт при пересечении вниз", Example: "set_allow_short true",
+			Params: []smp.Param{smp.BoolParam("", "true/false", &s.AllowShort)}},
+	})
+}
+
+func (s *MACrossover) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	return s.commands().Do(s.Type(), cmd, params)
+}
+
+func (s *MACrossover) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
+
+func (s *MACrossover) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), `ma_crossover - стратегия, пересечение скользящих средних
+	При пересечении быстрой средней медленной снизу вверх покупка, сверху вниз продажа`)
+}
+
+// ma - moving average of candles by MAType
+func (s *MACrossover) ma(cs smp.Candles, period int) float64 {
+	if s.MAType == EMA {
+		return cs.EMA(period)
+	}
+	return cs.SMA(period)
+}
+
+// applyOrder - applies status of market order
+func (s *MACrossover) applyOrder(status smp.StatusOrder, prices []smp.LotPrices, meta *smp.MetaForStep) {
+	if status != smp.Complete && status != smp.Canceled {
+		return
+	}
+
+	cnt, price := lotPricesSum(prices)
+	meta.HasChanges = true
+	s.OrderId = ""
+	if !s.OrderBuy {
+		cnt, price = -cnt, -price
+	}
+	s.InMarket += cnt
+	s.InMarketPrice = smp.Round(s.InMarketPrice+price, 6)
+	if s.InMarket == 0 {
+		s.Iteration++
+		s.Profit = smp.Round(s.Profit-s.InMarketPrice, 6)
+		s.InMarketPrice = 0
+	}
+}
+
+// order - market order to change position by cnt lots
+func (s *MACrossover) order(p smp.StepParams, cnt int, meta *smp.MetaForStep) (err *mft.Error) {
+	if cnt == 0 {
+		return nil
+	}
+	meta.HasChanges = true
+	s.OrderBuy = cnt > 0
+	if s.OrderBuy {
+		s.OrderId, err = p.BuyByMarket(s.InstrumentId, s.Ticker, cnt, &smp.MetaForOperations{NameOfStrategy: s.Name, IsStopLoss: meta.IsStopLoss})
+		if err != nil {
+			s.OrderId = ""
+			return smp.GenerateErrorE(500001803, err)
+		}
+		return nil
+	}
+	s.OrderId, err = p.SellByMarket(s.InstrumentId, s.Ticker, -cnt, &smp.MetaForOperations{NameOfStrategy: s.Name, IsStopLoss: meta.IsStopLoss})
+	if err != nil {
+		s.OrderId = ""
+		return smp.GenerateErrorE(500001804, err)
+	}
+	return nil
+}
+
+// checkOrder - status of active order
+func (s *MACrossover) checkOrder(p smp.StepParams, meta *smp.MetaForStep) (err *mft.Error) {
+	if s.OrderId == "" {
+		return nil
+	}
+	var status smp.StatusOrder
+	var prices []smp.LotPrices
+	if s.OrderBuy {
+		status, prices, err = p.StatusBuyOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	} else {
+		status, prices, err = p.StatusSellOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	}
+	if err != nil {
+		return smp.GenerateErrorE(500001802, err)
+	}
+	s.applyOrder(status, prices, meta)
+	return nil
+}
+
+// ActiveOrders - market order of strategy
+func (s *MACrossover) ActiveOrders() []smp.ActiveOrder {
+	if s.OrderId == "" {
+		return nil
+	}
+	return []smp.ActiveOrder{{InstrumentId: s.InstrumentId, Ticker: s.Ticker, OrderId: s.OrderId, Buy: s.OrderBuy}}
+}
+
+// FlattenPosition - closes long or short position by market
+func (s *MACrossover) FlattenPosition(p smp.StepParams, meta *smp.MetaForStep) (done bool, err *mft.Error) {
+	if s.InMarket == 0 {
+		return true, nil
+	}
+	meta.OpDescr = append(meta.OpDescr, "Flatten")
+	return false, s.order(p, -s.InMarket, meta)
+}
+
+// ResetState - clears averages, profit and iterations
+func (s *MACrossover) ResetState() (err *mft.Error) {
+	if s.InMarket != 0 {
+		return smp.GenerateError(500003551, s.Type(), s.InMarket)
+	}
+	s.Fast = 0
+	s.Slow = 0
+	s.InMarketPrice = 0
+	s.Profit = 0
+	s.Iteration = 0
+	return nil
+}
+
+func (s *MACrossover) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
+	meta.Name = s.Name
+
+	err = s.checkOrder(p, &meta)
+	if err != nil {
+		return meta, err
+	}
+
+	if halt, err := s.Lifecycle.Step(p, s, &meta); halt || err != nil {
+		return meta, err
+	}
+
+	if !s.IsOnline || s.OrderId != "" || s.Volume <= 0 ||
+		s.FastPeriod <= 0 || s.SlowPeriod <= s.FastPeriod {
+		return meta, nil
+	}
+
+	ob, err := p.GetOrderBook(s.InstrumentId, s.Ticker)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500001800, err)
+	}
+	if ob.TradeStatus != smp.NormalTrading {
+		return meta, nil
+	}
+
+	// EMA needs more history to be stable
+	count := s.SlowPeriod + 1
+	if s.MAType == EMA {
+		count = s.SlowPeriod * 3
+	}
+	cs, err := historyCandles(p, s.InstrumentId, s.Ticker, ob.Time, s.Frame, count)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500001801, err)
+	}
+	if cs.Len() < s.SlowPeriod+1 {
+		return meta, nil
+	}
+
+	prevFast, prevSlow := s.ma(cs[:cs.Len()-1], s.FastPeriod), s.ma(cs[:cs.Len()-1], s.SlowPeriod)
+	fast, slow := s.ma(cs, s.FastPeriod), s.ma(cs, s.SlowPeriod)
+	if smp.Round(fast, 6) != s.Fast || smp.Round(slow, 6) != s.Slow {
+		meta.HasChanges = true
+		s.Fast, s.Slow = smp.Round(fast, 6), smp.Round(slow, 6)
+	}
+
+	target := s.InMarket
+	switch {
+	case prevFast <= prevSlow && fast > slow:
+		meta.OpDescr = append(meta.OpDescr, "Fast MA crosses slow MA up")
+		target = s.Volume
+	case prevFast >= prevSlow && fast < slow:
+		meta.OpDescr = append(meta.OpDescr, "Fast MA crosses slow MA down")
+		target = 0
+		if s.AllowShort {
+			target = -s.Volume
+		}
+	}
+
+	err = s.order(p, target-s.InMarket, &meta)
+	if err != nil {
+		return meta, err
+	}
+	return meta, nil
+}
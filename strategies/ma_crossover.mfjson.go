@@ -0,0 +1,19 @@
+// Code generated by mfjson for marshaling/unmarshaling. DO NOT EDIT.
+// https://github.com/myfantasy/json
+
+package strategies
+
+import (
+	mfj "github.com/myfantasy/json"
+)
+func (obj *MACrossover) UnmarshalJSONTypeName() string {
+	return "smp.strategies.ma_crossover"
+}
+
+func init() {
+	mfj.GlobalStructFactory.Add("smp.strategies.ma_crossover", func() mfj.JsonInterfaceMarshaller { return &MACrossover{} })
+	mfj.GlobalStructFactory.AddNil("smp.strategies.ma_crossover", func() mfj.JsonInterfaceMarshaller {
+		var out *MACrossover
+		return out
+	})
+}
@@ -0,0 +1,154 @@
+package strategies
+
+import (
+	"testing"
+	"time"
+
+	smp "github.com/myfantasy/stock_market_primitives"
+	"github.com/myfantasy/stock_market_primitives/market"
+)
+
+func maCandles() smp.Candles {
+	closes := []float64{10, 9, 8, 7, 6, 7, 8, 9, 10, 11, 12, 11, 9, 7, 5, 4, 4, 4}
+	ohlc := make([][4]float64, len(closes))
+	for i, c := range closes {
+		ohlc[i] = [4]float64{c, c + 0.5, c - 0.5, c}
+	}
+	return testCandles(ohlc...)
+}
+
+// stepMarket - simulator of market
+type stepMarket interface {
+	smp.StepParams
+	DoStep() bool
+}
+
+func TestMACrossover(t *testing.T) {
+	markets := map[string]func() stepMarket{
+		"dummy":   func() stepMarket { return &market.StepParamsDummy{Candles: maCandles()} },
+		"virtual": func() stepMarket { return &market.VirtualMarket{Candles: maCandles()} },
+	}
+
+	for name, newMarket := range markets {
+		for _, maType := range []MAType{SMA, EMA} {
+			p := newMarket()
+			s := &MACrossover{
+				Ticker:     "TTTT",
+				Volume:     3,
+				FastPeriod: 2,
+				SlowPeriod: 4,
+				MAType:     maType,
+				IsOnline:   true,
+			}
+
+			bought := false
+			for p.DoStep() {
+				_, err := s.Step(p)
+				if err != nil {
+					t.Fatalf("%v %v: %v", name, maType, err)
+				}
+				if s.InMarket == 3 {
+					bought = true
+				}
+				if s.InMarket < 0 {
+					t.Fatalf("%v %v: short is not allowed: %v", name, maType, s.Json())
+				}
+			}
+			if !bought || s.InMarket != 0 || s.Iteration != 1 {
+				t.Fatalf("%v %v: wrong state: %v", name, maType, s.Json())
+			}
+		}
+	}
+}
+
+func TestMACrossoverShort(t *testing.T) {
+	p := &market.StepParamsDummy{Candles: maCandles()}
+	s := &MACrossover{
+		Ticker:     "TTTT",
+		Volume:     3,
+		FastPeriod: 2,
+		SlowPeriod: 4,
+		AllowShort: true,
+		IsOnline:   true,
+	}
+
+	for p.DoStep() {
+		_, err := s.Step(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.InMarket != -3 {
+		t.Fatalf("short is not opened: %v", s.Json())
+	}
+}
+
+// weekdayCandles - candles are moved to 10:00 of working days (no candles on weekends)
+func weekdayCandles(cs smp.Candles) smp.Candles {
+	day := time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC)
+	for i := range cs {
+		for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			day = day.AddDate(0, 0, 1)
+		}
+		cs[i].Start, cs[i].Date = day, day.Add(time.Minute)
+		day = day.AddDate(0, 0, 1)
+	}
+	return cs
+}
+
+func TestMACrossoverDailyFrame(t *testing.T) {
+	p := &market.StepParamsDummy{Candles: weekdayCandles(maCandles())}
+	s := &MACrossover{
+		Ticker:     "TTTT",
+		Volume:     3,
+		FastPeriod: 2,
+		SlowPeriod: 5,
+		Frame:      1440,
+		IsOnline:   true,
+	}
+
+	// week has 5 candles: history of 6 candles is requested over weekend
+	bought := false
+	for p.DoStep() {
+		_, err := s.Step(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.InMarket == 3 {
+			bought = true
+		}
+	}
+	if !bought || s.InMarket != 0 || s.Iteration != 1 {
+		t.Fatalf("wrong state: %v", s.Json())
+	}
+}
+
+func TestMACrossoverNoChanges(t *testing.T) {
+	ohlc := make([][4]float64, 12)
+	for i := range ohlc {
+		ohlc[i] = [4]float64{10.1234567, 10.1234567, 10.1234567, 10.1234567}
+	}
+	p := &market.StepParamsDummy{Candles: testCandles(ohlc...)}
+	s := &MACrossover{
+		Ticker:     "TTTT",
+		Volume:     3,
+		FastPeriod: 3,
+		SlowPeriod: 7,
+		MAType:     EMA,
+		IsOnline:   true,
+	}
+
+	changes := 0
+	for p.DoStep() {
+		meta, err := s.Step(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.HasChanges {
+			changes++
+		}
+	}
+	if s.Fast != 10.123457 || s.Slow != 10.123457 || changes != 1 {
+		t.Fatalf("flat MA should be changed once (current %v): %v", changes, s.Json())
+	}
+}
@@ -0,0 +1,344 @@
+package strategies
+
+import (
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+// Тексты стратегий пакета на английском языке для smp.GlobalMessageCatalog (тексты на русском - в коде стратегий)
+
+func init() {
+	for tp, m := range messagesEn {
+		smp.GlobalMessageCatalog.AddStrategy(smp.LocaleEn, tp, m)
+	}
+}
+
+// positional - description of positional param
+func positional(text string) map[string]string {
+	return map[string]string{smp.PositionalParam: text}
+}
+
+// childrenLifecycleMessagesEn - texts of childrenLifecycleCommands
+var childrenLifecycleMessagesEn = map[smp.Command]smp.CommandMessages{
+	smp.StartCommand:  {Description: "Start all inner strategies"},
+	smp.StopCommand:   {Description: "Stop all inner strategies (orders are canceled by default)"},
+	smp.PauseCommand:  {Description: "Pause all inner strategies"},
+	smp.ResumeCommand: {Description: "Resume all inner strategies"},
+	smp.ResetCommand:  {Description: "Reset trading state of all inner strategies"},
+}
+
+// withChildrenLifecycle - commands with texts of childrenLifecycleCommands
+func withChildrenLifecycle(commands map[smp.Command]smp.CommandMessages) map[smp.Command]smp.CommandMessages {
+	for cmd, cm := range childrenLifecycleMessagesEn {
+		commands[cmd] = cm
+	}
+	return commands
+}
+
+// messagesEn - english texts by types of strategies ("" - texts of smp.Lifecycle commands and show of all strategies)
+var messagesEn = map[string]smp.StrategyMessages{
+	"": {
+		Commands: map[smp.Command]smp.CommandMessages{
+			smp.ShowCommand:  {Description: "Show"},
+			smp.StartCommand: {Description: "Start"},
+			smp.StopCommand: {Description: "Stop (orders are canceled by default)",
+				Params: positional("keep - keep orders, cancel - cancel orders, flatten - cancel orders and close position by market")},
+			smp.PauseCommand:  {Description: "Pause (orders are canceled, position is kept)"},
+			smp.ResumeCommand: {Description: "Resume after pause"},
+			smp.ResetCommand:  {Description: "Reset trading state of stopped strategy"},
+		},
+	},
+
+	"bracket": {
+		Description: `bracket - strategy, entry with take profit and stop loss (OCO)
+	Buy by price or by market, then sell by take profit price and stop by market are placed together
+	If market supports OCO, orders are placed as OCO pair, execution of one order cancels the other one by market,
+	the rest is placed as new pair
+	Otherwise execution of one order cancels the other one, partial execution reduces volume of the other one,
+	lots sold by both orders over position are bought back by market (step returns error)
+	Without stop orders support stop loss is sold by market when price falls`,
+		Commands: map[smp.Command]smp.CommandMessages{
+			smp.ShowCommand:  {Description: "Show (position, exit, profit)"},
+			smp.StartCommand: {Description: "Start (after close - new entry)"},
+			SetVolume:        {Description: "Set entry volume", Params: positional("lots")},
+			SetEntryPrice:    {Description: "Set entry price (0 - by market)", Params: positional("price")},
+			SetTakeProfit:    {Description: "Set take profit price", Params: positional("price")},
+			SetStopLoss:      {Description: "Set stop loss price", Params: positional("price")},
+		},
+	},
+
+	"breakout": {
+		Description: `breakout - strategy, channel breakout (Donchian)
+	Buy when price is above high of channel, sell by ATR stop or when price is below low of exit channel`,
+		Commands: map[smp.Command]smp.CommandMessages{
+			SetVolume:        {Description: "Set volume", Params: positional("volume (lots)")},
+			SetPeriod:        {Description: "Set candles of entry channel", Params: positional("candles")},
+			SetExitPeriod:    {Description: "Set candles of exit channel (0 - without exit channel)", Params: positional("candles")},
+			SetAtrPeriod:     {Description: "Set candles of ATR", Params: positional("candles")},
+			SetAtrMultiplier: {Description: "Set ATR multiplier of stop (0 - without stop)", Params: positional("multiplier")},
+			SetFrame:         {Description: "Set candle size", Params: positional("minutes")},
+		},
+	},
+
+	"composite": {
+		Description: `composite - group of any strategies
+	Children are stepped one by one or in parallel, shared StopLostBank is passed to strategies that use it
+	Commands are sent to all children (that support them) or to children selected by child=name or label=key:value`,
+		Params: map[string]string{
+			CompositeChildParam: "name",
+			CompositeLabelParam: "key:value",
+		},
+		Commands: map[smp.Command]smp.CommandMessages{
+			smp.ShowCommand:  {Description: "Show (group and children)"},
+			smp.StartCommand: {Description: "Start (without child and label - group and all children)"},
+			smp.StopCommand: {Description: "Stop (without child and label - group and all children, " +
+				"orders are canceled by default)"},
+			SetMode: {Description: "Set order of children steps (sequential - one by one, parallel - at the same time)",
+				Params: positional("order")},
+			RemoveChild: {Description: "Remove child"},
+		},
+	},
+
+	"dca": {
+		Description: `dca - strategy, dollar cost averaging
+	Instrument is bought for the amount by schedule (by market)`,
+		Commands: map[smp.Command]smp.CommandMessages{
+			smp.ShowCommand: {Description: "Show (lots and average price)"},
+			SetAmount:       {Description: "Set amount of one buy", Params: positional("amount")},
+			SetSchedule: {Description: "Set schedule (interval - every interval, daily - every day, " +
+				"weekly - every week, monthly - every month)", Params: positional("schedule")},
+			SetInterval:      {Description: "Set interval between buys (interval)", Params: positional("minutes")},
+			SetTime:          {Description: "Set time of buy (daily, weekly, monthly)", Params: positional("hh:mm")},
+			SetWeekday:       {Description: "Set weekday of buy (weekly)", Params: positional("0 - sunday ... 6 - saturday")},
+			SetMonthDay:      {Description: "Set day of month of buy (monthly)", Params: positional("day of month")},
+			SetCeiling:       {Description: "Set max price of buy (0 - without limit)", Params: positional("price")},
+			SetDipPercent:    {Description: "Set price fall from average price to increase buy", Params: positional("percent")},
+			SetDipMultiplier: {Description: "Set multiplier of buy amount when price falls", Params: positional("multiplier")},
+			SetTarget:        {Description: "Set target position (0 - without limit)", Params: positional("lots")},
+		},
+	},
+
+	"dividend_capture": {
+		Description: `dividend_capture - strategy, dividend capture
+	Buy by market before the last day to buy for dividend, hold over record date
+	Sell by market when part of dividend gap is closed or when holding period is over
+	Dividend is included in income of strategy`,
+		Commands: map[smp.Command]smp.CommandMessages{
+			smp.ShowCommand: {Description: "Show (position, profit and dividends)"},
+			SetVolume:       {Description: "Set buy volume", Params: positional("lots")},
+			AddDividend: {Description: "Add dividend to calendar", Params: map[string]string{
+				"a": "amount per share",
+				"d": "last day to buy (YYYY-MM-DD)",
+			}},
+			SetBuyDays:     {Description: "Set days before the last day to buy when buy is allowed", Params: positional("days")},
+			SetMinYield:    {Description: "Set min dividend yield", Params: positional("percent of price")},
+			SetRecovery:    {Description: "Set part of dividend gap closed before sell", Params: positional("part of gap")},
+			SetMaxHoldDays: {Description: "Set max holding period after record date (0 - without limit)", Params: positional("days")},
+		},
+	},
+
+	"grid": {
+		Description: `grid - strategy, grid
+	Every level buys by price of level and sells by price of the next level`,
+		Commands: withChildrenLifecycle(map[smp.Command]smp.CommandMessages{
+			SetLevel:     {Description: "Set center of grid", Params: positional("level")},
+			SetSpacing:   {Description: "Set grid spacing (arithmetic - price step, geometric - step in percent)", Params: positional("spacing")},
+			SetPriceStep: {Description: "Set grid step", Params: positional("price step or percent")},
+			SetVolume:    {Description: "Set volume of level", Params: positional("volume (lots)")},
+			SetVolumeMode: {Description: "Set change of volume below center of grid " +
+				"(flat - the same, linear - +volume step, martingale - *multiplier)", Params: positional("change of volume")},
+			SetVolumeStep:       {Description: "Set volume step (linear)", Params: positional("lots")},
+			SetVolumeMultiplier: {Description: "Set volume multiplier (martingale)", Params: positional("multiplier")},
+			SetVolumeMax:        {Description: "Set max volume of level (0 - without limit)", Params: positional("lots")},
+			SetLowerBound:       {Description: "Set lower bound (grid places no orders below it)", Params: positional("price")},
+			SetUpperBound:       {Description: "Set upper bound (grid places no orders above it)", Params: positional("price")},
+			SetRecenter:         {Description: "Rebuild grid around current price when price leaves levels of grid", Params: positional("true/false")},
+			Render: {Description: "Generate levels of grid", Params: map[string]string{
+				"f": "from steps",
+				"t": "to steps",
+			}},
+		}),
+	},
+
+	"iceberg": {
+		Description: `iceberg - strategy, iceberg order
+	Only visible part of order is placed by price, the next part is placed after execution
+	Volume and price of visible part can randomly vary (within price step)`,
+		Commands: map[smp.Command]smp.CommandMessages{
+			smp.ShowCommand:    {Description: "Show (executed, average price, visible part)"},
+			Cancel:             {Description: "Cancel (visible order is canceled)"},
+			SetSide:            {Description: "Set side", Params: positional("side")},
+			SetLevel:           {Description: "Set price (visible order is replaced)", Params: positional("price")},
+			SetVolume:          {Description: "Set volume of whole order", Params: positional("lots")},
+			SetVisible:         {Description: "Set volume of visible part", Params: positional("lots")},
+			SetVisibleVariance: {Description: "Set random variance of visible volume (0 - without variance)", Params: positional("lots")},
+			SetPriceOffset:     {Description: "Set random price offset to passive side (0 - without offset)", Params: positional("price steps")},
+		},
+	},
+
+	"ma_crossover": {
+		Description: `ma_crossover - strategy, moving averages crossover
+	Buy when fast average crosses slow one upward, sell when it crosses downward`,
+		Commands: map[smp.Command]smp.CommandMessages{
+			SetVolume:     {Description: "Set volume", Params: positional("volume (lots)")},
+			SetFast:       {Description: "Set period of fast average", Params: positional("candles")},
+			SetSlow:       {Description: "Set period of slow average", Params: positional("candles")},
+			SetMAType:     {Description: "Set type of average", Params: positional("type of average")},
+			SetFrame:      {Description: "Set candle size", Params: positional("minutes")},
+			SetAllowShort: {Description: "Sell short on downward crossover", Params: positional("true/false")},
+		},
+	},
+
+	"pairs": {
+		Description: `pairs - strategy, pairs trading
+	Spread A - HedgeRatio * B is opened on z-score deviation and closed on return to mean`,
+		Commands: map[smp.Command]smp.CommandMessages{
+			SetVolume:        {Description: "Set volume of the first instrument", Params: positional("volume (lots)")},
+			SetEntryZ:        {Description: "Set z-score of spread opening", Params: positional("z-score")},
+			SetExitZ:         {Description: "Set z-score of spread closing", Params: positional("z-score")},
+			SetStopZ:         {Description: "Set z-score of stop (0 - without stop)", Params: positional("z-score")},
+			SetLookback:      {Description: "Set candles of calculation", Params: positional("candles")},
+			SetFrame:         {Description: "Set candle size", Params: positional("minutes")},
+			SetMaxLegRetries: {Description: "Set retries of not executed leg before spread is closed", Params: positional("retries")},
+			SetLegTimeout:    {Description: "Set steps of waiting leg order before it is canceled (0 - without cancel)", Params: positional("steps")},
+		},
+	},
+
+	"rebalance": {
+		Description: `rebalance - strategy, rebalance of portfolio to target weights
+	Once a month or when weight drifts over threshold positions are brought to target weights (by market, rounded to lots)
+	Sells first, then buys for released money`,
+		Commands: map[smp.Command]smp.CommandMessages{
+			smp.ShowCommand: {Description: "Show (value, drift of weights)"},
+			DryRun:          {Description: "Show orders of rebalance planned on the last step (without placing)"},
+			RebalanceNow:    {Description: "Rebalance on the next step"},
+			SetWeight: {Description: "Set target weight of instrument (0 - sell and remove instrument)", Params: map[string]string{
+				"i": "instrument_id",
+				"t": "ticker",
+				"w": "weight (sum of weights is not greater than 1)",
+			}},
+			SetDrift:    {Description: "Set weight drift of rebalance (0 - by schedule only)", Params: positional("percentage points")},
+			SetMonthDay: {Description: "Set day of month of rebalance (0 - without schedule)", Params: positional("day of month")},
+			SetTime:     {Description: "Set time of rebalance", Params: positional("hh:mm")},
+		},
+	},
+
+	"take_profit_buy": {
+		Description: `take_profit_buy - strategy, take profit by buy
+	When price reaches the level (buy price on market) buy order by the price is placed
+	Nothing happens when price is above the level
+	Canceled (expired) order is placed again for the rest of volume
+	With rearm strategy starts again after whole volume is executed`,
+		Commands: takeProfitMessagesEn,
+	},
+
+	"take_profit_sell": {
+		Description: `take_profit_sell - strategy, take profit by sell
+	When price reaches the level (sell price on market) sell order by the price is placed
+	Nothing happens when price is below the level
+	Canceled (expired) order is placed again for the rest of volume
+	With rearm strategy starts again after whole volume is executed`,
+		Commands: takeProfitMessagesEn,
+	},
+
+	"trailing_stop": {
+		Description: `trailing_stop - strategy, trailing stop
+	Sell stop is placed below max price and follows it
+	Position is sold when price falls by trail`,
+		Commands: map[smp.Command]smp.CommandMessages{
+			SetVolume:           {Description: "Set protected volume", Params: positional("volume (lots)")},
+			SetTrail:            {Description: "Set trail of stop from max price", Params: positional("price, percent or ATR multiplier")},
+			SetTrailMode:        {Description: "Set type of trail (amount - price, percent - percent, atr - ATR multiplier)", Params: positional("type of trail")},
+			SetAtrPeriod:        {Description: "Set candles of ATR", Params: positional("candles")},
+			SetAtrFrame:         {Description: "Set candle size of ATR", Params: positional("minutes")},
+			SetActivationProfit: {Description: "Set profit after which stop is activated", Params: positional("price")},
+			SetEntryPrice:       {Description: "Set entry price", Params: positional("price")},
+			TakePosition:        {Description: "Take volume and entry price from position of account"},
+		},
+	},
+
+	"twap": {
+		Description: `twap - execution strategy, equal parts by time
+	Parent order is split into equal child orders (by price or by market) within execution window
+	Benchmark - average close price of candles within window`,
+		Commands: parentOrderMessagesEn(),
+	},
+
+	"vwap": {
+		Description: `vwap - execution strategy by volume profile
+	Parent order is split into child orders in proportion to traded volume in the same parts of window of previous days
+	Without volume history parts are equal (as twap)
+	Benchmark - volume weighted close price of candles within window`,
+		Commands: func() map[smp.Command]smp.CommandMessages {
+			commands := parentOrderMessagesEn()
+			commands[SetProfileDays] = smp.CommandMessages{Description: "Set days of history of volume profile", Params: positional("days")}
+			return commands
+		}(),
+	},
+
+	"winged_swing": {
+		Description: `winged_swing - strategy, swing`,
+		Commands: map[smp.Command]smp.CommandMessages{
+			SetLevelDown: {Description: "Set buy level", Params: positional("level")},
+			SetLevelUp:   {Description: "Set sell level", Params: positional("level")},
+			SetVolume:    {Description: "Set volume", Params: positional("volume")},
+			SetLevelPriceOnTheMarketUp: {Description: "Set upper level of strategy work " +
+				"(buy happens from this level)", Params: positional("level")},
+			SetLevelPriceOnTheMarketDown: {Description: "Set lower level of strategy work " +
+				"(buy happens from this level)", Params: positional("level")},
+			SetLevelPriceOnTheMarketDownByMarket: {Description: "Set lower level of strategy work by market price " +
+				"(buy by market happens up to this level)", Params: positional("level")},
+		},
+	},
+
+	"winged_swing_group": {
+		Description: "`winged_swing_group` - strategy, group of swings",
+		Commands: withChildrenLifecycle(map[smp.Command]smp.CommandMessages{
+			SetLevel: {Description: "Set level of strategy work (strategies are distributed from this level)",
+				Params: positional("level")},
+			SetPriceUp:                      {Description: "Set sell step", Params: positional("price step")},
+			SetPriceDown:                    {Description: "Set buy step", Params: positional("price step")},
+			SetPriceOnTheMarketUp:           {Description: "Set step of market entry", Params: positional("price step")},
+			SetPriceOnTheMarketDown:         {Description: "Set step of market entry", Params: positional("price step")},
+			SetPriceOnTheMarketDownByMarket: {Description: "Set step of market entry", Params: positional("price step")},
+			SetPriceBetween:                 {Description: "Set step between strategies", Params: positional("level")},
+			SetVolume:                       {Description: "Set volume", Params: positional("volume (lots)")},
+			Render: {Description: "Generate inner strategies", Params: map[string]string{
+				"f": "from steps",
+				"t": "to steps",
+			}},
+			SetInMarket: {Description: "Set shares bought on market to max volume", Params: map[string]string{
+				"f": "from steps",
+				"t": "to steps",
+			}},
+			SetOutOfMarket: {Description: "Set shares bought on market to 0", Params: map[string]string{
+				"f": "from steps",
+				"t": "to steps",
+			}},
+		}),
+	},
+}
+
+// takeProfitMessagesEn - texts of take_profit_buy and take_profit_sell commands
+var takeProfitMessagesEn = map[smp.Command]smp.CommandMessages{
+	SetLevel:        {Description: "Set level", Params: positional("level")},
+	SetVolume:       {Description: "Set volume", Params: positional("volume")},
+	SetStayInMarket: {Description: "Stay in market [place order without waiting for price to come near]", Params: positional("true/false")},
+	SetRearm:        {Description: "Start again after whole volume is executed", Params: positional("true/false")},
+}
+
+// parentOrderMessagesEn - texts of ParentOrder commands
+func parentOrderMessagesEn() map[smp.Command]smp.CommandMessages {
+	return map[smp.Command]smp.CommandMessages{
+		smp.ShowCommand: {Description: "Show (executed, average price, benchmark, market share)"},
+		Cancel:          {Description: "Cancel execution (child order is canceled)"},
+		SetSide:         {Description: "Set side", Params: positional("side")},
+		SetVolume:       {Description: "Set volume of parent order", Params: positional("lots")},
+		SetWindow: {Description: "Set execution window", Params: map[string]string{
+			"f": "start (RFC3339)",
+			"t": "end (RFC3339)",
+		}},
+		SetLimit:    {Description: "Set limit price (0 - without limit)", Params: positional("price")},
+		SetSlices:   {Description: "Set number of parts", Params: positional("parts")},
+		SetByMarket: {Description: "Child orders by market", Params: positional("true or false")},
+	}
+}
@@ -0,0 +1,137 @@
+package strategies
+
+import (
+	"encoding/json"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+// Миграции сохранённых стратегий между версиями схемы (smp.GlobalStrategyRegistry)
+
+// migrateWingedSwing0 - version 0 (before state machine): sets `state` by orders and `is_bought`
+func migrateWingedSwing0(data map[string]json.RawMessage) (err *mft.Error) {
+	if _, ok := data["state"]; ok {
+		return nil
+	}
+
+	var old struct {
+		IsBought    bool   `json:"is_bought"`
+		OrderIdSell string `json:"order_id_sell"`
+		OrderIdBuy  string `json:"order_id_buy"`
+	}
+	b, er0 := json.Marshal(data)
+	if er0 == nil {
+		er0 = json.Unmarshal(b, &old)
+	}
+	if er0 != nil {
+		return smp.GenerateErrorE(500003500, er0, "winged_swing")
+	}
+
+	state := SwingWaiting
+	switch {
+	case old.OrderIdSell != "":
+		state = SwingSelling
+	case old.OrderIdBuy != "":
+		state = SwingBuying
+	case old.IsBought:
+		state = SwingHolding
+	}
+	data["state"], _ = json.Marshal(state)
+
+	return nil
+}
+
+// migrateWingedSwing1 - version 1: `need_send_cancel` is replaced by lifecycle stopping with cancel of orders
+func migrateWingedSwing1(data map[string]json.RawMessage) (err *mft.Error) {
+	raw, ok := data["need_send_cancel"]
+	if !ok {
+		return nil
+	}
+	delete(data, "need_send_cancel")
+
+	var needSendCancel bool
+	er0 := json.Unmarshal(raw, &needSendCancel)
+	if er0 != nil {
+		return smp.GenerateErrorE(500003500, er0, "winged_swing")
+	}
+	if needSendCancel {
+		data["lifecycle"], _ = json.Marshal(smp.Lifecycle{State: smp.LifecycleStopping, StopMode: smp.StopCancelOrders})
+		data["is_online"], _ = json.Marshal(false)
+	}
+
+	return nil
+}
+
+// migratePaused0 - version 0 (dca, iceberg, twap, vwap): `is_paused` is replaced by lifecycle paused
+func migratePaused0(tp string) smp.StrategyMigration {
+	return func(data map[string]json.RawMessage) (err *mft.Error) {
+		if _, ok := data["is_paused"]; !ok {
+			return nil
+		}
+
+		var old struct {
+			IsOnline bool `json:"is_online"`
+			IsPaused bool `json:"is_paused"`
+		}
+		b, er0 := json.Marshal(data)
+		if er0 == nil {
+			er0 = json.Unmarshal(b, &old)
+		}
+		if er0 != nil {
+			return smp.GenerateErrorE(500003500, er0, tp)
+		}
+		delete(data, "is_paused")
+		if old.IsOnline && old.IsPaused {
+			data["lifecycle"], _ = json.Marshal(smp.Lifecycle{State: smp.LifecyclePaused})
+		}
+
+		return nil
+	}
+}
+
+// migrateWingedSwingGroup0 - version 0: swings are migrated as winged_swing of version 0
+func migrateWingedSwingGroup0(data map[string]json.RawMessage) (err *mft.Error) {
+	return migrateSwings(data, "swings", "winged_swing_group", migrateWingedSwing0)
+}
+
+// migrateWingedSwingGroup1 - version 1: swings are migrated as winged_swing of version 1
+func migrateWingedSwingGroup1(data map[string]json.RawMessage) (err *mft.Error) {
+	return migrateSwings(data, "swings", "winged_swing_group", migrateWingedSwing1)
+}
+
+// migrateGrid0 - version 0: levels are migrated as winged_swing of versions 0 and 1
+func migrateGrid0(data map[string]json.RawMessage) (err *mft.Error) {
+	return migrateSwings(data, "levels", "grid", migrateWingedSwing0, migrateWingedSwing1)
+}
+
+// migrateSwings - migrates swings of field key of container tp by migrations of winged_swing
+func migrateSwings(data map[string]json.RawMessage, key string, tp string, migrations ...smp.StrategyMigration) (err *mft.Error) {
+	raw, ok := data[key]
+	if !ok {
+		return nil
+	}
+
+	var swings []map[string]json.RawMessage
+	er0 := json.Unmarshal(raw, &swings)
+	if er0 != nil {
+		return smp.GenerateErrorE(500003500, er0, tp)
+	}
+	for i := range swings {
+		if swings[i] == nil {
+			continue
+		}
+		for _, migration := range migrations {
+			err = migration(swings[i])
+			if err != nil {
+				return smp.GenerateErrorE(500003501, err, i, tp)
+			}
+		}
+	}
+	data[key], er0 = json.Marshal(swings)
+	if er0 != nil {
+		return smp.GenerateErrorE(500003500, er0, tp)
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package strategies
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/myfantasy/mft"
+
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+// wingedSwingV0 - winged_swing saved before state machine
+const wingedSwingV0 = `{"name":"old","ticker":"TTTT","volume":2,"level_price_up":105,"level_price_down":100,
+"is_online":true,"in_market":2,"in_market_price":200,"is_bought":true,"order_id_sell":"s1","order_id_buy":""}`
+
+func TestMigrationWingedSwing(t *testing.T) {
+	if v := smp.GlobalStrategyRegistry.Version("winged_swing"); v != 2 {
+		t.Fatalf("version 2 of winged_swing expected, got %v", v)
+	}
+
+	s, err := smp.GlobalStrategyRegistry.Load(smp.JsonTypedContainer{Type: "winged_swing", Data: json.RawMessage(wingedSwingV0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws := s.(*WingedSwing); ws.State != SwingSelling || ws.OrderIdSell != "s1" || ws.InMarket != 2 {
+		t.Fatalf("wrong migrated swing: %v", s.Json())
+	}
+
+	c, err := smp.GlobalStrategyRegistry.Save(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 2 {
+		t.Fatalf("version 2 of saved winged_swing expected, got %v", c.Version)
+	}
+
+	// saved state of current version is not migrated
+	c.Data = json.RawMessage(`{"name":"new","is_bought":true,"state":"waiting"}`)
+	s, err = smp.GlobalStrategyRegistry.Load(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws := s.(*WingedSwing); ws.State != SwingWaiting {
+		t.Fatalf("state of current version is changed: %v", s.Json())
+	}
+}
+
+func TestMigrationWingedSwingNeedSendCancel(t *testing.T) {
+	c := smp.JsonTypedContainer{Type: "winged_swing", Version: 1,
+		Data: json.RawMessage(`{"name":"v1","is_online":true,"order_id_buy":"b1","state":"buying","need_send_cancel":true}`)}
+	s, err := smp.GlobalStrategyRegistry.Load(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := s.Status(); st.IsOnline || st.State != smp.LifecycleStopping || s.(*WingedSwing).Lifecycle.StopMode != smp.StopCancelOrders {
+		t.Fatalf("stopping with cancel of orders expected: %v", s.Json())
+	}
+
+	c.Data = json.RawMessage(`{"name":"v1","is_online":true,"need_send_cancel":false}`)
+	s, err = smp.GlobalStrategyRegistry.Load(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := s.Status(); !st.IsOnline || st.State != smp.LifecycleRunning {
+		t.Fatalf("running swing expected: %v", s.Json())
+	}
+}
+
+func TestMigrationWingedSwingGroup(t *testing.T) {
+	data := `{"name":"group","swings":[` + wingedSwingV0 + `,{"name":"empty","order_id_buy":"b1"}]}`
+	s, err := smp.GlobalStrategyRegistry.Load(smp.JsonTypedContainer{Type: "winged_swing_group", Data: json.RawMessage(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := s.(*WingedSwingGroup)
+	if len(g.Swings) != 2 || g.Swings[0].State != SwingSelling || g.Swings[1].State != SwingBuying {
+		t.Fatalf("wrong migrated group: %v", s.Json())
+	}
+
+	data = `{"name":"grid","levels":[` + wingedSwingV0[:len(wingedSwingV0)-1] + `,"need_send_cancel":true}]}`
+	s, err = smp.GlobalStrategyRegistry.Load(smp.JsonTypedContainer{Type: "grid", Data: json.RawMessage(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := s.(*Grid).Levels[0]; l.State != SwingSelling || l.Lifecycle.State != smp.LifecycleStopping {
+		t.Fatalf("wrong migrated grid: %v", s.Json())
+	}
+}
+
+func TestMigrationComposite(t *testing.T) {
+	data := `{"name":"group","mode":"sequential","is_online":true,"items":[` +
+		`{"name":"swing","strategy":{"type":"winged_swing","version":0,"data":` + wingedSwingV0 + `}},` +
+		`{"name":"empty","strategy":null}]}`
+	s, err := smp.GlobalStrategyRegistry.Load(smp.JsonTypedContainer{Type: "composite", Data: json.RawMessage(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := s.(*Composite)
+	if len(g.Items) != 2 || g.Items[1].Strategy != nil {
+		t.Fatalf("wrong migrated composite: %v", s.Json())
+	}
+	if ws, ok := g.Items[0].Strategy.(*WingedSwing); !ok || ws.State != SwingSelling || ws.InMarket != 2 {
+		t.Fatalf("swing of version 0 is not migrated: %v", s.Json())
+	}
+
+	// children are saved with their versions
+	c, err := smp.GlobalStrategyRegistry.Save(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved struct {
+		Items []struct {
+			Strategy *smp.JsonTypedContainer `json:"strategy"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(c.Data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 0 || len(saved.Items) != 2 || saved.Items[0].Strategy.Type != "winged_swing" || saved.Items[0].Strategy.Version != 2 {
+		t.Fatalf("children are saved without versions: %s", c.Data)
+	}
+
+	// fields of child unknown after migrations
+	data = `{"name":"group","items":[{"name":"b","strategy":{"type":"bracket","version":0,"data":{"vol":5}}}]}`
+	_, err = smp.GlobalStrategyRegistry.Load(smp.JsonTypedContainer{Type: "composite", Data: json.RawMessage(data)})
+	if err == nil || err.Code != 500003411 {
+		t.Fatalf("unknown field of child error expected, got %v", err)
+	}
+
+	data = `{"name":"group","items":[{"name":"x","strategy":{"type":"unknown","data":{}}}]}`
+	_, err = smp.GlobalStrategyRegistry.Load(smp.JsonTypedContainer{Type: "composite", Data: json.RawMessage(data)})
+	if err == nil || err.Code != 500003411 || err.InternalError == nil || err.InternalError.Code != 500003221 ||
+		err.InternalError.InternalError == nil || err.InternalError.InternalError.Code != 500003400 {
+		t.Fatalf("unknown type of child error expected, got %v", err)
+	}
+}
+
+func TestMigrationErrors(t *testing.T) {
+	c := smp.JsonTypedContainer{Type: "winged_swing", Version: 3, Data: json.RawMessage(`{}`)}
+	if _, err := smp.GlobalStrategyRegistry.Load(c); err == nil || err.Code != 500003412 {
+		t.Fatalf("newer version error expected, got %v", err)
+	}
+
+	// renamed field without migration
+	c = smp.JsonTypedContainer{Type: "bracket", Data: json.RawMessage(`{"vol":5}`)}
+	if _, err := smp.GlobalStrategyRegistry.Load(c); err == nil || err.Code != 500003411 {
+		t.Fatalf("unknown field error expected, got %v", err)
+	}
+
+	r := &smp.StrategyRegistry{}
+	r.Add(func() smp.Strategy { return &Bracket{} })
+	r.AddMigration("bracket", 1, func(data map[string]json.RawMessage) *mft.Error {
+		data["volume"] = data["vol"]
+		delete(data, "vol")
+		return nil
+	})
+	if _, err := r.Load(smp.JsonTypedContainer{Type: "bracket", Data: json.RawMessage(`{"vol":5}`)}); err == nil || err.Code != 500003413 {
+		t.Fatalf("missing migration error expected, got %v", err)
+	}
+
+	s, err := r.Load(smp.JsonTypedContainer{Type: "bracket", Version: 1, Data: json.RawMessage(`{"vol":5}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.(*Bracket).Volume != 5 {
+		t.Fatalf("field is not renamed: %v", s.Json())
+	}
+
+	r.AddMigration("bracket", 0, func(data map[string]json.RawMessage) *mft.Error {
+		return smp.GenerateError(500003500, "bracket")
+	})
+	if _, err := r.Load(smp.JsonTypedContainer{Type: "bracket", Data: json.RawMessage(`{}`)}); err == nil || err.Code != 500003414 {
+		t.Fatalf("migration error expected, got %v", err)
+	}
+}
@@ -0,0 +1,502 @@
+package strategies
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+//go:generate mfjson pairs.go
+
+// Парный трейдинг (Pairs)
+
+var (
+	_ smp.Strategy = &Pairs{}
+)
+
+type PairState string
+
+const (
+	PairFlat PairState = "flat"
+	// PairLongSpread - A is bought, B is sold
+	PairLongSpread PairState = "long_spread"
+	// PairShortSpread - A is sold, B is bought
+	PairShortSpread PairState = "short_spread"
+)
+
+//mfjson:interface smp.strategies.pairs
+type Pairs struct {
+	Name string `json:"name"`
+
+	InstrumentIdA string `json:"instrument_id_a"`
+	TickerA       string `json:"ticker_a"`
+	InstrumentIdB string `json:"instrument_id_b"`
+	TickerB       string `json:"ticker_b"`
+
+	// VolumeA - lots of A; lots of B are VolumeA * HedgeRatio (by items)
+	VolumeA int `json:"volume_a"`
+
+	// Lookback, Frame - count of candles and candle frame in minutes for hedge ratio and z-score
+	Lookback int `json:"lookback"`
+	Frame    int `json:"frame"`
+
+	// EntryZ - spread is opened when |z| >= EntryZ
+	EntryZ float64 `json:"entry_z"`
+	// ExitZ - spread is closed when |z| <= ExitZ
+	ExitZ float64 `json:"exit_z"`
+	// StopZ - spread is closed when |z| >= StopZ (0 - no stop)
+	StopZ float64 `json:"stop_z"`
+
+	// MaxLegRetries - retries of not filled leg before both legs are closed
+	MaxLegRetries int `json:"max_leg_retries"`
+	// LegTimeout - steps of waiting leg order before it is canceled and retried (0 - without cancel)
+	LegTimeout int `json:"leg_timeout"`
+
+	IsOnline bool `json:"is_online"`
+
+	State      PairState `json:"state"`
+	HedgeRatio float64   `json:"hedge_ratio"`
+	ZScore     float64   `json:"z_score"`
+
+	// TargetA, TargetB, PositionA, PositionB - lots (negative - short)
+	TargetA   int `json:"target_a"`
+	TargetB   int `json:"target_b"`
+	PositionA int `json:"position_a"`
+	PositionB int `json:"position_b"`
+
+	OrderIdA   string `json:"order_id_a"`
+	OrderIdB   string `json:"order_id_b"`
+	LegRetries int    `json:"leg_retries"`
+	// LegSteps - steps of waiting leg orders
+	LegSteps int `json:"leg_steps"`
+
+	// Cash - money of current spread (sells - buys)
+	Cash      float64 `json:"cash"`
+	Profit    float64 `json:"profit"`
+	Iteration int     `json:"iteration"`
+
+	Lifecycle smp.Lifecycle `json:"lifecycle"`
+}
+
+func (s *Pairs) Type() string {
+	return "pairs"
+}
+
+func (s *Pairs) String() string {
+	return "pairs"
+}
+
+func (s *Pairs) Status() smp.StartegyStatus {
+	return s.Lifecycle.Status(s.IsOnline)
+}
+func (s *Pairs) Json() string {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return string(b)
+}
+
+// commands - commands of strategy
+func (s *Pairs) commands() smp.CommandDefs {
+	return lifecycleCommands(showCommand("Отобразить", s.String), s.Lifecycle.Commands(s.Type(), s, &s.IsOnline), smp.CommandDefs{
+		{Command: SetVolume, Description: "Установить объём первого инструмента", Example: "set_vol 25",
+			Params: []smp.Param{smp.IntParam("", "объём (кол-во лотов)", &s.VolumeA).WithMin(0)}},
+		{Command: SetEntryZ, Description: "Установить z-score открытия спреда", Example: "set_entry_z 2",
+			Params: []smp.Param{smp.FloatParam("", "z-score", &s.EntryZ).WithMin(0)}},
+		{Command: SetExitZ, Description: "Установить z-score закрытия спреда", Example: "set_exit_z 0.5",
+			Params: []smp.Param{smp.FloatParam("", "z-score", &s.ExitZ).WithMin(0)}},
+		{Command: SetStopZ, Description: "Установить z-score стопа (0 - без стопа)", Example: "set_stop_z 4",
+			Params: []smp.Param{smp.FloatParam("", "z-score", &s.StopZ).WithMin(0)}},
+		{Command: SetLookback, Description: "Установить кол-во свечей для расчёта", Example: "set_lookback 60",
+			Params: []smp.Param{smp.IntParam("", "кол-во свечей", &s.Lookback).WithMin(2)}},
+		{Command: SetFrame, Description: "Установить размер свечи", Example: "set_frame 5",
+			Params: []smp.Param{smp.IntParam("", "минуты", &s.Frame).WithMin(1)}},
+		{Command: SetMaxLegRetries, Description: "Установить кол-во повторов неисполненной ноги до закрытия спреда",
+			Example: "set_max_leg_retries 3",
+			Params:  []smp.Param{smp.IntParam("", "кол-во", &s.MaxLegRetries).WithMin(0)}},
+		{Command: SetLegTimeout, Description: "Установить кол-во шагов ожидания заявки ноги до отмены (0 - без отмены)",
+			Example: "set_leg_timeout 5",
+			Params:  []smp.Param{smp.IntParam("", "кол-во шагов", &s.LegTimeout).WithMin(0)}},
+	})
+}
+
+func (s *Pairs) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	return s.commands().Do(s.Type(), cmd, params)
+}
+
+func (s *Pairs) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
+
+func (s *Pairs) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), `pairs - стратегия, парный трейдинг
+	Спред A - HedgeRatio * B открывается при отклонении z-score и закрывается при возврате к среднему`)
+}
+
+// hedgeRatio - OLS ratio of a by b
+func hedgeRatio(a []float64, b []float64) float64 {
+	n := float64(len(a))
+	if n == 0 {
+		return 0
+	}
+	ma, mb := 0.0, 0.0
+	for i := range a {
+		ma += a[i]
+		mb += b[i]
+	}
+	ma, mb = ma/n, mb/n
+
+	cov, vb := 0.0, 0.0
+	for i := range a {
+		cov += (a[i] - ma) * (b[i] - mb)
+		vb += (b[i] - mb) * (b[i] - mb)
+	}
+	if vb == 0 {
+		return 0
+	}
+	return cov / vb
+}
+
+// zScore - distance of x from mean of values in standard deviations
+func zScore(values []float64, x float64) float64 {
+	n := float64(len(values))
+	if n < 2 {
+		return 0
+	}
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= n
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	std := math.Sqrt(variance / (n - 1))
+	if std == 0 {
+		return 0
+	}
+	return (x - mean) / std
+}
+
+// closes - last Lookback close prices of instruments A and B by the same candle starts
+// (candles are joined before they are trimmed to Lookback)
+func (s *Pairs) closes(p smp.StepParams, tm time.Time) (a []float64, b []float64, err *mft.Error) {
+	duration := frameDuration(s.Frame)
+	err = historyWindows(tm, duration, s.Lookback, func(from time.Time) (done bool, err *mft.Error) {
+		csA, err := frameCandles(p, s.InstrumentIdA, s.TickerA, from, tm, duration)
+		if err != nil {
+			return false, err
+		}
+		csB, err := frameCandles(p, s.InstrumentIdB, s.TickerB, from, tm, duration)
+		if err != nil {
+			return false, err
+		}
+
+		byStart := make(map[time.Time]float64, csB.Len())
+		for _, c := range csB {
+			byStart[c.Start] = c.Close
+		}
+		a, b = a[:0], b[:0]
+		for _, c := range csA {
+			if cb, ok := byStart[c.Start]; ok {
+				a = append(a, c.Close)
+				b = append(b, cb)
+			}
+		}
+		return len(a) >= s.Lookback, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(a) > s.Lookback {
+		a, b = a[len(a)-s.Lookback:], b[len(b)-s.Lookback:]
+	}
+	return a, b, nil
+}
+
+// applyOrder - applies status of leg order
+func (s *Pairs) applyOrder(status smp.StatusOrder, prices []smp.LotPrices, buy bool, position *int, orderId *string, meta *smp.MetaForStep) {
+	if status != smp.Complete && status != smp.Canceled {
+		return
+	}
+	cnt, price := lotPricesSum(prices)
+	meta.HasChanges = true
+	*orderId = ""
+	if buy {
+		*position += cnt
+		s.Cash = smp.Round(s.Cash-price, 6)
+	} else {
+		*position -= cnt
+		s.Cash = smp.Round(s.Cash+price, 6)
+	}
+}
+
+// pollLeg - applies status of leg order (sell leg is polled as sell order)
+func (s *Pairs) pollLeg(p smp.StepParams, instrumentId string, ticker string, buy bool, position *int, orderId *string, meta *smp.MetaForStep) (err *mft.Error) {
+	var status smp.StatusOrder
+	var prices []smp.LotPrices
+	if buy {
+		status, prices, err = p.StatusBuyOrder(instrumentId, ticker, *orderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	} else {
+		status, prices, err = p.StatusSellOrder(instrumentId, ticker, *orderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	}
+	if err != nil {
+		return smp.GenerateErrorE(500001602, err, ticker)
+	}
+	s.applyOrder(status, prices, buy, position, orderId, meta)
+	return nil
+}
+
+// cancelLeg - cancels leg order (status is applied on next step)
+func (s *Pairs) cancelLeg(p smp.StepParams, instrumentId string, ticker string, buy bool, orderId string) (err *mft.Error) {
+	if orderId == "" {
+		return nil
+	}
+	if buy {
+		_, err = p.CancelBuyOrder(instrumentId, ticker, orderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	} else {
+		_, err = p.CancelSellOrder(instrumentId, ticker, orderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	}
+	if err != nil {
+		return smp.GenerateErrorE(500001606, err, ticker)
+	}
+	return nil
+}
+
+// sendLeg - market order for difference between target and position of leg
+func (s *Pairs) sendLeg(p smp.StepParams, instrumentId string, ticker string, diff int) (orderId string, err *mft.Error) {
+	if diff > 0 {
+		orderId, err = p.BuyByMarket(instrumentId, ticker, diff, &smp.MetaForOperations{NameOfStrategy: s.Name})
+		if err != nil {
+			return "", smp.GenerateErrorE(500001603, err, ticker)
+		}
+	}
+	if diff < 0 {
+		orderId, err = p.SellByMarket(instrumentId, ticker, -diff, &smp.MetaForOperations{NameOfStrategy: s.Name})
+		if err != nil {
+			return "", smp.GenerateErrorE(500001604, err, ticker)
+		}
+	}
+	return orderId, nil
+}
+
+// sendLegs - orders of both legs; error of one leg does not stop the other (leg risk is handled on next step)
+func (s *Pairs) sendLegs(p smp.StepParams, meta *smp.MetaForStep) (err *mft.Error) {
+	meta.HasChanges = true
+	s.LegSteps = 0
+	var errA, errB *mft.Error
+	if s.OrderIdA == "" && s.TargetA != s.PositionA {
+		s.OrderIdA, errA = s.sendLeg(p, s.InstrumentIdA, s.TickerA, s.TargetA-s.PositionA)
+	}
+	if s.OrderIdB == "" && s.TargetB != s.PositionB {
+		s.OrderIdB, errB = s.sendLeg(p, s.InstrumentIdB, s.TickerB, s.TargetB-s.PositionB)
+	}
+	if errA != nil {
+		return errA
+	}
+	return errB
+}
+
+// ActiveOrders - orders of legs
+func (s *Pairs) ActiveOrders() (orders []smp.ActiveOrder) {
+	if s.OrderIdA != "" {
+		orders = append(orders, smp.ActiveOrder{InstrumentId: s.InstrumentIdA, Ticker: s.TickerA, OrderId: s.OrderIdA, Buy: s.TargetA > s.PositionA})
+	}
+	if s.OrderIdB != "" {
+		orders = append(orders, smp.ActiveOrder{InstrumentId: s.InstrumentIdB, Ticker: s.TickerB, OrderId: s.OrderIdB, Buy: s.TargetB > s.PositionB})
+	}
+	return orders
+}
+
+// FlattenPosition - closes both legs by market
+func (s *Pairs) FlattenPosition(p smp.StepParams, meta *smp.MetaForStep) (done bool, err *mft.Error) {
+	if s.PositionA == 0 && s.PositionB == 0 {
+		return true, nil
+	}
+	meta.OpDescr = append(meta.OpDescr, "Flatten: spread is closed")
+	s.State = PairFlat
+	s.TargetA, s.TargetB = 0, 0
+	return false, s.sendLegs(p, meta)
+}
+
+// ResetState - clears spread state, cash, profit and iterations
+func (s *Pairs) ResetState() (err *mft.Error) {
+	if s.PositionA != 0 {
+		return smp.GenerateError(500003551, s.Type(), s.PositionA)
+	}
+	if s.PositionB != 0 {
+		return smp.GenerateError(500003551, s.Type(), s.PositionB)
+	}
+	s.State = PairFlat
+	s.HedgeRatio = 0
+	s.ZScore = 0
+	s.TargetA, s.TargetB = 0, 0
+	s.LegRetries = 0
+	s.LegSteps = 0
+	s.Cash = 0
+	s.Profit = 0
+	s.Iteration = 0
+	return nil
+}
+
+func (s *Pairs) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
+	meta.Name = s.Name
+
+	if s.OrderIdA != "" {
+		err = s.pollLeg(p, s.InstrumentIdA, s.TickerA, s.TargetA > s.PositionA, &s.PositionA, &s.OrderIdA, &meta)
+		if err != nil {
+			return meta, err
+		}
+	}
+	if s.OrderIdB != "" {
+		err = s.pollLeg(p, s.InstrumentIdB, s.TickerB, s.TargetB > s.PositionB, &s.PositionB, &s.OrderIdB, &meta)
+		if err != nil {
+			return meta, err
+		}
+	}
+
+	if halt, err := s.Lifecycle.Step(p, s, &meta); halt || err != nil {
+		return meta, err
+	}
+
+	if s.OrderIdA != "" || s.OrderIdB != "" {
+		if s.LegTimeout <= 0 {
+			return meta, nil
+		}
+		meta.HasChanges = true
+		s.LegSteps++
+		if s.LegSteps < s.LegTimeout {
+			return meta, nil
+		}
+		// leg is not filled in time: it is canceled, then retried or spread is closed
+		meta.OpDescr = append(meta.OpDescr, "Leg timeout: order is canceled")
+		s.LegSteps = 0
+		err = s.cancelLeg(p, s.InstrumentIdA, s.TickerA, s.TargetA > s.PositionA, s.OrderIdA)
+		if err != nil {
+			return meta, err
+		}
+		err = s.cancelLeg(p, s.InstrumentIdB, s.TickerB, s.TargetB > s.PositionB, s.OrderIdB)
+		if err != nil {
+			return meta, err
+		}
+		return meta, nil
+	}
+
+	// one leg is not filled: retry it or close both legs
+	if s.PositionA != s.TargetA || s.PositionB != s.TargetB {
+		if s.LegRetries >= s.MaxLegRetries {
+			meta.OpDescr = append(meta.OpDescr, "Leg risk: spread is closed")
+			s.State = PairFlat
+			s.TargetA, s.TargetB = 0, 0
+		} else {
+			meta.OpDescr = append(meta.OpDescr, "Leg risk: retry")
+		}
+		s.LegRetries++
+		err = s.sendLegs(p, &meta)
+		if err != nil {
+			return meta, err
+		}
+		return meta, nil
+	}
+	if s.LegRetries > 0 {
+		meta.HasChanges = true
+		s.LegRetries = 0
+	}
+
+	if s.State == PairFlat && s.PositionA == 0 && s.PositionB == 0 && s.Cash != 0 {
+		meta.HasChanges = true
+		s.Iteration++
+		s.Profit = smp.Round(s.Profit+s.Cash, 6)
+		s.Cash = 0
+	}
+
+	if !s.IsOnline || s.VolumeA <= 0 || s.Lookback < 2 {
+		return meta, nil
+	}
+
+	obA, err := p.GetOrderBook(s.InstrumentIdA, s.TickerA)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500001600, err, s.TickerA)
+	}
+	obB, err := p.GetOrderBook(s.InstrumentIdB, s.TickerB)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500001600, err, s.TickerB)
+	}
+	if obA.TradeStatus != smp.NormalTrading || obB.TradeStatus != smp.NormalTrading {
+		return meta, nil
+	}
+
+	a, b, err := s.closes(p, obA.Time)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500001601, err)
+	}
+	if len(a) < s.Lookback {
+		return meta, nil
+	}
+
+	s.HedgeRatio = smp.Round(hedgeRatio(a, b), 6)
+	spread := make([]float64, len(a))
+	for i := range a {
+		spread[i] = a[i] - s.HedgeRatio*b[i]
+	}
+	s.ZScore = smp.Round(zScore(spread, obA.Price()-s.HedgeRatio*obB.Price()), 6)
+	meta.HasChanges = true
+
+	z := math.Abs(s.ZScore)
+	state := s.State
+	switch {
+	case s.State == "" || s.State == PairFlat:
+		if s.EntryZ > 0 && z >= s.EntryZ && (s.StopZ <= 0 || z < s.StopZ) && s.HedgeRatio > 0 {
+			state = PairLongSpread
+			if s.ZScore > 0 {
+				state = PairShortSpread
+			}
+		}
+	case z <= s.ExitZ:
+		meta.OpDescr = append(meta.OpDescr, "Spread is closed")
+		state = PairFlat
+	case s.StopZ > 0 && z >= s.StopZ:
+		meta.OpDescr = append(meta.OpDescr, "Spread is closed by stop")
+		meta.IsStopLoss = true
+		state = PairFlat
+	}
+	if state == s.State {
+		return meta, nil
+	}
+
+	s.State = state
+	switch state {
+	case PairFlat:
+		s.TargetA, s.TargetB = 0, 0
+	default:
+		iiA, err := p.GetInstrumentInfo(s.InstrumentIdA, s.TickerA)
+		if err != nil {
+			return meta, smp.GenerateErrorE(500001605, err, s.TickerA)
+		}
+		iiB, err := p.GetInstrumentInfo(s.InstrumentIdB, s.TickerB)
+		if err != nil {
+			return meta, smp.GenerateErrorE(500001605, err, s.TickerB)
+		}
+		volumeB := int(math.Round(float64(s.VolumeA*iiA.Lot()) * s.HedgeRatio / float64(iiB.Lot())))
+		if state == PairLongSpread {
+			meta.OpDescr = append(meta.OpDescr, "Long spread is opened")
+			s.TargetA, s.TargetB = s.VolumeA, -volumeB
+		} else {
+			meta.OpDescr = append(meta.OpDescr, "Short spread is opened")
+			s.TargetA, s.TargetB = -s.VolumeA, volumeB
+		}
+	}
+
+	err = s.sendLegs(p, &meta)
+	if err != nil {
+		return meta, err
+	}
+	return meta, nil
+}
@@ -0,0 +1,19 @@
+// Code generated by mfjson for marshaling/unmarshaling. DO NOT EDIT.
+// https://github.com/myfantasy/json
+
+package strategies
+
+import (
+	mfj "github.com/myfantasy/json"
+)
+func (obj *Pairs) UnmarshalJSONTypeName() string {
+	return "smp.strategies.pairs"
+}
+
+func init() {
+	mfj.GlobalStructFactory.Add("smp.strategies.pairs", func() mfj.JsonInterfaceMarshaller { return &Pairs{} })
+	mfj.GlobalStructFactory.AddNil("smp.strategies.pairs", func() mfj.JsonInterfaceMarshaller {
+		var out *Pairs
+		return out
+	})
+}
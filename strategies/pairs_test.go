@@ -0,0 +1,335 @@
+package strategies
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+// pairsMarket - market of two instruments, market orders are filled at once by close price
+type pairsMarket struct {
+	candles  map[string]smp.Candles
+	position int
+
+	// reject - count of orders to reject by ticker
+	reject map[string]int
+
+	// hang - count of orders to keep waiting (until cancel) by ticker
+	hang map[string]int
+
+	nextId int
+	filled map[string]smp.LotPrices
+	lots   map[string]int
+	// buys, waiting, canceled - side (true - buy) and state of orders
+	buys     map[string]bool
+	waiting  map[string]bool
+	canceled map[string]bool
+}
+
+func (m *pairsMarket) candle(ticker string) smp.Candle {
+	return m.candles[ticker][m.position]
+}
+
+func (m *pairsMarket) GetCandles(instrumentId string, ticker string, dateFrom time.Time, dateTo time.Time) (cs smp.Candles, err *mft.Error) {
+	return m.candles[ticker][:m.position].After(dateFrom).Before(dateTo).Clone(), nil
+}
+func (m *pairsMarket) GetOrderBook(instrumentId string, ticker string) (ob *smp.OrderBook, err *mft.Error) {
+	c := m.candle(ticker)
+	return c.OrderBook(), nil
+}
+func (m *pairsMarket) GetInstrumentInfo(instrumentId string, ticker string) (instrumentInfo *smp.InstrumentInfo, err *mft.Error) {
+	return &smp.InstrumentInfo{Ticker: ticker, LotSize: 1}, nil
+}
+func (m *pairsMarket) order(ticker string, cnt int) (orderId string, err *mft.Error) {
+	if m.reject[ticker] > 0 {
+		m.reject[ticker]--
+		return "", mft.ErrorS("rejected")
+	}
+	m.nextId++
+	orderId = strconv.Itoa(m.nextId)
+	if m.buys == nil {
+		m.buys = make(map[string]bool)
+		m.waiting = make(map[string]bool)
+		m.canceled = make(map[string]bool)
+	}
+	m.buys[orderId] = cnt > 0
+	if m.hang[ticker] > 0 {
+		m.hang[ticker]--
+		m.waiting[orderId] = true
+		return orderId, nil
+	}
+	m.filled[orderId] = smp.LotPrices{Count: int(math.Abs(float64(cnt))), Price: m.candle(ticker).Close}
+	m.lots[ticker] += cnt
+	return orderId, nil
+}
+func (m *pairsMarket) BuyByMarket(instrumentId string, ticker string, cnt int, meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	return m.order(ticker, cnt)
+}
+func (m *pairsMarket) SellByMarket(instrumentId string, ticker string, cnt int, meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	return m.order(ticker, -cnt)
+}
+func (m *pairsMarket) BuyByPrice(instrumentId string, ticker string, cnt int, price float64, meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	return "", mft.ErrorS("not supported")
+}
+func (m *pairsMarket) SellByPrice(instrumentId string, ticker string, cnt int, price float64, meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	return "", mft.ErrorS("not supported")
+}
+
+// cancel - cancels waiting order (order of other side is error)
+func (m *pairsMarket) cancel(orderId string, buy bool) (ok bool, err *mft.Error) {
+	if b, found := m.buys[orderId]; found && b != buy {
+		return false, mft.ErrorS("wrong side of order")
+	}
+	if !m.waiting[orderId] {
+		return false, nil
+	}
+	delete(m.waiting, orderId)
+	m.canceled[orderId] = true
+	return true, nil
+}
+func (m *pairsMarket) CancelBuyOrder(instrumentId string, ticker string, orderId string, meta *smp.MetaForOperations) (ok bool, err *mft.Error) {
+	return m.cancel(orderId, true)
+}
+func (m *pairsMarket) CancelSellOrder(instrumentId string, ticker string, orderId string, meta *smp.MetaForOperations) (ok bool, err *mft.Error) {
+	return m.cancel(orderId, false)
+}
+
+// status - status of order (order of other side is error)
+func (m *pairsMarket) status(orderId string, buy bool) (status smp.StatusOrder, prices []smp.LotPrices, err *mft.Error) {
+	if b, found := m.buys[orderId]; found && b != buy {
+		return smp.Unknown, nil, mft.ErrorS("wrong side of order")
+	}
+	if m.waiting[orderId] {
+		return smp.Wait, nil, nil
+	}
+	if m.canceled[orderId] {
+		return smp.Canceled, nil, nil
+	}
+	lp, ok := m.filled[orderId]
+	if !ok {
+		return smp.Unknown, nil, mft.ErrorS("Not found")
+	}
+	return smp.Complete, []smp.LotPrices{lp}, nil
+}
+func (m *pairsMarket) StatusBuyOrder(instrumentId string, ticker string, orderId string, meta *smp.MetaForOperations) (status smp.StatusOrder, prices []smp.LotPrices, err *mft.Error) {
+	return m.status(orderId, true)
+}
+func (m *pairsMarket) StatusSellOrder(instrumentId string, ticker string, orderId string, meta *smp.MetaForOperations) (status smp.StatusOrder, prices []smp.LotPrices, err *mft.Error) {
+	return m.status(orderId, false)
+}
+
+// newPairsMarket - B is moving around 50, A is 2 * B + spread
+func newPairsMarket(spread []float64) *pairsMarket {
+	b := make([][4]float64, len(spread))
+	a := make([][4]float64, len(spread))
+	for i := range spread {
+		pb := 50 + float64(i%5)
+		pa := 2*pb + spread[i]
+		b[i] = [4]float64{pb, pb, pb, pb}
+		a[i] = [4]float64{pa, pa, pa, pa}
+	}
+	csA, csB := testCandles(a...), testCandles(b...)
+	for i := range csA {
+		csA[i].Ticker = "AAAA"
+		csB[i].Ticker = "BBBB"
+	}
+	return &pairsMarket{
+		candles: map[string]smp.Candles{"AAAA": csA, "BBBB": csB},
+		reject:  make(map[string]int),
+		filled:  make(map[string]smp.LotPrices),
+		lots:    make(map[string]int),
+	}
+}
+
+func testPairs() *Pairs {
+	return &Pairs{
+		TickerA:       "AAAA",
+		TickerB:       "BBBB",
+		VolumeA:       10,
+		Lookback:      10,
+		EntryZ:        2,
+		ExitZ:         0.5,
+		MaxLegRetries: 1,
+		IsOnline:      true,
+	}
+}
+
+func TestPairsStats(t *testing.T) {
+	a := []float64{3, 5, 7, 9}
+	b := []float64{1, 2, 3, 4}
+	if r := hedgeRatio(a, b); r != 2 {
+		t.Fatalf("hedge ratio should be 2 (current %v)", r)
+	}
+	if z := zScore([]float64{1, 2, 3}, 4); z != 2 {
+		t.Fatalf("z-score should be 2 (current %v)", z)
+	}
+}
+
+func TestPairsOpenClose(t *testing.T) {
+	spread := []float64{0.1, -0.1, 0.1, -0.1, 0.1, -0.1, 0.1, -0.1, 0.1, -0.1, 0.1, 0.1, 3, 3, 0, 0, 0}
+	m := newPairsMarket(spread)
+	s := testPairs()
+	// spread of open is inside lookback
+	s.ExitZ = 1
+
+	for m.position = 10; m.position < 12; m.position++ {
+		_, err := s.Step(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.State != PairFlat && s.State != "" {
+			t.Fatalf("spread is opened without deviation: %v", s.Json())
+		}
+	}
+	if math.Abs(s.HedgeRatio-2) > 0.01 {
+		t.Fatalf("hedge ratio should be 2 (current %v)", s.HedgeRatio)
+	}
+
+	// A is expensive: sell A, buy B
+	_, err := s.Step(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.State != PairShortSpread || s.TargetA != -10 || s.TargetB != 20 {
+		t.Fatalf("short spread is not opened: %v", s.Json())
+	}
+
+	m.position++
+	_, err = s.Step(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.PositionA != -10 || s.PositionB != 20 || s.State != PairShortSpread {
+		t.Fatalf("legs are not filled: %v", s.Json())
+	}
+
+	m.position++
+	_, err = s.Step(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.State != PairFlat {
+		t.Fatalf("spread is not closed: %v", s.Json())
+	}
+
+	m.position++
+	_, err = s.Step(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.PositionA != 0 || s.PositionB != 0 || s.Iteration != 1 || s.Profit <= 0 {
+		t.Fatalf("wrong state after close: %v", s.Json())
+	}
+	if m.lots["AAAA"] != 0 || m.lots["BBBB"] != 0 {
+		t.Fatalf("market positions are not closed: %v", m.lots)
+	}
+}
+
+func TestPairsLegRisk(t *testing.T) {
+	spread := []float64{0.1, -0.1, 0.1, -0.1, 0.1, -0.1, 0.1, -0.1, 0.1, -0.1, -3, -3, -3, -3}
+	m := newPairsMarket(spread)
+	s := testPairs()
+
+	// B leg is rejected twice: retry once then close A
+	m.reject["BBBB"] = 2
+	m.position = 10
+	_, err := s.Step(m)
+	if err == nil {
+		t.Fatal("error of leg is expected")
+	}
+	if s.State != PairLongSpread || s.OrderIdA == "" || s.OrderIdB != "" {
+		t.Fatalf("wrong state after rejected leg: %v", s.Json())
+	}
+
+	m.position++
+	_, err = s.Step(m)
+	if err == nil {
+		t.Fatal("error of leg retry is expected")
+	}
+	if s.LegRetries != 1 || s.PositionA != 10 || s.PositionB != 0 {
+		t.Fatalf("wrong state after retry: %v", s.Json())
+	}
+
+	m.position++
+	_, err = s.Step(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.State != PairFlat || s.TargetA != 0 || s.OrderIdA == "" {
+		t.Fatalf("leg is not closed: %v", s.Json())
+	}
+
+	m.position++
+	_, err = s.Step(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.PositionA != 0 || s.PositionB != 0 || s.LegRetries != 0 || m.lots["AAAA"] != 0 {
+		t.Fatalf("wrong state after leg close: %v", s.Json())
+	}
+}
+
+func TestPairsLegTimeout(t *testing.T) {
+	spread := []float64{0.1, -0.1, 0.1, -0.1, 0.1, -0.1, 0.1, -0.1, 0.1, -0.1, -3, -3, -3, -3, -3, -3, -3, -3}
+	m := newPairsMarket(spread)
+	s := testPairs()
+	s.LegTimeout = 2
+
+	// sell order of B is not filled: A is bought, B is waiting
+	m.hang = map[string]int{"BBBB": 2}
+	for m.position = 10; m.position < 13; m.position++ {
+		_, err := s.Step(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.State != PairLongSpread || s.PositionA != 10 || s.OrderIdB == "" || !m.canceled[s.OrderIdB] {
+		t.Fatalf("hung leg is not canceled by timeout: %v", s.Json())
+	}
+
+	// canceled leg is retried, hangs again and spread is closed
+	for ; m.position < 17; m.position++ {
+		_, err := s.Step(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.State != PairFlat || s.LegRetries != 2 || s.OrderIdA == "" || s.OrderIdB != "" {
+		t.Fatalf("spread is not closed after leg retries: %v", s.Json())
+	}
+
+	_, err := s.Step(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.PositionA != 0 || s.PositionB != 0 || s.LegRetries != 0 || m.lots["AAAA"] != 0 || m.lots["BBBB"] != 0 {
+		t.Fatalf("wrong state after leg close: %v", s.Json())
+	}
+}
+
+func TestPairsClosesJoin(t *testing.T) {
+	spread := make([]float64, 20)
+	m := newPairsMarket(spread)
+	s := testPairs()
+
+	// B has no candle inside lookback: legs are joined before trim
+	csB := m.candles["BBBB"]
+	m.candles["BBBB"] = append(csB[:12:12], csB[13:]...)
+	m.position = 15
+	a, b, err := s.closes(m, m.candle("AAAA").Start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != s.Lookback || len(b) != s.Lookback {
+		t.Fatalf("joined closes should have %v candles: %v %v", s.Lookback, a, b)
+	}
+	for i := range a {
+		if a[i] != 2*b[i] {
+			t.Fatalf("closes are joined by wrong candles: %v %v", a, b)
+		}
+	}
+}
@@ -0,0 +1,528 @@
+package strategies
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+//go:generate mfjson rebalance.go
+
+// Ребалансировка портфеля к целевым долям
+
+var (
+	_ smp.Strategy = &Rebalance{}
+)
+
+type RebalancePhase string
+
+const (
+	RebalanceIdle    RebalancePhase = "idle"
+	RebalanceSelling RebalancePhase = "selling"
+	RebalanceBuying  RebalancePhase = "buying"
+)
+
+// RebalanceTarget - target weight of instrument in portfolio value
+// (target with 0 weight is sold and removed after position is closed)
+type RebalanceTarget struct {
+	InstrumentId string  `json:"instrument_id"`
+	Ticker       string  `json:"ticker"`
+	Weight       float64 `json:"weight"`
+
+	// Quantity, Price, Drift - state of last plan
+	Quantity int     `json:"quantity"`
+	Price    float64 `json:"price"`
+	Drift    float64 `json:"drift"`
+}
+
+// RebalanceOrder - planned or sent order of rebalance
+type RebalanceOrder struct {
+	InstrumentId string  `json:"instrument_id"`
+	Ticker       string  `json:"ticker"`
+	Buy          bool    `json:"buy"`
+	Cnt          int     `json:"cnt"`
+	Price        float64 `json:"price"`
+
+	OrderId     string  `json:"order_id,omitempty"`
+	Filled      int     `json:"filled,omitempty"`
+	FilledPrice float64 `json:"filled_price,omitempty"`
+}
+
+func (o RebalanceOrder) String() string {
+	side := "sell"
+	if o.Buy {
+		side = "buy"
+	}
+	return fmt.Sprintf("%v %v %v lots by %v", side, o.Ticker, o.Cnt, o.Price)
+}
+
+//mfjson:interface smp.strategies.rebalance
+type Rebalance struct {
+	Name string `json:"name"`
+
+	Targets []RebalanceTarget `json:"targets"`
+	// Currency - currency of cash (rest of weights is kept in cash)
+	Currency string `json:"currency"`
+
+	// MonthDay, Hour, Minute - time of monthly rebalance (MonthDay 0 - no monthly rebalance)
+	MonthDay int `json:"month_day"`
+	Hour     int `json:"hour"`
+	Minute   int `json:"minute"`
+	// DriftThreshold - rebalance when weight of any instrument drifts more than percent points (0 - no drift rebalance)
+	DriftThreshold float64 `json:"drift_threshold"`
+
+	IsOnline bool `json:"is_online"`
+	// IsForced - rebalance on next step
+	IsForced bool `json:"is_forced"`
+
+	Phase    RebalancePhase `json:"phase"`
+	NextTime time.Time      `json:"next_time"`
+	LastTime time.Time      `json:"last_time"`
+
+	// Value, Cash, Drift - state of last plan (Drift - max drift in percent points)
+	Value float64 `json:"value"`
+	Cash  float64 `json:"cash"`
+	Drift float64 `json:"drift"`
+	// Plan - orders to rebalance by last step (sells before buys)
+	Plan []RebalanceOrder `json:"plan"`
+	// PlanTime - time of market data of Plan
+	PlanTime time.Time `json:"plan_time"`
+	// Orders - orders of current phase
+	Orders []RebalanceOrder `json:"orders"`
+
+	Rebalances int `json:"rebalances"`
+
+	Lifecycle smp.Lifecycle `json:"lifecycle"`
+}
+
+func (s *Rebalance) Type() string {
+	return "rebalance"
+}
+
+func (s *Rebalance) String() string {
+	return "rebalance"
+}
+
+func (s *Rebalance) Status() smp.StartegyStatus {
+	return s.Lifecycle.Status(s.IsOnline)
+}
+func (s *Rebalance) Json() string {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return string(b)
+}
+
+// commands - commands of strategy
+func (s *Rebalance) commands() smp.CommandDefs {
+	show := showCommand("Отобразить (стоимость, отклонение долей)", func() string {
+		weights := make([]string, 0, len(s.Targets))
+		for _, t := range s.Targets {
+			weights = append(weights, fmt.Sprintf("%v %v (drift %v)", t.Ticker, t.Weight, t.Drift))
+		}
+		return fmt.Sprintf("%v (%v): value %v, cash %v, drift %v, rebalances %v, next %v: %v",
+			s.String(), s.Phase, s.Value, s.Cash, s.Drift, s.Rebalances, s.NextTime.Format(time.RFC3339),
+			strings.Join(weights, ", "))
+	})
+	return lifecycleCommands(show, s.Lifecycle.Commands(s.Type(), s, &s.IsOnline), smp.CommandDefs{
+		{Command: DryRun, Description: "Показать заявки ребалансировки по последнему шагу (без выставления)", Example: "dry_run",
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				res.Message = s.dryRun()
+				return res, nil
+			}},
+		{Command: RebalanceNow, Description: "Ребалансировать на следующем шаге", Example: "rebalance",
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				s.IsForced = true
+				return res, nil
+			}},
+		{Command: SetWeight, Description: "Установить целевую долю инструмента (0 - продать и убрать инструмент)",
+			Example: "set_weight t=SBER w=0.25",
+			Params: []smp.Param{smp.StringParam("i", "instrument_id", nil).AsOptional(),
+				smp.StringParam("t", "тикер", nil),
+				smp.FloatParam("w", "доля (сумма долей не больше 1)", nil).WithMin(0).WithMax(1)},
+			Do: s.setWeight},
+		{Command: SetDrift, Description: "Установить отклонение доли для ребалансировки (0 - только по расписанию)",
+			Example: "set_drift 5",
+			Params:  []smp.Param{smp.FloatParam("", "процентные пункты", &s.DriftThreshold).WithMin(0)}},
+		{Command: SetMonthDay, Description: "Установить день месяца ребалансировки (0 - без расписания)",
+			Example: "set_month_day 1",
+			Params:  []smp.Param{smp.IntParam("", "день месяца", &s.MonthDay).WithMin(0).WithMax(31)},
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				s.NextTime = time.Time{}
+				return res, nil
+			}},
+		{Command: SetTime, Description: "Установить время ребалансировки", Example: "set_time 10:30",
+			Params: []smp.Param{smp.ClockParam("", "ЧЧ:ММ", nil)},
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				s.Hour, s.Minute = v.Time("").Hour(), v.Time("").Minute()
+				s.NextTime = time.Time{}
+				return res, nil
+			}},
+	})
+}
+
+func (s *Rebalance) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	return s.commands().Do(s.Type(), cmd, params)
+}
+
+func (s *Rebalance) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
+
+// dryRun - planned orders of rebalance
+func (s *Rebalance) dryRun() string {
+	if s.PlanTime.IsZero() {
+		return fmt.Sprintf("%v: no plan yet (plan is made on step)", s.String())
+	}
+	if len(s.Plan) == 0 {
+		return fmt.Sprintf("%v: no orders planned at %v (value %v, cash %v)",
+			s.String(), s.PlanTime.Format(time.RFC3339), s.Value, s.Cash)
+	}
+	orders := make([]string, 0, len(s.Plan))
+	for _, o := range s.Plan {
+		orders = append(orders, o.String())
+	}
+	return fmt.Sprintf("%v: planned orders at %v (value %v, cash %v): %v",
+		s.String(), s.PlanTime.Format(time.RFC3339), s.Value, s.Cash, strings.Join(orders, "; "))
+}
+
+// setWeight - sets target weight of instrument (sum of weights is not greater than 1;
+// target with 0 weight is kept until position is sold)
+func (s *Rebalance) setWeight(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+	tS, iS, w := v.String("t"), v.String("i"), v.Float("w")
+
+	sum := w
+	found := -1
+	for i, t := range s.Targets {
+		if t.Ticker == tS && t.InstrumentId == iS {
+			found = i
+			continue
+		}
+		sum += t.Weight
+	}
+	if sum > 1+1e-9 {
+		return res, smp.GenerateError(500002613, SetWeight, w)
+	}
+
+	if found >= 0 {
+		s.Targets[found].Weight = w
+	} else if w > 0 {
+		s.Targets = append(s.Targets, RebalanceTarget{InstrumentId: iS, Ticker: tS, Weight: w})
+	}
+	return res, nil
+}
+
+func (s *Rebalance) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), `rebalance - стратегия, ребалансировка портфеля к целевым долям
+	Раз в месяц или при отклонении доли больше порога позиции приводятся к целевым долям (по рынку, с округлением до лотов)
+	Сначала продажи, затем покупки на освободившиеся деньги`)
+}
+
+// next - time of monthly rebalance after tm (zero when it is not set)
+func (s *Rebalance) next(tm time.Time) time.Time {
+	if s.MonthDay <= 0 {
+		return time.Time{}
+	}
+	y, m, _ := tm.Date()
+	for i := 0; ; i++ {
+		t := monthDayTime(y, m+time.Month(i), s.MonthDay, s.Hour, s.Minute, tm.Location())
+		if t.After(tm) {
+			return t
+		}
+	}
+}
+
+// plan - orders to rebalance (sells before buys, buys are limited by cash)
+func (s *Rebalance) plan(p smp.PortfolioStepParams) (tm time.Time, err *mft.Error) {
+	lots := make([]int, len(s.Targets))
+	value := 0.0
+	for i := range s.Targets {
+		t := &s.Targets[i]
+		pos, err := p.GetPosition(t.InstrumentId, t.Ticker)
+		if err != nil {
+			return tm, smp.GenerateErrorE(500002701, err, t.Ticker)
+		}
+		ob, err := p.GetOrderBook(t.InstrumentId, t.Ticker)
+		if err != nil {
+			return tm, smp.GenerateErrorE(500002702, err, t.Ticker)
+		}
+		ii, err := p.GetInstrumentInfo(t.InstrumentId, t.Ticker)
+		if err != nil {
+			return tm, smp.GenerateErrorE(500002703, err, t.Ticker)
+		}
+		if ob.Time.After(tm) {
+			tm = ob.Time
+		}
+		t.Quantity = pos.Quantity
+		t.Price = ob.Price()
+		lots[i] = ii.Lot()
+		value += float64(pos.Quantity*lots[i]) * t.Price
+	}
+
+	money, err := p.GetMoney()
+	if err != nil {
+		return tm, smp.GenerateErrorE(500002704, err)
+	}
+	s.Cash = 0
+	for _, m := range money {
+		if m.Currency == s.Currency {
+			s.Cash = m.Free()
+		}
+	}
+
+	s.Value = smp.Round(value+s.Cash, 6)
+	s.Drift = 0
+	sells := make([]RebalanceOrder, 0)
+	buys := make([]RebalanceOrder, 0)
+	for i := range s.Targets {
+		t := &s.Targets[i]
+		if t.Price <= 0 || s.Value <= 0 {
+			continue
+		}
+		lotPrice := t.Price * float64(lots[i])
+		t.Drift = smp.Round((float64(t.Quantity)*lotPrice/s.Value-t.Weight)*100, 4)
+		s.Drift = math.Max(s.Drift, math.Abs(t.Drift))
+
+		cnt := int(math.Floor(s.Value*t.Weight/lotPrice)) - t.Quantity
+		o := RebalanceOrder{InstrumentId: t.InstrumentId, Ticker: t.Ticker, Buy: cnt > 0, Cnt: cnt, Price: t.Price}
+		if cnt < 0 {
+			o.Cnt = -cnt
+			sells = append(sells, o)
+		}
+		if cnt > 0 {
+			buys = append(buys, o)
+		}
+	}
+
+	// cash of sells is spent by buys of the most underweight instruments first
+	sort.SliceStable(buys, func(i, j int) bool {
+		return s.Targets[s.target(buys[i])].Drift < s.Targets[s.target(buys[j])].Drift
+	})
+	cash := s.Cash
+	for _, o := range sells {
+		cash += float64(o.Cnt) * o.Price * float64(lots[s.target(o)])
+	}
+	s.Plan = sells
+	for _, o := range buys {
+		lotPrice := o.Price * float64(lots[s.target(o)])
+		if float64(o.Cnt)*lotPrice > cash {
+			o.Cnt = int(math.Floor(cash / lotPrice))
+		}
+		if o.Cnt <= 0 {
+			continue
+		}
+		cash -= float64(o.Cnt) * lotPrice
+		s.Plan = append(s.Plan, o)
+	}
+	s.PlanTime = tm
+
+	// targets with 0 weight are removed after position is closed
+	targets := s.Targets[:0]
+	for _, t := range s.Targets {
+		if t.Weight > 0 || t.Quantity != 0 {
+			targets = append(targets, t)
+		}
+	}
+	s.Targets = targets
+
+	return tm, nil
+}
+
+// target - index of target of order
+func (s *Rebalance) target(o RebalanceOrder) int {
+	for i, t := range s.Targets {
+		if t.Ticker == o.Ticker && t.InstrumentId == o.InstrumentId {
+			return i
+		}
+	}
+	return -1
+}
+
+// send - sends planned orders of side by market
+func (s *Rebalance) send(p smp.PortfolioStepParams, buy bool, meta *smp.MetaForStep) (err *mft.Error) {
+	s.Orders = nil
+	for _, o := range s.Plan {
+		if o.Buy != buy {
+			continue
+		}
+		meta.HasChanges = true
+		if buy {
+			o.OrderId, err = p.BuyByMarket(o.InstrumentId, o.Ticker, o.Cnt, &smp.MetaForOperations{NameOfStrategy: s.Name})
+			if err != nil {
+				return smp.GenerateErrorE(500002707, err, o.Ticker)
+			}
+		} else {
+			o.OrderId, err = p.SellByMarket(o.InstrumentId, o.Ticker, o.Cnt, &smp.MetaForOperations{NameOfStrategy: s.Name})
+			if err != nil {
+				return smp.GenerateErrorE(500002706, err, o.Ticker)
+			}
+		}
+		s.Orders = append(s.Orders, o)
+		meta.OpDescr = append(meta.OpDescr, o.String())
+	}
+	return nil
+}
+
+// wait - applies status of orders of current phase (done is true when all orders are finished)
+func (s *Rebalance) wait(p smp.PortfolioStepParams, meta *smp.MetaForStep) (done bool, err *mft.Error) {
+	done = true
+	for i := range s.Orders {
+		o := &s.Orders[i]
+		if o.OrderId == "" {
+			continue
+		}
+		var status smp.StatusOrder
+		var prices []smp.LotPrices
+		if o.Buy {
+			status, prices, err = p.StatusBuyOrder(o.InstrumentId, o.Ticker, o.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+		} else {
+			status, prices, err = p.StatusSellOrder(o.InstrumentId, o.Ticker, o.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+		}
+		if err != nil {
+			return false, smp.GenerateErrorE(500002705, err, o.Ticker)
+		}
+		if status != smp.Complete && status != smp.Canceled {
+			done = false
+			continue
+		}
+		meta.HasChanges = true
+		o.Filled, o.FilledPrice = lotPricesSum(prices)
+		o.OrderId = ""
+	}
+	return done, nil
+}
+
+// ActiveOrders - orders of current phase
+func (s *Rebalance) ActiveOrders() (orders []smp.ActiveOrder) {
+	for _, o := range s.Orders {
+		if o.OrderId != "" {
+			orders = append(orders, smp.ActiveOrder{InstrumentId: o.InstrumentId, Ticker: o.Ticker, OrderId: o.OrderId, Buy: o.Buy})
+		}
+	}
+	return orders
+}
+
+// ResetState - clears phase, plan, schedule and count of rebalances (portfolio is kept)
+func (s *Rebalance) ResetState() (err *mft.Error) {
+	s.IsForced = false
+	s.Phase = RebalanceIdle
+	s.NextTime = time.Time{}
+	s.LastTime = time.Time{}
+	s.Value = 0
+	s.Cash = 0
+	s.Drift = 0
+	s.Plan = nil
+	s.PlanTime = time.Time{}
+	s.Orders = nil
+	s.Rebalances = 0
+	return nil
+}
+
+func (s *Rebalance) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
+	meta.Name = s.Name
+
+	pp, ok := p.(smp.PortfolioStepParams)
+	if !ok {
+		return meta, smp.GenerateError(500002700)
+	}
+
+	if s.Phase == "" {
+		s.Phase = RebalanceIdle
+	}
+
+	done := true
+	if s.Phase != RebalanceIdle {
+		done, err = s.wait(pp, &meta)
+		if err != nil {
+			return meta, err
+		}
+	}
+
+	if halt, err := s.Lifecycle.Step(p, s, &meta); halt || err != nil {
+		return meta, err
+	}
+
+	if !done {
+		return meta, nil
+	}
+
+	if s.Phase == RebalanceSelling {
+		// buys are planned again by cash of sells
+		_, err = s.plan(pp)
+		if err != nil {
+			return meta, err
+		}
+		s.Phase = RebalanceBuying
+		err = s.send(pp, true, &meta)
+		if err != nil {
+			return meta, err
+		}
+		if len(s.Orders) > 0 {
+			return meta, nil
+		}
+	}
+
+	if s.Phase == RebalanceBuying {
+		meta.HasChanges = true
+		s.Phase = RebalanceIdle
+		s.Orders = nil
+		s.Rebalances++
+	}
+
+	tm, err := s.plan(pp)
+	if err != nil {
+		return meta, err
+	}
+
+	if !s.IsOnline || len(s.Targets) == 0 {
+		return meta, nil
+	}
+
+	if s.NextTime.IsZero() && s.MonthDay > 0 {
+		meta.HasChanges = true
+		s.NextTime = s.next(tm)
+	}
+
+	scheduled := !s.NextTime.IsZero() && !tm.Before(s.NextTime)
+	drifted := s.DriftThreshold > 0 && s.Drift >= s.DriftThreshold
+	if !s.IsForced && !scheduled && !drifted {
+		return meta, nil
+	}
+
+	meta.HasChanges = true
+	s.IsForced = false
+	s.LastTime = tm
+	if scheduled {
+		s.NextTime = s.next(tm)
+	}
+	if len(s.Plan) == 0 {
+		meta.OpDescr = append(meta.OpDescr, "Portfolio is balanced")
+		return meta, nil
+	}
+
+	s.Phase = RebalanceSelling
+	err = s.send(pp, false, &meta)
+	if err != nil {
+		return meta, err
+	}
+	if len(s.Orders) > 0 {
+		return meta, nil
+	}
+
+	// nothing to sell
+	s.Phase = RebalanceBuying
+	err = s.send(pp, true, &meta)
+	if err != nil {
+		return meta, err
+	}
+
+	return meta, nil
+}
@@ -0,0 +1,19 @@
+// Code generated by mfjson for marshaling/unmarshaling. DO NOT EDIT.
+// https://github.com/myfantasy/json
+
+package strategies
+
+import (
+	mfj "github.com/myfantasy/json"
+)
+func (obj *Rebalance) UnmarshalJSONTypeName() string {
+	return "smp.strategies.rebalance"
+}
+
+func init() {
+	mfj.GlobalStructFactory.Add("smp.strategies.rebalance", func() mfj.JsonInterfaceMarshaller { return &Rebalance{} })
+	mfj.GlobalStructFactory.AddNil("smp.strategies.rebalance", func() mfj.JsonInterfaceMarshaller {
+		var out *Rebalance
+		return out
+	})
+}
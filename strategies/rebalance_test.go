@@ -0,0 +1,237 @@
+package strategies
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+	"github.com/myfantasy/stock_market_primitives/market"
+)
+
+// basketMarket - pairsMarket with lot sizes and account
+type basketMarket struct {
+	*pairsMarket
+	lotSize map[string]int
+	account market.Account
+}
+
+func (m *basketMarket) GetInstrumentInfo(instrumentId string, ticker string) (instrumentInfo *smp.InstrumentInfo, err *mft.Error) {
+	return &smp.InstrumentInfo{Ticker: ticker, LotSize: m.lotSize[ticker]}, nil
+}
+func (m *basketMarket) trade(instrumentId string, ticker string, buy bool, cnt int) (orderId string, err *mft.Error) {
+	if buy {
+		orderId, err = m.order(ticker, cnt)
+	} else {
+		orderId, err = m.order(ticker, -cnt)
+	}
+	if err != nil {
+		return "", err
+	}
+	ii, _ := m.GetInstrumentInfo(instrumentId, ticker)
+	m.account.Trade(ii, instrumentId, ticker, buy, cnt, m.candle(ticker).Close)
+	return orderId, nil
+}
+func (m *basketMarket) BuyByMarket(instrumentId string, ticker string, cnt int, meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	return m.trade(instrumentId, ticker, true, cnt)
+}
+func (m *basketMarket) SellByMarket(instrumentId string, ticker string, cnt int, meta *smp.MetaForOperations) (orderId string, err *mft.Error) {
+	return m.trade(instrumentId, ticker, false, cnt)
+}
+func (m *basketMarket) GetPositions() (positions []smp.Position, err *mft.Error) {
+	for _, p := range m.account.Positions {
+		positions = append(positions, *p)
+	}
+	return positions, nil
+}
+func (m *basketMarket) GetPosition(instrumentId string, ticker string) (position smp.Position, err *mft.Error) {
+	return m.account.Position(instrumentId, ticker), nil
+}
+func (m *basketMarket) GetMoney() (money []smp.MoneyBalance, err *mft.Error) {
+	return []smp.MoneyBalance{{Amount: m.account.Money[""]}}, nil
+}
+func (m *basketMarket) GetBuyingPower(currency string) (amount float64, err *mft.Error) {
+	return m.account.Money[currency], nil
+}
+
+// newBasketMarket - AAAA by 100 (lot 1) and BBBB by 50 (lot 10); 1000 cash, 50 lots of AAAA and 4 lots of BBBB
+func newBasketMarket() *basketMarket {
+	a := make([][4]float64, 5)
+	b := make([][4]float64, 5)
+	for i := range a {
+		a[i] = [4]float64{100, 100, 100, 100}
+		b[i] = [4]float64{50, 50, 50, 50}
+	}
+	csA, csB := testCandles(a...), testCandles(b...)
+	for i := range csA {
+		csA[i].Ticker = "AAAA"
+		csB[i].Ticker = "BBBB"
+	}
+	m := &basketMarket{
+		pairsMarket: &pairsMarket{
+			candles: map[string]smp.Candles{"AAAA": csA, "BBBB": csB},
+			reject:  make(map[string]int),
+			filled:  make(map[string]smp.LotPrices),
+			lots:    make(map[string]int),
+		},
+		lotSize: map[string]int{"AAAA": 1, "BBBB": 10},
+	}
+	m.account.Trade(&smp.InstrumentInfo{LotSize: 1}, "", "AAAA", true, 50, 100)
+	m.account.Trade(&smp.InstrumentInfo{LotSize: 10}, "", "BBBB", true, 4, 50)
+	m.account.Money[""] = 1000
+	return m
+}
+
+func testRebalance() *Rebalance {
+	return &Rebalance{
+		Name: "test",
+		Targets: []RebalanceTarget{
+			{Ticker: "AAAA", Weight: 0.5},
+			{Ticker: "BBBB", Weight: 0.4},
+		},
+	}
+}
+
+func stepRebalance(t *testing.T, m *basketMarket, s *Rebalance) {
+	t.Helper()
+	_, err := s.Step(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.position++
+}
+
+func TestRebalanceDryRunAndRebalance(t *testing.T) {
+	m := newBasketMarket()
+	s := testRebalance()
+
+	res, _, _ := s.Command(DryRun, nil)
+	if !strings.Contains(res.Message, "no plan yet") {
+		t.Fatalf("dry run without plan: %v", res.Message)
+	}
+
+	// plan is refreshed while strategy is offline
+	stepRebalance(t, m, s)
+	if s.Value != 8000 || s.Drift != 15 || len(m.filled) != 0 {
+		t.Fatalf("wrong plan: %v", s.Json())
+	}
+	res, ok, err := s.Command(DryRun, nil)
+	if !ok || err != nil {
+		t.Fatalf("dry_run: %v", err)
+	}
+	if !strings.Contains(res.Message, "at 2021-01-04T10:01:00Z") ||
+		!strings.Contains(res.Message, "sell AAAA 10 lots by 100; buy BBBB 2 lots by 50") {
+		t.Fatalf("wrong dry run: %v", res.Message)
+	}
+
+	s.Command(smp.StartCommand, nil)
+	s.Command(RebalanceNow, nil)
+
+	// sells are sent before buys
+	stepRebalance(t, m, s)
+	if s.Phase != RebalanceSelling || len(s.Orders) != 1 || s.Orders[0].Buy {
+		t.Fatalf("wrong sell phase: %v", s.Json())
+	}
+
+	stepRebalance(t, m, s)
+	if s.Phase != RebalanceBuying || len(s.Orders) != 1 || !s.Orders[0].Buy {
+		t.Fatalf("wrong buy phase: %v", s.Json())
+	}
+
+	// BBBB is under target weight by lot rounding
+	stepRebalance(t, m, s)
+	if s.Phase != RebalanceIdle || s.Rebalances != 1 || s.Drift != 2.5 || len(s.Plan) != 0 {
+		t.Fatalf("wrong state after rebalance: %v", s.Json())
+	}
+	if m.account.Position("", "AAAA").Quantity != 40 || m.account.Position("", "BBBB").Quantity != 6 ||
+		m.account.Money[""] != 1000 {
+		t.Fatalf("wrong account after rebalance: %v", m.account)
+	}
+}
+
+func TestRebalanceDrift(t *testing.T) {
+	m := newBasketMarket()
+	s := testRebalance()
+	s.IsOnline = true
+	s.DriftThreshold = 20
+
+	stepRebalance(t, m, s)
+	if s.Phase != RebalanceIdle {
+		t.Fatalf("rebalance under drift threshold: %v", s.Json())
+	}
+
+	s.DriftThreshold = 10
+	stepRebalance(t, m, s)
+	if s.Phase != RebalanceSelling {
+		t.Fatalf("no rebalance over drift threshold: %v", s.Json())
+	}
+}
+
+func TestRebalanceSchedule(t *testing.T) {
+	m := newBasketMarket()
+	s := testRebalance()
+	s.IsOnline = true
+	s.MonthDay = 31
+
+	stepRebalance(t, m, s)
+	if s.Phase != RebalanceIdle || !s.NextTime.Equal(time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("wrong schedule: %v", s.Json())
+	}
+	if n := s.next(s.NextTime); !n.Equal(time.Date(2021, 2, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("wrong next time after short month: %v", n)
+	}
+}
+
+func TestRebalanceSetWeight(t *testing.T) {
+	s := testRebalance()
+
+	_, ok, err := s.Command(SetWeight, map[string]string{"t": "CCCC", "w": "0.2"})
+	if ok || err == nil || err.Code != 500002613 {
+		t.Fatalf("sum of weights over 1 should fail: %v", err)
+	}
+
+	// target with 0 weight is kept until position is sold
+	_, ok, err = s.Command(SetWeight, map[string]string{"t": "AAAA", "w": "0"})
+	if !ok || err != nil || len(s.Targets) != 2 || s.Targets[0].Weight != 0 {
+		t.Fatalf("target with 0 weight is not kept: %v", err)
+	}
+
+	_, ok, err = s.Command(SetWeight, map[string]string{"t": "CCCC", "w": "0.2"})
+	if !ok || err != nil || len(s.Targets) != 3 || s.Targets[2].Weight != 0.2 {
+		t.Fatalf("target is not added: %v", err)
+	}
+}
+
+func TestRebalanceZeroWeight(t *testing.T) {
+	m := newBasketMarket()
+	s := testRebalance()
+	s.Command(SetWeight, map[string]string{"t": "BBBB", "w": "0"})
+
+	// position of BBBB is counted in value and planned to sell
+	stepRebalance(t, m, s)
+	if s.Value != 8000 || len(s.Targets) != 2 || s.Targets[1].Drift != 25 {
+		t.Fatalf("wrong plan: %v", s.Json())
+	}
+	res, _, _ := s.Command(DryRun, nil)
+	if !strings.Contains(res.Message, "sell AAAA 10 lots by 100; sell BBBB 4 lots by 50") {
+		t.Fatalf("wrong dry run: %v", res.Message)
+	}
+
+	s.Command(smp.StartCommand, nil)
+	s.Command(RebalanceNow, nil)
+	stepRebalance(t, m, s)
+	if s.Phase != RebalanceSelling || len(s.Orders) != 2 || len(s.Targets) != 2 {
+		t.Fatalf("wrong sell phase: %v", s.Json())
+	}
+
+	// target is removed after position is sold
+	stepRebalance(t, m, s)
+	if len(s.Targets) != 1 || s.Targets[0].Ticker != "AAAA" {
+		t.Fatalf("target with 0 weight is not removed: %v", s.Json())
+	}
+	if m.account.Position("", "AAAA").Quantity != 40 || m.account.Position("", "BBBB").Quantity != 0 ||
+		m.account.Money[""] != 4000 {
+		t.Fatalf("wrong account after rebalance: %v", m.account)
+	}
+}
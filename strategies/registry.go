@@ -0,0 +1,36 @@
+package strategies
+
+import (
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+// Стратегии пакета и миграции их версий схемы в smp.GlobalStrategyRegistry
+
+func init() {
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &TakeProfitBuy{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &TakeProfitSell{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &WingedSwing{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &WingedSwingGroup{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &Grid{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &TrailingStop{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &DCA{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &Pairs{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &MACrossover{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &Breakout{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &TWAP{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &VWAP{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &Iceberg{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &Rebalance{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &DividendCapture{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &Bracket{} })
+	smp.GlobalStrategyRegistry.Add(func() smp.Strategy { return &Composite{} })
+
+	smp.GlobalStrategyRegistry.AddMigration("winged_swing", 0, migrateWingedSwing0)
+	smp.GlobalStrategyRegistry.AddMigration("winged_swing", 1, migrateWingedSwing1)
+	smp.GlobalStrategyRegistry.AddMigration("winged_swing_group", 0, migrateWingedSwingGroup0)
+	smp.GlobalStrategyRegistry.AddMigration("winged_swing_group", 1, migrateWingedSwingGroup1)
+	smp.GlobalStrategyRegistry.AddMigration("grid", 0, migrateGrid0)
+	for _, tp := range []string{"dca", "iceberg", "twap", "vwap"} {
+		smp.GlobalStrategyRegistry.AddMigration(tp, 0, migratePaused0(tp))
+	}
+}
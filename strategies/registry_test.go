@@ -0,0 +1,112 @@
+package strategies
+
+import (
+	"testing"
+	"time"
+
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+func TestRegistryInfos(t *testing.T) {
+	infos := smp.GlobalStrategyRegistry.Infos(smp.DefaultLocale)
+	if len(infos) != 17 {
+		t.Fatalf("17 strategies expected, got %v", len(infos))
+	}
+	for i, info := range infos {
+		if i > 0 && infos[i-1].Type >= info.Type {
+			t.Fatalf("infos are not sorted: %v after %v", info.Type, infos[i-1].Type)
+		}
+		if info.Description == "" || len(info.Commands) == 0 {
+			t.Fatalf("no description or commands of %v", info.Type)
+		}
+		if _, ok := info.Commands[smp.StartCommand]; !ok {
+			t.Fatalf("no start command of %v", info.Type)
+		}
+	}
+}
+
+func TestRegistryBuild(t *testing.T) {
+	s, err := smp.GlobalStrategyRegistry.Build("trailing_stop", map[string]string{
+		"name":       "ts",
+		"ticker":     "TTTT",
+		"volume":     "5",
+		"trail_mode": "percent",
+		"trail":      "2.5",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, ok := s.(*TrailingStop)
+	if !ok || ts.Name != "ts" || ts.Ticker != "TTTT" || ts.Volume != 5 || ts.TrailMode != TrailPercent ||
+		ts.Trail != 2.5 || ts.IsOnline {
+		t.Fatalf("wrong trailing stop: %v", s.Json())
+	}
+
+	// fields of embedded ParentOrder
+	s, err = smp.GlobalStrategyRegistry.Build("twap", map[string]string{
+		"quantity": "20",
+		"from":     "2021-01-04T10:00:00Z",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tw := s.(*TWAP); tw.Quantity != 20 || !tw.From.Equal(time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("wrong twap: %v", s.Json())
+	}
+
+	if _, err := smp.GlobalStrategyRegistry.Build("unknown", nil); err == nil || err.Code != 500003400 {
+		t.Fatalf("unknown type error expected, got %v", err)
+	}
+	if _, err := smp.GlobalStrategyRegistry.Build("bracket", map[string]string{"vol": "1"}); err == nil || err.Code != 500003402 {
+		t.Fatalf("unknown param error expected, got %v", err)
+	}
+	if _, err := smp.GlobalStrategyRegistry.Build("bracket", map[string]string{"volume": "x"}); err == nil || err.Code != 500003404 {
+		t.Fatalf("wrong value error expected, got %v", err)
+	}
+	if _, err := smp.GlobalStrategyRegistry.Build("bracket", map[string]string{"take": "1"}); err == nil || err.Code != 500003403 {
+		t.Fatalf("not settable param error expected, got %v", err)
+	}
+
+	// state is not set by commands
+	for _, name := range []string{"in_market", "is_online", "lifecycle"} {
+		if _, err := smp.GlobalStrategyRegistry.Build("bracket", map[string]string{name: "1"}); err == nil || err.Code != 500003403 {
+			t.Fatalf("%v: not settable param error expected, got %v", name, err)
+		}
+	}
+
+	// bounds and values of params of commands
+	_, err = smp.GlobalStrategyRegistry.Build("dca", map[string]string{"schedule": "weekly", "weekday": "9"})
+	if err == nil || err.Code != 500003404 || err.InternalError == nil || err.InternalError.Code != 500003604 {
+		t.Fatalf("max of weekday error expected, got %v", err)
+	}
+	_, err = smp.GlobalStrategyRegistry.Build("dca", map[string]string{"schedule": "yearly"})
+	if err == nil || err.Code != 500003404 || err.InternalError == nil || err.InternalError.Code != 500003605 {
+		t.Fatalf("values of schedule error expected, got %v", err)
+	}
+}
+
+func TestRegistrySaveLoad(t *testing.T) {
+	s := testComposite(t)
+	s.IsOnline = true
+
+	c, err := smp.GlobalStrategyRegistry.Save(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Type != "composite" {
+		t.Fatalf("wrong type of container: %v", c.Type)
+	}
+
+	out, err := smp.GlobalStrategyRegistry.Load(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Json() != s.Json() {
+		t.Fatalf("loaded strategy is different:\n%v\n%v", out.Json(), s.Json())
+	}
+
+	c.Data = []byte("{")
+	if _, err := smp.GlobalStrategyRegistry.Load(c); err == nil || err.Code != 500003411 {
+		t.Fatalf("unmarshal error expected, got %v", err)
+	}
+}
@@ -20,6 +20,11 @@ type StopLostBank struct {
 	mx    mfs.PMutex
 }
 
+// StopLostBankUser - strategy that uses StopLostBank shared by its container (WingedSwingGroup, Composite)
+type StopLostBankUser interface {
+	UseStopLostBank(slb *StopLostBank)
+}
+
 func MakeKey(instrumentId string, ticker string) string {
 	return instrumentId + "-" + ticker
 }
@@ -68,7 +73,7 @@ func (slb *StopLostBank) RequestToBuyDO(p smp.StepParams, instrumentId string, t
 		slb.Items[key] = ii
 	}
 
-	ii.RequestToBuy += ii.RequestToBuy
+	ii.RequestToBuy += cnt
 
 	return nil
 }
@@ -90,6 +95,12 @@ func (slb *StopLostBank) Sell(p smp.StepParams, instrumentId string, ticker stri
 	ii.Cnt += cnt
 	ii.SellCnt += cnt
 	ii.SellPrice = smp.Round(ii.SellPrice+float64(cnt)*price, 6)
+	// sale satisfies request to buy
+	if ii.RequestToBuy > cnt {
+		ii.RequestToBuy -= cnt
+	} else {
+		ii.RequestToBuy = 0
+	}
 
 	return nil
 }
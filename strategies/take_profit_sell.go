@@ -2,7 +2,6 @@ package strategies
 
 import (
 	"encoding/json"
-	"strconv"
 
 	"github.com/myfantasy/mft"
 	smp "github.com/myfantasy/stock_market_primitives"
@@ -30,6 +29,16 @@ type TakeProfitSell struct {
 
 	InMarketWait      int     `json:"in_market_wait"`
 	InMarketPriceWait float64 `json:"in_market_price_wait"`
+
+	// Rearm - start again after Volume is filled
+	Rearm     bool `json:"rearm"`
+	Iteration int  `json:"iteration"`
+
+	// Total, TotalPrice - lots and amount of finished iterations
+	Total      int     `json:"total"`
+	TotalPrice float64 `json:"total_price"`
+
+	Lifecycle smp.Lifecycle `json:"lifecycle"`
 }
 
 func (s *TakeProfitSell) Type() string {
@@ -41,9 +50,7 @@ func (s *TakeProfitSell) String() string {
 }
 
 func (s *TakeProfitSell) Status() smp.StartegyStatus {
-	return smp.StartegyStatus{
-		IsOnline: s.IsOnline,
-	}
+	return s.Lifecycle.Status(s.IsOnline)
 }
 func (s *TakeProfitSell) Json() string {
 	b, err := json.MarshalIndent(s, "", "  ")
@@ -53,119 +60,100 @@ func (s *TakeProfitSell) Json() string {
 
 	return string(b)
 }
-func (s *TakeProfitSell) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
-	if cmd == smp.ShowCommand {
-		res.Message = s.String()
-		return res, true, nil
-	}
-
-	if cmd == smp.StartCommand {
-		s.IsOnline = true
-		return res, true, nil
-	}
 
-	if cmd == smp.StopCommand {
-		s.IsOnline = false
-		return res, true, nil
-	}
+// commands - commands of strategy
+func (s *TakeProfitSell) commands() smp.CommandDefs {
+	return lifecycleCommands(showCommand("Отобразить", s.String), s.Lifecycle.Commands(s.Type(), s, &s.IsOnline), smp.CommandDefs{
+		{Command: SetLevel, Description: "Установить уровень", Example: "set_level 345.67",
+			Params: []smp.Param{smp.FloatParam("", "уровень", &s.LevelPrice)}},
+		{Command: SetVolume, Description: "Установить объём", Example: "set_vol 25",
+			Params: []smp.Param{smp.IntParam("", "объём", &s.Volume).WithMin(0)}},
+		{Command: SetStayInMarket, Description: "Оставаться в рынке [выставлять заявку не дожидаясь приближения цены]",
+			Example: "stay_in_market true",
+			Params:  []smp.Param{smp.BoolParam("", "true/false", &s.StayInMarket)}},
+		{Command: SetRearm, Description: "Начинать заново после исполнения всего объёма", Example: "rearm true",
+			Params: []smp.Param{smp.BoolParam("", "true/false", &s.Rearm)}},
+	})
+}
 
-	if cmd == SetLevel {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseFloat(fS, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000211, er0, cmd, fS)
-			}
-			s.LevelPrice = f
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000210, cmd)
-		}
-	}
+func (s *TakeProfitSell) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	return s.commands().Do(s.Type(), cmd, params)
+}
 
-	if cmd == SetVolume {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseInt(fS, 10, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000221, er0, cmd, fS)
-			}
-			s.Volume = int(f)
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000220, cmd)
-		}
-	}
-	if cmd == SetStayInMarket {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseBool(fS)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000231, er0, cmd, fS)
-			}
-			s.StayInMarket = f
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000230, cmd)
-		}
-	}
+func (s *TakeProfitSell) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
 
-	return res, false, smp.GenerateError(500000200, cmd)
+func (s *TakeProfitSell) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), `take_profit_sell - стратегия, продажа профита
+	При достижении цены указанного уровня (цена продажи на рынке) выставляется заявка на продажу по указанной цене
+	При цене ниже указанной не происходит ничего
+	Отменённая (истёкшая) заявка выставляется заново на остаток объёма
+	При rearm после исполнения всего объёма стратегия начинает заново`)
 }
 
-func (s *TakeProfitSell) AllowCommands() map[smp.Command]smp.CommandInfo {
-	return map[smp.Command]smp.CommandInfo{
-		smp.ShowCommand:  {0, "Отобразить", "", ""},
-		smp.StartCommand: {1, "Старт", "", ""},
-		smp.StopCommand:  {2, "Стоп", "", ""},
-		SetLevel:         {3, "Установить уровень", "параметр: уровень", "set_level 345.67"},
-		SetVolume:        {4, "Установить объём", "параметр: объём", "set_vol 25"},
-		SetStayInMarket:  {5, "Оставаться в рынке [выставлять заявку не дожидаясь приближения цены]", "параметр: true/false", "stay_in_market true"},
+// ActiveOrders - order of strategy
+func (s *TakeProfitSell) ActiveOrders() []smp.ActiveOrder {
+	if s.OrderId == "" {
+		return nil
 	}
+	return []smp.ActiveOrder{{InstrumentId: s.InstrumentId, Ticker: s.Ticker, OrderId: s.OrderId, Buy: false}}
 }
 
-func (s *TakeProfitSell) Description() string {
-	return `take_profit_sell - стратегия, продажа профита
-	При достижении цены указанного уровня (цена продажи на рынке) выставляется заявка на продажу по указанной цене
-	При цене ниже указанной не происходит ничего`
+// ResetState - clears filled volume and iterations (strategy starts again)
+func (s *TakeProfitSell) ResetState() (err *mft.Error) {
+	s.InMarket = 0
+	s.InMarketPrice = 0
+	s.InMarketWait = 0
+	s.InMarketPriceWait = 0
+	s.Iteration = 0
+	s.Total = 0
+	s.TotalPrice = 0
+	return nil
 }
 
 func (s *TakeProfitSell) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
-	if s.Volume == 0 {
-		return meta, nil
-	}
-
 	if s.OrderId != "" {
 		status, prices, err := p.StatusSellOrder(s.InstrumentId, s.Ticker, s.OrderId, nil)
 		if err != nil {
 			return meta, smp.GenerateErrorE(500000302, err)
 		}
 
-		cnt := 0
-		price := 0.0
-		for _, pr := range prices {
-			cnt += pr.Count
-			price += pr.Price * float64(pr.Count)
-		}
+		cnt, price := lotPricesSum(prices)
 
-		meta.HasChanges = true
 		if status == smp.Complete || status == smp.Canceled {
+			// canceled (expired) order is placed again for the rest of volume
+			meta.HasChanges = true
 			s.InMarket += cnt
-			s.InMarketPrice += price
+			s.InMarketPrice = smp.Round(s.InMarketPrice+price, 6)
 			s.OrderId = ""
 			s.InMarketWait = 0
 			s.InMarketPriceWait = 0
-		} else {
+		} else if s.InMarketWait != cnt {
+			meta.HasChanges = true
 			s.InMarketWait = cnt
-			s.InMarketPriceWait = price
+			s.InMarketPriceWait = smp.Round(price, 6)
 		}
 	}
 
-	if !s.IsOnline {
+	if s.OrderId == "" && s.Volume > 0 && s.InMarket >= s.Volume && s.Rearm {
+		meta.HasChanges = true
+		s.Iteration++
+		s.Total += s.InMarket
+		s.TotalPrice = smp.Round(s.TotalPrice+s.InMarketPrice, 6)
+		s.InMarket = 0
+		s.InMarketPrice = 0
+	}
+
+	if halt, err := s.Lifecycle.Step(p, s, &meta); halt || err != nil {
+		return meta, err
+	}
+
+	if !s.IsOnline || s.Volume == 0 {
 		return meta, nil
 	}
 
-	if s.OrderId != "" {
+	if s.OrderId != "" || s.InMarket >= s.Volume {
 		return meta, nil
 	}
 
@@ -182,15 +170,13 @@ func (s *TakeProfitSell) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.
 		return meta, nil
 	}
 
-	if s.InMarket < s.Volume && s.OrderId != "" {
-		if s.StayInMarket ||
-			ob.BuyPrice() >= s.LevelPrice {
-			meta.HasChanges = true
-			s.OrderId, err = p.SellByPrice(s.InstrumentId, s.Ticker, s.Volume-s.InMarket, s.LevelPrice, nil)
-			if err != nil {
-				s.OrderId = ""
-				return meta, smp.GenerateErrorE(500000301, err)
-			}
+	if s.StayInMarket ||
+		ob.BuyPrice() >= s.LevelPrice {
+		meta.HasChanges = true
+		s.OrderId, err = p.SellByPrice(s.InstrumentId, s.Ticker, s.Volume-s.InMarket, s.LevelPrice, nil)
+		if err != nil {
+			s.OrderId = ""
+			return meta, smp.GenerateErrorE(500000301, err)
 		}
 	}
 	return meta, nil
@@ -0,0 +1,163 @@
+package strategies
+
+import (
+	"testing"
+
+	smp "github.com/myfantasy/stock_market_primitives"
+	"github.com/myfantasy/stock_market_primitives/market"
+)
+
+func TestTakeProfitBuyPartialFill(t *testing.T) {
+	p := &market.VirtualMarket{
+		Candles: testCandles(
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{101, 101, 99, 99},
+			[4]float64{101, 101, 99, 99},
+			[4]float64{101, 101, 99, 99},
+			[4]float64{101, 101, 99, 99},
+			[4]float64{101, 101, 99, 99},
+		),
+	}
+	s := &TakeProfitBuy{
+		Ticker:     "TTTT",
+		Volume:     150,
+		LevelPrice: 100,
+		IsOnline:   true,
+	}
+
+	step := func() {
+		t.Helper()
+		p.DoStep()
+		_, err := s.Step(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// price is over level
+	step()
+	if s.OrderId != "" {
+		t.Fatal("order is placed over level")
+	}
+
+	step()
+	if s.OrderId == "" {
+		t.Fatal("order is not placed")
+	}
+
+	step()
+	if s.InMarketWait != 100 || s.InMarket != 0 {
+		t.Fatalf("wrong state after partial fill: %v", s.Json())
+	}
+
+	// order is expired: the rest is placed again
+	orderId := s.OrderId
+	_, err := p.CancelBuyOrder("", "TTTT", orderId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step()
+	if s.InMarket != 100 || s.InMarketPrice != 10000 || s.OrderId == "" || s.OrderId == orderId {
+		t.Fatalf("wrong state after expiry: %v", s.Json())
+	}
+
+	step()
+	if s.InMarket != 150 || s.InMarketPrice != 15000 || s.OrderId != "" {
+		t.Fatalf("wrong state after fill: %v", s.Json())
+	}
+
+	// volume is filled: nothing to do
+	step()
+	if s.OrderId != "" || s.Iteration != 0 {
+		t.Fatalf("order is placed after fill: %v", s.Json())
+	}
+}
+
+func TestTakeProfitSellRearm(t *testing.T) {
+	p := &market.VirtualMarket{
+		Candles: testCandles(
+			[4]float64{104, 104, 103, 104},
+			[4]float64{104, 104, 103, 104},
+			[4]float64{104, 106, 104, 106},
+			[4]float64{104, 106, 104, 106},
+			[4]float64{104, 106, 104, 106},
+		),
+	}
+	s := &TakeProfitSell{
+		Ticker:     "TTTT",
+		Volume:     50,
+		LevelPrice: 105,
+		Rearm:      true,
+		IsOnline:   true,
+	}
+
+	step := func() {
+		t.Helper()
+		p.DoStep()
+		_, err := s.Step(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	step()
+	if s.OrderId != "" {
+		t.Fatal("order is placed under level")
+	}
+
+	step()
+	if s.OrderId == "" {
+		t.Fatal("order is not placed")
+	}
+
+	// order is filled and placed again
+	step()
+	if s.Iteration != 1 || s.Total != 50 || s.TotalPrice != 5250 || s.InMarket != 0 || s.OrderId == "" {
+		t.Fatalf("wrong state after rearm: %v", s.Json())
+	}
+
+	step()
+	if s.Iteration != 2 || s.Total != 100 {
+		t.Fatalf("wrong state after second rearm: %v", s.Json())
+	}
+}
+
+func TestTakeProfitBuyZeroVolume(t *testing.T) {
+	p := &market.VirtualMarket{
+		Candles: testCandles(
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{101, 101, 99, 99},
+			[4]float64{101, 101, 99, 99},
+		),
+	}
+	s := &TakeProfitBuy{
+		Ticker:     "TTTT",
+		Volume:     50,
+		LevelPrice: 100,
+		IsOnline:   true,
+	}
+
+	for i := 0; i < 2; i++ {
+		p.DoStep()
+		if _, err := s.Step(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.OrderId == "" {
+		t.Fatal("order is not placed")
+	}
+
+	// order placed before set_vol 0 is still polled and stop waits for it
+	if _, err := smp.ExecuteCommands(s, "set_vol 0; stop"); err != nil {
+		t.Fatal(err)
+	}
+	p.DoStep()
+	if _, err := s.Step(p); err != nil {
+		t.Fatal(err)
+	}
+	if s.InMarket != 50 || s.OrderId != "" || s.Status().State != smp.LifecycleStopped {
+		t.Fatalf("fill is not recorded or strategy is not stopped: %v", s.Json())
+	}
+}
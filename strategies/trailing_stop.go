@@ -0,0 +1,328 @@
+package strategies
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+//go:generate mfjson trailing_stop.go
+
+// Скользящий стоп (TrailingStop)
+
+var (
+	_ smp.Strategy = &TrailingStop{}
+)
+
+type TrailMode string
+
+const (
+	// TrailAmount - stop is Trail under the peak price
+	TrailAmount TrailMode = "amount"
+	// TrailPercent - stop is Trail percents under the peak price
+	TrailPercent TrailMode = "percent"
+	// TrailATR - stop is Trail * ATR under the peak price
+	TrailATR TrailMode = "atr"
+)
+
+//mfjson:interface smp.strategies.trailing_stop
+type TrailingStop struct {
+	Name string `json:"name"`
+
+	InstrumentId string `json:"instrument_id"`
+	Ticker       string `json:"ticker"`
+
+	// Volume - protected lots
+	Volume     int     `json:"volume"`
+	EntryPrice float64 `json:"entry_price"`
+
+	TrailMode TrailMode `json:"trail_mode"`
+	Trail     float64   `json:"trail"`
+	// AtrPeriod, AtrFrame - count of candles and candle frame in minutes for TrailATR
+	AtrPeriod int `json:"atr_period"`
+	AtrFrame  int `json:"atr_frame"`
+
+	// ActivationProfit - stop is armed when price is higher than EntryPrice + ActivationProfit
+	ActivationProfit float64 `json:"activation_profit"`
+
+	// TakePosition - Volume and EntryPrice are taken from account on next step
+	TakePosition bool `json:"take_position"`
+
+	IsOnline bool `json:"is_online"`
+
+	IsArmed   bool    `json:"is_armed"`
+	Peak      float64 `json:"peak"`
+	StopPrice float64 `json:"stop_price"`
+	OrderId   string  `json:"order_id"`
+
+	Sold      int     `json:"sold"`
+	SoldPrice float64 `json:"sold_price"`
+
+	Lifecycle smp.Lifecycle `json:"lifecycle"`
+}
+
+func (s *TrailingStop) Type() string {
+	return "trailing_stop"
+}
+
+func (s *TrailingStop) String() string {
+	return "trailing_stop"
+}
+
+func (s *TrailingStop) Status() smp.StartegyStatus {
+	return s.Lifecycle.Status(s.IsOnline)
+}
+func (s *TrailingStop) Json() string {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return string(b)
+}
+
+// commands - commands of strategy
+func (s *TrailingStop) commands() smp.CommandDefs {
+	return lifecycleCommands(showCommand("Отобразить", s.String), s.Lifecycle.Commands(s.Type(), s, &s.IsOnline), smp.CommandDefs{
+		{Command: SetVolume, Description: "Установить защищаемый объём", Example: "set_vol 25",
+			Params: []smp.Param{smp.IntParam("", "объём (кол-во лотов)", &s.Volume).WithMin(0)}},
+		{Command: SetTrail, Description: "Установить отступ стопа от максимальной цены", Example: "set_trail 2.5",
+			Params: []smp.Param{smp.FloatParam("", "цена, процент или множитель ATR", &s.Trail).WithMin(0)}},
+		{Command: SetTrailMode, Description: "Установить тип отступа (amount - цена, percent - процент, atr - множитель ATR)",
+			Example: "set_trail_mode atr",
+			Params: []smp.Param{smp.StringParam("", "тип отступа", &s.TrailMode).
+				WithValues(string(TrailAmount), string(TrailPercent), string(TrailATR))}},
+		{Command: SetAtrPeriod, Description: "Установить кол-во свечей для ATR", Example: "set_atr_period 14",
+			Params: []smp.Param{smp.IntParam("", "кол-во свечей", &s.AtrPeriod).WithMin(1)}},
+		{Command: SetAtrFrame, Description: "Установить размер свечи для ATR", Example: "set_atr_frame 60",
+			Params: []smp.Param{smp.IntParam("", "минуты", &s.AtrFrame).WithMin(1)}},
+		{Command: SetActivationProfit, Description: "Установить прибыль, после которой включается стоп",
+			Example: "set_activation_profit 10",
+			Params:  []smp.Param{smp.FloatParam("", "цена", &s.ActivationProfit)}},
+		{Command: SetEntryPrice, Description: "Установить цену входа", Example: "set_entry_price 345.67",
+			Params: []smp.Param{smp.FloatParam("", "цена", &s.EntryPrice)}},
+		{Command: TakePosition, Description: "Взять объём и цену входа из позиции счёта", Example: "take_position",
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				s.TakePosition = true
+				return res, nil
+			}},
+	})
+}
+
+func (s *TrailingStop) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	return s.commands().Do(s.Type(), cmd, params)
+}
+
+func (s *TrailingStop) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
+
+func (s *TrailingStop) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), `trailing_stop - стратегия, скользящий стоп
+	Стоп на продажу выставляется под максимальной ценой и передвигается за ней
+	При падении цены на отступ позиция продаётся`)
+}
+
+// atr - ATR of candles before order book time
+func (s *TrailingStop) atr(p smp.StepParams, ob *smp.OrderBook) (float64, *mft.Error) {
+	period := s.AtrPeriod
+	if period <= 0 {
+		period = 14
+	}
+
+	cs, err := historyCandles(p, s.InstrumentId, s.Ticker, ob.Time, s.AtrFrame, period+1)
+	if err != nil {
+		return 0, err
+	}
+	return cs.ATR(period), nil
+}
+
+// trailDistance - distance between peak and stop
+func (s *TrailingStop) trailDistance(p smp.StepParams, ob *smp.OrderBook) (float64, *mft.Error) {
+	switch s.TrailMode {
+	case TrailPercent:
+		return s.Peak * s.Trail / 100, nil
+	case TrailATR:
+		atr, err := s.atr(p, ob)
+		if err != nil {
+			return 0, err
+		}
+		return atr * s.Trail, nil
+	}
+	return s.Trail, nil
+}
+
+// applyOrder - applies status of stop (sell) order
+func (s *TrailingStop) applyOrder(status smp.StatusOrder, prices []smp.LotPrices, meta *smp.MetaForStep) {
+	if status != smp.Complete && status != smp.Canceled {
+		return
+	}
+
+	cnt, price := lotPricesSum(prices)
+	meta.HasChanges = true
+	s.OrderId = ""
+	s.Volume -= cnt
+	s.Sold += cnt
+	s.SoldPrice = smp.Round(s.SoldPrice+price, 6)
+	if cnt > 0 {
+		meta.OpDescr = append(meta.OpDescr, "Stop is executed")
+	}
+}
+
+// ActiveOrders - stop order (or sell order by market) of strategy
+func (s *TrailingStop) ActiveOrders() []smp.ActiveOrder {
+	if s.OrderId == "" {
+		return nil
+	}
+	return []smp.ActiveOrder{{InstrumentId: s.InstrumentId, Ticker: s.Ticker, OrderId: s.OrderId}}
+}
+
+// FlattenPosition - sells protected lots by market
+func (s *TrailingStop) FlattenPosition(p smp.StepParams, meta *smp.MetaForStep) (done bool, err *mft.Error) {
+	if s.Volume <= 0 {
+		return true, nil
+	}
+
+	s.OrderId, err = p.SellByMarket(s.InstrumentId, s.Ticker, s.Volume, &smp.MetaForOperations{NameOfStrategy: s.Name})
+	if err != nil {
+		s.OrderId = ""
+		return false, smp.GenerateErrorE(500001208, err)
+	}
+	meta.HasChanges = true
+	meta.OpDescr = append(meta.OpDescr, "Sell by market (flatten)")
+	return false, nil
+}
+
+// ResetState - disarms stop and clears peak and sold lots (protected Volume is kept)
+func (s *TrailingStop) ResetState() (err *mft.Error) {
+	s.IsArmed = false
+	s.Peak = 0
+	s.StopPrice = 0
+	s.Sold = 0
+	s.SoldPrice = 0
+	return nil
+}
+
+func (s *TrailingStop) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
+	meta.Name = s.Name
+
+	if s.OrderId != "" {
+		status, prices, err := p.StatusSellOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name, IsStopLoss: true})
+		if err != nil {
+			return meta, smp.GenerateErrorE(500001202, err)
+		}
+		s.applyOrder(status, prices, &meta)
+	}
+
+	if s.TakePosition {
+		pp, ok := p.(smp.PortfolioStepParams)
+		if !ok {
+			return meta, smp.GenerateError(500001205)
+		}
+		position, err := pp.GetPosition(s.InstrumentId, s.Ticker)
+		if err != nil {
+			return meta, smp.GenerateErrorE(500001206, err)
+		}
+		meta.HasChanges = true
+		s.TakePosition = false
+		s.Volume = position.Free()
+		if s.OrderId != "" {
+			// lots of current stop are blocked
+			s.Volume = position.Quantity
+		}
+		s.EntryPrice = position.AveragePrice
+	}
+
+	if halt, err := s.Lifecycle.Step(p, s, &meta); halt || err != nil {
+		return meta, err
+	}
+
+	if !s.IsOnline || s.Volume <= 0 {
+		return meta, nil
+	}
+
+	ob, err := p.GetOrderBook(s.InstrumentId, s.Ticker)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500001200, err)
+	}
+
+	if ob.TradeStatus != smp.NormalTrading {
+		return meta, nil
+	}
+
+	price := ob.Price()
+	if price > s.Peak {
+		meta.HasChanges = true
+		s.Peak = price
+	}
+
+	if !s.IsArmed {
+		if s.ActivationProfit > 0 && price < s.EntryPrice+s.ActivationProfit {
+			return meta, nil
+		}
+		meta.HasChanges = true
+		meta.OpDescr = append(meta.OpDescr, "Stop is armed")
+		s.IsArmed = true
+	}
+
+	distance, err := s.trailDistance(p, ob)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500001201, err)
+	}
+	if distance <= 0 {
+		return meta, nil
+	}
+	stop := smp.Round(s.Peak-distance, 6)
+
+	sp, ok := p.(smp.StopOrderStepParams)
+	if !ok {
+		// market does not support stop orders: sell by market when price falls under stop
+		s.StopPrice = math.Max(s.StopPrice, stop)
+		if s.OrderId == "" && ob.SellPrice() <= s.StopPrice {
+			meta.HasChanges = true
+			meta.IsStopLoss = true
+			s.OrderId, err = p.SellByMarket(s.InstrumentId, s.Ticker, s.Volume, &smp.MetaForOperations{NameOfStrategy: s.Name, IsStopLoss: true})
+			if err != nil {
+				s.OrderId = ""
+				return meta, smp.GenerateErrorE(500001203, err)
+			}
+		}
+		return meta, nil
+	}
+
+	if s.OrderId != "" && stop <= s.StopPrice {
+		return meta, nil
+	}
+
+	if s.OrderId != "" {
+		// stop is moved up: cancel and place again
+		_, err := sp.CancelSellOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+		if err != nil {
+			return meta, smp.GenerateErrorE(500001207, err)
+		}
+		status, prices, err := sp.StatusSellOrder(s.InstrumentId, s.Ticker, s.OrderId, &smp.MetaForOperations{NameOfStrategy: s.Name})
+		if err != nil {
+			return meta, smp.GenerateErrorE(500001202, err)
+		}
+		if status == smp.Wait {
+			// cancel is not finished
+			return meta, nil
+		}
+		s.applyOrder(status, prices, &meta)
+		if s.Volume <= 0 {
+			return meta, nil
+		}
+	}
+
+	meta.HasChanges = true
+	s.StopPrice = math.Max(s.StopPrice, stop)
+	s.OrderId, err = sp.SellStopByMarket(s.InstrumentId, s.Ticker, s.Volume, s.StopPrice, &smp.MetaForOperations{NameOfStrategy: s.Name, IsStopLoss: true})
+	if err != nil {
+		s.OrderId = ""
+		return meta, smp.GenerateErrorE(500001204, err)
+	}
+
+	return meta, nil
+}
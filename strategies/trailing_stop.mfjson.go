@@ -0,0 +1,19 @@
+// Code generated by mfjson for marshaling/unmarshaling. DO NOT EDIT.
+// https://github.com/myfantasy/json
+
+package strategies
+
+import (
+	mfj "github.com/myfantasy/json"
+)
+func (obj *TrailingStop) UnmarshalJSONTypeName() string {
+	return "smp.strategies.trailing_stop"
+}
+
+func init() {
+	mfj.GlobalStructFactory.Add("smp.strategies.trailing_stop", func() mfj.JsonInterfaceMarshaller { return &TrailingStop{} })
+	mfj.GlobalStructFactory.AddNil("smp.strategies.trailing_stop", func() mfj.JsonInterfaceMarshaller {
+		var out *TrailingStop
+		return out
+	})
+}
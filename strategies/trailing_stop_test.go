@@ -0,0 +1,155 @@
+package strategies
+
+import (
+	"testing"
+
+	smp "github.com/myfantasy/stock_market_primitives"
+	"github.com/myfantasy/stock_market_primitives/market"
+)
+
+// plainStepParams - market without stop orders
+type plainStepParams struct {
+	smp.StepParams
+}
+
+func TestTrailingStopMove(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{101, 101.5, 100.5, 101},
+			[4]float64{101, 101.5, 100.5, 101},
+			[4]float64{104, 104.5, 103.5, 104},
+			[4]float64{106, 106, 105, 106},
+			[4]float64{105, 105.5, 104.5, 105},
+			[4]float64{104.5, 104.5, 103, 103},
+			[4]float64{103, 103, 102, 102},
+		),
+	}
+	p.Account.Trade(nil, "", "TTTT", true, 10, 100)
+
+	s := &TrailingStop{
+		Ticker:           "TTTT",
+		Trail:            2,
+		ActivationProfit: 3,
+		IsOnline:         true,
+	}
+	_, ok, err := s.Command(TakePosition, nil)
+	if err != nil || !ok {
+		t.Fatal(err)
+	}
+
+	step := func() {
+		t.Helper()
+		p.DoStep()
+		_, err := s.Step(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	step()
+	if s.Volume != 10 || s.EntryPrice != 100 || s.IsArmed || s.OrderId != "" {
+		t.Fatalf("wrong state after take position: %v", s.Json())
+	}
+
+	step()
+	if !s.IsArmed || s.StopPrice != 102 || s.OrderId == "" {
+		t.Fatalf("stop is not placed: %v", s.Json())
+	}
+
+	orderId := s.OrderId
+	step()
+	if s.StopPrice != 104 || s.OrderId == orderId {
+		t.Fatalf("stop is not moved: %v", s.Json())
+	}
+
+	orderId = s.OrderId
+	step()
+	if s.StopPrice != 104 || s.OrderId != orderId {
+		t.Fatalf("stop is moved down: %v", s.Json())
+	}
+
+	step()
+	if s.Volume != 0 || s.Sold != 10 || s.SoldPrice != 1040 || s.OrderId != "" {
+		t.Fatalf("stop is not executed: %v", s.Json())
+	}
+	if p.Account.Position("", "TTTT").Quantity != 0 {
+		t.Fatalf("position is not closed: %v", p.Account.Position("", "TTTT"))
+	}
+}
+
+func TestTrailingStopByMarket(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{100, 100.5, 99.5, 100},
+			[4]float64{100, 100.5, 99.5, 100},
+			[4]float64{110, 110.5, 109.5, 110},
+			[4]float64{108, 108.5, 107, 107.5},
+			[4]float64{107, 107, 106, 106},
+			[4]float64{106, 106, 105, 105},
+		),
+	}
+	s := &TrailingStop{
+		Ticker:    "TTTT",
+		Volume:    5,
+		TrailMode: TrailPercent,
+		Trail:     2,
+		IsOnline:  true,
+	}
+	pp := &plainStepParams{StepParams: p}
+
+	step := func() {
+		t.Helper()
+		p.DoStep()
+		_, err := s.Step(pp)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	step()
+	step()
+	if s.StopPrice != 107.8 || s.OrderId != "" {
+		t.Fatalf("wrong stop: %v", s.Json())
+	}
+
+	step()
+	if s.OrderId == "" {
+		t.Fatalf("sell by market is not placed: %v", s.Json())
+	}
+
+	step()
+	if s.Volume != 0 || s.Sold != 5 {
+		t.Fatalf("sell by market is not executed: %v", s.Json())
+	}
+}
+
+func TestTrailingStopATR(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+			[4]float64{100, 101, 99, 100},
+		),
+	}
+	s := &TrailingStop{
+		Ticker:    "TTTT",
+		Volume:    5,
+		TrailMode: TrailATR,
+		Trail:     1.5,
+		AtrPeriod: 3,
+		IsOnline:  true,
+	}
+
+	p.DoStep()
+	p.DoStep()
+	p.DoStep()
+	_, err := s.Step(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.StopPrice != 97 || s.OrderId == "" {
+		t.Fatalf("wrong stop: %v", s.Json())
+	}
+}
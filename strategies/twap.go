@@ -0,0 +1,66 @@
+package strategies
+
+import (
+	"encoding/json"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+//go:generate mfjson twap.go
+
+// Исполнение равными частями по времени (TWAP - time weighted average price)
+
+var (
+	_ smp.Strategy = &TWAP{}
+)
+
+//mfjson:interface smp.strategies.twap
+type TWAP struct {
+	ParentOrder
+}
+
+func (s *TWAP) Type() string {
+	return "twap"
+}
+
+func (s *TWAP) String() string {
+	return "twap"
+}
+
+func (s *TWAP) Status() smp.StartegyStatus {
+	return s.status()
+}
+func (s *TWAP) Json() string {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return string(b)
+}
+
+// commands - commands of strategy
+func (s *TWAP) commands() smp.CommandDefs {
+	return s.ParentOrder.commands(s.Type(), s, showCommand("Отобразить (исполнено, средняя цена, бенчмарк, доля рынка)", func() string {
+		return s.show(s.String(), s.MarketTWAP())
+	}))
+}
+
+func (s *TWAP) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	return s.commands().Do(s.Type(), cmd, params)
+}
+
+func (s *TWAP) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
+
+func (s *TWAP) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), `twap - стратегия исполнения, равные части по времени
+	Родительская заявка делится на равные дочерние заявки (по цене или по рынку) в окне исполнения
+	Бенчмарк - средняя цена закрытия свечей в окне`)
+}
+
+func (s *TWAP) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
+	return s.step(p, s.evenTarget)
+}
@@ -0,0 +1,19 @@
+// Code generated by mfjson for marshaling/unmarshaling. DO NOT EDIT.
+// https://github.com/myfantasy/json
+
+package strategies
+
+import (
+	mfj "github.com/myfantasy/json"
+)
+func (obj *TWAP) UnmarshalJSONTypeName() string {
+	return "smp.strategies.twap"
+}
+
+func init() {
+	mfj.GlobalStructFactory.Add("smp.strategies.twap", func() mfj.JsonInterfaceMarshaller { return &TWAP{} })
+	mfj.GlobalStructFactory.AddNil("smp.strategies.twap", func() mfj.JsonInterfaceMarshaller {
+		var out *TWAP
+		return out
+	})
+}
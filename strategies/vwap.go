@@ -0,0 +1,157 @@
+package strategies
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+)
+
+//go:generate mfjson vwap.go
+
+// Исполнение по профилю объёма (VWAP - volume weighted average price)
+
+var (
+	_ smp.Strategy = &VWAP{}
+)
+
+//mfjson:interface smp.strategies.vwap
+type VWAP struct {
+	ParentOrder
+
+	// ProfileDays - days of history for volume profile
+	ProfileDays int `json:"profile_days"`
+	// Profile - cumulative part of volume at the end of each slice (empty - even slices)
+	Profile []float64 `json:"profile,omitempty"`
+	// IsProfiled - Profile is built
+	IsProfiled bool `json:"is_profiled"`
+}
+
+func (s *VWAP) Type() string {
+	return "vwap"
+}
+
+func (s *VWAP) String() string {
+	return "vwap"
+}
+
+func (s *VWAP) Status() smp.StartegyStatus {
+	return s.status()
+}
+func (s *VWAP) Json() string {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return string(b)
+}
+
+// commands - commands of strategy (volume profile is built again after change of window, slices or days)
+func (s *VWAP) commands() smp.CommandDefs {
+	defs := s.ParentOrder.commands(s.Type(), s, showCommand("Отобразить (исполнено, средняя цена, бенчмарк, доля рынка)", func() string {
+		return s.show(s.String(), s.MarketVWAP())
+	}))
+	for i := range defs {
+		if defs[i].Command == SetSlices || defs[i].Command == SetWindow {
+			defs[i].Do = s.resetProfile
+		}
+	}
+	return append(defs, smp.CommandDef{
+		Command: SetProfileDays, Description: "Установить кол-во дней истории для профиля объёма", Example: "set_profile_days 5",
+		Params: []smp.Param{smp.IntParam("", "кол-во дней", &s.ProfileDays).WithMin(1)},
+		Do:     s.resetProfile,
+	})
+}
+
+func (s *VWAP) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
+	return s.commands().Do(s.Type(), cmd, params)
+}
+
+func (s *VWAP) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
+
+func (s *VWAP) resetProfile(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+	s.Profile = nil
+	s.IsProfiled = false
+	return res, nil
+}
+
+func (s *VWAP) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), `vwap - стратегия исполнения по профилю объёма
+	Родительская заявка делится на дочерние заявки пропорционально объёму торгов в те же части окна за предыдущие дни
+	Без истории объёма части равные (как twap)
+	Бенчмарк - средневзвешенная по объёму цена закрытия свечей в окне`)
+}
+
+// profile - cumulative part of volume of slices in the same window of previous days
+func (s *VWAP) profile(p smp.StepParams) (err *mft.Error) {
+	slices := s.Slices
+	if slices <= 0 {
+		slices = 1
+	}
+	vols := make([]int, slices)
+	total := 0
+
+	for d := 1; d <= s.ProfileDays; d++ {
+		shift := time.Duration(d) * 24 * time.Hour
+		// candles are selected by end date: the last candle of window ends after To
+		cs, err := p.GetCandles(s.InstrumentId, s.Ticker, s.From.Add(-shift), s.To.Add(-shift).Add(time.Hour))
+		if err != nil {
+			return err
+		}
+		for _, c := range cs {
+			if c.Start.Before(s.From.Add(-shift)) || !c.Start.Before(s.To.Add(-shift)) {
+				continue
+			}
+			vols[s.sliceOf(c.Start.Add(shift))] += c.Vol
+			total += c.Vol
+		}
+	}
+
+	s.IsProfiled = true
+	s.Profile = nil
+	if total == 0 {
+		return nil
+	}
+
+	s.Profile = make([]float64, slices)
+	sum := 0
+	for i, v := range vols {
+		sum += v
+		s.Profile[i] = smp.Round(float64(sum)/float64(total), 6)
+	}
+	return nil
+}
+
+// target - lots should be executed at the end of slice
+func (s *VWAP) target(slice int) int {
+	if len(s.Profile) == 0 || slice >= len(s.Profile) {
+		return s.evenTarget(slice)
+	}
+	if slice == len(s.Profile)-1 {
+		return s.Quantity
+	}
+	return int(math.Round(float64(s.Quantity) * s.Profile[slice]))
+}
+
+func (s *VWAP) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
+	// profile is built in window when candles of previous days are available
+	if !s.IsProfiled && s.IsOnline {
+		ob, err := p.GetOrderBook(s.InstrumentId, s.Ticker)
+		if err != nil {
+			return meta, smp.GenerateErrorE(500002313, err)
+		}
+		if !ob.Time.Before(s.From) {
+			err = s.profile(p)
+			if err != nil {
+				return meta, smp.GenerateErrorE(500002312, err)
+			}
+		}
+	}
+
+	return s.step(p, s.target)
+}
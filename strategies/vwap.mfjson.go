@@ -0,0 +1,19 @@
+// Code generated by mfjson for marshaling/unmarshaling. DO NOT EDIT.
+// https://github.com/myfantasy/json
+
+package strategies
+
+import (
+	mfj "github.com/myfantasy/json"
+)
+func (obj *VWAP) UnmarshalJSONTypeName() string {
+	return "smp.strategies.vwap"
+}
+
+func init() {
+	mfj.GlobalStructFactory.Add("smp.strategies.vwap", func() mfj.JsonInterfaceMarshaller { return &VWAP{} })
+	mfj.GlobalStructFactory.AddNil("smp.strategies.vwap", func() mfj.JsonInterfaceMarshaller {
+		var out *VWAP
+		return out
+	})
+}
@@ -2,7 +2,6 @@ package strategies
 
 import (
 	"encoding/json"
-	"strconv"
 
 	"github.com/myfantasy/mft"
 	smp "github.com/myfantasy/stock_market_primitives"
@@ -50,12 +49,31 @@ type WingedSwing struct {
 	Labels map[string]string `json:"labels"`
 
 	StopLostBank *StopLostBank `json:"stop_lost_bank,omitempty"`
+	// sharedBank - StopLostBank of container, used when StopLostBank is not set
+	sharedBank *StopLostBank
 
 	StopLostTimes int `json:"stop_lost_times"`
 
-	NeedSendCancel bool `json:"need_send_cancel"`
+	State WingedSwingState `json:"state"`
+
+	Lifecycle smp.Lifecycle `json:"lifecycle"`
 }
 
+type WingedSwingState string
+
+const (
+	// SwingWaiting - waits price inside working range to buy
+	SwingWaiting WingedSwingState = "waiting"
+	// SwingBuying - buy order is placed
+	SwingBuying WingedSwingState = "buying"
+	// SwingHolding - volume is bought, waits price to place sell order
+	SwingHolding WingedSwingState = "holding"
+	// SwingSelling - sell order is placed
+	SwingSelling WingedSwingState = "selling"
+	// SwingStopLoss - orders are canceled and lots are sold by market (or to StopLostBank)
+	SwingStopLoss WingedSwingState = "stop_loss"
+)
+
 func (s *WingedSwing) Type() string {
 	return "winged_swing"
 }
@@ -65,9 +83,7 @@ func (s *WingedSwing) String() string {
 }
 
 func (s *WingedSwing) Status() smp.StartegyStatus {
-	return smp.StartegyStatus{
-		IsOnline: s.IsOnline,
-	}
+	return s.Lifecycle.Status(s.IsOnline)
 }
 func (s *WingedSwing) Json() string {
 	b, err := json.MarshalIndent(s, "", "  ")
@@ -77,127 +93,96 @@ func (s *WingedSwing) Json() string {
 
 	return string(b)
 }
+
+// commands - commands of strategy
+func (s *WingedSwing) commands() smp.CommandDefs {
+	return lifecycleCommands(showCommand("Отобразить", s.String), s.Lifecycle.Commands(s.Type(), s, &s.IsOnline), smp.CommandDefs{
+		{Command: SetLevelDown, Description: "Установить уровень покупки", Example: "set_level_down 372.25",
+			Params: []smp.Param{smp.FloatParam("", "уровень", &s.LevelPriceDown)}},
+		{Command: SetLevelUp, Description: "Установить уровень продажи", Example: "set_level_up 392.80",
+			Params: []smp.Param{smp.FloatParam("", "уровень", &s.LevelPriceUp)}},
+		{Command: SetVolume, Description: "Установить объём", Example: "set_vol 25",
+			Params: []smp.Param{smp.IntParam("", "объём", &s.Volume).WithMin(0)}},
+
+		{Command: SetLevelPriceOnTheMarketUp, Description: "Установить уровень начала работы стратегии сверху " +
+			"(с этого уровня происходит покупка)", Example: "set_level_on_the_market_up 420.90",
+			Params: []smp.Param{smp.FloatParam("", "уровень", &s.LevelPriceOnTheMarketUp)}},
+		{Command: SetLevelPriceOnTheMarketDown, Description: "Установить уровень начала работы стратегии снизу " +
+			"(с этого уровня происходит покупка)", Example: "set_level_on_the_market_down 345.67",
+			Params: []smp.Param{smp.FloatParam("", "уровень", &s.LevelPriceOnTheMarketDown)}},
+		{Command: SetLevelPriceOnTheMarketDownByMarket, Description: "Установить уровень начала работы стратегии снизу по цене рынка " +
+			"(до этого уровня происходит покупка по рынку)", Example: "set_level_on_the_market_down_by_market 370.10",
+			Params: []smp.Param{smp.FloatParam("", "уровень", &s.LevelPriceOnTheMarketDownByMarket)}},
+	})
+}
+
 func (s *WingedSwing) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
-	if cmd == smp.ShowCommand {
-		res.Message = s.String()
-		return res, true, nil
-	}
+	return s.commands().Do(s.Type(), cmd, params)
+}
 
-	if cmd == smp.StartCommand {
-		s.IsOnline = true
-		return res, true, nil
-	}
+func (s *WingedSwing) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
 
-	if cmd == smp.StopCommand {
-		s.IsOnline = false
-		return res, true, nil
-	}
+func (s *WingedSwing) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), `winged_swing - стратегия, качель`)
+}
 
-	if cmd == SetLevelPriceOnTheMarketUp {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseFloat(fS, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000411, er0, cmd, fS)
-			}
-			s.LevelPriceOnTheMarketUp = f
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000410, cmd)
-		}
-	}
-	if cmd == SetLevelPriceOnTheMarketDown {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseFloat(fS, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000431, er0, cmd, fS)
-			}
-			s.LevelPriceOnTheMarketDown = f
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000430, cmd)
-		}
-	}
-	if cmd == SetLevelPriceOnTheMarketDownByMarket {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseFloat(fS, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000433, er0, cmd, fS)
-			}
-			s.LevelPriceOnTheMarketDownByMarket = f
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000432, cmd)
-		}
+// ActiveOrders - buy and sell orders of swing
+func (s *WingedSwing) ActiveOrders() (orders []smp.ActiveOrder) {
+	if s.OrderIdBuy != "" {
+		orders = append(orders, smp.ActiveOrder{InstrumentId: s.InstrumentId, Ticker: s.Ticker, OrderId: s.OrderIdBuy, Buy: true})
 	}
-
-	if cmd == SetLevelUp {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseFloat(fS, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000413, er0, cmd, fS)
-			}
-			s.LevelPriceUp = f
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000412, cmd)
-		}
+	if s.OrderIdSell != "" {
+		orders = append(orders, smp.ActiveOrder{InstrumentId: s.InstrumentId, Ticker: s.Ticker, OrderId: s.OrderIdSell})
 	}
+	return orders
+}
 
-	if cmd == SetLevelDown {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseFloat(fS, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000415, er0, cmd, fS)
-			}
-			s.LevelPriceDown = f
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000414, cmd)
-		}
+// FlattenPosition - sells lots in market by market
+func (s *WingedSwing) FlattenPosition(p smp.StepParams, meta *smp.MetaForStep) (done bool, err *mft.Error) {
+	if s.InMarket <= 0 {
+		return true, nil
 	}
 
-	if cmd == SetVolume {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseInt(fS, 10, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000421, er0, cmd, fS)
-			}
-			s.Volume = int(f)
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000420, cmd)
-		}
+	s.OrderIdSell, err = p.SellByMarket(s.InstrumentId, s.Ticker, s.InMarket, s.operationMeta(false))
+	if err != nil {
+		s.OrderIdSell = ""
+		return false, smp.GenerateErrorE(500000519, err)
 	}
+	meta.HasChanges = true
+	meta.OpDescr = append(meta.OpDescr, "Sell by market (flatten)")
+	s.State = SwingSelling
+	return false, nil
+}
 
-	return res, false, smp.GenerateError(500000400, cmd)
+// ResetState - clears profit, iterations and counters of stop loss (lots in market should be sold)
+func (s *WingedSwing) ResetState() (err *mft.Error) {
+	if s.InMarket != 0 {
+		return smp.GenerateError(500003551, s.Type(), s.InMarket)
+	}
+	s.InMarketPrice = 0
+	s.IsBought = false
+	s.InMarketWait = 0
+	s.InMarketPriceWait = 0
+	s.Profit = 0
+	s.Iteration = 0
+	s.StopLostTimes = 0
+	s.State = SwingWaiting
+	return nil
 }
 
-func (s *WingedSwing) AllowCommands() map[smp.Command]smp.CommandInfo {
-	return map[smp.Command]smp.CommandInfo{
-		smp.ShowCommand:  {0, "Отобразить", "", ""},
-		smp.StartCommand: {1, "Старт", "", ""},
-		smp.StopCommand:  {2, "Стоп", "", ""},
-		SetLevelDown:     {3, "Установить уровень покупки", "уровень", "set_level_down 372.25"},
-		SetLevelUp:       {4, "Установить уровень продажи", "уровень", "set_level_up 392.80"},
-		SetVolume:        {5, "Установить объём:", "объём", "пример set_vol 25"},
-
-		SetLevelPriceOnTheMarketUp: {6, "Установить уровень начала работы стратегии сверху " +
-			"(с этого уровня происходит покупка", "уровень", "set_level 420.90"},
-		SetLevelPriceOnTheMarketDown: {7, "Установить уровень начала работы стратегии снизу " +
-			"(с этого уровня происходит покупка", "уровень", "set_level 345.67"},
-		SetLevelPriceOnTheMarketDownByMarket: {8, "Установить уровень начала работы стратегии снизу по цене рынка " +
-			"(до этого уровня происходит покупка по рынку)", "уровень", "set_level 370.10"},
-	}
+// UseStopLostBank - sets StopLostBank shared by container
+func (s *WingedSwing) UseStopLostBank(slb *StopLostBank) {
+	s.sharedBank = slb
 }
 
-func (s *WingedSwing) Description() string {
-	return `winged_swing - стратегия, качель`
+// bank - own StopLostBank or shared one
+func (s *WingedSwing) bank() *StopLostBank {
+	if s.StopLostBank != nil {
+		return s.StopLostBank
+	}
+	return s.sharedBank
 }
 
 func (s *WingedSwing) ComputeVolume() int {
@@ -210,482 +195,393 @@ func (s *WingedSwing) ComputeVolume() int {
 	return s.Volume
 }
 
-func (s *WingedSwing) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
-	panic("not implemet correct")
+func (s *WingedSwing) operationMeta(isStopLoss bool) *smp.MetaForOperations {
+	return &smp.MetaForOperations{
+		NameOfStrategy: s.Name,
+		IsStopLoss:     isStopLoss,
+	}
+}
 
+// Step - one step of state machine
+// waiting -> buying -> holding -> selling -> waiting;
+// waiting, buying, holding, selling -> stop_loss -> waiting
+func (s *WingedSwing) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
 	meta.Name = s.Name
+
 	ob, err := p.GetOrderBook(s.InstrumentId, s.Ticker)
 	if err != nil {
 		return meta, smp.GenerateErrorE(500000500, err)
 	}
 
-	computeVolume := s.ComputeVolume()
-
-	if s.OrderIdBuy == "" && s.OrderIdSell == "" && s.Volume > 0 {
-		// Заполняем если установили объёмы руками
-		if s.IsBought && s.InMarket <= 0 {
-			meta.HasChanges = true
-			s.IsBought = false
-			s.InMarketPrice = 0
-		}
-
-		if !s.IsBought && s.InMarket >= computeVolume {
-			meta.HasChanges = true
-			s.IsBought = true
-			s.InMarketPrice = smp.Round(s.LevelPriceDown*float64(s.InMarket), 6)
-		}
-	}
-
-	// Проверяем что Покупка прошла
 	if s.OrderIdBuy != "" {
-		status, prices, err := p.StatusBuyOrder(s.InstrumentId, s.Ticker, s.OrderIdBuy,
-			&smp.MetaForOperations{
-				NameOfStrategy: s.Name,
-			},
-		)
+		status, prices, err := p.StatusBuyOrder(s.InstrumentId, s.Ticker, s.OrderIdBuy, s.operationMeta(false))
 		if err != nil {
 			return meta, smp.GenerateErrorE(500000502, err)
 		}
-
-		cnt := 0
-		price := 0.0
-		for _, pr := range prices {
-			cnt += pr.Count
-			price += pr.Price * float64(pr.Count)
-		}
-
-		if status == smp.Complete || status == smp.Canceled {
-			s.InMarket += cnt
-			s.InMarketPrice = smp.Round(s.InMarketPrice+price, 6)
-			s.OrderIdBuy = ""
-			s.InMarketWait = 0
-			s.InMarketPriceWait = 0
-
-			if s.InMarket >= computeVolume {
-				s.IsBought = true
-			}
-
-		} else {
-			s.InMarketWait = cnt
-			s.InMarketPriceWait = price
-		}
+		s.applyBuyOrder(status, prices, &meta)
 	}
 
-	// Проверяем что покупка прошла
 	if s.OrderIdSell != "" {
-		status, prices, err := p.StatusSellOrder(s.InstrumentId, s.Ticker, s.OrderIdSell,
-			&smp.MetaForOperations{
-				NameOfStrategy: s.Name,
-			},
-		)
+		status, prices, err := p.StatusSellOrder(s.InstrumentId, s.Ticker, s.OrderIdSell, s.operationMeta(s.State == SwingStopLoss))
 		if err != nil {
 			return meta, smp.GenerateErrorE(500000503, err)
 		}
+		s.applySellOrder(status, prices, s.State == SwingStopLoss, &meta)
+	}
 
-		cnt := 0
-		price := 0.0
-		for _, pr := range prices {
-			cnt += pr.Count
-			price += pr.Price * float64(pr.Count)
-		}
-
-		if status == smp.Complete || status == smp.Canceled {
-			s.InMarket -= cnt
-			s.InMarketPrice = smp.Round(s.InMarketPrice-price, 6)
-			s.OrderIdSell = ""
-			s.InMarketWait = 0
-			s.InMarketPriceWait = 0
-
-			if s.InMarket <= 0 {
-				s.Iteration++
-
-				s.Profit = smp.Round(s.Profit-s.InMarketPrice, 6)
-				s.InMarketPrice = 0
-				s.IsBought = false
-			}
-
-			if cnt > s.Volume {
-				s.StopLostTimes = -1
-			}
-		} else {
-			s.InMarketWait = -cnt
-			s.InMarketPriceWait = -price
-		}
+	if s.OrderIdBuy == "" && s.OrderIdSell == "" {
+		s.settleState(&meta)
 	}
 
 	if ob.TradeStatus != smp.NormalTrading {
 		return meta, nil
 	}
 
-	if s.NeedSendCancel {
-		if s.OrderIdBuy != "" {
-			_, err := p.CancelBuyOrder(s.InstrumentId, s.Ticker, s.OrderIdBuy,
-				&smp.MetaForOperations{
-					NameOfStrategy: s.Name,
-				},
-			)
-			if err != nil {
-				return meta, smp.GenerateErrorE(500000513, err)
-			}
-		}
-
-		if s.OrderIdSell != "" {
-			_, err := p.CancelSellOrder(s.InstrumentId, s.Ticker, s.OrderIdSell,
-				&smp.MetaForOperations{
-					NameOfStrategy: s.Name,
-				},
-			)
-			if err != nil {
-				return meta, smp.GenerateErrorE(500000514, err)
-			}
-		}
-
-		meta.HasChanges = true
-		s.NeedSendCancel = false
+	if halt, err := s.Lifecycle.Step(p, s, &meta); halt || err != nil {
+		return meta, err
 	}
 
-	if !s.IsOnline {
+	if !s.IsOnline || s.Volume <= 0 {
 		return meta, nil
 	}
 
-	if ob.SellPrice() < s.LevelPriceStopLoss && s.OrderIdSell != "" {
-		meta.HasChanges = true
-		s.NeedSendCancel = true
-		meta.OpDescr = append(meta.OpDescr, "PriceDown Need cancel Sell")
+	if len(ob.Bids) < 1 || len(ob.Asks) < 1 {
 		return meta, nil
 	}
 
-	if ob.SellPrice() < s.LevelPriceStopLoss && s.OrderIdBuy != "" {
-		meta.HasChanges = true
-		s.NeedSendCancel = true
-		meta.OpDescr = append(meta.OpDescr, "PriceDown Need cancel Buy")
-		return meta, nil
+	if s.State == SwingStopLoss ||
+		s.LevelPriceStopLoss > 0 && ob.SellPrice() < s.LevelPriceStopLoss &&
+			(s.InMarket > 0 || s.OrderIdBuy != "") {
+		return s.stepStopLoss(p, ob, meta)
 	}
 
-	if s.StopLostBank != nil {
-		if ob.SellPrice() < s.LevelPriceStopLoss && s.InMarket > 0 {
-			err := s.StopLostBank.Sell(p,
-				s.InstrumentId,
-				s.Ticker,
-				s.InMarket, s.LevelPriceUp)
-			if err != nil {
-				return meta, smp.GenerateErrorE(500000515, err)
-			}
-			s.StopLostTimes = s.StopLostTimes + 1
-			s.InMarketPrice = smp.Round(s.InMarketPrice-float64(s.InMarket)*ob.SellPrice(), 6)
-			s.InMarket = 0
-
-			meta.HasChanges = true
-			meta.OpDescr = append(meta.OpDescr, "Stop loss sale")
-			return meta, nil
+	if s.State == SwingHolding || s.State == SwingSelling {
+		if slb := s.bank(); slb != nil && slb.AllowRequestCount(s.InstrumentId, s.Ticker) > 0 &&
+			// текущая цена покупки не ниже цены покупки стратегии
+			ob.BuyPrice() >= s.LevelPriceDown {
+			return s.stepRequestSale(p, ob, slb, meta)
 		}
 	}
 
-	if s.StopLostBank != nil {
-		if s.IsBought && s.InMarket > 0 &&
-			// Текущая цена покупки выше чем цена покупки стратегии
-			ob.BuyPrice() >= s.LevelPriceDown {
-			if req := s.StopLostBank.AllowRequestCount(s.InstrumentId, s.Ticker); req > 0 {
-				if s.OrderIdSell != "" {
-					meta.HasChanges = true
-					s.NeedSendCancel = true
-					meta.OpDescr = append(meta.OpDescr, "Need cancel Sell for Request from SLB")
-					return meta, nil
-				} else {
-					if s.InMarket < req {
-						req = s.InMarket
-					}
-
-					err = s.StopLostBank.Sell(p, s.InstrumentId, s.Ticker, req, s.LevelPriceUp)
-					if err != nil {
-						return meta, smp.GenerateErrorE(500000516, err)
-					}
-
-					s.StopLostTimes = s.StopLostTimes + 1
-					s.InMarketPrice = smp.Round(s.InMarketPrice-float64(req)*ob.SellPrice(), 6)
-					s.InMarket -= req
-
-					meta.HasChanges = true
-					meta.OpDescr = append(meta.OpDescr, "Requet to SLB sale")
-					return meta, nil
-				}
-			}
-		}
+	switch s.State {
+	case SwingWaiting:
+		return s.stepBuy(p, ob, meta)
+	case SwingHolding:
+		return s.stepSell(p, ob, meta)
 	}
 
-	if s.IsBought {
-		if s.InMarket > 0 {
-
-			if ob.SellPrice() <= s.LevelPriceOnTheMarketUp {
-				s.OrderIdSell, err = p.SellByPrice(s.InstrumentId, s.Ticker, s.InMarket, s.LevelPriceUp,
-					&smp.MetaForOperations{
-						NameOfStrategy: s.Name,
-					},
-				)
-				if err != nil {
-					s.OrderIdSell = ""
-					return meta, smp.GenerateErrorE(500000504, err)
-				}
-				meta.HasChanges = true
-				meta.OpDescr = append(meta.OpDescr,
-					"Заявка на покупку",
-				)
-			}
-			return meta, nil
-		} else {
-			return meta, smp.GenerateError(500000505, s.InMarket)
-		}
-	} else {
-		if computeVolume-s.InMarket > 0 {
-		} else {
-			return meta, smp.GenerateError(500000506, s.InMarket, computeVolume)
+	return meta, nil
+}
+
+func lotPricesSum(prices []smp.LotPrices) (cnt int, price float64) {
+	for _, pr := range prices {
+		cnt += pr.Count
+		price += pr.Price * float64(pr.Count)
+	}
+	return cnt, price
+}
+
+// applyBuyOrder - applies status of buy order
+func (s *WingedSwing) applyBuyOrder(status smp.StatusOrder, prices []smp.LotPrices, meta *smp.MetaForStep) {
+	cnt, price := lotPricesSum(prices)
+
+	if status != smp.Complete && status != smp.Canceled {
+		if s.InMarketWait != cnt {
+			meta.HasChanges = true
 		}
+		s.InMarketWait = cnt
+		s.InMarketPriceWait = smp.Round(price, 6)
+		return
 	}
 
-	return meta, nil
+	meta.HasChanges = true
+	s.InMarket += cnt
+	s.InMarketPrice = smp.Round(s.InMarketPrice+price, 6)
+	s.OrderIdBuy = ""
+	s.InMarketWait = 0
+	s.InMarketPriceWait = 0
+
+	if s.InMarket >= s.ComputeVolume() {
+		s.IsBought = true
+	}
 }
 
-/*
+// applySellOrder - applies status of sell order; the cycle is finished when all lots are sold
+// (isStopLoss - order is stop loss sale)
+func (s *WingedSwing) applySellOrder(status smp.StatusOrder, prices []smp.LotPrices, isStopLoss bool, meta *smp.MetaForStep) {
+	cnt, price := lotPricesSum(prices)
 
-*
-*
-*
-*
-*
+	if status != smp.Complete && status != smp.Canceled {
+		if s.InMarketWait != -cnt {
+			meta.HasChanges = true
+		}
+		s.InMarketWait = -cnt
+		s.InMarketPriceWait = -smp.Round(price, 6)
+		return
+	}
 
- */
+	meta.HasChanges = true
+	s.InMarket -= cnt
+	s.InMarketPrice = smp.Round(s.InMarketPrice-price, 6)
+	s.OrderIdSell = ""
+	s.InMarketWait = 0
+	s.InMarketPriceWait = 0
 
-func (s *WingedSwing) Step_old(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
-	meta.Name = s.Name
+	if s.InMarket <= 0 {
+		s.finishIteration(isStopLoss, meta)
+	}
+}
 
-	ob, err := p.GetOrderBook(s.InstrumentId, s.Ticker)
-	if err != nil {
-		return meta, smp.GenerateErrorE(500000500, err)
+// finishIteration - all lots are sold (by profit or by stop loss - isStopLoss)
+func (s *WingedSwing) finishIteration(isStopLoss bool, meta *smp.MetaForStep) {
+	s.Iteration++
+	s.Profit = smp.Round(s.Profit-s.InMarketPrice, 6)
+	s.InMarket = 0
+	s.InMarketPrice = 0
+	s.IsBought = false
+
+	if isStopLoss {
+		s.StopLostTimes++
+		meta.OpDescr = append(meta.OpDescr, "Stop loss is finished")
+	} else {
+		s.StopLostTimes = 0
+		meta.OpDescr = append(meta.OpDescr, "Iteration is finished")
 	}
+	s.State = SwingWaiting
+}
 
+// settleState - sets state by InMarket when there are no active orders (also after manual InMarket change)
+func (s *WingedSwing) settleState(meta *smp.MetaForStep) {
+	state := s.State
 	computeVolume := s.ComputeVolume()
 
-	if s.OrderIdBuy == "" && s.OrderIdSell == "" && s.Volume > 0 {
-		// Заполняем если установили объёмы руками
-		if s.IsBought && s.InMarket <= 0 {
+	switch {
+	case s.State == SwingStopLoss && s.InMarket > 0:
+		// the rest has to be sold
+	case s.InMarket <= 0:
+		if s.IsBought || s.InMarketPrice != 0 && s.State != SwingWaiting {
 			s.IsBought = false
 			s.InMarketPrice = 0
+			meta.HasChanges = true
 		}
+		state = SwingWaiting
+	case s.IsBought:
+		state = SwingHolding
+	case s.InMarket >= computeVolume:
+		// InMarket is set manually
+		s.IsBought = true
+		s.InMarketPrice = smp.Round(s.LevelPriceDown*float64(s.InMarket), 6)
+		meta.HasChanges = true
+		state = SwingHolding
+	default:
+		state = SwingWaiting
+	}
 
-		if !s.IsBought && s.InMarket >= computeVolume {
+	if state != s.State {
+		s.State = state
+		meta.HasChanges = true
+	}
+}
+
+// stepBuy - waiting: buys the rest of volume when price is inside working range
+func (s *WingedSwing) stepBuy(p smp.StepParams, ob *smp.OrderBook, meta smp.MetaForStep) (smp.MetaForStep, *mft.Error) {
+	need := s.ComputeVolume() - s.InMarket
+	if need <= 0 {
+		return meta, nil
+	}
+
+	if ob.BuyPrice() < s.LevelPriceOnTheMarketDown || ob.SellPrice() > s.LevelPriceOnTheMarketUp {
+		return meta, nil
+	}
+
+	if slb := s.bank(); slb != nil && slb.AllowCount(s.InstrumentId, s.Ticker) > 0 {
+		success, err := slb.Buy(p, s.InstrumentId, s.Ticker, need, s.LevelPriceDown)
+		if err != nil {
+			return meta, smp.GenerateErrorE(500000510, err)
+		}
+		if success > 0 {
+			meta.HasChanges = true
+			meta.OpDescr = append(meta.OpDescr, "Buy from StopLostBank")
+			s.InMarket += success
+			s.InMarketPrice = smp.Round(s.InMarketPrice+float64(success)*s.LevelPriceDown, 6)
+			need -= success
+		}
+		if need <= 0 {
 			s.IsBought = true
-			s.InMarketPrice = smp.Round(s.LevelPriceDown*float64(s.InMarket), 6)
+			s.State = SwingHolding
+			return meta, nil
 		}
 	}
 
-	if s.OrderIdBuy != "" {
-		status, prices, err := p.StatusBuyOrder(s.InstrumentId, s.Ticker, s.OrderIdBuy,
-			&smp.MetaForOperations{
-				NameOfStrategy: s.Name,
-			},
-		)
+	var err *mft.Error
+	if ob.BuyPrice() < s.LevelPriceOnTheMarketDownByMarket {
+		s.OrderIdBuy, err = p.BuyByMarket(s.InstrumentId, s.Ticker, need, s.operationMeta(false))
 		if err != nil {
-			return meta, smp.GenerateErrorE(500000502, err)
+			s.OrderIdBuy = ""
+			return meta, smp.GenerateErrorE(500000518, err)
 		}
-
-		cnt := 0
-		price := 0.0
-		for _, pr := range prices {
-			cnt += pr.Count
-			price += pr.Price * float64(pr.Count)
+		meta.OpDescr = append(meta.OpDescr, "Покупка по рынку")
+	} else {
+		s.OrderIdBuy, err = p.BuyByPrice(s.InstrumentId, s.Ticker, need, s.LevelPriceDown, s.operationMeta(false))
+		if err != nil {
+			s.OrderIdBuy = ""
+			return meta, smp.GenerateErrorE(500000501, err)
 		}
+		meta.OpDescr = append(meta.OpDescr, "Заявка на покупку")
+	}
 
-		if status == smp.Complete || status == smp.Canceled {
-			s.InMarket += cnt
-			s.InMarketPrice = smp.Round(s.InMarketPrice+price, 6)
-			s.OrderIdBuy = ""
-			s.InMarketWait = 0
-			s.InMarketPriceWait = 0
+	meta.HasChanges = true
+	s.State = SwingBuying
+	return meta, nil
+}
 
-			if s.InMarket >= computeVolume {
-				s.IsBought = true
-			}
+// stepSell - holding: places sell order when price is under working level
+func (s *WingedSwing) stepSell(p smp.StepParams, ob *smp.OrderBook, meta smp.MetaForStep) (smp.MetaForStep, *mft.Error) {
+	if s.InMarket <= 0 {
+		return meta, smp.GenerateError(500000505, s.InMarket)
+	}
 
-		} else {
-			s.InMarketWait = cnt
-			s.InMarketPriceWait = price
-		}
+	if ob.SellPrice() > s.LevelPriceOnTheMarketUp {
+		return meta, nil
 	}
 
+	var err *mft.Error
+	s.OrderIdSell, err = p.SellByPrice(s.InstrumentId, s.Ticker, s.InMarket, s.LevelPriceUp, s.operationMeta(false))
+	if err != nil {
+		s.OrderIdSell = ""
+		return meta, smp.GenerateErrorE(500000504, err)
+	}
+
+	meta.HasChanges = true
+	meta.OpDescr = append(meta.OpDescr, "Заявка на продажу")
+	s.State = SwingSelling
+	return meta, nil
+}
+
+// stepRequestSale - holding: sells lots to StopLostBank by its request to buy (sell order is canceled before)
+func (s *WingedSwing) stepRequestSale(p smp.StepParams, ob *smp.OrderBook, slb *StopLostBank, meta smp.MetaForStep) (smp.MetaForStep, *mft.Error) {
 	if s.OrderIdSell != "" {
-		status, prices, err := p.StatusSellOrder(s.InstrumentId, s.Ticker, s.OrderIdSell,
-			&smp.MetaForOperations{
-				NameOfStrategy: s.Name,
-			},
-		)
+		_, err := p.CancelSellOrder(s.InstrumentId, s.Ticker, s.OrderIdSell, s.operationMeta(false))
+		if err != nil {
+			return meta, smp.GenerateErrorE(500000514, err)
+		}
+		status, prices, err := p.StatusSellOrder(s.InstrumentId, s.Ticker, s.OrderIdSell, s.operationMeta(false))
 		if err != nil {
 			return meta, smp.GenerateErrorE(500000503, err)
 		}
-
-		cnt := 0
-		price := 0.0
-		for _, pr := range prices {
-			cnt += pr.Count
-			price += pr.Price * float64(pr.Count)
+		if status == smp.Wait {
+			// cancel is not finished
+			return meta, nil
 		}
-
-		if status == smp.Complete || status == smp.Canceled {
-			s.InMarket -= cnt
-			s.InMarketPrice = smp.Round(s.InMarketPrice-price, 6)
-			s.OrderIdSell = ""
-			s.InMarketWait = 0
-			s.InMarketPriceWait = 0
-
-			if s.InMarket <= 0 {
-				s.Iteration++
-
-				s.Profit = smp.Round(s.Profit-s.InMarketPrice, 6)
-				s.InMarketPrice = 0
-				s.IsBought = false
-			}
-
-			if cnt > s.Volume {
-				s.StopLostTimes = -1
-			}
-		} else {
-			s.InMarketWait = -cnt
-			s.InMarketPriceWait = -price
+		s.applySellOrder(status, prices, false, &meta)
+		if s.InMarket <= 0 {
+			// all lots were sold before cancel
+			return meta, nil
 		}
+		s.State = SwingHolding
 	}
 
-	computeVolume = s.ComputeVolume()
+	req := slb.AllowRequestCount(s.InstrumentId, s.Ticker)
+	if s.InMarket < req {
+		req = s.InMarket
+	}
+	err := slb.Sell(p, s.InstrumentId, s.Ticker, req, s.LevelPriceUp)
+	if err != nil {
+		return meta, smp.GenerateErrorE(500000516, err)
+	}
 
-	if s.Volume == 0 {
-		return meta, nil
+	meta.HasChanges = true
+	meta.OpDescr = append(meta.OpDescr, "Request sale to StopLostBank")
+	// sale of request increases volume of next buy (as stop loss)
+	s.StopLostTimes++
+	s.InMarketPrice = smp.Round(s.InMarketPrice-float64(req)*ob.SellPrice(), 6)
+	s.InMarket -= req
+	if s.InMarket <= 0 {
+		stopLostTimes := s.StopLostTimes
+		s.finishIteration(false, &meta)
+		s.StopLostTimes = stopLostTimes
 	}
+	return meta, nil
+}
+
+// stepStopLoss - cancels active orders and sells all lots to StopLostBank or by market
+func (s *WingedSwing) stepStopLoss(p smp.StepParams, ob *smp.OrderBook, meta smp.MetaForStep) (smp.MetaForStep, *mft.Error) {
+	meta.IsStopLoss = true
 
-	if !s.IsOnline {
+	if s.State == SwingStopLoss && s.OrderIdSell != "" {
+		// wait sell by market
 		return meta, nil
 	}
 
-	if ob.TradeStatus != smp.NormalTrading {
-		return meta, nil
+	meta.HasChanges = true
+	if s.State != SwingStopLoss {
+		meta.OpDescr = append(meta.OpDescr, "Stop loss")
 	}
+	s.State = SwingStopLoss
 
-	if ob.BuyPrice() < s.LevelPriceStopLoss && s.OrderIdSell != "" {
-		_, err := p.CancelSellOrder(s.InstrumentId, s.Ticker, s.OrderIdSell,
-			&smp.MetaForOperations{
-				NameOfStrategy: s.Name,
-			},
-		)
+	if s.OrderIdBuy != "" {
+		_, err := p.CancelBuyOrder(s.InstrumentId, s.Ticker, s.OrderIdBuy, s.operationMeta(true))
 		if err != nil {
-			return meta, smp.GenerateErrorE(500000507, err)
+			return meta, smp.GenerateErrorE(500000517, err)
 		}
+		status, prices, err := p.StatusBuyOrder(s.InstrumentId, s.Ticker, s.OrderIdBuy, s.operationMeta(true))
+		if err != nil {
+			return meta, smp.GenerateErrorE(500000502, err)
+		}
+		if status == smp.Wait {
+			// cancel is not finished
+			return meta, nil
+		}
+		s.applyBuyOrder(status, prices, &meta)
 	}
 
-	if !s.IsBought &&
-		ob.SellPrice() <= s.LevelPriceUp && ob.BuyPrice() >= s.LevelPriceDown &&
-		s.StopLostBank.AllowCount(s.InstrumentId, s.Ticker) > 0 &&
-		computeVolume-s.InMarket > 0 {
-
-		success, err := s.StopLostBank.Buy(p, s.InstrumentId, s.Ticker, computeVolume-s.InMarket, s.LevelPriceDown)
+	if s.OrderIdSell != "" {
+		_, err := p.CancelSellOrder(s.InstrumentId, s.Ticker, s.OrderIdSell, s.operationMeta(true))
 		if err != nil {
-			return meta, smp.GenerateErrorE(500000511, err)
+			return meta, smp.GenerateErrorE(500000507, err)
 		}
-		s.InMarket += success
-		s.InMarketPrice = smp.Round(s.InMarketPrice+float64(success)*s.LevelPriceDown, 6)
-
-		if s.OrderIdBuy != "" {
-			_, err = p.CancelBuyOrder(s.InstrumentId, s.Ticker, s.OrderIdBuy,
-				&smp.MetaForOperations{
-					NameOfStrategy: s.Name,
-					IsStopLoss:     true,
-				})
-			if err != nil {
-				return meta, smp.GenerateErrorE(500000512, err)
-			}
+		status, prices, err := p.StatusSellOrder(s.InstrumentId, s.Ticker, s.OrderIdSell, s.operationMeta(true))
+		if err != nil {
+			return meta, smp.GenerateErrorE(500000503, err)
+		}
+		if status == smp.Wait {
+			// cancel is not finished
+			return meta, nil
+		}
+		// canceled order is profit sale: lots sold by it are not stop loss
+		s.applySellOrder(status, prices, false, &meta)
+		if s.State != SwingStopLoss {
+			// all lots were sold before cancel
+			return meta, nil
 		}
 	}
 
-	if s.OrderIdBuy != "" {
-		return meta, nil
-	}
-	if s.OrderIdSell != "" {
+	if s.InMarket <= 0 {
+		s.State = SwingWaiting
+		s.IsBought = false
+		s.InMarketPrice = 0
 		return meta, nil
 	}
 
-	if len(ob.Bids) < 1 || len(ob.Asks) < 1 {
+	if slb := s.bank(); slb != nil {
+		err := slb.Sell(p, s.InstrumentId, s.Ticker, s.InMarket, s.LevelPriceUp)
+		if err != nil {
+			return meta, smp.GenerateErrorE(500000509, err)
+		}
+		meta.OpDescr = append(meta.OpDescr, "Stop loss sale to StopLostBank")
+		s.InMarketPrice = smp.Round(s.InMarketPrice-float64(s.InMarket)*ob.SellPrice(), 6)
+		s.InMarket = 0
+		s.finishIteration(true, &meta)
 		return meta, nil
 	}
 
-	if s.IsBought {
-		if s.InMarket > 0 {
-			if ob.BuyPrice() <= s.LevelPriceStopLoss {
-				if s.StopLostBank != nil {
-					err = s.StopLostBank.Sell(p, s.InstrumentId, s.Ticker, s.InMarket, s.LevelPriceUp)
-					if err != nil {
-						return meta, smp.GenerateErrorE(500000509, err)
-					}
-					meta.IsStopLoss = true
-					s.StopLostTimes = s.StopLostTimes + 1
-				} else {
-					s.OrderIdSell, err = p.SellByMarket(s.InstrumentId, s.Ticker, s.InMarket,
-						&smp.MetaForOperations{
-							NameOfStrategy: s.Name,
-							IsStopLoss:     true,
-						},
-					)
-					if err != nil {
-						s.OrderIdSell = ""
-						meta.IsStopLoss = true
-						return meta, smp.GenerateErrorE(500000508, err)
-					}
-					s.StopLostTimes = s.StopLostTimes + 1
-				}
-			} else {
-				if ob.SellPrice() <= s.LevelPriceOnTheMarketUp {
-					s.OrderIdSell, err = p.SellByPrice(s.InstrumentId, s.Ticker, s.InMarket, s.LevelPriceUp,
-						&smp.MetaForOperations{
-							NameOfStrategy: s.Name,
-						},
-					)
-					if err != nil {
-						s.OrderIdSell = ""
-						return meta, smp.GenerateErrorE(500000504, err)
-					}
-				}
-			}
-		} else {
-			return meta, smp.GenerateError(500000505, s.InMarket)
-		}
-	} else {
-		if computeVolume-s.InMarket > 0 {
-			if ob.BuyPrice() >= s.LevelPriceOnTheMarketDown && ob.SellPrice() <= s.LevelPriceOnTheMarketUp {
-				if s.StopLostBank != nil {
-					success, err := s.StopLostBank.Buy(p, s.InstrumentId, s.Ticker, computeVolume-s.InMarket, s.LevelPriceDown)
-					if err != nil {
-						return meta, smp.GenerateErrorE(500000510, err)
-					}
-					s.InMarket += success
-					s.InMarketPrice = smp.Round(s.InMarketPrice+float64(success)*s.LevelPriceDown, 6)
-				}
-
-				if ob.BuyPrice() < s.LevelPriceOnTheMarketDownByMarket {
-					s.OrderIdBuy, err = p.BuyByMarket(s.InstrumentId, s.Ticker, computeVolume-s.InMarket,
-						&smp.MetaForOperations{
-							NameOfStrategy: s.Name,
-						},
-					)
-				} else {
-					s.OrderIdBuy, err = p.BuyByPrice(s.InstrumentId, s.Ticker, computeVolume-s.InMarket, s.LevelPriceDown,
-						&smp.MetaForOperations{
-							NameOfStrategy: s.Name,
-						},
-					)
-				}
-				if err != nil {
-					s.OrderIdBuy = ""
-					return meta, smp.GenerateErrorE(500000501, err)
-				}
-			}
-		} else {
-			return meta, smp.GenerateError(500000506, s.InMarket, computeVolume)
-		}
+	var err *mft.Error
+	s.OrderIdSell, err = p.SellByMarket(s.InstrumentId, s.Ticker, s.InMarket, s.operationMeta(true))
+	if err != nil {
+		s.OrderIdSell = ""
+		return meta, smp.GenerateErrorE(500000508, err)
 	}
+	meta.OpDescr = append(meta.OpDescr, "Stop loss sale by market")
+
 	return meta, nil
 }
@@ -52,9 +52,7 @@ func (s *WingedSwingGroup) String() string {
 }
 
 func (s *WingedSwingGroup) Status() smp.StartegyStatus {
-	return smp.StartegyStatus{
-		IsOnline: s.IsOnline,
-	}
+	return childrenStatus(s.IsOnline, s.children())
 }
 func (s *WingedSwingGroup) Json() string {
 	b, err := json.MarshalIndent(s, "", "  ")
@@ -64,294 +62,123 @@ func (s *WingedSwingGroup) Json() string {
 
 	return string(b)
 }
+
+// commands - commands of strategy
+func (s *WingedSwingGroup) commands() smp.CommandDefs {
+	return lifecycleCommands(showCommand("Отобразить", s.String), childrenLifecycleCommands(s.Type(), &s.IsOnline, s.children), smp.CommandDefs{
+		{Command: SetLevel, Description: "Установить уровень начала работы стратегии (с этого уровня происходит распределение стратегии)",
+			Example: "set_level 345.67",
+			Params:  []smp.Param{smp.FloatParam("", "уровень", &s.LevelPrice)}},
+
+		{Command: SetPriceUp, Description: "Установить шаг продажи", Example: "set_price_up 12.25",
+			Params: []smp.Param{smp.FloatParam("", "шаг цены", &s.PriceUp)}},
+		{Command: SetPriceDown, Description: "Установить шаг покупки", Example: "set_price_down -0.80",
+			Params: []smp.Param{smp.FloatParam("", "шаг цены", &s.PriceDown)}},
+
+		{Command: SetPriceOnTheMarketUp, Description: "Установить шаг входа в рынок", Example: "set_price_on_the_market_up 60.20",
+			Params: []smp.Param{smp.FloatParam("", "шаг цены", &s.PriceOnTheMarketUp)}},
+		{Command: SetPriceOnTheMarketDown, Description: "Установить шаг входа в рынок", Example: "set_price_on_the_market_down -50.80",
+			Params: []smp.Param{smp.FloatParam("", "шаг цены", &s.PriceOnTheMarketDown)}},
+		{Command: SetPriceOnTheMarketDownByMarket, Description: "Установить шаг входа в рынок",
+			Example: "set_price_on_the_market_down_by_market -10.80",
+			Params:  []smp.Param{smp.FloatParam("", "шаг цены", &s.PriceOnTheMarketDownByMarket)}},
+
+		{Command: SetPriceBetween, Description: "Установить шаг между стратегиями", Example: "set_price_between 10.2",
+			Params: []smp.Param{smp.FloatParam("", "уровень", &s.PriceBetween)}},
+
+		{Command: SetVolume, Description: "Установить объём", Example: "set_vol 25",
+			Params: []smp.Param{smp.IntParam("", "объём (кол-во лотов)", &s.Volume).WithMin(0)}},
+
+		{Command: Render, Description: "Сгенерировать внутренние стратегии", Example: "render f=-5 t=10",
+			Params: []smp.Param{smp.IntParam("f", "от шагов", nil), smp.IntParam("t", "до шагов", nil)},
+			Do:     s.render},
+
+		{Command: SetInMarket, Description: "Установить кол-во акций купленных на рынке, по максимальному объёму ",
+			Example: "set_in_market f=-4 t=8",
+			Params:  []smp.Param{smp.IntParam("f", "от шагов", nil), smp.IntParam("t", "до шагов", nil)},
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				return res, s.setInMarket(v.Int("f"), v.Int("t"), true)
+			}},
+		{Command: SetOutOfMarket, Description: "Установить кол-во акций купленных на рынке, в 0 ",
+			Example: "set_out_of_market f=-4 t=8",
+			Params:  []smp.Param{smp.IntParam("f", "от шагов", nil), smp.IntParam("t", "до шагов", nil)},
+			Do: func(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+				return res, s.setInMarket(v.Int("f"), v.Int("t"), false)
+			}},
+	})
+}
+
 func (s *WingedSwingGroup) Command(cmd smp.Command, params map[string]string) (res smp.CommandResult, ok bool, err *mft.Error) {
 	s.mx.Lock()
 	defer s.mx.Unlock()
-	if cmd == smp.ShowCommand {
-		res.Message = s.String()
-		return res, true, nil
-	}
-
-	if cmd == smp.StartCommand {
-		s.IsOnline = true
-		for i := range s.Swings {
-			s.Swings[i].IsOnline = true
-		}
-		return res, true, nil
-	}
-
-	if cmd == smp.StopCommand {
-		s.IsOnline = false
-		for i := range s.Swings {
-			s.Swings[i].IsOnline = false
-		}
-		return res, true, nil
-	}
-
-	if cmd == SetLevel {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseFloat(fS, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000611, er0, cmd, fS)
-			}
-			s.LevelPrice = f
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000610, cmd)
-		}
-	}
+	return s.commands().Do(s.Type(), cmd, params)
+}
 
-	if cmd == SetPriceUp {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseFloat(fS, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000613, er0, cmd, fS)
-			}
-			s.PriceUp = f
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000612, cmd)
-		}
-	}
+func (s *WingedSwingGroup) AllowCommands(locale smp.Locale) map[smp.Command]smp.CommandInfo {
+	return s.commands().AllowCommands(s.Type(), locale)
+}
 
-	if cmd == SetPriceDown {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseFloat(fS, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000615, er0, cmd, fS)
-			}
-			s.PriceDown = f
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000614, cmd)
-		}
+// children - swings of group
+func (s *WingedSwingGroup) children() []smp.Strategy {
+	children := make([]smp.Strategy, 0, len(s.Swings))
+	for i := range s.Swings {
+		children = append(children, &s.Swings[i])
 	}
+	return children
+}
 
-	if cmd == SetPriceOnTheMarketDownByMarket {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseFloat(fS, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000617, er0, cmd, fS)
-			}
-			s.PriceOnTheMarketDownByMarket = f
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000616, cmd)
-		}
-	}
+// render - generates swings from f to t steps of PriceBetween from LevelPrice
+func (s *WingedSwingGroup) render(v smp.ParamValues) (res smp.CommandResult, err *mft.Error) {
+	for i := v.Int("f"); i <= v.Int("t"); i++ {
+		level := s.LevelPrice + float64(i)*s.PriceBetween
+		s.Swings = append(s.Swings, WingedSwing{
+			Name:         s.Name + "[" + strconv.Itoa(i) + "]",
+			InstrumentId: s.InstrumentId,
+			Ticker:       s.Ticker,
+			Volume:       s.Volume,
 
-	if cmd == SetPriceOnTheMarketDown {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseFloat(fS, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000661, er0, cmd, fS)
-			}
-			s.PriceOnTheMarketDown = f
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000660, cmd)
-		}
-	}
+			LevelPriceUp:   smp.Round(level+s.PriceUp, 6),
+			LevelPriceDown: smp.Round(level+s.PriceDown, 6),
 
-	if cmd == SetPriceOnTheMarketUp {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseFloat(fS, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000663, er0, cmd, fS)
-			}
-			s.PriceOnTheMarketUp = f
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000662, cmd)
-		}
-	}
+			LevelPriceOnTheMarketUp:           smp.Round(level+s.PriceOnTheMarketUp, 6),
+			LevelPriceOnTheMarketDown:         smp.Round(level+s.PriceOnTheMarketDown, 6),
+			LevelPriceOnTheMarketDownByMarket: smp.Round(level+s.PriceOnTheMarketDownByMarket, 6),
 
-	if cmd == SetPriceBetween {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseFloat(fS, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000619, er0, cmd, fS)
-			}
-			s.PriceBetween = f
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000618, cmd)
-		}
-	}
+			IsOnline: s.IsOnline,
 
-	if cmd == SetVolume {
-		fS, ok := params[""]
-		if ok {
-			f, er0 := strconv.ParseInt(fS, 10, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000621, er0, cmd, fS)
-			}
-			s.Volume = int(f)
-			return res, true, nil
-		} else {
-			return res, false, smp.GenerateError(500000620, cmd)
-		}
+			Labels: map[string]string{"i": strconv.Itoa(i)},
+		})
 	}
 
-	if cmd == Render {
-		fS, ok := params["f"]
-		if !ok {
-			return res, false, smp.GenerateError(500000640, cmd, "f")
-		}
-		f, er0 := strconv.ParseInt(fS, 10, 64)
-		if er0 != nil {
-			return res, false, smp.GenerateErrorE(500000641, er0, cmd, fS, "f")
-		}
-		tS, ok := params["t"]
-		if !ok {
-			return res, false, smp.GenerateError(500000642, cmd, "t")
-		}
-		t, er0 := strconv.ParseInt(tS, 10, 64)
-		if er0 != nil {
-			return res, false, smp.GenerateErrorE(500000643, er0, cmd, tS, "t")
-		}
-
-		for i := f; i <= t; i++ {
-			level := s.LevelPrice + float64(i)*s.PriceBetween
-			s.Swings = append(s.Swings, WingedSwing{
-				Name:         s.Name + "[" + strconv.Itoa(int(i)) + "]",
-				InstrumentId: s.InstrumentId,
-				Ticker:       s.Ticker,
-				Volume:       s.Volume,
-
-				LevelPriceUp:   smp.Round(level+s.PriceUp, 6),
-				LevelPriceDown: smp.Round(level+s.PriceDown, 6),
-
-				LevelPriceOnTheMarketUp:           smp.Round(level+s.PriceOnTheMarketUp, 6),
-				LevelPriceOnTheMarketDown:         smp.Round(level+s.PriceOnTheMarketDown, 6),
-				LevelPriceOnTheMarketDownByMarket: smp.Round(level+s.PriceOnTheMarketDownByMarket, 6),
-
-				IsOnline: s.IsOnline,
-
-				Labels: map[string]string{"i": strconv.Itoa(int(i))},
-			})
-		}
-
-		return res, true, nil
-	}
+	return res, nil
+}
 
-	if cmd == SetInMarket {
-		fS, ok := params["f"]
-		if !ok {
-			return res, false, smp.GenerateError(500000644, cmd, "f")
-		}
-		f, er0 := strconv.ParseInt(fS, 10, 64)
-		if er0 != nil {
-			return res, false, smp.GenerateErrorE(500000645, er0, cmd, fS, "f")
-		}
-		tS, ok := params["t"]
+// setInMarket - sets InMarket of swings from f to t steps to Volume (inMarket) or to 0
+func (s *WingedSwingGroup) setInMarket(f int, t int, inMarket bool) (err *mft.Error) {
+	for i := range s.Swings {
+		iS, ok := s.Swings[i].Labels["i"]
 		if !ok {
-			return res, false, smp.GenerateError(500000646, cmd, "t")
+			return smp.GenerateError(500000650, s.Swings[i].Name)
 		}
-		t, er0 := strconv.ParseInt(tS, 10, 64)
+		iVal, er0 := strconv.Atoi(iS)
 		if er0 != nil {
-			return res, false, smp.GenerateErrorE(500000647, er0, cmd, tS, "t")
+			return smp.GenerateErrorE(500000651, er0, s.Swings[i].Name, iS)
 		}
 
-		for i := range s.Swings {
-			iS, ok := s.Swings[i].Labels["i"]
-			if !ok {
-				return res, false, smp.GenerateError(500000650, cmd)
-			}
-			iVal, er0 := strconv.ParseInt(iS, 10, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000651, er0, cmd, tS)
-			}
-
-			if f <= iVal && t >= iVal {
+		if f <= iVal && t >= iVal {
+			if inMarket {
 				s.Swings[i].InMarket = s.Swings[i].Volume
-			}
-		}
-
-		return res, true, nil
-	}
-
-	if cmd == SetOutOfMarket {
-		fS, ok := params["f"]
-		if !ok {
-			return res, false, smp.GenerateError(500000644, cmd, "f")
-		}
-		f, er0 := strconv.ParseInt(fS, 10, 64)
-		if er0 != nil {
-			return res, false, smp.GenerateErrorE(500000645, er0, cmd, fS, "f")
-		}
-		tS, ok := params["t"]
-		if !ok {
-			return res, false, smp.GenerateError(500000646, cmd, "t")
-		}
-		t, er0 := strconv.ParseInt(tS, 10, 64)
-		if er0 != nil {
-			return res, false, smp.GenerateErrorE(500000647, er0, cmd, tS, "t")
-		}
-
-		for i := range s.Swings {
-			iS, ok := s.Swings[i].Labels["i"]
-			if !ok {
-				return res, false, smp.GenerateError(500000650, cmd)
-			}
-			iVal, er0 := strconv.ParseInt(iS, 10, 64)
-			if er0 != nil {
-				return res, false, smp.GenerateErrorE(500000651, er0, cmd, tS)
-			}
-
-			if f <= iVal && t >= iVal {
+			} else {
 				s.Swings[i].InMarket = 0
 			}
 		}
-
-		return res, true, nil
 	}
 
-	return res, false, smp.GenerateError(500000600, cmd)
-}
-
-func (s *WingedSwingGroup) AllowCommands() map[smp.Command]smp.CommandInfo {
-	return map[smp.Command]smp.CommandInfo{
-		smp.ShowCommand:  {0, "Отобразить", "", ""},
-		smp.StartCommand: {1, "Старт", "", ""},
-		smp.StopCommand:  {2, "Стоп", "", ""},
-		SetLevel:         {3, "Установить уровень начала работы стратегии (с этого уровня происходит распределение стратегии)", "уровень", "set_level 345.67"},
-
-		SetPriceUp:   {4, "Установить шаг продажи", "шаг цены", "set_price_up 12.25"},
-		SetPriceDown: {5, "Установить шаг покупки", "шаг цены", "set_price_down -0.80"},
-
-		SetPriceOnTheMarketUp: {6, "Установить шаг входа в рынок", "шаг цены",
-			"set_price_on_the_market_up 60.20"},
-		SetPriceOnTheMarketDown: {7, "Установить шаг входа в рынок", "шаг цены",
-			"set_price_on_the_market_down -50.80"},
-		SetPriceOnTheMarketDownByMarket: {8, "Установить шаг входа в рынок", "шаг цены",
-			"set_price_on_the_market_down_by_market -10.80"},
-
-		SetPriceBetween: {9, "Установить шаг между стратегиями", "уровень", "set_price_between 10.2"},
-
-		SetVolume: {10, "Установить объём", "объём (кол-во лотов)", "set_vol 25"},
-
-		Render: {11, "Сгенерировать внутренние стратегии",
-			"f=[от шагов] t=[до шагов]", "render f=-5 t=10"},
-
-		SetInMarket: {12, "Установить кол-во акций купленных на рынке, по максимальному объёму ",
-			"f=[от шагов] t=[до шагов]) ",
-			"set_in_market f=-4 t=8"},
-		SetOutOfMarket: {13, "Установить кол-во акций купленных на рынке, в 0 ",
-			"f=[от шагов] t=[до шагов]",
-			"set_out_of_market f=-4 t=8"},
-	}
+	return nil
 }
 
-func (s *WingedSwingGroup) Description() string {
-	return "`winged_swing_group`" + ` - стратегия, группы качель`
+func (s *WingedSwingGroup) Description(locale smp.Locale) string {
+	return smp.GlobalMessageCatalog.Description(locale, s.Type(), "`winged_swing_group`"+` - стратегия, группы качель`)
 }
 
 func (s *WingedSwingGroup) Step(p smp.StepParams) (meta smp.MetaForStep, err *mft.Error) {
@@ -360,14 +187,13 @@ func (s *WingedSwingGroup) Step(p smp.StepParams) (meta smp.MetaForStep, err *mf
 	meta.Name = s.Name
 
 	for i := range s.Swings {
-		s.Swings[i].StopLostBank = s.StopLostBank
+		s.Swings[i].UseStopLostBank(s.StopLostBank)
 		mt, er := s.Swings[i].Step(p)
 		if mt.HasChanges {
 			meta.HasChanges = true
 		}
 		meta.SubMeta = append(meta.SubMeta, mt)
-		err.AppendList(er)
-		s.Swings[i].StopLostBank = nil
+		err = err.AppendList(er)
 	}
 
 	if err != nil {
@@ -0,0 +1,518 @@
+package strategies
+
+import (
+	"testing"
+	"time"
+
+	"github.com/myfantasy/mft"
+	smp "github.com/myfantasy/stock_market_primitives"
+	"github.com/myfantasy/stock_market_primitives/market"
+)
+
+// testCandles - 1 minute candles by [open, high, low, close]
+func testCandles(ohlc ...[4]float64) smp.Candles {
+	tmStart := time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC)
+	cs := make(smp.Candles, 0, len(ohlc))
+	for i, v := range ohlc {
+		tm := tmStart.Add(time.Duration(i) * time.Minute)
+		cs = append(cs, smp.Candle{
+			Ticker: "TTTT",
+			Start:  tm,
+			Date:   tm.Add(time.Minute),
+			Open:   v[0],
+			High:   v[1],
+			Low:    v[2],
+			Close:  v[3],
+			Vol:    100,
+		})
+	}
+	return cs
+}
+
+func testSwing(volume int) *WingedSwing {
+	return &WingedSwing{
+		Name:                      "test",
+		Ticker:                    "TTTT",
+		Volume:                    volume,
+		LevelPriceDown:            100,
+		LevelPriceUp:              105,
+		LevelPriceOnTheMarketDown: 90,
+		LevelPriceOnTheMarketUp:   110,
+		LevelPriceStopLoss:        85,
+		IsOnline:                  true,
+	}
+}
+
+// stepSwing - next candle and step of swing
+func stepSwing(t *testing.T, p interface {
+	smp.StepParams
+	DoStep() bool
+}, s *WingedSwing) {
+	t.Helper()
+	if !p.DoStep() {
+		t.Fatal("no more candles")
+	}
+	_, err := s.Step(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkSwing(t *testing.T, s *WingedSwing, state WingedSwingState, inMarket int) {
+	t.Helper()
+	if s.State != state {
+		t.Fatalf("state: %v expected, got %v", state, s.State)
+	}
+	if s.InMarket != inMarket {
+		t.Fatalf("in market: %v expected, got %v", inMarket, s.InMarket)
+	}
+}
+
+func TestWingedSwingCycle(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{102, 102, 101, 101},
+			[4]float64{120, 121, 119, 120},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{100, 100, 99, 99},
+			[4]float64{103, 104, 103, 104},
+			[4]float64{105, 106, 105, 106},
+			[4]float64{106, 106, 105, 105},
+		),
+	}
+	s := testSwing(2)
+
+	// price is out of working range
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingWaiting, 0)
+	if s.OrderIdBuy != "" {
+		t.Fatal("buy order is placed out of working range")
+	}
+
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingBuying, 0)
+	if s.OrderIdBuy == "" {
+		t.Fatal("buy order is not placed")
+	}
+
+	// buy is executed and sell order is placed at once
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingSelling, 2)
+	if !s.IsBought || s.InMarketPrice != 200 || s.OrderIdSell == "" {
+		t.Fatalf("wrong state after buy: %v", s.Json())
+	}
+
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingSelling, 2)
+
+	stepSwing(t, p, s)
+	if s.Iteration != 1 || s.Profit != 10 || s.IsBought || s.InMarketPrice != 0 {
+		t.Fatalf("wrong state after sell: %v", s.Json())
+	}
+	// the next iteration is started
+	checkSwing(t, s, SwingBuying, 0)
+
+	if len(p.Actions) != 2 {
+		t.Fatalf("2 actions expected, got %v", len(p.Actions))
+	}
+}
+
+func TestWingedSwingByMarket(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{95, 95, 94, 94},
+			[4]float64{95, 95, 94, 94},
+			[4]float64{96, 96, 95, 95},
+			[4]float64{96, 96, 95, 95},
+		),
+	}
+	s := testSwing(3)
+	s.LevelPriceOnTheMarketDownByMarket = 97
+
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingBuying, 0)
+
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingSelling, 3)
+	if s.InMarketPrice != 3*96 {
+		t.Fatalf("price of market buy: %v expected, got %v", 3*96, s.InMarketPrice)
+	}
+}
+
+func TestWingedSwingStopLoss(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{100, 100, 99, 99},
+			[4]float64{86, 86, 84, 84},
+			[4]float64{84, 84, 83, 83},
+			[4]float64{84, 84, 83, 83},
+			[4]float64{84, 84, 83, 83},
+		),
+	}
+	s := testSwing(2)
+
+	stepSwing(t, p, s)
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingSelling, 2)
+
+	// sell order is canceled, lots are sold by market
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingStopLoss, 2)
+	if s.OrderIdSell == "" {
+		t.Fatal("sell by market is not placed")
+	}
+
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingWaiting, 0)
+	if s.StopLostTimes != 1 || s.Iteration != 1 || s.Profit != 2*83-200 {
+		t.Fatalf("wrong state after stop loss: %v", s.Json())
+	}
+	if s.ComputeVolume() != 3 {
+		t.Fatalf("volume after stop loss: 3 expected, got %v", s.ComputeVolume())
+	}
+
+	// price is under stop loss: nothing to do
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingWaiting, 0)
+	if s.OrderIdBuy != "" {
+		t.Fatal("buy order is placed under stop loss")
+	}
+}
+
+func TestWingedSwingRequestSale(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{100, 100, 99, 99},
+			[4]float64{101, 101, 100, 101},
+			[4]float64{101, 101, 100, 101},
+			[4]float64{101, 101, 100, 101},
+		),
+	}
+	s := testSwing(3)
+	s.StopLostBank = &StopLostBank{}
+	if err := s.StopLostBank.RequestToBuyDO(p, "", "TTTT", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// bought lots are sold to StopLostBank by its request
+	stepSwing(t, p, s)
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingHolding, 2)
+	if s.StopLostTimes != 1 || s.ComputeVolume() != 4 {
+		t.Fatalf("sale of request should increase volume: %v", s.Json())
+	}
+	if s.StopLostBank.AllowCount("", "TTTT") != 1 || s.StopLostBank.AllowRequestCount("", "TTTT") != 0 {
+		t.Fatalf("1 lot in StopLostBank expected: %+v", s.StopLostBank.Items)
+	}
+
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingSelling, 2)
+
+	// sell order is canceled for request
+	if err := s.StopLostBank.RequestToBuyDO(p, "", "TTTT", 5, 0); err != nil {
+		t.Fatal(err)
+	}
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingWaiting, 0)
+	if s.OrderIdSell != "" || s.Iteration != 1 || s.StopLostBank.AllowCount("", "TTTT") != 3 {
+		t.Fatalf("lots are not sold to StopLostBank: %v", s.Json())
+	}
+	if s.StopLostTimes != 2 || s.ComputeVolume() != 5 {
+		t.Fatalf("volume of next buy should be increased by request sales: %v", s.Json())
+	}
+	if s.StopLostBank.AllowRequestCount("", "TTTT") != 3 {
+		t.Fatalf("request of 3 lots expected, got %v", s.StopLostBank.AllowRequestCount("", "TTTT"))
+	}
+}
+
+// fillOnCancelParams - profit sell order is executed by 105 when its cancel is sent
+type fillOnCancelParams struct {
+	*market.StepParamsDummy
+	canceled bool
+}
+
+func (p *fillOnCancelParams) CancelSellOrder(instrumentId string, ticker string, orderId string,
+	meta *smp.MetaForOperations) (ok bool, err *mft.Error) {
+	p.canceled = true
+	return false, nil
+}
+
+func (p *fillOnCancelParams) StatusSellOrder(instrumentId string, ticker string, orderId string,
+	meta *smp.MetaForOperations) (status smp.StatusOrder, prices []smp.LotPrices, err *mft.Error) {
+	if !p.canceled {
+		return smp.Wait, nil, nil
+	}
+	return smp.Complete, []smp.LotPrices{{Count: 2, Price: 105}}, nil
+}
+
+func TestWingedSwingStopLossProfitSold(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{100, 100, 99, 99},
+			[4]float64{86, 86, 84, 84},
+			[4]float64{86, 86, 84, 84},
+		),
+	}
+	s := testSwing(2)
+
+	stepSwing(t, p, s)
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingSelling, 2)
+
+	// profit sell is executed before its cancel by stop loss
+	stepSwing(t, &fillOnCancelParams{StepParamsDummy: p}, s)
+	checkSwing(t, s, SwingWaiting, 0)
+	if s.StopLostTimes != 0 || s.Iteration != 1 || s.Profit != 2*105-200 || s.OrderIdSell != "" {
+		t.Fatalf("profit sale is counted as stop loss: %v", s.Json())
+	}
+}
+
+func TestWingedSwingStopLossBank(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{100, 100, 99, 99},
+			[4]float64{86, 86, 84, 84},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+		),
+	}
+	s := testSwing(2)
+	s.StopLostBank = &StopLostBank{}
+
+	stepSwing(t, p, s)
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingSelling, 2)
+
+	// lots are given to StopLostBank at once
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingWaiting, 0)
+	if s.StopLostTimes != 1 || s.Profit != 2*84-200 || s.OrderIdSell != "" {
+		t.Fatalf("wrong state after stop loss: %v", s.Json())
+	}
+	if s.StopLostBank.AllowCount("", "TTTT") != 2 {
+		t.Fatalf("2 lots in StopLostBank expected, got %v", s.StopLostBank.AllowCount("", "TTTT"))
+	}
+
+	// lots are taken from StopLostBank, the rest is bought
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingBuying, 2)
+	if s.StopLostBank.AllowCount("", "TTTT") != 0 || s.OrderIdBuy == "" {
+		t.Fatalf("wrong state after buy from StopLostBank: %v", s.Json())
+	}
+}
+
+func TestWingedSwingPartialFill(t *testing.T) {
+	p := &market.VirtualMarket{
+		Candles: testCandles(
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{101, 101, 99, 99},
+			[4]float64{101, 101, 99, 99},
+			[4]float64{104, 106, 104, 106},
+			[4]float64{104, 106, 104, 106},
+			[4]float64{104, 106, 104, 106},
+		),
+	}
+	s := testSwing(150)
+
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingBuying, 0)
+
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingBuying, 0)
+	if s.InMarketWait != 100 {
+		t.Fatalf("100 lots wait expected, got %v", s.InMarketWait)
+	}
+
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingSelling, 150)
+	if s.InMarketWait != 0 || s.InMarketPrice != 15000 {
+		t.Fatalf("wrong state after buy: %v", s.Json())
+	}
+
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingSelling, 150)
+	if s.InMarketWait != -100 {
+		t.Fatalf("100 lots sold expected, got %v", -s.InMarketWait)
+	}
+
+	stepSwing(t, p, s)
+	if s.Iteration != 1 || s.Profit != 750 {
+		t.Fatalf("wrong state after sell: %v", s.Json())
+	}
+}
+
+func TestWingedSwingGroupStep(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+		),
+	}
+	g := &WingedSwingGroup{
+		Swings: []WingedSwing{*testSwing(1), *testSwing(1)},
+	}
+	g.Swings[1].LevelPriceDown = 99
+	g.Swings[1].LevelPriceUp = 104
+
+	p.DoStep()
+	_, err := g.Step(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range g.Swings {
+		checkSwing(t, &g.Swings[i], SwingBuying, 0)
+	}
+}
+
+func TestWingedSwingStop(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+		),
+	}
+	s := testSwing(1)
+
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingBuying, 0)
+
+	if _, ok, err := s.Command(smp.StopCommand, nil); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	stepSwing(t, p, s)
+	if st := s.Status(); st.IsOnline || st.State != smp.LifecycleStopping || s.OrderIdBuy == "" {
+		t.Fatalf("stopping expected until cancel is confirmed: %v", s.Json())
+	}
+
+	stepSwing(t, p, s)
+	if st := s.Status(); st.State != smp.LifecycleStopped || s.OrderIdBuy != "" {
+		t.Fatalf("stopped expected: %v", s.Json())
+	}
+	if _, _, err := s.Command(smp.ResetCommand, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWingedSwingStopFlatten(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{100, 100, 99, 99},
+			[4]float64{101, 101, 100, 100},
+			[4]float64{101, 101, 100, 100},
+			[4]float64{101, 101, 100, 100},
+			[4]float64{101, 101, 100, 100},
+			[4]float64{101, 101, 100, 100},
+		),
+	}
+	s := testSwing(2)
+
+	stepSwing(t, p, s)
+	stepSwing(t, p, s)
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingSelling, 2)
+
+	if _, ok, err := s.Command(smp.StopCommand, map[string]string{"": "flatten"}); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	if _, _, err := s.Command(smp.ResetCommand, nil); err == nil || err.Code != 500003901 {
+		t.Fatalf("reset of stopping swing must fail, got %v", err)
+	}
+
+	for i := 0; i < 4 && s.Status().State == smp.LifecycleStopping; i++ {
+		stepSwing(t, p, s)
+	}
+	if st := s.Status(); st.State != smp.LifecycleStopped || s.InMarket != 0 || s.Iteration != 1 ||
+		s.OrderIdSell != "" {
+		t.Fatalf("position is not closed: %v", s.Json())
+	}
+}
+
+func TestWingedSwingPause(t *testing.T) {
+	p := &market.StepParamsDummy{
+		Candles: testCandles(
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+			[4]float64{102, 102, 101, 101},
+		),
+	}
+	s := testSwing(1)
+
+	stepSwing(t, p, s)
+	orderId := s.OrderIdBuy
+
+	if _, ok, err := s.Command(smp.PauseCommand, nil); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	stepSwing(t, p, s)
+	stepSwing(t, p, s)
+	if st := s.Status(); st.IsOnline || st.State != smp.LifecyclePaused || s.OrderIdBuy != "" {
+		t.Fatalf("paused swing without orders expected: %v", s.Json())
+	}
+
+	if _, ok, err := s.Command(smp.ResumeCommand, nil); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	stepSwing(t, p, s)
+	checkSwing(t, s, SwingBuying, 0)
+	if s.OrderIdBuy == "" || s.OrderIdBuy == orderId {
+		t.Fatalf("buy order is not placed after resume: %v", s.Json())
+	}
+}
+
+func TestWingedSwingResetPosition(t *testing.T) {
+	s := testSwing(1)
+	if _, _, err := s.Command(smp.ResetCommand, nil); err == nil || err.Code != 500003901 {
+		t.Fatalf("reset of running swing must fail, got %v", err)
+	}
+
+	s.InMarket = 1
+	if _, _, err := s.Command(smp.StopCommand, map[string]string{"": "keep"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Command(smp.ResetCommand, nil); err == nil || err.Code != 500003551 {
+		t.Fatalf("reset with position must fail, got %v", err)
+	}
+}
+
+func TestWingedSwingGroupLifecycle(t *testing.T) {
+	g := &WingedSwingGroup{
+		Swings: []WingedSwing{*testSwing(1), *testSwing(1)},
+	}
+
+	if _, ok, err := g.Command(smp.StopCommand, map[string]string{"": "keep"}); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	if st := g.Status(); st.IsOnline || st.State != smp.LifecycleStopped {
+		t.Fatalf("stopped group expected: %+v", st)
+	}
+
+	if _, _, err := g.Swings[0].Command(smp.StartCommand, nil); err != nil {
+		t.Fatal(err)
+	}
+	if st := g.Status(); st.State != smp.LifecycleRunning {
+		t.Fatalf("running group expected: %+v", st)
+	}
+
+	if _, _, err := g.Command(smp.ResetCommand, nil); err == nil || err.Code != 500003550 ||
+		len(err.InternalErrors) != 1 {
+		t.Fatalf("error of 1 child expected, got %v", err)
+	}
+}
@@ -64,6 +64,8 @@ type StepParams interface {
 
 type StartegyStatus struct {
 	IsOnline bool `json:"is_online"`
+	// State - state of lifecycle ("" - strategy without lifecycle)
+	State LifecycleState `json:"state,omitempty"`
 }
 
 type Command string
@@ -86,6 +88,8 @@ type CommandInfo struct {
 	Description       string
 	ParamsDescription string
 	Example           string
+	// Params - params of command (for commands declared by CommandDefs)
+	Params []Param
 }
 
 type CommandResult struct {
@@ -99,8 +103,10 @@ type Strategy interface {
 	Type() string
 	Json() string
 	Command(cmd Command, params map[string]string) (res CommandResult, ok bool, err *mft.Error)
-	AllowCommands() map[Command]CommandInfo
-	Description() string
+	// AllowCommands - commands with texts in locale
+	AllowCommands(locale Locale) map[Command]CommandInfo
+	// Description - description of strategy in locale
+	Description(locale Locale) string
 }
 
 func Round(price float64, point int) float64 {